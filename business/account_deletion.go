@@ -0,0 +1,43 @@
+package business
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DeletedUserID is the reserved user that takes over a deleted account's place in
+// finished games and chat history (seeded in createTables.sql). Opponents keep
+// their results and stats, but nothing links back to the deleted account.
+const DeletedUserID = "00000000-0000-0000-0000-0000000de1e7"
+
+// SetAccountDeletedHandler sets a callback run after an account is deleted, given the
+// public IDs of the unfinished games that were abandoned because of it
+func (s *UserService) SetAccountDeletedHandler(fn func(userID string, abandonedGames []string)) {
+	s.onAccountDeleted = fn
+}
+
+// DeleteAccount permanently removes a user after checking their password. Their
+// unfinished games are abandoned, their sessions end, and their messages are blanked.
+func (s *UserService) DeleteAccount(ctx context.Context, userID, password string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+
+	abandoned, err := s.userRepo.DeleteUser(ctx, userID, DeletedUserID)
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	s.users.forget(userID)
+
+	if s.onAccountDeleted != nil {
+		s.onAccountDeleted(userID, abandoned)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDeleteAccountNeedsThePasswordAndEndsEverything(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	repo.liveGames = []string{"game-1"}
+	var deletedUserID string
+	var abandoned []string
+	s.SetAccountDeletedHandler(func(userID string, games []string) {
+		deletedUserID, abandoned = userID, games
+	})
+	token, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUsersByIDs(ctx, []string{"alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteAccount(ctx, "alice", "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong password: err = %v, want ErrWrongPassword", err)
+	}
+	if _, ok := repo.users["alice"]; !ok || deletedUserID != "" {
+		t.Fatal("the account was deleted with the wrong password")
+	}
+
+	if err := s.DeleteAccount(ctx, "alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.placeholder != DeletedUserID {
+		t.Errorf("history handed to %q, want DeletedUserID", repo.placeholder)
+	}
+	if deletedUserID != "alice" || !slices.Equal(abandoned, []string{"game-1"}) {
+		t.Errorf("handler got %q with games %v, want alice with [game-1]", deletedUserID, abandoned)
+	}
+	if userID, err := s.ValidateSession(ctx, token); err == nil {
+		t.Errorf("session still belongs to %q", userID)
+	}
+	if users, err := s.GetUsersByIDs(ctx, []string{"alice"}); err != nil || users["alice"] != nil {
+		t.Errorf("alice is still cached: %v, %v", users["alice"], err)
+	}
+}
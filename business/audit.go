@@ -0,0 +1,55 @@
+package business
+
+import (
+	"context"
+	"golf-card-game/database"
+	"log"
+)
+
+// Audit event types
+const (
+	AuditRegister       = "register"
+	AuditLoginSuccess   = "login_success"
+	AuditLoginFailure   = "login_failure"
+	AuditLogout         = "logout"
+	AuditPasswordChange = "password_change"
+	AuditAccountDeleted = "account_deleted"
+	AuditAdminAction    = "admin_action"
+)
+
+// AuditLogger records security-relevant events to the audit log
+type AuditLogger struct {
+	auditRepo database.AuditRepository
+}
+
+func NewAuditLogger(auditRepo database.AuditRepository) *AuditLogger {
+	return &AuditLogger{auditRepo: auditRepo}
+}
+
+// Record appends an event to the audit log. userID may be empty when the user
+// is unknown (e.g. a failed login for a username that doesn't exist).
+// Failures are logged but never returned, so auditing can't break the calling flow.
+func (a *AuditLogger) Record(ctx context.Context, eventType, userID, ipAddress, userAgent string, metadata map[string]string) {
+	if a == nil || a.auditRepo == nil {
+		return
+	}
+
+	event := &database.AuditEvent{
+		EventType: eventType,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  metadata,
+	}
+	if userID != "" {
+		event.UserID = &userID
+	}
+
+	if err := a.auditRepo.AppendEvent(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", eventType, err)
+	}
+}
+
+// GetEvents returns recent audit events, optionally filtered by user and event type
+func (a *AuditLogger) GetEvents(ctx context.Context, userID, eventType string, limit int) ([]*database.AuditEvent, error) {
+	return a.auditRepo.GetEvents(ctx, userID, eventType, limit)
+}
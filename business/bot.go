@@ -0,0 +1,135 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"golf-card-game/database"
+)
+
+// BotUserID is the reserved user that plays single-player games (seeded in createTables.sql)
+const BotUserID = "00000000-0000-0000-0000-00000000b07a"
+
+// IsBot reports whether userID is the computer opponent
+func IsBot(userID string) bool {
+	return userID == BotUserID
+}
+
+// CreateBotGame creates a two-player game against the bot and starts it straight away
+func (s *GameService) CreateBotGame(ctx context.Context, userID string, rules GameRules) (*database.Game, error) {
+	game, err := s.CreateGame(ctx, userID, MinPlayers, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.gameRepo.AddPlayer(ctx, game.PublicID, BotUserID, 1); err != nil {
+		return nil, fmt.Errorf("failed to add bot to game: %w", err)
+	}
+
+	// Accepting fills the game, which starts it
+	if _, err := s.AcceptInvitation(ctx, game.PublicID, BotUserID); err != nil {
+		return nil, err
+	}
+
+	return s.resolveGame(ctx, game.PublicID)
+}
+
+// BotShouldAct reports whether the bot has a move to make in this state
+func BotShouldAct(state *FullGameState, botUserID string) bool {
+	action, _ := ComputeBotMove(state, botUserID)
+	return action != ""
+}
+
+// ComputeBotMove picks the bot's next action with a simple greedy strategy. It returns
+// an empty action when the bot has nothing to do. cardIndex is -1 for actions that
+// don't target a card.
+func ComputeBotMove(state *FullGameState, botUserID string) (action string, cardIndex int) {
+	playerIdx, err := findPlayerIndex(state, botUserID)
+	if err != nil {
+		return "", -1
+	}
+	player := &state.Players[playerIdx]
+	rules := state.Rules.Scoring
+
+	if state.Phase == PhaseInitialFlip {
+		return botInitialFlip(player)
+	}
+
+	if (state.Phase != PhaseMainGame && state.Phase != PhaseFinalRound) || state.CurrentTurnIdx != playerIdx {
+		return "", -1
+	}
+
+	worstIdx := worstFaceUpCard(player, rules)
+
+	if state.DrawnCard == nil {
+		// Take the discard only if it beats the worst card showing
+		canTakeDiscard := len(state.DiscardPile) > 0 &&
+			!(state.TurnsPlayed == 0 && state.Rules.ForbidFirstTurnDiscardDraw)
+		if canTakeDiscard && worstIdx != -1 {
+			top := state.DiscardPile[len(state.DiscardPile)-1]
+			if getCardValue(top, rules) < getCardValue(player.Hand[worstIdx], rules) {
+				return "draw_discard", -1
+			}
+		}
+		return "draw_deck", -1
+	}
+
+	drawnValue := getCardValue(*state.DrawnCard, rules)
+
+	// Complete a column pair if the drawn card matches a face-up partner
+	for i, faceUp := range player.FaceUp {
+		partner := (i + 3) % 6
+		if !faceUp && player.FaceUp[partner] && player.Hand[partner].Rank == state.DrawnCard.Rank {
+			return "swap_card", i
+		}
+	}
+
+	if worstIdx != -1 && drawnValue < getCardValue(player.Hand[worstIdx], rules) {
+		return "swap_card", worstIdx
+	}
+
+	faceDown := faceDownIndexes(player)
+	if len(faceDown) > 0 {
+		// Low cards are worth keeping even blind
+		if drawnValue <= 4 {
+			return "swap_card", faceDown[0]
+		}
+		return "discard_flip", faceDown[0]
+	}
+
+	// Everything is face-up, so the drawn card has to go somewhere
+	if worstIdx == -1 {
+		worstIdx = 0
+	}
+	return "swap_card", worstIdx
+}
+
+// botInitialFlip flips one card from each row
+func botInitialFlip(player *PlayerState) (string, int) {
+	if player.InitialFlips >= 2 {
+		return "", -1
+	}
+	if player.InitialFlips == 1 && (player.FaceUp[0] || player.FaceUp[1] || player.FaceUp[2]) {
+		return "initial_flip", 3
+	}
+	return "initial_flip", 0
+}
+
+// worstFaceUpCard returns the index of the highest-scoring face-up card that isn't
+// part of a matched column, or -1 if there is none
+func worstFaceUpCard(player *PlayerState, rules ScoringRules) int {
+	worst, worstValue := -1, 0
+	for i, faceUp := range player.FaceUp {
+		if !faceUp {
+			continue
+		}
+		partner := (i + 3) % 6
+		if player.FaceUp[partner] && player.Hand[partner].Rank == player.Hand[i].Rank {
+			continue
+		}
+		value := getCardValue(player.Hand[i], rules)
+		if worst == -1 || value > worstValue {
+			worst, worstValue = i, value
+		}
+	}
+	return worst
+}
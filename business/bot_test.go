@@ -0,0 +1,115 @@
+package business
+
+import (
+	"context"
+	"testing"
+)
+
+// botTestState is a main-game state where it is the bot's turn. The bot holds hand, with
+// the cards at faceUp showing, and the discard pile shows discardTop.
+func botTestState(hand [6]string, faceUp []int, discardTop string) *FullGameState {
+	bot := PlayerState{UserID: BotUserID, InitialFlips: 2}
+	for i, rank := range hand {
+		bot.Hand[i] = CardDef{Suit: "hearts", Rank: rank}
+	}
+	for _, i := range faceUp {
+		bot.FaceUp[i] = true
+	}
+	return &FullGameState{
+		Phase:       PhaseMainGame,
+		Players:     []PlayerState{{UserID: "alice", InitialFlips: 2}, bot},
+		DiscardPile: []CardDef{{Suit: "spades", Rank: discardTop}},
+		Deck:        []CardDef{{Suit: "clubs", Rank: "7"}},
+		TurnsPlayed: 4,
+
+		CurrentTurnIdx: 1,
+	}
+}
+
+func TestComputeBotMove(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     *FullGameState
+		drawn     string
+		action    string
+		cardIndex int
+	}{
+		{
+			name:   "takes a discard that beats its worst card",
+			state:  botTestState([6]string{"Q", "2", "3", "4", "5", "6"}, []int{0, 1}, "A"),
+			action: "draw_discard", cardIndex: -1,
+		},
+		{
+			name:   "draws from the deck when the discard is no better",
+			state:  botTestState([6]string{"3", "2", "3", "4", "5", "6"}, []int{0, 1}, "9"),
+			action: "draw_deck", cardIndex: -1,
+		},
+		{
+			name:   "swaps a low card into its worst slot",
+			state:  botTestState([6]string{"2", "Q", "3", "4", "5", "6"}, []int{0, 1}, "9"),
+			drawn:  "A",
+			action: "swap_card", cardIndex: 1,
+		},
+		{
+			name:   "completes a column pair",
+			state:  botTestState([6]string{"8", "2", "3", "4", "5", "6"}, []int{0, 1}, "9"),
+			drawn:  "8",
+			action: "swap_card", cardIndex: 3,
+		},
+		{
+			name:   "discards a high card and flips one instead",
+			state:  botTestState([6]string{"2", "A", "3", "4", "5", "6"}, []int{0, 1}, "9"),
+			drawn:  "Q",
+			action: "discard_flip", cardIndex: 2,
+		},
+		{
+			name:   "keeps its matched columns",
+			state:  botTestState([6]string{"Q", "2", "3", "Q", "5", "6"}, []int{0, 1, 2, 3, 4, 5}, "9"),
+			drawn:  "8",
+			action: "swap_card", cardIndex: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.drawn != "" {
+			tt.state.DrawnCard = &CardDef{Suit: "clubs", Rank: tt.drawn}
+		}
+		action, cardIndex := ComputeBotMove(tt.state, BotUserID)
+		if action != tt.action || cardIndex != tt.cardIndex {
+			t.Errorf("%s: got %s %d, want %s %d", tt.name, action, cardIndex, tt.action, tt.cardIndex)
+		}
+	}
+}
+
+func TestComputeBotMoveWaitsItsTurn(t *testing.T) {
+	state := botTestState([6]string{"Q", "2", "3", "4", "5", "6"}, []int{0, 1}, "A")
+	state.CurrentTurnIdx = 0
+	if action, _ := ComputeBotMove(state, BotUserID); action != "" {
+		t.Fatalf("bot chose %s on alice's turn", action)
+	}
+	if BotShouldAct(state, BotUserID) {
+		t.Fatal("BotShouldAct on alice's turn")
+	}
+}
+
+// Every move the bot picks is one the engine accepts, through to the end of the game
+func TestBotsPlayAGameToTheEnd(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	moveLog := NewMoveLog(&fakeMoveRepo{}, repo)
+
+	repo.addGame("game", "in_progress", GameRules{}, "alice", BotUserID)
+	if _, _, err := s.LoadOrInitState(ctx, "game"); err != nil {
+		t.Fatal(err)
+	}
+	playGame(t, s, moveLog, "game")
+
+	game, err := repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "finished" {
+		t.Fatalf("game status = %q", game.Status)
+	}
+}
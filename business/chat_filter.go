@@ -0,0 +1,95 @@
+package business
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChatFilter masks words from a blocklist in chat messages. Matching is
+// case-insensitive and whole-word only, so a blocked word inside a longer
+// word (e.g. a place name) is left alone. A nil *ChatFilter filters nothing.
+type ChatFilter struct {
+	words map[string]bool
+}
+
+// NewChatFilter creates a filter for the given words
+func NewChatFilter(words []string) *ChatFilter {
+	f := &ChatFilter{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			f.words[word] = true
+		}
+	}
+	return f
+}
+
+// LoadChatFilter reads a wordlist with one word per line. Blank lines and lines
+// starting with '#' are skipped.
+func LoadChatFilter(path string) (*ChatFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chat filter wordlist: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat filter wordlist: %w", err)
+	}
+
+	return NewChatFilter(words), nil
+}
+
+// Sanitize replaces every blocked word in text with asterisks of the same length
+func (f *ChatFilter) Sanitize(text string) string {
+	if f == nil || len(f.words) == 0 {
+		return text
+	}
+
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			b.WriteString(text[i : i+size])
+			i += size
+			continue
+		}
+
+		// Find the end of this word
+		end := i
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+
+		word := text[i:end]
+		if f.words[strings.ToLower(word)] {
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(word)))
+		} else {
+			b.WriteString(word)
+		}
+		i = end
+	}
+	return b.String()
+}
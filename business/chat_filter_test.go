@@ -0,0 +1,41 @@
+package business
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChatFilterMasksWholeWordsOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# blocked\nDarn\n\n  heck  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := LoadChatFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, text, want string
+	}{
+		{"masked", "darn it", "**** it"},
+		{"any case", "DARN, Heck!", "****, ****!"},
+		{"inside a longer word", "darnedest hecklers", "darnedest hecklers"},
+		{"comment lines are not words", "# blocked", "# blocked"},
+	} {
+		if got := filter.Sanitize(tc.text); got != tc.want {
+			t.Errorf("%s: Sanitize(%q) = %q, want %q", tc.name, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestDisabledChatFilterChangesNothing(t *testing.T) {
+	var disabled *ChatFilter
+	if got := disabled.Sanitize("darn it"); got != "darn it" {
+		t.Errorf("nil filter gave %q", got)
+	}
+	if got := NewChatFilter(nil).Sanitize("darn it"); got != "darn it" {
+		t.Errorf("empty filter gave %q", got)
+	}
+}
@@ -0,0 +1,66 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"time"
+)
+
+// emailVerificationTTL is how long an emailed verification link keeps working
+const emailVerificationTTL = 24 * time.Hour
+
+var (
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrInvalidVerifyToken   = errors.New("verification link is invalid or has expired")
+)
+
+// SetVerificationMailer sets how email verification tokens are delivered to users
+func (s *UserService) SetVerificationMailer(fn func(user *database.User, token string) error) {
+	s.sendVerification = fn
+}
+
+// SendEmailVerification emails the user a fresh verification token. Links from
+// earlier emails stop working.
+func (s *UserService) SendEmailVerification(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.CreateEmailVerificationToken(ctx, user.UserID, hashToken(token), time.Now().Add(emailVerificationTTL))
+	if err != nil {
+		return fmt.Errorf("failed to save verification token: %w", err)
+	}
+
+	if s.sendVerification == nil {
+		return errors.New("verification email is not configured")
+	}
+	if err := s.sendVerification(user, token); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail marks the owner of an emailed token as having a verified address.
+// Returns their user ID.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (string, error) {
+	userID, err := s.userRepo.VerifyEmailWithToken(ctx, hashToken(token))
+	if errors.Is(err, database.ErrInvalidVerifyToken) {
+		return "", ErrInvalidVerifyToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to verify email: %w", err)
+	}
+	s.users.forget(userID)
+	return userID, nil
+}
@@ -0,0 +1,844 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golf-card-game/database"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeGameRepo is an in-memory GameRepository holding just enough to exercise the
+// game service. Methods a test doesn't need panic through the nil embedded interface.
+type fakeGameRepo struct {
+	database.GameRepository
+
+	mu        sync.Mutex
+	txMu      sync.Mutex // held for a whole WithTx, so transactions don't interleave
+	nextID    int
+	games     map[string]*database.Game
+	players   map[string][]*database.GamePlayer
+	states    map[string]*fakeStateRow
+	rematches map[int]string // original game ID -> rematch public ID
+	lastMove  map[string]time.Time
+	joinLinks map[string]string // public ID -> current join token
+
+	// failAddPlayer makes AddPlayer fail for these user IDs
+	failAddPlayer map[string]error
+}
+
+type fakeStateRow struct {
+	state   []byte
+	initial []byte
+	version int
+}
+
+func newFakeGameRepo() *fakeGameRepo {
+	return &fakeGameRepo{
+		games:         make(map[string]*database.Game),
+		players:       make(map[string][]*database.GamePlayer),
+		states:        make(map[string]*fakeStateRow),
+		rematches:     make(map[int]string),
+		lastMove:      make(map[string]time.Time),
+		joinLinks:     make(map[string]string),
+		failAddPlayer: make(map[string]error),
+	}
+}
+
+// addGame creates a game with the given players, all active, the first one as creator
+func (r *fakeGameRepo) addGame(publicID, status string, rules GameRules, userIDs ...string) *database.Game {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rulesJSON, _ := json.Marshal(rules)
+	r.nextID++
+	game := &database.Game{
+		GameID:     r.nextID,
+		PublicID:   publicID,
+		CreatedBy:  userIDs[0],
+		Status:     status,
+		MaxPlayers: MaxPlayers,
+		Rules:      rulesJSON,
+	}
+	r.games[publicID] = game
+	for i, userID := range userIDs {
+		r.players[publicID] = append(r.players[publicID], &database.GamePlayer{
+			GameID: game.GameID, UserID: userID, OrderIndex: i, IsActive: true,
+		})
+	}
+	return game
+}
+
+func (r *fakeGameRepo) GetGameByPublicID(ctx context.Context, publicID string) (*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return nil, database.ErrGameNotFound
+	}
+	copied := *game
+	copied.PlayerCount = len(r.players[publicID])
+	return &copied, nil
+}
+
+func (r *fakeGameRepo) GetGameByJoinToken(ctx context.Context, token string) (*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for publicID, joinToken := range r.joinLinks {
+		if joinToken == token {
+			copied := *r.games[publicID]
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrGameNotFound
+}
+
+func (r *fakeGameRepo) SetJoinToken(ctx context.Context, publicID string, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.games[publicID]; !ok {
+		return database.ErrGameNotFound
+	}
+	r.joinLinks[publicID] = token
+	return nil
+}
+
+func (r *fakeGameRepo) GetGamePlayers(ctx context.Context, publicID string) ([]*database.GamePlayer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	players := make([]*database.GamePlayer, 0, len(r.players[publicID]))
+	for _, player := range r.players[publicID] {
+		copied := *player
+		players = append(players, &copied)
+	}
+	return players, nil
+}
+
+func (r *fakeGameRepo) GetActivePlayerIDs(ctx context.Context, publicID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	for _, player := range r.players[publicID] {
+		if player.IsActive {
+			ids = append(ids, player.UserID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeGameRepo) AddPlayer(ctx context.Context, publicID string, userID string, orderIndex int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failAddPlayer[userID]; err != nil {
+		return err
+	}
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	r.players[publicID] = append(r.players[publicID], &database.GamePlayer{
+		GameID: game.GameID, UserID: userID, OrderIndex: orderIndex,
+	})
+	return nil
+}
+
+func (r *fakeGameRepo) DeletePlayer(ctx context.Context, publicID string, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	players := r.players[publicID][:0]
+	for _, player := range r.players[publicID] {
+		if player.UserID != userID {
+			players = append(players, player)
+		}
+	}
+	r.players[publicID] = players
+	return nil
+}
+
+func (r *fakeGameRepo) UpdatePlayerStatus(ctx context.Context, publicID string, userID string, isActive bool, joinedAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, player := range r.players[publicID] {
+		if player.UserID == userID {
+			player.IsActive = isActive
+			player.JoinedAt = joinedAt
+			return nil
+		}
+	}
+	return fmt.Errorf("player %s not in game %s", userID, publicID)
+}
+
+func (r *fakeGameRepo) UpdateGameStatus(ctx context.Context, publicID string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	game.Status = status
+	return nil
+}
+
+func (r *fakeGameRepo) UpdatePlayerScore(ctx context.Context, publicID string, userID string, score int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, player := range r.players[publicID] {
+		if player.UserID == userID {
+			player.Score = &score
+			return nil
+		}
+	}
+	return fmt.Errorf("player %s not in game %s", userID, publicID)
+}
+
+func (r *fakeGameRepo) FinishGame(ctx context.Context, publicID string, winnerUserID *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	game.Status = "finished"
+	game.WinnerUserID = winnerUserID
+	return nil
+}
+
+func (r *fakeGameRepo) GetStaleInProgressGames(ctx context.Context, cutoff time.Time) ([]*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var games []*database.Game
+	for publicID, game := range r.games {
+		lastMove, ok := r.lastMove[publicID]
+		if !ok {
+			lastMove = game.CreatedAt
+		}
+		if game.Status == "in_progress" && lastMove.Before(cutoff) {
+			copied := *game
+			games = append(games, &copied)
+		}
+	}
+	return games, nil
+}
+
+func (r *fakeGameRepo) AbandonGame(ctx context.Context, publicID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if game, ok := r.games[publicID]; ok && game.Status == "in_progress" {
+		game.Status = "abandoned"
+	}
+	return nil
+}
+
+func (r *fakeGameRepo) SaveGameState(ctx context.Context, publicID string, stateJSON []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.states[publicID]; ok {
+		return database.ErrStateExists
+	}
+	r.states[publicID] = &fakeStateRow{state: stateJSON, initial: stateJSON, version: 1}
+	return nil
+}
+
+func (r *fakeGameRepo) LoadGameState(ctx context.Context, publicID string) ([]byte, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok {
+		return nil, 0, database.ErrStateNotFound
+	}
+	return row.state, row.version, nil
+}
+
+func (r *fakeGameRepo) LoadInitialGameState(ctx context.Context, publicID string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok || row.initial == nil {
+		return nil, database.ErrStateNotFound
+	}
+	return row.initial, nil
+}
+
+func (r *fakeGameRepo) UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok || row.version != expectedVersion {
+		return database.ErrVersionConflict
+	}
+	row.state = stateJSON
+	row.version++
+	return nil
+}
+
+func (r *fakeGameRepo) CreateRematchGame(ctx context.Context, originalGameID int, createdByUserID string, maxPlayers int, rulesJSON []byte) (*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rematches[originalGameID]; ok {
+		return nil, database.ErrRematchExists
+	}
+	r.nextID++
+	game := &database.Game{
+		GameID:     r.nextID,
+		PublicID:   fmt.Sprintf("rematch-%d", originalGameID),
+		CreatedBy:  createdByUserID,
+		Status:     "waiting_for_players",
+		MaxPlayers: maxPlayers,
+		Rules:      rulesJSON,
+	}
+	r.games[game.PublicID] = game
+	r.rematches[originalGameID] = game.PublicID
+	copied := *game
+	return &copied, nil
+}
+
+func (r *fakeGameRepo) GetRematchGame(ctx context.Context, originalGameID int) (*database.Game, error) {
+	r.mu.Lock()
+	publicID, ok := r.rematches[originalGameID]
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, database.ErrGameNotFound
+	}
+	return r.GetGameByPublicID(ctx, publicID)
+}
+
+func (r *fakeGameRepo) GetPlayersForGames(ctx context.Context, gameIDs []int) (map[int][]*database.GamePlayer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byGame := make(map[int][]*database.GamePlayer)
+	for publicID, game := range r.games {
+		if !slices.Contains(gameIDs, game.GameID) {
+			continue
+		}
+		for _, player := range r.players[publicID] {
+			if player.IsActive {
+				copied := *player
+				byGame[game.GameID] = append(byGame[game.GameID], &copied)
+			}
+		}
+	}
+	return byGame, nil
+}
+
+// GetCompletedGames pages through finished and abandoned games the same way the SQL
+// does: newest finished_at first, then highest game ID, strictly after the cursor
+func (r *fakeGameRepo) GetCompletedGames(ctx context.Context, userID string, after *database.GameCursor, limit int) ([]*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var games []*database.Game
+	for publicID, game := range r.games {
+		if game.Status != "finished" && game.Status != "abandoned" || game.FinishedAt == nil {
+			continue
+		}
+		active := false
+		for _, player := range r.players[publicID] {
+			active = active || player.UserID == userID && player.IsActive
+		}
+		if !active {
+			continue
+		}
+		if after != nil && !gameOlderThan(game, after.FinishedAt, after.GameID) {
+			continue
+		}
+		copied := *game
+		games = append(games, &copied)
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		return gameOlderThan(games[j], *games[i].FinishedAt, games[i].GameID)
+	})
+	return games[:min(limit, len(games))], nil
+}
+
+// gameBefore reports whether game comes after (finishedAt, gameID) in newest-first order
+func gameOlderThan(game *database.Game, finishedAt time.Time, gameID int) bool {
+	if !game.FinishedAt.Equal(finishedAt) {
+		return game.FinishedAt.Before(finishedAt)
+	}
+	return game.GameID < gameID
+}
+
+// WithTx runs fn against the repository itself, putting back everything it held
+// before if fn fails
+func (r *fakeGameRepo) WithTx(ctx context.Context, fn func(tx database.GameRepository) error) error {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	saved := r.snapshot()
+	if err := fn(r); err != nil {
+		r.mu.Lock()
+		r.nextID, r.games, r.players, r.states, r.rematches = saved.nextID, saved.games, saved.players, saved.states, saved.rematches
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// snapshot deep-copies the repository's contents
+func (r *fakeGameRepo) snapshot() *fakeGameRepo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := newFakeGameRepo()
+	saved.nextID = r.nextID
+	for publicID, game := range r.games {
+		copied := *game
+		saved.games[publicID] = &copied
+	}
+	for publicID, players := range r.players {
+		for _, player := range players {
+			copied := *player
+			saved.players[publicID] = append(saved.players[publicID], &copied)
+		}
+	}
+	for publicID, row := range r.states {
+		copied := *row
+		saved.states[publicID] = &copied
+	}
+	for gameID, publicID := range r.rematches {
+		saved.rematches[gameID] = publicID
+	}
+	return saved
+}
+
+// fakeMoveRepo is an in-memory MoveLogRepository
+type fakeMoveRepo struct {
+	mu    sync.Mutex
+	moves []*database.Move
+}
+
+func (r *fakeMoveRepo) RecordMove(ctx context.Context, gameID int, userID string, action string, cardIndex *int, decksJSON []byte, resultingVersion int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, move := range r.moves {
+		if move.GameID == gameID && move.ResultingVersion == resultingVersion {
+			return fmt.Errorf("duplicate move for version %d", resultingVersion)
+		}
+	}
+	r.moves = append(r.moves, &database.Move{
+		GameMoveID:       len(r.moves) + 1,
+		GameID:           gameID,
+		UserID:           userID,
+		Action:           action,
+		CardIndex:        cardIndex,
+		Decks:            decksJSON,
+		ResultingVersion: resultingVersion,
+	})
+	return nil
+}
+
+func (r *fakeMoveRepo) GetMoves(ctx context.Context, gameID int) ([]*database.Move, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var moves []*database.Move
+	for _, move := range r.moves {
+		if move.GameID == gameID {
+			moves = append(moves, move)
+		}
+	}
+	return moves, nil
+}
+
+// fakeUserRepo is an in-memory UserRepository keyed by user ID
+type fakeUserRepo struct {
+	database.UserRepository
+
+	mu       sync.Mutex
+	users    map[string]*database.User
+	lookups  int                     // GetUserByUsername calls
+	fetched  [][]string              // the IDs asked for by each GetUsersByIDs call
+	delay    time.Duration           // how long GetUserByUsername takes
+	sessions map[string]*fakeSession // by token
+
+	resetTokens  map[string]*fakeToken // by token hash
+	verifyTokens map[string]*fakeToken
+
+	liveGames   []string // public IDs DeleteUser reports as abandoned
+	placeholder string   // who DeleteUser last handed a user's history to
+}
+
+// fakeSession is a row of sessions
+type fakeSession struct {
+	userID    string
+	expiresAt time.Time
+	userAgent string
+	ipAddress string
+}
+
+// fakeToken is a row of password_reset_tokens or email_verification_tokens
+type fakeToken struct {
+	userID    string
+	expiresAt time.Time
+	used      bool
+}
+
+func newFakeUserRepo(userIDs ...string) *fakeUserRepo {
+	r := &fakeUserRepo{
+		users:        make(map[string]*database.User),
+		sessions:     make(map[string]*fakeSession),
+		resetTokens:  make(map[string]*fakeToken),
+		verifyTokens: make(map[string]*fakeToken),
+	}
+	for _, userID := range userIDs {
+		r.users[userID] = &database.User{UserID: userID, Username: userID}
+	}
+	return r
+}
+
+func (r *fakeUserRepo) GetUserByID(ctx context.Context, userID string) (*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, database.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fetched = append(r.fetched, slices.Clone(userIDs))
+	users := make(map[string]*database.User)
+	for _, userID := range userIDs {
+		if user, ok := r.users[userID]; ok {
+			copied := *user
+			users[userID] = &copied
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepo) GetUserByUsername(ctx context.Context, username string) (*database.User, error) {
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) UserExists(ctx context.Context, username string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeUserRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email != "" && user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeUserRepo) CreateUser(ctx context.Context, username, hashedPassword, email string) (*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID := fmt.Sprintf("user-%d", len(r.users)+1)
+	user := &database.User{UserID: userID, Username: username, Password: hashedPassword, Email: email}
+	r.users[userID] = user
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) CreateSession(ctx context.Context, userID, token string, expiresAt time.Time, userAgent, ipAddress string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[token] = &fakeSession{userID: userID, expiresAt: expiresAt, userAgent: userAgent, ipAddress: ipAddress}
+	return nil
+}
+
+func (r *fakeUserRepo) ValidateSession(ctx context.Context, token string) (string, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[token]
+	if !ok || !time.Now().Before(session.expiresAt) {
+		return "", time.Time{}, database.ErrSessionNotFound
+	}
+	return session.userID, session.expiresAt, nil
+}
+
+func (r *fakeUserRepo) GetSessions(ctx context.Context, userID string) ([]*database.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessions []*database.Session
+	for token, session := range r.sessions {
+		if session.userID == userID && time.Now().Before(session.expiresAt) {
+			sessions = append(sessions, &database.Session{
+				ID:        database.SessionIDForToken(token),
+				Type:      "web",
+				UserAgent: session.userAgent,
+				IPAddress: session.ipAddress,
+				ExpiresAt: session.expiresAt,
+			})
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeUserRepo) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, session := range r.sessions {
+		if session.userID == userID && database.SessionIDForToken(token) == sessionID {
+			delete(r.sessions, token)
+			return nil
+		}
+	}
+	return database.ErrSessionNotFound
+}
+
+// RefreshSession extends a session that hasn't expired yet
+func (r *fakeUserRepo) RefreshSession(ctx context.Context, token string, newExpiry time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[token]; ok && time.Now().Before(session.expiresAt) {
+		session.expiresAt = newExpiry
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) UpdatePassword(ctx context.Context, userID, hashedPassword string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[userID].Password = hashedPassword
+	return nil
+}
+
+func (r *fakeUserRepo) DeleteAllSessions(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, session := range r.sessions {
+		if session.userID == userID {
+			delete(r.sessions, token)
+		}
+	}
+	return nil
+}
+
+// issueToken stores a token like the repository does: the user's unused tokens stop working
+func issueToken(tokens map[string]*fakeToken, userID, tokenHash string, expiresAt time.Time) {
+	for _, token := range tokens {
+		if token.userID == userID {
+			token.used = true
+		}
+	}
+	tokens[tokenHash] = &fakeToken{userID: userID, expiresAt: expiresAt}
+}
+
+// redeemToken uses up a live token and returns its user
+func redeemToken(tokens map[string]*fakeToken, tokenHash string) (string, bool) {
+	token, ok := tokens[tokenHash]
+	if !ok || token.used || !time.Now().Before(token.expiresAt) {
+		return "", false
+	}
+	token.used = true
+	return token.userID, true
+}
+
+func (r *fakeUserRepo) DeleteUser(ctx context.Context, userID, placeholderUserID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, userID)
+	for token, session := range r.sessions {
+		if session.userID == userID {
+			delete(r.sessions, token)
+		}
+	}
+	r.placeholder = placeholderUserID
+	return r.liveGames, nil
+}
+
+func (r *fakeUserRepo) CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	issueToken(r.resetTokens, userID, tokenHash, expiresAt)
+	return nil
+}
+
+func (r *fakeUserRepo) ResetPasswordWithToken(ctx context.Context, tokenHash, hashedPassword string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID, ok := redeemToken(r.resetTokens, tokenHash)
+	if !ok {
+		return "", database.ErrInvalidResetToken
+	}
+	r.users[userID].Password = hashedPassword
+	return userID, nil
+}
+
+func (r *fakeUserRepo) CreateEmailVerificationToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	issueToken(r.verifyTokens, userID, tokenHash, expiresAt)
+	return nil
+}
+
+func (r *fakeUserRepo) VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID, ok := redeemToken(r.verifyTokens, tokenHash)
+	if !ok {
+		return "", database.ErrInvalidVerifyToken
+	}
+	r.users[userID].EmailVerified = true
+	return userID, nil
+}
+
+// fakeFriendRepo keeps friendships in memory the way the friendships table does: at
+// most one row per pair, in whichever direction it was first requested
+type fakeFriendRepo struct {
+	mu          sync.Mutex
+	friendships map[[2]string]string // requester, addressee -> "pending" or "accepted"
+}
+
+func newFakeFriendRepo() *fakeFriendRepo {
+	return &fakeFriendRepo{friendships: make(map[[2]string]string)}
+}
+
+func (r *fakeFriendRepo) SendRequest(ctx context.Context, requesterID, addresseeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range [][2]string{{requesterID, addresseeID}, {addresseeID, requesterID}} {
+		if status, ok := r.friendships[key]; ok {
+			if status == "accepted" {
+				return database.ErrAlreadyFriends
+			}
+			return database.ErrFriendRequestSent
+		}
+	}
+	r.friendships[[2]string{requesterID, addresseeID}] = "pending"
+	return nil
+}
+
+func (r *fakeFriendRepo) AcceptRequest(ctx context.Context, requesterID, addresseeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{requesterID, addresseeID}
+	if r.friendships[key] != "pending" {
+		return database.ErrFriendshipNotFound
+	}
+	r.friendships[key] = "accepted"
+	return nil
+}
+
+func (r *fakeFriendRepo) RemoveFriend(ctx context.Context, userID, otherUserID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range [][2]string{{userID, otherUserID}, {otherUserID, userID}} {
+		if _, ok := r.friendships[key]; ok {
+			delete(r.friendships, key)
+			return nil
+		}
+	}
+	return database.ErrFriendshipNotFound
+}
+
+func (r *fakeFriendRepo) ListFriends(ctx context.Context, userID string) ([]*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var friends []*database.User
+	for key, status := range r.friendships {
+		if status != "accepted" {
+			continue
+		}
+		switch userID {
+		case key[0]:
+			friends = append(friends, &database.User{UserID: key[1], Username: key[1]})
+		case key[1]:
+			friends = append(friends, &database.User{UserID: key[0], Username: key[0]})
+		}
+	}
+	return friends, nil
+}
+
+func (r *fakeFriendRepo) ListFriendRequests(ctx context.Context, userID string) ([]*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var requesters []*database.User
+	for key, status := range r.friendships {
+		if status == "pending" && key[1] == userID {
+			requesters = append(requesters, &database.User{UserID: key[0], Username: key[0]})
+		}
+	}
+	return requesters, nil
+}
@@ -0,0 +1,98 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+)
+
+var (
+	ErrCannotFriendSelf      = errors.New("cannot send a friend request to yourself")
+	ErrCannotFriendUser      = errors.New("this user cannot be added as a friend")
+	ErrFriendRequestSent     = errors.New("friend request already sent")
+	ErrAlreadyFriends        = errors.New("you are already friends")
+	ErrFriendRequestNotFound = errors.New("no pending friend request from this user")
+	ErrFriendNotFound        = errors.New("not friends with this user")
+)
+
+// FriendService manages friend requests and friend lists
+type FriendService struct {
+	friendRepo database.FriendRepository
+}
+
+func NewFriendService(friendRepo database.FriendRepository) *FriendService {
+	return &FriendService{friendRepo: friendRepo}
+}
+
+// SendRequest asks toUserID to be fromUserID's friend. If toUserID had already asked
+// fromUserID, their request is accepted instead. Reports whether the two are now friends.
+func (s *FriendService) SendRequest(ctx context.Context, fromUserID, toUserID string) (bool, error) {
+	if fromUserID == toUserID {
+		return false, ErrCannotFriendSelf
+	}
+	if IsBot(toUserID) || toUserID == DeletedUserID {
+		return false, ErrCannotFriendUser
+	}
+
+	err := s.friendRepo.AcceptRequest(ctx, toUserID, fromUserID)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, database.ErrFriendshipNotFound) {
+		return false, fmt.Errorf("failed to accept friend request: %w", err)
+	}
+
+	err = s.friendRepo.SendRequest(ctx, fromUserID, toUserID)
+	switch {
+	case errors.Is(err, database.ErrFriendRequestSent):
+		return false, ErrFriendRequestSent
+	case errors.Is(err, database.ErrAlreadyFriends):
+		return false, ErrAlreadyFriends
+	case err != nil:
+		return false, fmt.Errorf("failed to send friend request: %w", err)
+	}
+	return false, nil
+}
+
+// AcceptRequest accepts the pending request fromUserID sent to userID
+func (s *FriendService) AcceptRequest(ctx context.Context, userID, fromUserID string) error {
+	err := s.friendRepo.AcceptRequest(ctx, fromUserID, userID)
+	if errors.Is(err, database.ErrFriendshipNotFound) {
+		return ErrFriendRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to accept friend request: %w", err)
+	}
+	return nil
+}
+
+// RemoveFriend unfriends otherUserID, or declines or cancels a pending request between them
+func (s *FriendService) RemoveFriend(ctx context.Context, userID, otherUserID string) error {
+	err := s.friendRepo.RemoveFriend(ctx, userID, otherUserID)
+	if errors.Is(err, database.ErrFriendshipNotFound) {
+		return ErrFriendNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove friend: %w", err)
+	}
+	return nil
+}
+
+// ListFriends returns the user's friends, ordered by username
+func (s *FriendService) ListFriends(ctx context.Context, userID string) ([]*database.User, error) {
+	friends, err := s.friendRepo.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list friends: %w", err)
+	}
+	return friends, nil
+}
+
+// ListFriendRequests returns the users waiting for userID to accept their request
+func (s *FriendService) ListFriendRequests(ctx context.Context, userID string) ([]*database.User, error) {
+	requests, err := s.friendRepo.ListFriendRequests(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list friend requests: %w", err)
+	}
+	return requests, nil
+}
@@ -0,0 +1,97 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"testing"
+)
+
+// userIDs lists the IDs of users
+func userIDs(users []*database.User) []string {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.UserID
+	}
+	return ids
+}
+
+func TestFriendRequestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := NewFriendService(newFakeFriendRepo())
+
+	if friends, err := s.SendRequest(ctx, "alice", "bob"); err != nil || friends {
+		t.Fatalf("SendRequest = %v, %v, want a pending request", friends, err)
+	}
+	if _, err := s.SendRequest(ctx, "alice", "bob"); !errors.Is(err, ErrFriendRequestSent) {
+		t.Fatalf("duplicate request: err = %v, want ErrFriendRequestSent", err)
+	}
+	if requests, _ := s.ListFriendRequests(ctx, "bob"); len(requests) != 1 || requests[0].UserID != "alice" {
+		t.Fatalf("bob's requests = %v, want alice's", userIDs(requests))
+	}
+	if friends, _ := s.ListFriends(ctx, "alice"); len(friends) != 0 {
+		t.Fatalf("alice has friends %v before bob accepted", userIDs(friends))
+	}
+
+	if err := s.AcceptRequest(ctx, "alice", "bob"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Fatalf("accepting her own request: err = %v, want ErrFriendRequestNotFound", err)
+	}
+	if err := s.AcceptRequest(ctx, "bob", "alice"); err != nil {
+		t.Fatalf("AcceptRequest: %v", err)
+	}
+	for _, pair := range [][2]string{{"alice", "bob"}, {"bob", "alice"}} {
+		friends, err := s.ListFriends(ctx, pair[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(friends) != 1 || friends[0].UserID != pair[1] {
+			t.Fatalf("%s's friends = %v, want [%s]", pair[0], userIDs(friends), pair[1])
+		}
+	}
+	if requests, _ := s.ListFriendRequests(ctx, "bob"); len(requests) != 0 {
+		t.Fatalf("accepted request still pending: %v", userIDs(requests))
+	}
+	if _, err := s.SendRequest(ctx, "bob", "alice"); !errors.Is(err, ErrAlreadyFriends) {
+		t.Fatalf("request between friends: err = %v, want ErrAlreadyFriends", err)
+	}
+
+	if err := s.RemoveFriend(ctx, "bob", "alice"); err != nil {
+		t.Fatalf("RemoveFriend: %v", err)
+	}
+	if friends, _ := s.ListFriends(ctx, "alice"); len(friends) != 0 {
+		t.Fatalf("alice still has friends %v", userIDs(friends))
+	}
+	if err := s.RemoveFriend(ctx, "alice", "bob"); !errors.Is(err, ErrFriendNotFound) {
+		t.Fatalf("removing again: err = %v, want ErrFriendNotFound", err)
+	}
+}
+
+func TestCrossedFriendRequestsMakeFriends(t *testing.T) {
+	ctx := context.Background()
+	s := NewFriendService(newFakeFriendRepo())
+
+	if _, err := s.SendRequest(ctx, "alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	friends, err := s.SendRequest(ctx, "bob", "alice")
+	if err != nil || !friends {
+		t.Fatalf("SendRequest back = %v, %v, want it to accept alice's request", friends, err)
+	}
+	if list, _ := s.ListFriends(ctx, "alice"); len(list) != 1 || list[0].UserID != "bob" {
+		t.Fatalf("alice's friends = %v, want [bob]", userIDs(list))
+	}
+}
+
+func TestFriendRequestsToReservedUsersAreRejected(t *testing.T) {
+	ctx := context.Background()
+	s := NewFriendService(newFakeFriendRepo())
+
+	if _, err := s.SendRequest(ctx, "alice", "alice"); !errors.Is(err, ErrCannotFriendSelf) {
+		t.Errorf("to self: err = %v, want ErrCannotFriendSelf", err)
+	}
+	for _, userID := range []string{BotUserID, DeletedUserID} {
+		if _, err := s.SendRequest(ctx, "alice", userID); !errors.Is(err, ErrCannotFriendUser) {
+			t.Errorf("to %s: err = %v, want ErrCannotFriendUser", userID, err)
+		}
+	}
+}
@@ -3,21 +3,30 @@ package business
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"golf-card-game/database"
+	"log"
+	mathrand "math/rand"
+	"sort"
 	"time"
 )
 
 var (
-	ErrGameNotFound      = errors.New("game not found")
-	ErrGameFull          = errors.New("game is full")
-	ErrAlreadyInvited    = errors.New("user already invited to this game")
-	ErrAlreadyInGame     = errors.New("user is already in this game")
-	ErrInvalidGameStatus = errors.New("game is not in a valid state for this operation")
-	ErrNotInvited        = errors.New("user is not invited to this game")
-	ErrCannotInviteSelf  = errors.New("cannot invite yourself")
+	ErrGameNotFound       = errors.New("game not found")
+	ErrGameFull           = errors.New("game is full")
+	ErrAlreadyInvited     = errors.New("user already invited to this game")
+	ErrAlreadyInGame      = errors.New("user is already in this game")
+	ErrInvalidGameStatus  = errors.New("game is not in a valid state for this operation")
+	ErrNotInvited         = errors.New("user is not invited to this game")
+	ErrCannotInviteSelf   = errors.New("cannot invite yourself")
+	ErrCreatorCannotLeave = errors.New("the game creator cannot leave before the game starts")
+	ErrInvalidCursor      = errors.New("invalid history cursor")
+	ErrSpectatingDisabled = errors.New("spectators are not allowed in this game")
+	ErrInvalidPlayerCount = errors.New("games must have between 2 and 4 players")
 
 	// Game action errors
 	ErrNotYourTurn        = errors.New("it is not your turn")
@@ -25,15 +34,29 @@ var (
 	ErrInvalidCardIndex   = errors.New("invalid card index")
 	ErrCardAlreadyFaceUp  = errors.New("card is already face-up")
 	ErrInvalidInitialFlip = errors.New("initial flip must be one card from top row and one from bottom row")
+	ErrInitialFlipsDone   = errors.New("you have already flipped your two initial cards")
+	ErrInitialFlipsNeeded = errors.New("you must flip your two initial cards first")
 	ErrNoDrawnCard        = errors.New("no card has been drawn yet")
 	ErrCardAlreadyDrawn   = errors.New("a card has already been drawn this turn")
 	ErrEmptyDeck          = errors.New("deck is empty")
 	ErrEmptyDiscard       = errors.New("discard pile is empty")
+	ErrFirstTurnDiscard   = errors.New("drawing from the discard pile is not allowed on the first turn")
+	ErrCannotCancelDraw   = errors.New("a card taken from the discard pile can't be put back")
+
+	ErrCannotDiscardDrawnDiscard = errors.New("a card taken from the discard pile must be swapped into your hand")
+)
+
+// Supported table sizes
+const (
+	MinPlayers = 2
+	MaxPlayers = 4
 )
 
 type GameService struct {
-	gameRepo database.GameRepository
-	userRepo database.UserRepository
+	gameRepo    database.GameRepository
+	userRepo    database.UserRepository
+	leaderboard *leaderboardCache
+	moveLog     *MoveLog // for VerifyGameState, nil until SetMoveLog
 }
 
 // CardDef represents a single playing card in the game
@@ -70,36 +93,55 @@ type FullGameState struct {
 	Players          []PlayerState `json:"players"`          // Player states (indexed by order_index)
 	CurrentTurnIdx   int           `json:"currentTurnIdx"`   // Index into Players array for whose turn it is
 	DrawnCard        *CardDef      `json:"drawnCard"`        // Card currently drawn (waiting for swap/discard decision)
+	DrawnFromDiscard bool          `json:"drawnFromDiscard"` // DrawnCard came from the discard pile, so the draw can't be cancelled
 	TriggerPlayerIdx *int          `json:"triggerPlayerIdx"` // Index of player who flipped all cards (triggers final round)
 	FinalRoundTurns  int           `json:"finalRoundTurns"`  // Remaining turns in final round
+	TurnsPlayed      int           `json:"turnsPlayed"`      // Completed turns since the initial flip
+	Rules            GameRules     `json:"rules"`            // Optional rules chosen at game creation
 	Version          int           `json:"version"`          // For optimistic locking
+	SchemaVersion    int           `json:"schemaVersion"`    // Layout version of this struct, see CurrentStateSchemaVersion
+
+	// Match play: deals repeat until someone's cumulative score reaches TargetScore (0 = single deal)
+	TargetScore      int            `json:"targetScore"`
+	CumulativeScores map[string]int `json:"cumulativeScores"` // Totals from completed deals
+	Round            int            `json:"round"`            // 1-based deal number
+
+	Seed *int64 `json:"seed,omitempty"` // Set for reproducible games; every shuffle derives from it
+
+	// Decks shuffled since the state was loaded, oldest first. The move log stores them
+	// with the move that shuffled them, so replays are exact even for unseeded games.
+	newDecks [][]CardDef
+	// While replaying: the decks the move being replayed recorded, used instead of shuffling
+	recordedDecks [][]CardDef
+	replaying     bool
 }
 
 func NewGameService(gameRepo database.GameRepository, userRepo database.UserRepository) *GameService {
 	return &GameService{
-		gameRepo: gameRepo,
-		userRepo: userRepo,
+		gameRepo:    gameRepo,
+		userRepo:    userRepo,
+		leaderboard: newLeaderboardCache(),
 	}
 }
 
-// CreateGame creates a new 1v1 game and adds the creator as the first player
-func (s *GameService) CreateGame(ctx context.Context, createdByUserID string) (*database.Game, error) {
-	// Create game with max 2 players for 1v1
-	game, err := s.gameRepo.CreateGame(ctx, createdByUserID, 2)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create game: %w", err)
+// CreateGame creates a new game for maxPlayers players and adds the creator as the first player
+func (s *GameService) CreateGame(ctx context.Context, createdByUserID string, maxPlayers int, rules GameRules) (*database.Game, error) {
+	if maxPlayers < MinPlayers || maxPlayers > MaxPlayers {
+		return nil, ErrInvalidPlayerCount
 	}
 
-	// Add creator as first player (order_index = 0, is_active = true, joined immediately)
-	err = s.gameRepo.AddPlayer(ctx, game.PublicID, createdByUserID, 0)
+	rulesJSON, err := json.Marshal(rules)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add creator to game: %w", err)
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
 	}
 
-	now := time.Now()
-	err = s.gameRepo.UpdatePlayerStatus(ctx, game.PublicID, createdByUserID, true, &now)
+	game, err := s.gameRepo.CreateGame(ctx, createdByUserID, maxPlayers, rulesJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to activate creator: %w", err)
+		return nil, fmt.Errorf("failed to create game: %w", err)
+	}
+
+	if err := seatCreator(ctx, s.gameRepo, game.PublicID, createdByUserID); err != nil {
+		return nil, err
 	}
 
 	// Reload game to get updated player count
@@ -111,6 +153,22 @@ func (s *GameService) CreateGame(ctx context.Context, createdByUserID string) (*
 	return game, nil
 }
 
+// seatCreator adds the creator as first player (order_index = 0, is_active = true, joined immediately).
+// repo may be a transaction.
+func seatCreator(ctx context.Context, repo database.GameRepository, publicID string, createdByUserID string) error {
+	err := repo.AddPlayer(ctx, publicID, createdByUserID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to add creator to game: %w", err)
+	}
+
+	now := time.Now()
+	err = repo.UpdatePlayerStatus(ctx, publicID, createdByUserID, true, &now)
+	if err != nil {
+		return fmt.Errorf("failed to activate creator: %w", err)
+	}
+	return nil
+}
+
 // InvitePlayer adds a player to the game as a pending invitation
 func (s *GameService) InvitePlayer(ctx context.Context, publicID string, invitedUserID, inviterUserID string) error {
 	// Validate inviter is not inviting themselves
@@ -125,9 +183,9 @@ func (s *GameService) InvitePlayer(ctx context.Context, publicID string, invited
 	}
 
 	// Get game and validate
-	game, err := s.gameRepo.GetGameByPublicID(ctx, publicID)
+	game, err := s.resolveGame(ctx, publicID)
 	if err != nil {
-		return ErrGameNotFound
+		return err
 	}
 
 	if game.Status != "waiting_for_players" {
@@ -169,9 +227,7 @@ func (s *GameService) InvitePlayer(ctx context.Context, publicID string, invited
 	}
 
 	// Add player with is_active=false, joined_at=NULL (pending invitation)
-	// Order index is based on current player count
-	orderIndex := len(players)
-	err = s.gameRepo.AddPlayer(ctx, publicID, invitedUserID, orderIndex)
+	err = s.gameRepo.AddPlayer(ctx, publicID, invitedUserID, nextOrderIndex(players))
 	if err != nil {
 		return fmt.Errorf("failed to invite player: %w", err)
 	}
@@ -179,22 +235,23 @@ func (s *GameService) InvitePlayer(ctx context.Context, publicID string, invited
 	return nil
 }
 
-// AcceptInvitation activates a player's participation in a game
-func (s *GameService) AcceptInvitation(ctx context.Context, publicID string, userID string) error {
+// AcceptInvitation activates a player's participation in a game, dealing it once it is
+// full. started reports whether this call dealt the game.
+func (s *GameService) AcceptInvitation(ctx context.Context, publicID string, userID string) (started bool, err error) {
 	// Get game
-	game, err := s.gameRepo.GetGameByPublicID(ctx, publicID)
+	game, err := s.resolveGame(ctx, publicID)
 	if err != nil {
-		return ErrGameNotFound
+		return false, err
 	}
 
 	if game.Status != "waiting_for_players" {
-		return ErrInvalidGameStatus
+		return false, ErrInvalidGameStatus
 	}
 
 	// Get players
 	players, err := s.gameRepo.GetGamePlayers(ctx, publicID)
 	if err != nil {
-		return fmt.Errorf("failed to get game players: %w", err)
+		return false, fmt.Errorf("failed to get game players: %w", err)
 	}
 
 	// Find the user's player record
@@ -207,37 +264,43 @@ func (s *GameService) AcceptInvitation(ctx context.Context, publicID string, use
 	}
 
 	if userPlayer == nil {
-		return ErrNotInvited
+		return false, ErrNotInvited
 	}
 
 	if userPlayer.IsActive {
-		return ErrAlreadyInGame
+		return false, ErrAlreadyInGame
 	}
 
 	// Activate the player
 	now := time.Now()
 	err = s.gameRepo.UpdatePlayerStatus(ctx, publicID, userID, true, &now)
 	if err != nil {
-		return fmt.Errorf("failed to accept invitation: %w", err)
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
 	}
 
-	// Count active players
-	activeCount := 0
-	for _, player := range players {
-		if player.IsActive || player.UserID == userID {
-			activeCount++
-		}
+	// Count active players (now including the acceptor)
+	activePlayerIDs, err := s.gameRepo.GetActivePlayerIDs(ctx, publicID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get active players: %w", err)
 	}
 
 	// If we now have max players, start the game
-	if activeCount >= game.MaxPlayers {
-		err = s.gameRepo.UpdateGameStatus(ctx, publicID, "in_progress")
-		if err != nil {
-			return fmt.Errorf("failed to start game: %w", err)
-		}
+	if len(activePlayerIDs) < game.MaxPlayers {
+		return false, nil
+	}
+	err = s.gameRepo.UpdateGameStatus(ctx, publicID, "in_progress")
+	if err != nil {
+		return false, fmt.Errorf("failed to start game: %w", err)
 	}
 
-	return nil
+	// Deal straight away so the board has something to show. When two players fill
+	// the last seats at once only one deal is saved, and only that caller reports started.
+	_, started, err = s.LoadOrInitState(ctx, publicID)
+	if err != nil {
+		return false, fmt.Errorf("failed to initialize game: %w", err)
+	}
+
+	return started, nil
 }
 
 // DeclineInvitation removes a pending invitation
@@ -274,11 +337,84 @@ func (s *GameService) DeclineInvitation(ctx context.Context, publicID string, us
 	return nil
 }
 
+// LeaveGame undoes an accepted invitation while the game is still waiting for players.
+// Like declining, it removes the player's seat entirely so someone else can take it;
+// coming back needs a new invitation or the join link. Once the game is in progress
+// this is no longer allowed (that is a forfeit instead).
+func (s *GameService) LeaveGame(ctx context.Context, publicID string, userID string) error {
+	game, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return err
+	}
+
+	if game.Status != "waiting_for_players" {
+		return ErrInvalidGameStatus
+	}
+
+	if game.CreatedBy == userID {
+		return ErrCreatorCannotLeave
+	}
+
+	players, err := s.gameRepo.GetGamePlayers(ctx, publicID)
+	if err != nil {
+		return fmt.Errorf("failed to get game players: %w", err)
+	}
+
+	// Find the user's player record
+	var userPlayer *database.GamePlayer
+	for _, player := range players {
+		if player.UserID == userID {
+			userPlayer = player
+			break
+		}
+	}
+
+	if userPlayer == nil {
+		return ErrNotInvited
+	}
+
+	if !userPlayer.IsActive {
+		return errors.New("cannot leave - invitation not accepted")
+	}
+
+	// Delete the player record so the seat no longer counts against the game's size
+	err = s.gameRepo.DeletePlayer(ctx, publicID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to leave game: %w", err)
+	}
+
+	return nil
+}
+
+// nextOrderIndex returns the seat after the last one taken. Seats freed by declining
+// or leaving leave gaps, so the player count can't be used.
+func nextOrderIndex(players []*database.GamePlayer) int {
+	next := 0
+	for _, player := range players {
+		if player.OrderIndex >= next {
+			next = player.OrderIndex + 1
+		}
+	}
+	return next
+}
+
+// resolveGame looks up a game by public ID, mapping unknown IDs to ErrGameNotFound
+func (s *GameService) resolveGame(ctx context.Context, publicID string) (*database.Game, error) {
+	game, err := s.gameRepo.GetGameByPublicID(ctx, publicID)
+	if err != nil {
+		if errors.Is(err, database.ErrGameNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, fmt.Errorf("failed to get game: %w", err)
+	}
+	return game, nil
+}
+
 // GetGameWithPlayers retrieves a game and its players
 func (s *GameService) GetGameWithPlayers(ctx context.Context, publicID string) (*database.Game, []*database.GamePlayer, error) {
-	game, err := s.gameRepo.GetGameByPublicID(ctx, publicID)
+	game, err := s.resolveGame(ctx, publicID)
 	if err != nil {
-		return nil, nil, ErrGameNotFound
+		return nil, nil, err
 	}
 
 	players, err := s.gameRepo.GetGamePlayers(ctx, publicID)
@@ -309,11 +445,151 @@ func (s *GameService) GetActiveGames(ctx context.Context, userID string) ([]*dat
 
 // GetGameByPublicID retrieves a game by its public ID (for URL-based access)
 func (s *GameService) GetGameByPublicID(ctx context.Context, publicID string) (*database.Game, error) {
-	game, err := s.gameRepo.GetGameByPublicID(ctx, publicID)
+	return s.resolveGame(ctx, publicID)
+}
+
+// GetActivePlayerIDs returns the ordered user IDs of a game's active players
+func (s *GameService) GetActivePlayerIDs(ctx context.Context, publicID string) ([]string, error) {
+	userIDs, err := s.gameRepo.GetActivePlayerIDs(ctx, publicID)
 	if err != nil {
-		return nil, ErrGameNotFound
+		return nil, fmt.Errorf("failed to get active players: %w", err)
 	}
-	return game, nil
+	return userIDs, nil
+}
+
+// GetStreak returns the user's current win (positive) or loss (negative) streak
+func (s *GameService) GetStreak(ctx context.Context, userID string) (int, error) {
+	streak, err := s.gameRepo.GetStreak(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get streak: %w", err)
+	}
+	return streak, nil
+}
+
+// GetPlayerStats returns the user's games played, wins, and average and best scores
+func (s *GameService) GetPlayerStats(ctx context.Context, userID string) (*database.PlayerStats, error) {
+	stats, err := s.gameRepo.GetPlayerStats(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player stats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetCompletedGames returns a page of the user's finished games, newest first.
+// Pass the returned cursor to fetch the next page; it is empty on the last page.
+func (s *GameService) GetCompletedGames(ctx context.Context, userID string, cursor string, limit int) ([]*database.Game, string, error) {
+	var after *database.GameCursor
+	if cursor != "" {
+		decoded, err := decodeGameCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = decoded
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	games, err := s.gameRepo.GetCompletedGames(ctx, userID, after, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get completed games: %w", err)
+	}
+
+	if len(games) <= limit {
+		return games, "", nil
+	}
+
+	games = games[:limit]
+	last := games[len(games)-1]
+	if last.FinishedAt == nil {
+		return games, "", nil
+	}
+	return games, encodeGameCursor(database.GameCursor{FinishedAt: *last.FinishedAt, GameID: last.GameID}), nil
+}
+
+// Results of a past game from one player's point of view
+const (
+	GameResultWon       = "won"
+	GameResultLost      = "lost"
+	GameResultDraw      = "draw"      // Finished with no single winner
+	GameResultAbandoned = "abandoned" // Ended before anyone won, see AbandonStaleGames
+)
+
+// HistoryPlayer is a player's final standing in a past game
+type HistoryPlayer struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Score    *int   `json:"score,omitempty"` // Unset for abandoned games
+}
+
+// GameHistoryEntry is a past game with its final scores and how it went for the viewer
+type GameHistoryEntry struct {
+	*database.Game
+	Result  string           `json:"result"`
+	Players []*HistoryPlayer `json:"players"`
+}
+
+// GetGameHistory returns a page of the user's past games, newest first, each with every
+// player's final score and the user's result. Paging works as in GetCompletedGames.
+func (s *GameService) GetGameHistory(ctx context.Context, userID string, cursor string, limit int) ([]*GameHistoryEntry, string, error) {
+	games, nextCursor, err := s.GetCompletedGames(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gameIDs := make([]int, len(games))
+	for i, game := range games {
+		gameIDs[i] = game.GameID
+	}
+	playersByGame, err := s.gameRepo.GetPlayersForGames(ctx, gameIDs)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get game players: %w", err)
+	}
+
+	history := make([]*GameHistoryEntry, 0, len(games))
+	for _, game := range games {
+		entry := &GameHistoryEntry{Game: game, Players: []*HistoryPlayer{}}
+		switch {
+		case game.Status == "abandoned":
+			entry.Result = GameResultAbandoned
+		case game.WinnerUserID == nil:
+			entry.Result = GameResultDraw
+		case *game.WinnerUserID == userID:
+			entry.Result = GameResultWon
+		default:
+			entry.Result = GameResultLost
+		}
+
+		for _, player := range playersByGame[game.GameID] {
+			entry.Players = append(entry.Players, &HistoryPlayer{
+				UserID:   player.UserID,
+				Username: player.Username,
+				Score:    player.Score,
+			})
+		}
+		history = append(history, entry)
+	}
+
+	return history, nextCursor, nil
+}
+
+// encodeGameCursor turns a cursor into an opaque string for clients
+func encodeGameCursor(c database.GameCursor) string {
+	raw := fmt.Sprintf("%d:%d", c.FinishedAt.UnixMicro(), c.GameID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeGameCursor(cursor string) (*database.GameCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var micros int64
+	var gameID int
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &micros, &gameID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &database.GameCursor{FinishedAt: time.UnixMicro(micros), GameID: gameID}, nil
 }
 
 // ValidateUserInGame checks if a user is an active player in a game
@@ -332,14 +608,48 @@ func (s *GameService) ValidateUserInGame(ctx context.Context, publicID string, u
 	return false, nil
 }
 
+// CanSpectate checks whether a non-player may watch a game. In-progress games follow
+// the spectatorsAllowed rule; finished games are open for replay unless hideReplay is set.
+// Abandoned games have neither live play nor a replay, so they are closed to everyone.
+func (s *GameService) CanSpectate(ctx context.Context, publicID string) error {
+	game, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return err
+	}
+	rules := parseGameRules(game.Rules)
+
+	switch game.Status {
+	case "finished":
+		if rules.HideReplay {
+			return ErrSpectatingDisabled
+		}
+		return nil
+	case "abandoned":
+		return ErrSpectatingDisabled
+	}
+
+	if !rules.SpectatorsAllowed {
+		return ErrSpectatingDisabled
+	}
+	return nil
+}
+
 // Game Engine Functions
 
-// createDeck creates a shuffled standard deck with 2 jokers (54 cards total)
-func createDeck() []CardDef {
+// createDeckWithSeed returns the deck a seeded game shuffles from seed, so the same
+// seed always gives the same order. Deals add the round number to the game's seed.
+func createDeckWithSeed(seed int64, rules ScoringRules) []CardDef {
+	deck := newDeck(rules.JokerCount())
+	shuffleCardsWithSeed(deck, seed)
+	return deck
+}
+
+// newDeck returns the 52 standard cards plus jokers in a fixed, unshuffled order
+func newDeck(jokers int) []CardDef {
 	suits := []string{"hearts", "diamonds", "clubs", "spades"}
 	ranks := []string{"A", "2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K"}
 
-	deck := make([]CardDef, 0, 54)
+	deck := make([]CardDef, 0, 52+jokers)
 
 	// Add standard 52 cards
 	for _, suit := range suits {
@@ -348,17 +658,89 @@ func createDeck() []CardDef {
 		}
 	}
 
-	// Add 2 jokers
-	deck = append(deck, CardDef{Suit: "joker", Rank: "Joker"})
-	deck = append(deck, CardDef{Suit: "joker", Rank: "Joker"})
+	// Add jokers
+	for i := 0; i < jokers; i++ {
+		deck = append(deck, CardDef{Suit: "joker", Rank: "Joker"})
+	}
+
+	return deck
+}
 
-	// Shuffle using Fisher-Yates algorithm
-	for i := len(deck) - 1; i > 0; i-- {
+// shuffleCards shuffles cards in place using the Fisher-Yates algorithm
+func shuffleCards(cards []CardDef) {
+	for i := len(cards) - 1; i > 0; i-- {
 		j := randInt(i + 1)
-		deck[i], deck[j] = deck[j], deck[i]
+		cards[i], cards[j] = cards[j], cards[i]
 	}
+}
 
-	return deck
+// shuffleCardsWithSeed shuffles cards in place in an order fixed by seed
+func shuffleCardsWithSeed(cards []CardDef, seed int64) {
+	rng := mathrand.New(mathrand.NewSource(seed))
+	rng.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+}
+
+// shuffleForState shuffles cards in place for a game: with the next recorded deck when
+// replaying, from the seed (plus seedOffset) in seeded games, otherwise randomly. The
+// resulting order is kept in state.newDecks so it can be written to the move log.
+func shuffleForState(state *FullGameState, cards []CardDef, seedOffset int64) error {
+	switch {
+	case len(state.recordedDecks) > 0:
+		recorded := state.recordedDecks[0]
+		state.recordedDecks = state.recordedDecks[1:]
+		if !sameCards(cards, recorded) {
+			return ErrRecordedDeckMismatch
+		}
+		copy(cards, recorded)
+	case state.Seed != nil:
+		shuffleCardsWithSeed(cards, *state.Seed+seedOffset)
+	case state.replaying:
+		return ErrDeckNotRecorded
+	default:
+		shuffleCards(cards)
+	}
+
+	state.newDecks = append(state.newDecks, append([]CardDef(nil), cards...))
+	return nil
+}
+
+// sameCards reports whether a and b hold the same cards, in any order
+func sameCards(a, b []CardDef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[CardDef]int, len(a))
+	for _, card := range a {
+		counts[card]++
+	}
+	for _, card := range b {
+		counts[card]--
+		if counts[card] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reshuffleDiscardIntoDeck keeps the top discard and shuffles the rest back into the deck
+func reshuffleDiscardIntoDeck(state *FullGameState) error {
+	if len(state.DiscardPile) <= 1 {
+		return nil
+	}
+
+	topIdx := len(state.DiscardPile) - 1
+	reclaimed := make([]CardDef, topIdx)
+	copy(reclaimed, state.DiscardPile[:topIdx])
+	// Vary by turn so repeated reshuffles in a seeded game don't repeat an order
+	if err := shuffleForState(state, reclaimed, int64(state.Round)<<32+int64(state.TurnsPlayed)); err != nil {
+		return err
+	}
+
+	state.Deck = append(state.Deck, reclaimed...)
+	state.DiscardPile = []CardDef{state.DiscardPile[topIdx]}
+	return nil
 }
 
 // randInt returns a cryptographically random integer in range [0, n)
@@ -379,16 +761,58 @@ func randInt(n int) int {
 
 // InitializeGame creates the initial game state when all players have joined
 func (s *GameService) InitializeGame(ctx context.Context, publicID string, playerUserIDs []string) (*FullGameState, error) {
-	if len(playerUserIDs) != 2 {
-		return nil, errors.New("game requires exactly 2 players")
+	return s.initializeGame(ctx, publicID, playerUserIDs, nil)
+}
+
+// InitializeGameWithSeed is InitializeGame with every shuffle derived from seed, so the
+// whole game can be reproduced. Real games should use InitializeGame.
+func (s *GameService) InitializeGameWithSeed(ctx context.Context, publicID string, playerUserIDs []string, seed int64) (*FullGameState, error) {
+	return s.initializeGame(ctx, publicID, playerUserIDs, &seed)
+}
+
+func (s *GameService) initializeGame(ctx context.Context, publicID string, playerUserIDs []string, seed *int64) (*FullGameState, error) {
+	if len(playerUserIDs) < MinPlayers || len(playerUserIDs) > MaxPlayers {
+		return nil, ErrInvalidPlayerCount
+	}
+
+	// Look up the rules chosen when the game was created
+	game, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+	rules := parseGameRules(game.Rules)
+
+	// Create initial game state
+	state := &FullGameState{
+		PublicID:         publicID,
+		Rules:            rules,
+		Version:          1,
+		SchemaVersion:    CurrentStateSchemaVersion,
+		TargetScore:      rules.TargetScore,
+		CumulativeScores: make(map[string]int, len(playerUserIDs)),
+		Seed:             seed,
+	}
+	if err := dealRound(state, playerUserIDs); err != nil {
+		return nil, err
 	}
+	// The first deal is stored whole as the game's initial state, not in the move log
+	state.newDecks = nil
 
-	// Create and shuffle deck
-	deck := createDeck()
+	return state, nil
+}
+
+// dealRound shuffles a fresh deck and deals a new hand to each player, resetting
+// everything about the previous deal except the cumulative scores
+func dealRound(state *FullGameState, playerUserIDs []string) error {
+	// Create and shuffle deck; seeded games get a different but fixed order each deal
+	deck := newDeck(state.Rules.Scoring.JokerCount())
+	if err := shuffleForState(state, deck, int64(state.Round)); err != nil {
+		return err
+	}
 
 	// Deal 6 cards to each player
-	players := make([]PlayerState, 2)
-	for i := 0; i < 2; i++ {
+	players := make([]PlayerState, len(playerUserIDs))
+	for i := range players {
 		var hand [6]CardDef
 		for j := 0; j < 6; j++ {
 			hand[j] = deck[0]
@@ -405,24 +829,18 @@ func (s *GameService) InitializeGame(ctx context.Context, publicID string, playe
 	}
 
 	// Create discard pile with first card from deck
-	discardPile := []CardDef{deck[0]}
-	deck = deck[1:]
-
-	// Create initial game state
-	state := &FullGameState{
-		PublicID:         publicID,
-		Phase:            PhaseInitialFlip,
-		Deck:             deck,
-		DiscardPile:      discardPile,
-		Players:          players,
-		CurrentTurnIdx:   0,
-		DrawnCard:        nil,
-		TriggerPlayerIdx: nil,
-		FinalRoundTurns:  0,
-		Version:          1,
-	}
-
-	return state, nil
+	state.DiscardPile = []CardDef{deck[0]}
+	state.Deck = deck[1:]
+	state.Players = players
+	state.Phase = PhaseInitialFlip
+	state.CurrentTurnIdx = 0
+	state.DrawnCard = nil
+	state.DrawnFromDiscard = false
+	state.TriggerPlayerIdx = nil
+	state.FinalRoundTurns = 0
+	state.TurnsPlayed = 0
+	state.Round++
+	return nil
 }
 
 // findPlayerIndex returns the index of a player by their userID
@@ -453,14 +871,14 @@ func (s *GameService) InitialFlipCard(state *FullGameState, userID string, cardI
 
 	player := &state.Players[playerIdx]
 
-	// Check if card is already face-up
-	if player.FaceUp[cardIndex] {
-		return ErrCardAlreadyFaceUp
-	}
-
 	// Check if player has completed their 2 flips
 	if player.InitialFlips >= 2 {
-		return errors.New("you have already flipped 2 cards")
+		return ErrInitialFlipsDone
+	}
+
+	// Check if card is already face-up, on either flip
+	if player.FaceUp[cardIndex] {
+		return ErrCardAlreadyFaceUp
 	}
 
 	// Validate one from top row (0-2) and one from bottom row (3-5)
@@ -525,10 +943,20 @@ func (s *GameService) DrawFromDeck(state *FullGameState, userID string) error {
 		return ErrNotYourTurn
 	}
 
+	if state.Players[playerIdx].InitialFlips < 2 {
+		return ErrInitialFlipsNeeded
+	}
+
 	if state.DrawnCard != nil {
 		return ErrCardAlreadyDrawn
 	}
 
+	if len(state.Deck) == 0 {
+		if err := reshuffleDiscardIntoDeck(state); err != nil {
+			return err
+		}
+	}
+
 	if len(state.Deck) == 0 {
 		return ErrEmptyDeck
 	}
@@ -536,6 +964,7 @@ func (s *GameService) DrawFromDeck(state *FullGameState, userID string) error {
 	// Draw top card from deck
 	state.DrawnCard = &state.Deck[0]
 	state.Deck = state.Deck[1:]
+	state.DrawnFromDiscard = false
 
 	return nil
 }
@@ -555,6 +984,10 @@ func (s *GameService) DrawFromDiscard(state *FullGameState, userID string) error
 		return ErrNotYourTurn
 	}
 
+	if state.Players[playerIdx].InitialFlips < 2 {
+		return ErrInitialFlipsNeeded
+	}
+
 	if state.DrawnCard != nil {
 		return ErrCardAlreadyDrawn
 	}
@@ -563,10 +996,45 @@ func (s *GameService) DrawFromDiscard(state *FullGameState, userID string) error
 		return ErrEmptyDiscard
 	}
 
+	if state.TurnsPlayed == 0 && state.Rules.ForbidFirstTurnDiscardDraw {
+		return ErrFirstTurnDiscard
+	}
+
 	// Draw top card from discard pile (last element)
 	lastIdx := len(state.DiscardPile) - 1
 	state.DrawnCard = &state.DiscardPile[lastIdx]
 	state.DiscardPile = state.DiscardPile[:lastIdx]
+	state.DrawnFromDiscard = true
+
+	return nil
+}
+
+// CancelDraw puts a card drawn from the deck back on top of it and clears the draw.
+// Cards taken from the discard pile were public, so that draw can't be undone.
+func (s *GameService) CancelDraw(state *FullGameState, userID string) error {
+	if state.Phase != PhaseMainGame && state.Phase != PhaseFinalRound {
+		return ErrInvalidPhase
+	}
+
+	playerIdx, err := findPlayerIndex(state, userID)
+	if err != nil {
+		return err
+	}
+
+	if playerIdx != state.CurrentTurnIdx {
+		return ErrNotYourTurn
+	}
+
+	if state.DrawnCard == nil {
+		return ErrNoDrawnCard
+	}
+
+	if state.DrawnFromDiscard {
+		return ErrCannotCancelDraw
+	}
+
+	state.Deck = append([]CardDef{*state.DrawnCard}, state.Deck...)
+	state.DrawnCard = nil
 
 	return nil
 }
@@ -605,6 +1073,7 @@ func (s *GameService) SwapCard(state *FullGameState, userID string, cardIndex in
 	// Put old card on discard pile
 	state.DiscardPile = append(state.DiscardPile, oldCard)
 	state.DrawnCard = nil
+	state.DrawnFromDiscard = false
 
 	// Check if all cards are face-up
 	player.AllCardsFlipped = checkAllCardsFlipped(player)
@@ -632,6 +1101,10 @@ func (s *GameService) DiscardAndFlip(state *FullGameState, userID string, cardIn
 		return ErrNoDrawnCard
 	}
 
+	if state.DrawnFromDiscard {
+		return ErrCannotDiscardDrawnDiscard
+	}
+
 	// Validate card index
 	if cardIndex < 0 || cardIndex > 5 {
 		return ErrInvalidCardIndex
@@ -647,6 +1120,7 @@ func (s *GameService) DiscardAndFlip(state *FullGameState, userID string, cardIn
 	// Discard the drawn card
 	state.DiscardPile = append(state.DiscardPile, *state.DrawnCard)
 	state.DrawnCard = nil
+	state.DrawnFromDiscard = false
 
 	// Flip the chosen card
 	player.FaceUp[cardIndex] = true
@@ -658,6 +1132,70 @@ func (s *GameService) DiscardAndFlip(state *FullGameState, userID string, cardIn
 	return s.endTurn(state, playerIdx)
 }
 
+// ResolveStuckDraw discards a dangling drawn card and advances the turn.
+// Used to recover games whose current player left mid-turn.
+func (s *GameService) ResolveStuckDraw(state *FullGameState) error {
+	if state.Phase != PhaseMainGame && state.Phase != PhaseFinalRound {
+		return ErrInvalidPhase
+	}
+
+	if state.DrawnCard == nil {
+		return ErrNoDrawnCard
+	}
+
+	state.DiscardPile = append(state.DiscardPile, *state.DrawnCard)
+	state.DrawnCard = nil
+	state.DrawnFromDiscard = false
+
+	return s.endTurn(state, state.CurrentTurnIdx)
+}
+
+// ForceTimeoutMove plays the current player's turn for them when their time runs out:
+// draw from the deck if they haven't drawn, then discard and flip the face-down card
+// chosen by the game's timeout strategy. Returns the user ID of the player who timed out
+// and the card that was flipped (or replaced), or -1 if the drawn card was only discarded.
+func (s *GameService) ForceTimeoutMove(state *FullGameState) (string, int, error) {
+	return s.forceTimeoutMove(state, -1)
+}
+
+// forceTimeoutMove is ForceTimeoutMove, but flips the card at flip instead of asking the
+// strategy when flip isn't -1, so a logged timeout replays the card that was really flipped
+func (s *GameService) forceTimeoutMove(state *FullGameState, flip int) (string, int, error) {
+	if state.Phase != PhaseMainGame && state.Phase != PhaseFinalRound {
+		return "", -1, ErrInvalidPhase
+	}
+
+	player := &state.Players[state.CurrentTurnIdx]
+	userID := player.UserID
+
+	if state.DrawnCard == nil {
+		if err := s.DrawFromDeck(state, userID); err != nil {
+			return "", -1, err
+		}
+	}
+
+	if checkAllCardsFlipped(player) {
+		// Nothing left to flip, so just discard
+		return userID, -1, s.ResolveStuckDraw(state)
+	}
+
+	cardIndex := flip
+	if cardIndex == -1 {
+		strategy, err := TimeoutStrategyFor(state.Rules.TimeoutStrategy)
+		if err != nil {
+			return "", -1, err
+		}
+		cardIndex = strategy.ChooseFlip(player, state.Rules.Scoring)
+	}
+
+	// A card taken from the discard pile can't go straight back, so it replaces
+	// the card that would have been flipped
+	if state.DrawnFromDiscard {
+		return userID, cardIndex, s.SwapCard(state, userID, cardIndex)
+	}
+	return userID, cardIndex, s.DiscardAndFlip(state, userID, cardIndex)
+}
+
 // checkAllCardsFlipped checks if all 6 cards in a player's hand are face-up
 func checkAllCardsFlipped(player *PlayerState) bool {
 	for _, faceUp := range player.FaceUp {
@@ -672,32 +1210,69 @@ func checkAllCardsFlipped(player *PlayerState) bool {
 func (s *GameService) endTurn(state *FullGameState, currentPlayerIdx int) error {
 	player := &state.Players[currentPlayerIdx]
 
-	// Check if this player just flipped all their cards
-	if player.AllCardsFlipped && state.Phase == PhaseMainGame {
-		// Trigger final round
-		state.Phase = PhaseFinalRound
-		state.TriggerPlayerIdx = &currentPlayerIdx
-		// Each other player gets one more turn
-		state.FinalRoundTurns = len(state.Players) - 1
+	triggeredBy := func(idx int) bool {
+		return state.TriggerPlayerIdx != nil && *state.TriggerPlayerIdx == idx
 	}
 
-	// If in final round, decrement turns left BEFORE moving to next player
-	if state.Phase == PhaseFinalRound {
+	switch {
+	case player.AllCardsFlipped && state.Phase == PhaseMainGame:
+		// This player just flipped all their cards. Their turn starts the final
+		// round rather than using up one of its turns.
+		triggerFinalRound(state, currentPlayerIdx)
+
+	case state.Phase == PhaseFinalRound && !triggeredBy(currentPlayerIdx):
+		// Another player has taken their last turn
 		state.FinalRoundTurns--
-		if state.FinalRoundTurns < 0 {
-			// All players have had their final turn
+		if state.FinalRoundTurns <= 0 {
 			state.Phase = PhaseFinished
 		}
 	}
 
 	// Move to next player
 	state.CurrentTurnIdx = (state.CurrentTurnIdx + 1) % len(state.Players)
+	state.TurnsPlayed++
 
 	return nil
 }
 
-// getCardValue returns the point value of a card
-func getCardValue(card CardDef) int {
+// triggerFinalRound starts the final round on behalf of the player at playerIdx
+func triggerFinalRound(state *FullGameState, playerIdx int) {
+	state.Phase = PhaseFinalRound
+	state.TriggerPlayerIdx = &playerIdx
+	// Each other player gets one more turn
+	state.FinalRoundTurns = len(state.Players) - 1
+}
+
+// Knock ends the player's turn and starts the final round without them having
+// flipped every card. Each other player then gets one last turn.
+func (s *GameService) Knock(state *FullGameState, userID string) error {
+	if state.Phase != PhaseMainGame {
+		return ErrInvalidPhase
+	}
+
+	playerIdx, err := findPlayerIndex(state, userID)
+	if err != nil {
+		return err
+	}
+
+	if playerIdx != state.CurrentTurnIdx {
+		return ErrNotYourTurn
+	}
+
+	if state.DrawnCard != nil {
+		return ErrCardAlreadyDrawn
+	}
+
+	triggerFinalRound(state, playerIdx)
+	return s.endTurn(state, playerIdx)
+}
+
+// getCardValue returns the point value of a card under the given scoring rules
+func getCardValue(card CardDef, rules ScoringRules) int {
+	if value, ok := rules.RankValues[card.Rank]; ok {
+		return value
+	}
+
 	switch card.Rank {
 	case "A":
 		return 1
@@ -719,7 +1294,12 @@ func getCardValue(card CardDef) int {
 		return 9
 	case "10":
 		return 10
-	case "J", "Q", "K":
+	case "K":
+		if rules.KingsZero {
+			return 0
+		}
+		return 10
+	case "J", "Q":
 		return 10
 	case "Joker":
 		return -2
@@ -729,8 +1309,9 @@ func getCardValue(card CardDef) int {
 }
 
 // CalculateScore computes a player's score with column matching rules
-func CalculateScore(player *PlayerState) int {
+func CalculateScore(player *PlayerState, rules ScoringRules) int {
 	totalScore := 0
+	var matched [3]bool
 
 	// Check each column (3 columns: 0,3 | 1,4 | 2,5)
 	for col := 0; col < 3; col++ {
@@ -744,15 +1325,27 @@ func CalculateScore(player *PlayerState) int {
 		if player.FaceUp[topIdx] && player.FaceUp[bottomIdx] &&
 			topCard.Rank == bottomCard.Rank {
 			// Matching column - both cards cancel to 0 points
+			matched[col] = true
 			continue
 		}
 
 		// Add points for face-up cards
 		if player.FaceUp[topIdx] {
-			totalScore += getCardValue(topCard)
+			totalScore += getCardValue(topCard, rules)
 		}
 		if player.FaceUp[bottomIdx] {
-			totalScore += getCardValue(bottomCard)
+			totalScore += getCardValue(bottomCard, rules)
+		}
+	}
+
+	// Two adjacent matched columns of the same rank earn the four-of-a-kind bonus.
+	// Each column counts toward at most one block.
+	if rules.FourOfAKind {
+		for col := 0; col < 2; col++ {
+			if matched[col] && matched[col+1] && player.Hand[col].Rank == player.Hand[col+1].Rank {
+				totalScore += rules.FourOfAKindBonus
+				col++
+			}
 		}
 	}
 
@@ -772,46 +1365,123 @@ func flipRemainingCards(state *FullGameState) {
 	}
 }
 
-// FinishGame calculates final scores, determines winner, and updates database
-func (s *GameService) FinishGame(ctx context.Context, state *FullGameState) (string, error) {
+// FinishGame scores a completed deal. In match play, if nobody has reached the
+// target score yet, the deal's scores are banked and a new deal starts: no winners are
+// returned and the state is back in PhaseInitialFlip. Otherwise it returns the winners
+// (more than one means a tie); once the state is saved, pass them to RecordResult.
+func (s *GameService) FinishGame(state *FullGameState) ([]string, error) {
 	if state.Phase != PhaseFinished {
-		return "", errors.New("game is not finished yet")
+		return nil, errors.New("game is not finished yet")
 	}
 
 	// Flip all remaining cards before scoring
 	flipRemainingCards(state)
 
-	// Calculate scores for all players
-	scores := make(map[string]int)
-	lowestScore := int(^uint(0) >> 1) // Max int
-	var winnerUserID string
+	if state.TargetScore > 0 && !targetReached(state) {
+		bankRoundScores(state)
+		playerUserIDs := make([]string, len(state.Players))
+		for i, player := range state.Players {
+			playerUserIDs[i] = player.UserID
+		}
+		if err := dealRound(state, playerUserIDs); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return pickWinners(state, ""), nil
+}
+
+// ResignGame ends the game immediately with userID as a loser. The win goes to the
+// lowest scoring of the remaining players, who may tie. Allowed in any phase before
+// the game is finished. Once the state is saved, pass the winners to RecordResult.
+func (s *GameService) ResignGame(state *FullGameState, userID string) ([]string, error) {
+	if state.Phase == PhaseFinished {
+		return nil, ErrInvalidPhase
+	}
+
+	if _, err := findPlayerIndex(state, userID); err != nil {
+		return nil, err
+	}
+
+	state.Phase = PhaseFinished
+	state.DrawnCard = nil
+	state.DrawnFromDiscard = false
+	flipRemainingCards(state)
+
+	return pickWinners(state, userID), nil
+}
+
+// targetReached reports whether any player's total including the current deal meets the target
+func targetReached(state *FullGameState) bool {
+	for _, score := range GetFinalScores(state) {
+		if score >= state.TargetScore {
+			return true
+		}
+	}
+	return false
+}
 
+// bankRoundScores adds the current deal's scores to the cumulative totals
+func bankRoundScores(state *FullGameState) {
+	if state.CumulativeScores == nil {
+		state.CumulativeScores = make(map[string]int, len(state.Players))
+	}
 	for i := range state.Players {
 		player := &state.Players[i]
-		score := CalculateScore(player)
-		scores[player.UserID] = score
+		state.CumulativeScores[player.UserID] += CalculateScore(player, state.Rules.Scoring)
+	}
+}
 
-		if score < lowestScore {
+// pickWinners totals every player's score (including earlier deals in match play)
+// and returns the user IDs sharing the lowest one, in seating order.
+// excludeUserID (a player who resigned) can't win.
+func pickWinners(state *FullGameState, excludeUserID string) []string {
+	scores := GetFinalScores(state)
+	var winnerUserIDs []string
+	lowestScore := 0
+
+	for _, player := range state.Players {
+		if player.UserID == excludeUserID {
+			continue
+		}
+		score := scores[player.UserID]
+		switch {
+		case len(winnerUserIDs) == 0 || score < lowestScore:
 			lowestScore = score
-			winnerUserID = player.UserID
+			winnerUserIDs = []string{player.UserID}
+		case score == lowestScore:
+			winnerUserIDs = append(winnerUserIDs, player.UserID)
 		}
 	}
 
-	// Update player scores in database
-	for userID, score := range scores {
-		err := s.gameRepo.UpdatePlayerScore(ctx, state.PublicID, userID, score)
-		if err != nil {
-			return "", fmt.Errorf("failed to update player score: %w", err)
-		}
-	}
+	return winnerUserIDs
+}
 
-	// Update game status to finished with winner and timestamp
-	err := s.gameRepo.FinishGame(ctx, state.PublicID, winnerUserID)
-	if err != nil {
-		return "", fmt.Errorf("failed to finish game: %w", err)
-	}
+// RecordResult stores a finished game's final scores and its winner together, so a
+// failure leaves the game untouched. winnerUserIDs comes from FinishGame or ResignGame;
+// a tie is recorded as a draw, with no winner. Call it only once the finished state is saved.
+func (s *GameService) RecordResult(ctx context.Context, state *FullGameState, winnerUserIDs []string) error {
+	publicID := state.PublicID
+	scores := GetFinalScores(state)
+	var winnerUserID *string
+	if len(winnerUserIDs) == 1 {
+		winnerUserID = &winnerUserIDs[0]
+	}
+
+	return s.gameRepo.WithTx(ctx, func(tx database.GameRepository) error {
+		for userID, score := range scores {
+			if err := tx.UpdatePlayerScore(ctx, publicID, userID, score); err != nil {
+				return fmt.Errorf("failed to update player score: %w", err)
+			}
+		}
 
-	return winnerUserID, nil
+		// Update game status to finished with winner (nil for a draw) and timestamp
+		if err := tx.FinishGame(ctx, publicID, winnerUserID); err != nil {
+			return fmt.Errorf("failed to finish game: %w", err)
+		}
+		return nil
+	})
 }
 
 // GetFinalScores returns the scores for all players
@@ -819,11 +1489,91 @@ func GetFinalScores(state *FullGameState) map[string]int {
 	scores := make(map[string]int)
 	for i := range state.Players {
 		player := &state.Players[i]
-		scores[player.UserID] = CalculateScore(player)
+		scores[player.UserID] = state.CumulativeScores[player.UserID] + CalculateScore(player, state.Rules.Scoring)
 	}
 	return scores
 }
 
+// PlayerStanding is a single row of the final results, ordered by score
+type PlayerStanding struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username,omitempty"` // Filled in by callers that know usernames
+	Score    int    `json:"score"`
+	Rank     int    `json:"rank"` // 1 = winner; tied scores share a rank
+}
+
+// GetFinalStandings returns players sorted by score (lowest first).
+// Ties keep seating order and share the same rank (e.g. 1, 1, 3).
+func GetFinalStandings(state *FullGameState) []PlayerStanding {
+	standings := make([]PlayerStanding, 0, len(state.Players))
+	for i := range state.Players {
+		player := &state.Players[i]
+		standings = append(standings, PlayerStanding{
+			UserID: player.UserID,
+			Score:  state.CumulativeScores[player.UserID] + CalculateScore(player, state.Rules.Scoring),
+		})
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score < standings[j].Score
+	})
+
+	for i := range standings {
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+
+	return standings
+}
+
+// AbandonStaleGames ends every in-progress game that has had no move for at least
+// olderThan as of now, with no winner. Returns the public IDs of the games it abandoned.
+func (s *GameService) AbandonStaleGames(ctx context.Context, now time.Time, olderThan time.Duration) ([]string, error) {
+	staleGames, err := s.gameRepo.GetStaleInProgressGames(ctx, now.Add(-olderThan))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stale games: %w", err)
+	}
+
+	var abandoned []string
+	for _, game := range staleGames {
+		if err := s.abandonGame(ctx, game.PublicID); err != nil {
+			// A move that landed since the scan means the game isn't stale after all
+			if !errors.Is(err, database.ErrVersionConflict) {
+				log.Printf("failed to abandon game %s: %v", game.PublicID, err)
+			}
+			continue
+		}
+		abandoned = append(abandoned, game.PublicID)
+	}
+
+	return abandoned, nil
+}
+
+// abandonGame closes out the saved state so no further moves apply, then marks the
+// game abandoned
+func (s *GameService) abandonGame(ctx context.Context, publicID string) error {
+	state, version, err := s.LoadState(ctx, publicID)
+	switch {
+	case err == nil:
+		state.Phase = PhaseFinished
+		state.DrawnCard = nil
+		state.DrawnFromDiscard = false
+		if err := s.SaveState(ctx, state, version); err != nil {
+			return err
+		}
+	case !errors.Is(err, database.ErrStateNotFound):
+		return err
+	}
+
+	if err := s.gameRepo.AbandonGame(ctx, publicID); err != nil {
+		return fmt.Errorf("failed to mark game abandoned: %w", err)
+	}
+	return nil
+}
+
 // CleanupInactiveGames removes games that haven't had any activity for the specified duration
 // Returns the number of games cleaned up and any error encountered
 func (s *GameService) CleanupInactiveGames(ctx context.Context, inactiveDuration time.Duration) (int, error) {
@@ -0,0 +1,51 @@
+package business
+
+import (
+	"context"
+	"golf-card-game/database"
+	"log"
+)
+
+// Game event types for the per-game activity feed
+const (
+	GameEventPlayerJoined = "player_joined"
+	GameEventPlayerLeft   = "player_left"
+	GameEventTurnTaken    = "turn_taken"
+	GameEventRoundStarted = "round_started"
+	GameEventGameFinished = "game_finished"
+)
+
+// GameEventLog records what happens in a game for its activity feed
+type GameEventLog struct {
+	eventRepo database.GameEventRepository
+}
+
+func NewGameEventLog(eventRepo database.GameEventRepository) *GameEventLog {
+	return &GameEventLog{eventRepo: eventRepo}
+}
+
+// Record appends an event to the game's feed. userID may be empty for events that
+// aren't tied to a player. Failures are logged but never returned, so the feed can't
+// interrupt gameplay.
+func (l *GameEventLog) Record(ctx context.Context, publicID, eventType, userID string, metadata map[string]string) {
+	if l == nil || l.eventRepo == nil {
+		return
+	}
+
+	event := &database.GameEvent{
+		EventType: eventType,
+		Metadata:  metadata,
+	}
+	if userID != "" {
+		event.UserID = &userID
+	}
+
+	if err := l.eventRepo.AppendGameEvent(ctx, publicID, event); err != nil {
+		log.Printf("Failed to record game event %s for game %s: %v", eventType, publicID, err)
+	}
+}
+
+// GetEvents returns a game's most recent events, oldest first
+func (l *GameEventLog) GetEvents(ctx context.Context, publicID string, limit int) ([]*database.GameEvent, error) {
+	return l.eventRepo.GetGameEvents(ctx, publicID, limit)
+}
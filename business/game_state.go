@@ -0,0 +1,112 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+)
+
+// CurrentStateSchemaVersion is the FullGameState layout written by this build.
+// Bump it and add a step to upgradeState whenever a stored field changes meaning.
+const CurrentStateSchemaVersion = 2
+
+var ErrUnsupportedStateSchema = errors.New("game state was written by a newer schema version")
+
+// upgradeState migrates a decoded state from an older schema version in place
+func upgradeState(state *FullGameState) error {
+	if state.SchemaVersion > CurrentStateSchemaVersion {
+		return ErrUnsupportedStateSchema
+	}
+
+	// Version 0 predates the schema field; the layout is otherwise identical
+	if state.SchemaVersion == 0 {
+		state.SchemaVersion = 1
+	}
+
+	// Version 1 spent one FinalRoundTurns on the triggering turn itself; version 2
+	// only counts the other players' turns
+	if state.SchemaVersion == 1 {
+		if state.Phase == PhaseFinalRound {
+			state.FinalRoundTurns++
+		}
+		state.SchemaVersion = 2
+	}
+
+	return nil
+}
+
+// LoadState returns the persisted state for a game along with its row version.
+// Returns database.ErrStateNotFound if the game has not been dealt yet.
+func (s *GameService) LoadState(ctx context.Context, publicID string) (*FullGameState, int, error) {
+	stateJSON, version, err := s.gameRepo.LoadGameState(ctx, publicID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var state FullGameState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse game state: %w", err)
+	}
+	if err := upgradeState(&state); err != nil {
+		return nil, 0, err
+	}
+	state.PublicID = publicID // Ensure PublicID is set
+	state.Version = version   // The row version is authoritative
+
+	return &state, version, nil
+}
+
+// LoadOrInitState loads the persisted state, dealing and saving a new one if none exists.
+// created is true only for the caller whose deal was saved; a caller that loses a race
+// to deal gets the winner's state instead.
+func (s *GameService) LoadOrInitState(ctx context.Context, publicID string) (state *FullGameState, created bool, err error) {
+	state, _, err = s.LoadState(ctx, publicID)
+	if !errors.Is(err, database.ErrStateNotFound) {
+		return state, false, err
+	}
+
+	activePlayers, err := s.gameRepo.GetActivePlayerIDs(ctx, publicID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get active players: %w", err)
+	}
+
+	state, err = s.InitializeGame(ctx, publicID, activePlayers)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal game state: %w", err)
+	}
+	if err := s.gameRepo.SaveGameState(ctx, publicID, stateJSON); err != nil {
+		if errors.Is(err, database.ErrStateExists) {
+			state, _, err = s.LoadState(ctx, publicID)
+			return state, false, err
+		}
+		return nil, false, fmt.Errorf("failed to save initial state: %w", err)
+	}
+
+	return state, true, nil
+}
+
+// SaveState persists a mutated state if it is still at expectedVersion and bumps
+// state.Version to match. Returns database.ErrVersionConflict if another writer got there first.
+func (s *GameService) SaveState(ctx context.Context, state *FullGameState, expectedVersion int) error {
+	state.SchemaVersion = CurrentStateSchemaVersion
+	state.Version = expectedVersion + 1
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		state.Version = expectedVersion
+		return fmt.Errorf("failed to marshal game state: %w", err)
+	}
+
+	if err := s.gameRepo.UpdateGameState(ctx, state.PublicID, stateJSON, expectedVersion); err != nil {
+		state.Version = expectedVersion
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"testing"
+)
+
+func TestSaveStateRejectsAStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob")
+	if _, _, err := s.LoadOrInitState(ctx, "game"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, version, err := s.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := s.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveState(ctx, first, version); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	if first.Version != version+1 {
+		t.Fatalf("saved state has version %d, want %d", first.Version, version+1)
+	}
+
+	err = s.SaveState(ctx, second, version)
+	if !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+	if second.Version != version {
+		t.Fatalf("rejected state's version moved to %d", second.Version)
+	}
+}
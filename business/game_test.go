@@ -0,0 +1,948 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newWaitingGame creates a two-seat game that alice created and bob has accepted
+func newWaitingGame(repo *fakeGameRepo) {
+	game := repo.addGame("game", "waiting_for_players", GameRules{}, "alice", "bob")
+	game.MaxPlayers = 2
+}
+
+func TestLeaveGameReopensTheSeat(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, newFakeUserRepo("alice", "bob", "carol"))
+	newWaitingGame(repo)
+
+	if err := s.InvitePlayer(ctx, "game", "carol", "alice"); !errors.Is(err, ErrGameFull) {
+		t.Fatalf("inviting into a full game: err = %v, want ErrGameFull", err)
+	}
+
+	if err := s.LeaveGame(ctx, "game", "bob"); err != nil {
+		t.Fatalf("LeaveGame: %v", err)
+	}
+
+	if err := s.InvitePlayer(ctx, "game", "carol", "alice"); err != nil {
+		t.Fatalf("inviting into the freed seat: %v", err)
+	}
+	players, err := repo.GetGamePlayers(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 2 || players[1].UserID != "carol" {
+		t.Fatalf("got %d players, want alice and carol", len(players))
+	}
+}
+
+func TestLeaveGameIsRejectedOnceInProgress(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	newWaitingGame(repo)
+	if err := repo.UpdateGameStatus(ctx, "game", "in_progress"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.LeaveGame(ctx, "game", "bob"); !errors.Is(err, ErrInvalidGameStatus) {
+		t.Fatalf("err = %v, want ErrInvalidGameStatus", err)
+	}
+	players, err := repo.GetGamePlayers(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 2 || !players[1].IsActive {
+		t.Fatalf("players = %+v, want bob still seated", players)
+	}
+}
+
+func TestLeaveGameIsRejectedForTheCreator(t *testing.T) {
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	newWaitingGame(repo)
+
+	if err := s.LeaveGame(context.Background(), "game", "alice"); !errors.Is(err, ErrCreatorCannotLeave) {
+		t.Fatalf("err = %v, want ErrCreatorCannotLeave", err)
+	}
+}
+
+func TestGetCompletedGamesPagesWithoutGapsOrDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+
+	// Several games share each finish time, to the microsecond the cursor keeps
+	const games = 103
+	base := time.Date(2026, 1, 1, 12, 0, 0, 123456000, time.UTC)
+	for i := 0; i < games; i++ {
+		game := repo.addGame(fmt.Sprintf("game-%d", i), "finished", GameRules{}, "alice", "bob")
+		finishedAt := base.Add(time.Duration(i/4) * time.Minute)
+		game.FinishedAt = &finishedAt
+	}
+	repo.addGame("live", "in_progress", GameRules{}, "alice", "bob")
+
+	seen := make(map[int]bool)
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > games {
+			t.Fatal("paging did not end")
+		}
+		batch, next, err := s.GetCompletedGames(ctx, "alice", cursor, 10)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		for _, game := range batch {
+			if seen[game.GameID] {
+				t.Fatalf("game %d returned twice", game.GameID)
+			}
+			seen[game.GameID] = true
+		}
+		if next == "" {
+			if len(batch) == 0 || len(batch) > 10 {
+				t.Fatalf("last page has %d games", len(batch))
+			}
+			break
+		}
+		if len(batch) != 10 {
+			t.Fatalf("page %d has %d games but isn't the last", page, len(batch))
+		}
+		cursor = next
+	}
+
+	if len(seen) != games {
+		t.Fatalf("paged through %d games, want %d", len(seen), games)
+	}
+}
+
+func TestGetCompletedGamesRejectsABadCursor(t *testing.T) {
+	s := NewGameService(newFakeGameRepo(), nil)
+	for _, cursor := range []string{"not base64!", "bm9wZQ"} {
+		if _, _, err := s.GetCompletedGames(context.Background(), "alice", cursor, 10); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestGameHistoryTellsResultsApart(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+
+	finished := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	finish := func(publicID, status string, winner *string, scores ...int) {
+		game := repo.addGame(publicID, status, GameRules{}, "alice", "bob")
+		finishedAt := finished.Add(time.Duration(game.GameID) * time.Minute)
+		game.FinishedAt = &finishedAt
+		game.WinnerUserID = winner
+		for i, score := range scores {
+			repo.players[publicID][i].Score = &score
+		}
+	}
+	alice, bob := "alice", "bob"
+	finish("won", "finished", &alice, 5, 12)
+	finish("lost", "finished", &bob, 14, 3)
+	finish("draw", "finished", nil, 8, 8)
+	finish("abandoned", "abandoned", nil)
+
+	history, next, err := s.GetGameHistory(ctx, "alice", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "" {
+		t.Fatalf("one page of history came with a next cursor")
+	}
+
+	// Newest first
+	want := []struct {
+		publicID, result string
+		aliceScore       *int
+	}{
+		{"abandoned", GameResultAbandoned, nil},
+		{"draw", GameResultDraw, intPtr(8)},
+		{"lost", GameResultLost, intPtr(14)},
+		{"won", GameResultWon, intPtr(5)},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("got %d games, want %d", len(history), len(want))
+	}
+	for i, entry := range history {
+		if entry.PublicID != want[i].publicID || entry.Result != want[i].result {
+			t.Errorf("entry %d = %s %s, want %s %s", i, entry.PublicID, entry.Result, want[i].publicID, want[i].result)
+		}
+		if len(entry.Players) != 2 || entry.Players[0].UserID != "alice" {
+			t.Fatalf("entry %d players = %+v", i, entry.Players)
+		}
+		if got := entry.Players[0].Score; (got == nil) != (want[i].aliceScore == nil) ||
+			got != nil && *got != *want[i].aliceScore {
+			t.Errorf("entry %d: alice's score = %v, want %v", i, got, want[i].aliceScore)
+		}
+	}
+}
+
+func TestInvitePlayerRejections(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, newFakeUserRepo("alice", "bob", "carol", "dave", "erin"))
+	repo.addGame("game", "waiting_for_players", GameRules{}, "alice", "dave", "bob")
+	repo.players["game"][2].IsActive = false // bob is still only invited
+	repo.addGame("started", "in_progress", GameRules{}, "alice", "dave")
+	repo.addGame("full", "waiting_for_players", GameRules{}, "alice", "dave").MaxPlayers = 2
+
+	for _, tc := range []struct {
+		name             string
+		publicID         string
+		invited, inviter string
+		want             error // nil only checks that the invite fails
+	}{
+		{"inviting yourself", "game", "alice", "alice", ErrCannotInviteSelf},
+		{"unknown user", "game", "nobody", "alice", database.ErrUserNotFound},
+		{"unknown game", "nowhere", "carol", "alice", ErrGameNotFound},
+		{"game already started", "started", "carol", "alice", ErrInvalidGameStatus},
+		{"game full", "full", "carol", "alice", ErrGameFull},
+		{"already playing", "game", "dave", "alice", ErrAlreadyInGame},
+		{"already invited", "game", "bob", "alice", ErrAlreadyInvited},
+		{"inviter only invited", "game", "carol", "bob", nil},
+		{"inviter not in the game", "game", "carol", "erin", nil},
+	} {
+		err := s.InvitePlayer(ctx, tc.publicID, tc.invited, tc.inviter)
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	for publicID, want := range map[string]int{"game": 3, "started": 2, "full": 2} {
+		if got := len(repo.players[publicID]); got != want {
+			t.Errorf("%s has %d players after rejected invites, want %d", publicID, got, want)
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// faceUpPlayer returns a player holding ranks, top row first, with every card face up
+func faceUpPlayer(userID string, ranks ...string) PlayerState {
+	player := PlayerState{UserID: userID, InitialFlips: 2, AllCardsFlipped: true}
+	for i, rank := range ranks {
+		player.Hand[i] = CardDef{Suit: "hearts", Rank: rank}
+		player.FaceUp[i] = true
+	}
+	return player
+}
+
+// failingTx fails the failAt-th write made inside a transaction, counting from 1
+type failingTx struct {
+	*fakeGameRepo
+
+	failAt int
+	writes int
+}
+
+func (tx *failingTx) write() error {
+	tx.writes++
+	if tx.writes == tx.failAt {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (tx *failingTx) UpdatePlayerScore(ctx context.Context, publicID string, userID string, score int) error {
+	if err := tx.write(); err != nil {
+		return err
+	}
+	return tx.fakeGameRepo.UpdatePlayerScore(ctx, publicID, userID, score)
+}
+
+func (tx *failingTx) FinishGame(ctx context.Context, publicID string, winnerUserID *string) error {
+	if err := tx.write(); err != nil {
+		return err
+	}
+	return tx.fakeGameRepo.FinishGame(ctx, publicID, winnerUserID)
+}
+
+func (tx *failingTx) WithTx(ctx context.Context, fn func(tx database.GameRepository) error) error {
+	return tx.fakeGameRepo.WithTx(ctx, func(database.GameRepository) error { return fn(tx) })
+}
+
+// dealCountingRepo counts the initial states that were saved
+type dealCountingRepo struct {
+	*fakeGameRepo
+	deals atomic.Int32
+}
+
+func (r *dealCountingRepo) SaveGameState(ctx context.Context, publicID string, stateJSON []byte) error {
+	err := r.fakeGameRepo.SaveGameState(ctx, publicID, stateJSON)
+	if err == nil {
+		r.deals.Add(1)
+	}
+	return err
+}
+
+func TestLastSeatsAcceptedAtOnceDealOnce(t *testing.T) {
+	ctx := context.Background()
+	for round := 0; round < 50; round++ {
+		repo := &dealCountingRepo{fakeGameRepo: newFakeGameRepo()}
+		s := NewGameService(repo, nil)
+		repo.addGame("game", "waiting_for_players", GameRules{}, "alice", "bob", "carol").MaxPlayers = 3
+		repo.players["game"][1].IsActive = false
+		repo.players["game"][2].IsActive = false
+
+		// Only an accept that reports started announces game_started, so one must
+		var starts atomic.Int32
+		var accepts sync.WaitGroup
+		ready := make(chan struct{})
+		for _, userID := range []string{"bob", "carol"} {
+			accepts.Add(1)
+			go func() {
+				defer accepts.Done()
+				<-ready
+				started, err := s.AcceptInvitation(ctx, "game", userID)
+				if err != nil {
+					t.Errorf("round %d: %s accepting: %v", round, userID, err)
+				}
+				if started {
+					starts.Add(1)
+				}
+			}()
+		}
+		close(ready)
+		accepts.Wait()
+
+		if n := starts.Load(); n != 1 {
+			t.Fatalf("round %d: %d accepts reported starting the game, want 1", round, n)
+		}
+		if n := repo.deals.Load(); n != 1 {
+			t.Fatalf("round %d: dealt %d times, want once", round, n)
+		}
+		state, _, err := s.LoadState(ctx, "game")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Players) != 3 {
+			t.Fatalf("round %d: dealt %d players in, want all 3", round, len(state.Players))
+		}
+	}
+}
+
+func TestRecordResultRollsBackAPartialWrite(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob")
+	state := &FullGameState{
+		PublicID: "game",
+		Phase:    PhaseFinished,
+		Players: []PlayerState{
+			faceUpPlayer("alice", "A", "2", "3", "4", "5", "6"),
+			faceUpPlayer("bob", "7", "8", "9", "10", "J", "Q"),
+		},
+	}
+
+	// The first score is written, the second fails
+	tx := &failingTx{fakeGameRepo: repo, failAt: 2}
+	if err := NewGameService(tx, nil).RecordResult(ctx, state, []string{"alice"}); err == nil {
+		t.Fatal("RecordResult succeeded although a write failed")
+	}
+	game, err := repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "in_progress" || game.WinnerUserID != nil {
+		t.Fatalf("game = %s won by %v, want it untouched", game.Status, game.WinnerUserID)
+	}
+	players, err := repo.GetGamePlayers(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, player := range players {
+		if player.Score != nil {
+			t.Errorf("%s's score %d outlived the rollback", player.UserID, *player.Score)
+		}
+	}
+
+	// Nothing fails the second time, and everything is written
+	tx = &failingTx{fakeGameRepo: repo}
+	if err := NewGameService(tx, nil).RecordResult(ctx, state, []string{"alice"}); err != nil {
+		t.Fatalf("RecordResult: %v", err)
+	}
+	if tx.writes != 3 {
+		t.Fatalf("%d writes, want two scores and the result", tx.writes)
+	}
+	game, err = repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "finished" || game.WinnerUserID == nil || *game.WinnerUserID != "alice" {
+		t.Fatalf("game = %s won by %v, want finished and won by alice", game.Status, game.WinnerUserID)
+	}
+}
+
+// mainGameState returns a deal in the main game with players seated in order, the
+// first one to move, a deck of aces and a seven on the discard pile
+func mainGameState(players ...PlayerState) *FullGameState {
+	state := &FullGameState{
+		PublicID:      "game",
+		Phase:         PhaseMainGame,
+		Players:       players,
+		DiscardPile:   []CardDef{{Suit: "clubs", Rank: "7"}},
+		Version:       1,
+		SchemaVersion: CurrentStateSchemaVersion,
+		Round:         1,
+		TurnsPlayed:   1,
+	}
+	for i := 0; i < 10; i++ {
+		state.Deck = append(state.Deck, CardDef{Suit: "spades", Rank: "A"})
+	}
+	return state
+}
+
+// faceDown turns the cards at indices face down again
+func faceDown(player PlayerState, indices ...int) PlayerState {
+	for _, i := range indices {
+		player.FaceUp[i] = false
+	}
+	player.AllCardsFlipped = checkAllCardsFlipped(&player)
+	return player
+}
+
+// playTurn has userID draw from the deck and discard it, flipping their first
+// face-down card, or swap it for their first card once every card is up
+func playTurn(t *testing.T, s *GameService, state *FullGameState, userID string) {
+	t.Helper()
+
+	if err := s.DrawFromDeck(state, userID); err != nil {
+		t.Fatalf("%s draws: %v", userID, err)
+	}
+	player := &state.Players[state.CurrentTurnIdx]
+	for i, up := range player.FaceUp {
+		if !up {
+			if err := s.DiscardAndFlip(state, userID, i); err != nil {
+				t.Fatalf("%s flips %d: %v", userID, i, err)
+			}
+			return
+		}
+	}
+	if err := s.SwapCard(state, userID, 0); err != nil {
+		t.Fatalf("%s swaps: %v", userID, err)
+	}
+}
+
+func TestFinalRoundGivesEveryOtherPlayerOneTurn(t *testing.T) {
+	ctx := context.Background()
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+
+	for _, tc := range []struct {
+		name    string
+		storeAs func(state *FullGameState)
+	}{
+		{"current schema", func(state *FullGameState) {}},
+		{"schema 1", func(state *FullGameState) {
+			// Version 1 had already spent a turn on bob's own
+			state.SchemaVersion = 1
+			state.FinalRoundTurns--
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newFakeGameRepo()
+			repo.addGame("game", "in_progress", GameRules{}, "alice", "bob", "carol")
+			s := NewGameService(repo, nil)
+
+			state := mainGameState(
+				faceDown(faceUpPlayer("alice", hand...), 4, 5),
+				faceDown(faceUpPlayer("bob", hand...), 5),
+				faceDown(faceUpPlayer("carol", hand...), 4, 5),
+			)
+			state.CurrentTurnIdx = 1
+
+			// Bob turns up his last card
+			playTurn(t, s, state, "bob")
+			if state.Phase != PhaseFinalRound || *state.TriggerPlayerIdx != 1 {
+				t.Fatalf("after bob: phase %s, trigger %v", state.Phase, state.TriggerPlayerIdx)
+			}
+
+			tc.storeAs(state)
+			stateJSON, err := json.Marshal(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.SaveGameState(ctx, "game", stateJSON); err != nil {
+				t.Fatal(err)
+			}
+			state, _, err = s.LoadState(ctx, "game")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.FinalRoundTurns != 2 {
+				t.Fatalf("loaded with %d final round turns, want 2", state.FinalRoundTurns)
+			}
+
+			for _, userID := range []string{"carol", "alice"} {
+				if state.Phase != PhaseFinalRound {
+					t.Fatalf("game ended before %s's last turn", userID)
+				}
+				if current := state.Players[state.CurrentTurnIdx].UserID; current != userID {
+					t.Fatalf("%s moves, want %s", current, userID)
+				}
+				playTurn(t, s, state, userID)
+			}
+			if state.Phase != PhaseFinished {
+				t.Fatalf("phase = %s after everyone's last turn, want finished", state.Phase)
+			}
+		})
+	}
+}
+
+func TestATieIsRecordedAsADraw(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob", "carol")
+	s := NewGameService(repo, nil)
+
+	state := mainGameState(
+		faceUpPlayer("alice", "A", "2", "3", "4", "5", "6"),
+		faceUpPlayer("bob", "6", "5", "4", "3", "2", "A"),
+		faceUpPlayer("carol", "7", "8", "9", "10", "J", "Q"),
+	)
+	state.Phase = PhaseFinished
+
+	winners, err := s.FinishGame(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(winners) != 2 || winners[0] != "alice" || winners[1] != "bob" {
+		t.Fatalf("winners = %v, want alice and bob", winners)
+	}
+
+	if err := s.RecordResult(ctx, state, winners); err != nil {
+		t.Fatal(err)
+	}
+	game, err := repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "finished" || game.WinnerUserID != nil {
+		t.Fatalf("game = %s won by %v, want a finished draw", game.Status, game.WinnerUserID)
+	}
+	players, err := repo.GetGamePlayers(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, player := range players {
+		if player.Score == nil {
+			t.Errorf("%s has no score", player.UserID)
+		}
+	}
+}
+
+func TestFirstTurnDiscardDrawRule(t *testing.T) {
+	s := NewGameService(nil, nil)
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+	newDeal := func(forbid bool) *FullGameState {
+		state := mainGameState(faceDown(faceUpPlayer("alice", hand...), 1, 2, 4, 5), faceDown(faceUpPlayer("bob", hand...), 1, 2, 4, 5))
+		state.TurnsPlayed = 0
+		state.Rules.ForbidFirstTurnDiscardDraw = forbid
+		return state
+	}
+
+	state := newDeal(false)
+	if err := s.DrawFromDiscard(state, "alice"); err != nil {
+		t.Fatalf("without the rule: %v", err)
+	}
+
+	state = newDeal(true)
+	if err := s.DrawFromDiscard(state, "alice"); !errors.Is(err, ErrFirstTurnDiscard) {
+		t.Fatalf("first turn: err = %v, want ErrFirstTurnDiscard", err)
+	}
+	if state.DrawnCard != nil || len(state.DiscardPile) != 1 {
+		t.Fatal("the rejected draw took the discard")
+	}
+
+	// Alice draws from the deck instead, and after her turn the discard is fair game
+	playTurn(t, s, state, "alice")
+	if err := s.DrawFromDiscard(state, "bob"); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+}
+
+func TestEmptyDeckIsRefilledFromTheDiscardPile(t *testing.T) {
+	s := NewGameService(nil, nil)
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+	state := mainGameState(faceDown(faceUpPlayer("alice", hand...), 2, 4, 5), faceDown(faceUpPlayer("bob", hand...), 2, 4, 5))
+	state.Deck = []CardDef{{Suit: "hearts", Rank: "9"}, {Suit: "hearts", Rank: "10"}}
+
+	// Two turns drain the deck onto the discard pile
+	playTurn(t, s, state, "alice")
+	playTurn(t, s, state, "bob")
+	if len(state.Deck) != 0 {
+		t.Fatalf("deck still holds %d cards", len(state.Deck))
+	}
+	pile := append([]CardDef(nil), state.DiscardPile...)
+	top := pile[len(pile)-1]
+
+	if err := s.DrawFromDeck(state, "alice"); err != nil {
+		t.Fatalf("drawing from the empty deck: %v", err)
+	}
+	if len(state.DiscardPile) != 1 || state.DiscardPile[0] != top {
+		t.Fatalf("discard pile = %v, want just its old top %v", state.DiscardPile, top)
+	}
+	if drawn := append([]CardDef{*state.DrawnCard}, state.Deck...); !sameCards(drawn, pile[:len(pile)-1]) {
+		t.Fatalf("new deck and draw %v aren't the old discard pile %v under its top", drawn, pile[:len(pile)-1])
+	}
+	if len(state.newDecks) != 1 {
+		t.Fatalf("%d decks recorded for the move log, want the reshuffle", len(state.newDecks))
+	}
+}
+
+func TestDrawingWithNothingToReshuffleFails(t *testing.T) {
+	s := NewGameService(nil, nil)
+	state := mainGameState(faceUpPlayer("alice", "2", "3", "4", "5", "6", "8"), faceUpPlayer("bob", "2", "3", "4", "5", "6", "8"))
+	state.Deck = nil
+
+	if err := s.DrawFromDeck(state, "alice"); !errors.Is(err, ErrEmptyDeck) {
+		t.Fatalf("err = %v, want ErrEmptyDeck", err)
+	}
+	if len(state.DiscardPile) != 1 {
+		t.Fatal("the discard pile's only card was taken")
+	}
+}
+
+func TestFourOfAKindNeedsAdjacentColumns(t *testing.T) {
+	bonus := ScoringRules{FourOfAKind: true, FourOfAKindBonus: -10}
+	for _, tc := range []struct {
+		name  string
+		ranks []string // top row, then bottom row
+		rules ScoringRules
+		want  int
+	}{
+		{"adjacent columns", []string{"5", "5", "9", "5", "5", "2"}, bonus, 1},
+		{"adjacent columns, rule off", []string{"5", "5", "9", "5", "5", "2"}, ScoringRules{}, 11},
+		{"adjacent columns, no bonus", []string{"5", "5", "9", "5", "5", "2"}, ScoringRules{FourOfAKind: true}, 11},
+		{"outer columns", []string{"5", "9", "5", "5", "2", "5"}, bonus, 11},
+		{"adjacent columns of two ranks", []string{"5", "6", "9", "5", "6", "2"}, bonus, 11},
+		{"three columns make one block", []string{"5", "5", "5", "5", "5", "5"}, bonus, -10},
+	} {
+		player := faceUpPlayer("alice", tc.ranks...)
+		if got := CalculateScore(&player, tc.rules); got != tc.want {
+			t.Errorf("%s: score = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestKnockStartsTheFinalRound(t *testing.T) {
+	s := NewGameService(nil, nil)
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+	state := mainGameState(faceDown(faceUpPlayer("alice", hand...), 2, 5), faceDown(faceUpPlayer("bob", hand...), 2, 5))
+
+	if err := s.Knock(state, "bob"); !errors.Is(err, ErrNotYourTurn) {
+		t.Fatalf("bob knocking out of turn: err = %v, want ErrNotYourTurn", err)
+	}
+	if err := s.DrawFromDeck(state, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Knock(state, "alice"); !errors.Is(err, ErrCardAlreadyDrawn) {
+		t.Fatalf("knocking with a drawn card: err = %v, want ErrCardAlreadyDrawn", err)
+	}
+	if err := s.CancelDraw(state, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Knock(state, "alice"); err != nil {
+		t.Fatalf("Knock: %v", err)
+	}
+	if state.Phase != PhaseFinalRound || state.TriggerPlayerIdx == nil || *state.TriggerPlayerIdx != 0 {
+		t.Fatalf("after knocking: phase %s, trigger %v", state.Phase, state.TriggerPlayerIdx)
+	}
+	if state.CurrentTurnIdx != 1 || state.FinalRoundTurns != 1 {
+		t.Fatalf("turn %d with %d final turns left, want bob's last turn", state.CurrentTurnIdx, state.FinalRoundTurns)
+	}
+	if state.Players[0].FaceUp[2] || state.Players[0].FaceUp[5] {
+		t.Fatal("knocking turned up alice's cards")
+	}
+
+	playTurn(t, s, state, "bob")
+	if state.Phase != PhaseFinished {
+		t.Fatalf("phase = %s after bob's last turn, want finished", state.Phase)
+	}
+}
+
+func TestCancelDraw(t *testing.T) {
+	s := NewGameService(nil, nil)
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+	newDeal := func() *FullGameState {
+		state := mainGameState(faceDown(faceUpPlayer("alice", hand...), 2, 5), faceDown(faceUpPlayer("bob", hand...), 2, 5))
+		state.Deck[0] = CardDef{Suit: "hearts", Rank: "Q"}
+		return state
+	}
+
+	t.Run("from the deck", func(t *testing.T) {
+		state := newDeal()
+		deck := append([]CardDef(nil), state.Deck...)
+		if err := s.DrawFromDeck(state, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CancelDraw(state, "bob"); !errors.Is(err, ErrNotYourTurn) {
+			t.Fatalf("bob cancelling alice's draw: err = %v, want ErrNotYourTurn", err)
+		}
+
+		if err := s.CancelDraw(state, "alice"); err != nil {
+			t.Fatalf("CancelDraw: %v", err)
+		}
+		if state.DrawnCard != nil || state.CurrentTurnIdx != 0 {
+			t.Fatalf("drawn card %v, turn %d: want no draw and still alice's turn", state.DrawnCard, state.CurrentTurnIdx)
+		}
+		if !reflect.DeepEqual(state.Deck, deck) {
+			t.Fatal("the queen didn't go back on top of the deck")
+		}
+		if err := s.CancelDraw(state, "alice"); !errors.Is(err, ErrNoDrawnCard) {
+			t.Fatalf("cancelling twice: err = %v, want ErrNoDrawnCard", err)
+		}
+	})
+
+	t.Run("from the discard pile", func(t *testing.T) {
+		state := newDeal()
+		if err := s.DrawFromDiscard(state, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CancelDraw(state, "alice"); !errors.Is(err, ErrCannotCancelDraw) {
+			t.Fatalf("err = %v, want ErrCannotCancelDraw", err)
+		}
+		if state.DrawnCard == nil || len(state.DiscardPile) != 0 || len(state.Deck) != 10 {
+			t.Fatal("the refused cancel moved cards")
+		}
+	})
+}
+
+func TestCardFromTheDiscardPileCannotBeDiscarded(t *testing.T) {
+	s := NewGameService(nil, nil)
+	hand := []string{"2", "3", "4", "5", "6", "8"}
+	state := mainGameState(faceDown(faceUpPlayer("alice", hand...), 2, 5), faceDown(faceUpPlayer("bob", hand...), 2, 5))
+
+	if err := s.DrawFromDiscard(state, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DiscardAndFlip(state, "alice", 2); !errors.Is(err, ErrCannotDiscardDrawnDiscard) {
+		t.Fatalf("err = %v, want ErrCannotDiscardDrawnDiscard", err)
+	}
+	if state.DrawnCard == nil || state.Players[0].FaceUp[2] || state.CurrentTurnIdx != 0 {
+		t.Fatal("the refused discard changed the turn")
+	}
+
+	// Swapping it in is still allowed
+	if err := s.SwapCard(state, "alice", 2); err != nil {
+		t.Fatalf("SwapCard: %v", err)
+	}
+	if state.Players[0].Hand[2].Rank != "7" || state.DiscardPile[0].Rank != "4" {
+		t.Fatalf("hand card %v, discard %v after swapping", state.Players[0].Hand[2], state.DiscardPile)
+	}
+}
+
+func TestFinalStandingsShareRanksOnEqualScores(t *testing.T) {
+	state := mainGameState(
+		faceUpPlayer("alice", "9", "9", "9", "8", "8", "8"), // 51
+		faceUpPlayer("bob", "A", "2", "3", "4", "5", "6"),   // 21
+		faceUpPlayer("carol", "6", "5", "4", "3", "2", "A"), // 21
+		faceUpPlayer("dave", "2", "2", "3", "2", "2", "4"),  // 7
+	)
+	state.Phase = PhaseFinished
+
+	want := []PlayerStanding{
+		{UserID: "dave", Score: 7, Rank: 1},
+		{UserID: "bob", Score: 21, Rank: 2},
+		{UserID: "carol", Score: 21, Rank: 2},
+		{UserID: "alice", Score: 51, Rank: 4},
+	}
+	// The same every time, with tied players in seating order
+	for i := 0; i < 10; i++ {
+		if got := GetFinalStandings(state); !reflect.DeepEqual(got, want) {
+			t.Fatalf("standings = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestAbandonStaleGamesLeavesFreshAndFinishedGames(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+
+	for _, game := range []struct {
+		publicID, status string
+		lastMove         time.Duration // before now
+	}{
+		{"stale", "in_progress", 25 * time.Hour},
+		{"fresh", "in_progress", time.Hour},
+		{"finished", "finished", 48 * time.Hour},
+	} {
+		repo.addGame(game.publicID, game.status, GameRules{}, "alice", "bob")
+		repo.lastMove[game.publicID] = now.Add(-game.lastMove)
+	}
+	// Never dealt, so it counts from when it was created
+	repo.addGame("undealt", "in_progress", GameRules{}, "alice", "bob").CreatedAt = now.Add(-30 * time.Hour)
+
+	state := mainGameState(faceUpPlayer("alice", "2"), faceUpPlayer("bob", "3"))
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveGameState(ctx, "stale", stateJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	abandoned, err := s.AbandonStaleGames(ctx, now, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(abandoned)
+	if want := []string{"stale", "undealt"}; !reflect.DeepEqual(abandoned, want) {
+		t.Fatalf("abandoned %v, want %v", abandoned, want)
+	}
+
+	for publicID, want := range map[string]string{
+		"stale":    "abandoned",
+		"undealt":  "abandoned",
+		"fresh":    "in_progress",
+		"finished": "finished",
+	} {
+		if got := repo.games[publicID].Status; got != want {
+			t.Errorf("%s status = %s, want %s", publicID, got, want)
+		}
+	}
+	state, _, err = s.LoadState(ctx, "stale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Phase != PhaseFinished {
+		t.Errorf("abandoned game's state is in phase %s, want finished", state.Phase)
+	}
+}
+
+func TestFourPlayersTakeTurnsInSeatOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	userIDs := []string{"alice", "bob", "carol", "dave"}
+	repo.addGame("game", "in_progress", GameRules{}, userIDs...)
+
+	if _, err := s.InitializeGame(ctx, "game", append(userIDs, "erin")); !errors.Is(err, ErrInvalidPlayerCount) {
+		t.Fatalf("five players: err = %v, want ErrInvalidPlayerCount", err)
+	}
+
+	state, err := s.InitializeGame(ctx, "game", userIDs)
+	if err != nil {
+		t.Fatalf("InitializeGame: %v", err)
+	}
+	if want := 54 - 4*6 - 1; len(state.Deck) != want {
+		t.Fatalf("deck has %d cards after the deal, want %d", len(state.Deck), want)
+	}
+	for _, userID := range userIDs {
+		for _, i := range []int{0, 3} {
+			if err := s.InitialFlipCard(state, userID, i); err != nil {
+				t.Fatalf("%s flips %d: %v", userID, i, err)
+			}
+		}
+	}
+	if state.Phase != PhaseMainGame {
+		t.Fatalf("phase = %s after the initial flips, want main game", state.Phase)
+	}
+
+	first := state.CurrentTurnIdx
+	for turn := 0; turn < 2*len(userIDs); turn++ {
+		want := userIDs[(first+turn)%len(userIDs)]
+		if current := state.Players[state.CurrentTurnIdx].UserID; current != want {
+			t.Fatalf("turn %d: %s moves, want %s", turn, current, want)
+		}
+		playTurn(t, s, state, want)
+	}
+}
+
+// newlyDealt returns a player holding ranks, top row first, all face down
+func newlyDealt(userID string, ranks ...string) PlayerState {
+	player := faceDown(faceUpPlayer(userID, ranks...), 0, 1, 2, 3, 4, 5)
+	player.InitialFlips = 0
+	return player
+}
+
+func TestInitialFlipRejections(t *testing.T) {
+	s := NewGameService(newFakeGameRepo(), nil)
+
+	for _, tc := range []struct {
+		name    string
+		phase   GamePhase
+		faceUp  []int // alice's cards already face up without counting as flips
+		flipped []int // alice's earlier initial flips
+		flip    int
+		want    error
+	}{
+		{"outside the initial flip", PhaseMainGame, nil, nil, 0, ErrInvalidPhase},
+		{"index below the hand", PhaseInitialFlip, nil, nil, -1, ErrInvalidCardIndex},
+		{"index past the hand", PhaseInitialFlip, nil, nil, 6, ErrInvalidCardIndex},
+		{"first flip of a face-up card", PhaseInitialFlip, []int{2}, nil, 2, ErrCardAlreadyFaceUp},
+		{"second flip of the same card", PhaseInitialFlip, nil, []int{1}, 1, ErrCardAlreadyFaceUp},
+		{"second flip in the same row", PhaseInitialFlip, nil, []int{1}, 2, ErrInvalidInitialFlip},
+		{"third flip", PhaseInitialFlip, nil, []int{0, 3}, 4, ErrInitialFlipsDone},
+		{"third flip of a face-up card", PhaseInitialFlip, nil, []int{0, 3}, 0, ErrInitialFlipsDone},
+	} {
+		state := mainGameState(newlyDealt("alice", "A", "2", "3", "4", "5", "6"), newlyDealt("bob", "7", "8", "9", "10", "J", "Q"))
+		state.Phase = PhaseInitialFlip
+		for _, i := range tc.faceUp {
+			state.Players[0].FaceUp[i] = true
+		}
+		for _, i := range tc.flipped {
+			if err := s.InitialFlipCard(state, "alice", i); err != nil {
+				t.Fatalf("%s: flipping %d first: %v", tc.name, i, err)
+			}
+		}
+		state.Phase = tc.phase
+
+		before := state.Players[0]
+		if err := s.InitialFlipCard(state, "alice", tc.flip); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+		if state.Players[0] != before {
+			t.Errorf("%s: a rejected flip changed alice's hand", tc.name)
+		}
+	}
+
+	state := mainGameState(faceUpPlayer("alice", "A", "2", "3", "4", "5", "6"))
+	state.Phase = PhaseInitialFlip
+	if err := s.InitialFlipCard(state, "mallory", 0); err == nil {
+		t.Error("someone outside the game flipped a card")
+	}
+}
+
+func TestDrawingNeedsBothInitialFlips(t *testing.T) {
+	s := NewGameService(newFakeGameRepo(), nil)
+
+	for name, draw := range map[string]func(*FullGameState, string) error{
+		"deck":    s.DrawFromDeck,
+		"discard": s.DrawFromDiscard,
+	} {
+		// The phase was forced on while alice had only flipped one card
+		alice := newlyDealt("alice", "A", "2", "3", "4", "5", "6")
+		alice.FaceUp[0], alice.InitialFlips = true, 1
+		state := mainGameState(alice, faceUpPlayer("bob", "7", "8", "9", "10", "J", "Q"))
+		deck, discard := len(state.Deck), len(state.DiscardPile)
+
+		if err := draw(state, "alice"); !errors.Is(err, ErrInitialFlipsNeeded) {
+			t.Errorf("%s: err = %v, want ErrInitialFlipsNeeded", name, err)
+		}
+		if state.DrawnCard != nil || len(state.Deck) != deck || len(state.DiscardPile) != discard {
+			t.Errorf("%s: a rejected draw moved cards", name)
+		}
+
+		state.Players[0].InitialFlips = 2
+		if err := draw(state, "alice"); err != nil {
+			t.Errorf("%s: drawing after both flips: %v", name, err)
+		}
+	}
+}
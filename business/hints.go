@@ -0,0 +1,64 @@
+package business
+
+// CardDistribution describes the cards a player has not seen yet. Unseen cards are
+// either still in the deck or face-down in someone's hand.
+type CardDistribution struct {
+	Unseen      int                `json:"unseen"`      // Total cards the viewer can't see
+	DeckCount   int                `json:"deckCount"`   // How many of those are in the deck
+	ByRank      map[string]int     `json:"byRank"`      // Unseen count for each rank
+	Probability map[string]float64 `json:"probability"` // Chance a random unseen card has each rank
+}
+
+// RemainingCardDistribution works out which cards are still unknown to viewerUserID,
+// using only what that player can see: face-up cards, the discard pile (every card in
+// it was shown when discarded) and their own drawn card.
+func (s *GameService) RemainingCardDistribution(state *FullGameState, viewerUserID string) (*CardDistribution, error) {
+	viewerIdx, err := findPlayerIndex(state, viewerUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	byRank := make(map[string]int)
+	for _, card := range newDeck(state.Rules.Scoring.JokerCount()) {
+		byRank[card.Rank]++
+	}
+
+	seen := func(card CardDef) {
+		byRank[card.Rank]--
+	}
+
+	for _, player := range state.Players {
+		for i, faceUp := range player.FaceUp {
+			if faceUp {
+				seen(player.Hand[i])
+			}
+		}
+	}
+
+	for _, card := range state.DiscardPile {
+		seen(card)
+	}
+
+	if state.DrawnCard != nil && state.CurrentTurnIdx == viewerIdx {
+		seen(*state.DrawnCard)
+	}
+
+	unseen := 0
+	for _, count := range byRank {
+		unseen += count
+	}
+
+	probability := make(map[string]float64, len(byRank))
+	for rank, count := range byRank {
+		if unseen > 0 {
+			probability[rank] = float64(count) / float64(unseen)
+		}
+	}
+
+	return &CardDistribution{
+		Unseen:      unseen,
+		DeckCount:   len(state.Deck),
+		ByRank:      byRank,
+		Probability: probability,
+	}, nil
+}
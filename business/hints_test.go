@@ -0,0 +1,93 @@
+package business
+
+import (
+	"math"
+	"testing"
+)
+
+// hintTestState deals two players six cards each from a shuffled deck, turns some
+// of them face up, discards one card and has the player to move holding a drawn card
+func hintTestState(currentTurnIdx int) *FullGameState {
+	deck := createDeckWithSeed(7, ScoringRules{})
+	state := &FullGameState{Phase: PhaseMainGame, CurrentTurnIdx: currentTurnIdx}
+	for _, userID := range []string{"alice", "bob"} {
+		player := PlayerState{UserID: userID, InitialFlips: 2}
+		copy(player.Hand[:], deck[:6])
+		deck = deck[6:]
+		state.Players = append(state.Players, player)
+	}
+	state.Players[0].FaceUp[0], state.Players[0].FaceUp[4] = true, true
+	state.Players[1].FaceUp[1], state.Players[1].FaceUp[2], state.Players[1].FaceUp[5] = true, true, true
+	state.DiscardPile = []CardDef{deck[0], deck[1]}
+	state.DrawnCard = &deck[2]
+	state.Deck = deck[3:]
+	return state
+}
+
+func TestRemainingCardsAreTheDeckMinusWhatTheViewerSees(t *testing.T) {
+	s := NewGameService(newFakeGameRepo(), nil)
+
+	for _, tc := range []struct {
+		name           string
+		currentTurnIdx int
+		seesDrawnCard  bool
+	}{
+		{"viewer holds the drawn card", 0, true},
+		{"opponent holds the drawn card", 1, false},
+	} {
+		state := hintTestState(tc.currentTurnIdx)
+		dist, err := s.RemainingCardDistribution(state, "alice")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// Rebuild the unseen cards from the hidden parts of the state
+		want := make(map[string]int)
+		for _, card := range state.Deck {
+			want[card.Rank]++
+		}
+		for _, player := range state.Players {
+			for i, faceUp := range player.FaceUp {
+				if !faceUp {
+					want[player.Hand[i].Rank]++
+				}
+			}
+		}
+		if !tc.seesDrawnCard {
+			want[state.DrawnCard.Rank]++
+		}
+
+		total := 0
+		for _, card := range newDeck(DefaultJokerCount) {
+			total++
+			if dist.ByRank[card.Rank] != want[card.Rank] {
+				t.Errorf("%s: %d unseen %s, want %d", tc.name, dist.ByRank[card.Rank], card.Rank, want[card.Rank])
+			}
+		}
+		visible := 2 + 3 + len(state.DiscardPile)
+		if tc.seesDrawnCard {
+			visible++
+		}
+		if dist.Unseen != total-visible {
+			t.Errorf("%s: %d unseen, want %d of %d cards minus %d visible", tc.name, dist.Unseen, total, total, visible)
+		}
+		if dist.DeckCount != len(state.Deck) {
+			t.Errorf("%s: deck count %d, want %d", tc.name, dist.DeckCount, len(state.Deck))
+		}
+
+		sum := 0.0
+		for rank, p := range dist.Probability {
+			sum += p
+			if want := float64(dist.ByRank[rank]) / float64(dist.Unseen); math.Abs(p-want) > 1e-9 {
+				t.Errorf("%s: P(%s) = %v, want %v", tc.name, rank, p, want)
+			}
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%s: probabilities sum to %v", tc.name, sum)
+		}
+	}
+
+	if _, err := s.RemainingCardDistribution(hintTestState(0), "mallory"); err == nil {
+		t.Error("a hint was worked out for someone not in the game")
+	}
+}
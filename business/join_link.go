@@ -0,0 +1,97 @@
+package business
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+)
+
+var (
+	ErrNotGameCreator   = errors.New("only the game creator can do this")
+	ErrInvalidJoinToken = errors.New("join link is invalid or has been replaced")
+)
+
+// RegenerateJoinToken issues a new join token for a waiting game, invalidating the old one.
+// Only the game creator may do this.
+func (s *GameService) RegenerateJoinToken(ctx context.Context, publicID string, userID string) (string, error) {
+	game, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return "", err
+	}
+
+	if game.CreatedBy != userID {
+		return "", ErrNotGameCreator
+	}
+
+	if game.Status != "waiting_for_players" {
+		return "", ErrInvalidGameStatus
+	}
+
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate join token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	if err := s.gameRepo.SetJoinToken(ctx, publicID, token); err != nil {
+		return "", fmt.Errorf("failed to save join token: %w", err)
+	}
+
+	return token, nil
+}
+
+// JoinByToken adds the user to the game behind a join token and activates them,
+// starting the game if it is now full. started reports whether this call dealt the game.
+func (s *GameService) JoinByToken(ctx context.Context, token string, userID string) (game *database.Game, started bool, err error) {
+	if token == "" {
+		return nil, false, ErrInvalidJoinToken
+	}
+
+	game, err = s.gameRepo.GetGameByJoinToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, database.ErrGameNotFound) {
+			return nil, false, ErrInvalidJoinToken
+		}
+		return nil, false, fmt.Errorf("failed to look up join token: %w", err)
+	}
+
+	if game.Status != "waiting_for_players" {
+		return nil, false, ErrInvalidGameStatus
+	}
+
+	players, err := s.gameRepo.GetGamePlayers(ctx, game.PublicID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get game players: %w", err)
+	}
+
+	// An existing invitation is simply accepted
+	invited := false
+	for _, player := range players {
+		if player.UserID == userID {
+			if player.IsActive {
+				return nil, false, ErrAlreadyInGame
+			}
+			invited = true
+		}
+	}
+
+	if !invited {
+		if len(players) >= game.MaxPlayers {
+			return nil, false, ErrGameFull
+		}
+		if err := s.gameRepo.AddPlayer(ctx, game.PublicID, userID, nextOrderIndex(players)); err != nil {
+			return nil, false, fmt.Errorf("failed to add player: %w", err)
+		}
+	}
+
+	started, err = s.AcceptInvitation(ctx, game.PublicID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	game, err = s.resolveGame(ctx, game.PublicID)
+	return game, started, err
+}
@@ -0,0 +1,48 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegeneratingAJoinLinkRetiresTheOldOne(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, newFakeUserRepo("alice", "bob"))
+	repo.addGame("game", "waiting_for_players", GameRules{}, "alice").MaxPlayers = 2
+
+	if _, err := s.RegenerateJoinToken(ctx, "game", "bob"); !errors.Is(err, ErrNotGameCreator) {
+		t.Fatalf("bob regenerating: err = %v, want ErrNotGameCreator", err)
+	}
+
+	old, err := s.RegenerateJoinToken(ctx, "game", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.RegenerateJoinToken(ctx, "game", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == old {
+		t.Fatal("regenerating issued the same token again")
+	}
+
+	for _, stale := range []string{old, ""} {
+		if _, _, err := s.JoinByToken(ctx, stale, "bob"); !errors.Is(err, ErrInvalidJoinToken) {
+			t.Fatalf("joining with %q: err = %v, want ErrInvalidJoinToken", stale, err)
+		}
+	}
+
+	game, started, err := s.JoinByToken(ctx, token, "bob")
+	if err != nil {
+		t.Fatalf("joining with the new token: %v", err)
+	}
+	if !started || game.Status != "in_progress" {
+		t.Errorf("game is %s, started %v; want bob's join to fill and start it", game.Status, started)
+	}
+
+	if _, err := s.RegenerateJoinToken(ctx, "game", "alice"); !errors.Is(err, ErrInvalidGameStatus) {
+		t.Errorf("regenerating once started: err = %v, want ErrInvalidGameStatus", err)
+	}
+}
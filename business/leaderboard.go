@@ -0,0 +1,60 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"sync"
+	"time"
+)
+
+// leaderboardTTL is how long a computed leaderboard is served before it's rebuilt
+const leaderboardTTL = time.Minute
+
+var ErrInvalidLeaderboardMetric = errors.New("metric must be \"wins\" or \"avg_score\"")
+
+type cachedLeaderboard struct {
+	entries   []*database.LeaderboardEntry
+	expiresAt time.Time
+}
+
+// leaderboardCache keeps recent leaderboards, keyed by metric and limit. Ranking
+// every player is expensive and doesn't need to be up to the second.
+type leaderboardCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLeaderboard
+}
+
+func newLeaderboardCache() *leaderboardCache {
+	return &leaderboardCache{entries: make(map[string]cachedLeaderboard)}
+}
+
+// GetLeaderboard returns the top limit players by metric, "wins" or "avg_score".
+// Results may be up to leaderboardTTL old.
+func (s *GameService) GetLeaderboard(ctx context.Context, metric string, limit int) ([]*database.LeaderboardEntry, error) {
+	if metric != "wins" && metric != "avg_score" {
+		return nil, ErrInvalidLeaderboardMetric
+	}
+
+	key := fmt.Sprintf("%s:%d", metric, limit)
+	s.leaderboard.mu.Lock()
+	cached, ok := s.leaderboard.entries[key]
+	s.leaderboard.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.entries, nil
+	}
+
+	entries, err := s.gameRepo.GetLeaderboard(ctx, metric, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard: %w", err)
+	}
+	if entries == nil {
+		entries = []*database.LeaderboardEntry{}
+	}
+
+	s.leaderboard.mu.Lock()
+	s.leaderboard.entries[key] = cachedLeaderboard{entries: entries, expiresAt: time.Now().Add(leaderboardTTL)}
+	s.leaderboard.mu.Unlock()
+	return entries, nil
+}
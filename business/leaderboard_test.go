@@ -0,0 +1,70 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"testing"
+	"time"
+)
+
+// countingLeaderboardRepo answers leaderboard queries with one entry and counts them
+type countingLeaderboardRepo struct {
+	*fakeGameRepo
+
+	queries int
+}
+
+func (r *countingLeaderboardRepo) GetLeaderboard(ctx context.Context, metric string, limit int) ([]*database.LeaderboardEntry, error) {
+	r.queries++
+	return []*database.LeaderboardEntry{{Rank: 1, Username: metric}}, nil
+}
+
+func TestLeaderboardIsCachedPerMetricAndLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingLeaderboardRepo{fakeGameRepo: newFakeGameRepo()}
+	s := NewGameService(repo, nil)
+
+	for i := 0; i < 3; i++ {
+		entries, err := s.GetLeaderboard(ctx, "wins", 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Username != "wins" {
+			t.Fatalf("entries = %+v", entries)
+		}
+	}
+	if repo.queries != 1 {
+		t.Fatalf("%d queries for the same leaderboard, want 1", repo.queries)
+	}
+
+	s.GetLeaderboard(ctx, "avg_score", 20)
+	s.GetLeaderboard(ctx, "wins", 10)
+	if repo.queries != 3 {
+		t.Fatalf("%d queries, want one per metric and limit", repo.queries)
+	}
+
+	// Once the cached copy is stale it's rebuilt
+	s.leaderboard.mu.Lock()
+	for key, cached := range s.leaderboard.entries {
+		cached.expiresAt = time.Now().Add(-time.Second)
+		s.leaderboard.entries[key] = cached
+	}
+	s.leaderboard.mu.Unlock()
+	s.GetLeaderboard(ctx, "wins", 20)
+	if repo.queries != 4 {
+		t.Fatalf("%d queries, want the expired leaderboard rebuilt", repo.queries)
+	}
+}
+
+func TestLeaderboardRejectsUnknownMetrics(t *testing.T) {
+	repo := &countingLeaderboardRepo{fakeGameRepo: newFakeGameRepo()}
+	s := NewGameService(repo, nil)
+
+	if _, err := s.GetLeaderboard(context.Background(), "losses", 20); !errors.Is(err, ErrInvalidLeaderboardMetric) {
+		t.Fatalf("err = %v, want ErrInvalidLeaderboardMetric", err)
+	}
+	if repo.queries != 0 {
+		t.Fatal("an unknown metric reached the database")
+	}
+}
@@ -0,0 +1,149 @@
+package business
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrAccountLocked = errors.New("too many failed login attempts, try again later")
+
+// loginAttempt is the failure history for one username+IP pair, or for a username
+// from every address combined
+type loginAttempt struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle locks out a username from an IP address after repeated failed logins.
+// A username is also locked from every address once it has failed maxAccountFailures
+// times in all, so an attacker who can change their address still runs out of tries.
+// Attempts are kept in memory, so they reset when the server restarts.
+type loginThrottle struct {
+	mu                 sync.Mutex
+	attempts           map[string]*loginAttempt
+	maxFailures        int // per username+IP
+	maxAccountFailures int // per username, from any address
+	window             time.Duration
+	lockout            time.Duration
+}
+
+func newLoginThrottle() *loginThrottle {
+	t := &loginThrottle{
+		attempts:           make(map[string]*loginAttempt),
+		maxFailures:        5,
+		maxAccountFailures: 20,
+		window:             15 * time.Minute,
+		lockout:            15 * time.Minute,
+	}
+	go t.cleanupExpiredAttempts()
+	return t
+}
+
+// loginAttemptKeys returns the keys a login attempt counts against, with the number of
+// failures each allows: the username from this address, and the username from anywhere
+func (t *loginThrottle) loginAttemptKeys(username, ipAddress string) map[string]int {
+	username = strings.ToLower(strings.TrimSpace(username))
+	return map[string]int{
+		username + "|" + ipAddress: t.maxFailures,
+		username:                   t.maxAccountFailures,
+	}
+}
+
+// reserve counts a login attempt as failed before its password is checked, so
+// concurrent attempts can't all get past the limit while bcrypt runs. It returns
+// false if logins for this username+IP, or for the username, are locked. The
+// attempt that uses up the last allowed failure starts a lockout, which reset
+// lifts if that login succeeds.
+func (t *loginThrottle) reserve(username, ipAddress string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	keys := t.loginAttemptKeys(username, ipAddress)
+	for key := range keys {
+		if attempt, ok := t.attempts[key]; ok && now.Before(attempt.lockedUntil) {
+			return false
+		}
+	}
+
+	for key, maxFailures := range keys {
+		attempt, ok := t.attempts[key]
+		if !ok || now.Sub(attempt.windowStart) > t.window {
+			attempt = &loginAttempt{windowStart: now}
+			t.attempts[key] = attempt
+		}
+
+		attempt.failures++
+		if attempt.failures >= maxFailures {
+			attempt.lockedUntil = now.Add(t.lockout)
+			attempt.failures = 0
+			attempt.windowStart = now
+		}
+	}
+	return true
+}
+
+// reset forgets the failures for a username, and for it from this address, after a
+// successful login
+func (t *loginThrottle) reset(username, ipAddress string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.loginAttemptKeys(username, ipAddress) {
+		delete(t.attempts, key)
+	}
+}
+
+func (t *loginThrottle) cleanupExpiredAttempts() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.mu.Lock()
+		now := time.Now()
+		for key, attempt := range t.attempts {
+			if now.Sub(attempt.windowStart) > t.window && now.After(attempt.lockedUntil) {
+				delete(t.attempts, key)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// SetLoginLockout sets how many failed logins within window lock a username+IP
+// out, and for how long. A username is locked from every address after four times
+// as many failures. Non-positive values keep the defaults.
+func (s *UserService) SetLoginLockout(maxFailures int, window, lockout time.Duration) {
+	s.loginThrottle.mu.Lock()
+	defer s.loginThrottle.mu.Unlock()
+
+	if maxFailures > 0 {
+		s.loginThrottle.maxFailures = maxFailures
+		s.loginThrottle.maxAccountFailures = 4 * maxFailures
+	}
+	if window > 0 {
+		s.loginThrottle.window = window
+	}
+	if lockout > 0 {
+		s.loginThrottle.lockout = lockout
+	}
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyPassword spends as long as a real password check, so a login for
+// an unknown username takes the same time as one with a wrong password
+func compareDummyPassword(password string, cost int) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("golf-card-game"), cost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
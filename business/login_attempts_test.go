@@ -0,0 +1,109 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newLoginTestService returns a user service with alice registered under password
+func newLoginTestService(t *testing.T, password string) (*UserService, *fakeUserRepo) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newFakeUserRepo("alice")
+	repo.users["alice"].Password = string(hash)
+
+	s := NewUserService(repo)
+	if err := s.SetBcryptCost(bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	return s, repo
+}
+
+func TestLoginLocksOutAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newLoginTestService(t, "correct horse")
+
+	for i := 0; i < s.loginThrottle.maxFailures; i++ {
+		if _, _, err := s.LoginUser(ctx, "alice", "wrong", "", "10.0.0.1"); err == nil || errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("attempt %d: err = %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "10.0.0.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("err = %v, want ErrAccountLocked even with the right password", err)
+	}
+	// The lockout is per IP address
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "10.0.0.2"); err != nil {
+		t.Fatalf("login from another address: %v", err)
+	}
+}
+
+func TestConcurrentLoginsCannotExceedTheFailureLimit(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	// Slow lookups keep every attempt in flight at once
+	repo.delay = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.LoginUser(ctx, "alice", "wrong", "", "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+
+	if repo.lookups > s.loginThrottle.maxFailures {
+		t.Fatalf("%d passwords were checked, want at most %d", repo.lookups, s.loginThrottle.maxFailures)
+	}
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "10.0.0.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestSuccessfulLoginResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < s.loginThrottle.maxFailures-1; i++ {
+			if _, _, err := s.LoginUser(ctx, "alice", "wrong", "", "10.0.0.1"); errors.Is(err, ErrAccountLocked) {
+				t.Fatalf("round %d: locked out after %d failures", round, i+1)
+			}
+		}
+		token, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		if session := repo.sessions[token]; session == nil || session.userID != "alice" {
+			t.Fatalf("round %d: no session stored for the token", round)
+		}
+	}
+}
+
+func TestChangingAddressDoesNotEscapeTheLockout(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newLoginTestService(t, "correct horse")
+
+	for i := 0; i < s.loginThrottle.maxAccountFailures; i++ {
+		ipAddress := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		if _, _, err := s.LoginUser(ctx, "Alice", "wrong", "", ipAddress); err == nil || errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("attempt %d: err = %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "192.0.2.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("login from a new address: err = %v, want ErrAccountLocked", err)
+	}
+}
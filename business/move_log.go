@@ -0,0 +1,81 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"log"
+)
+
+// MoveLog keeps the append-only history of committed actions for each game
+type MoveLog struct {
+	moveRepo database.MoveLogRepository
+	gameRepo database.GameRepository
+}
+
+func NewMoveLog(moveRepo database.MoveLogRepository, gameRepo database.GameRepository) *MoveLog {
+	return &MoveLog{moveRepo: moveRepo, gameRepo: gameRepo}
+}
+
+// Record appends an action just committed to state, along with any decks it shuffled.
+// cardIndex is nil for actions that don't target a card. Failures are logged but never
+// returned, since the move already happened.
+func (l *MoveLog) Record(ctx context.Context, publicID, userID, action string, cardIndex *int, state *FullGameState) {
+	decks := state.newDecks
+	state.newDecks = nil
+
+	if l == nil || l.moveRepo == nil {
+		return
+	}
+
+	var decksJSON []byte
+	if len(decks) > 0 {
+		var err error
+		if decksJSON, err = json.Marshal(decks); err != nil {
+			log.Printf("Failed to record move %s for game %s: %v", action, publicID, err)
+			return
+		}
+	}
+
+	game, err := l.gameRepo.GetGameByPublicID(ctx, publicID)
+	if err != nil {
+		log.Printf("Failed to record move %s for game %s: %v", action, publicID, err)
+		return
+	}
+
+	if err := l.moveRepo.RecordMove(ctx, game.GameID, userID, action, cardIndex, decksJSON, state.Version); err != nil {
+		log.Printf("Failed to record move %s for game %s: %v", action, publicID, err)
+	}
+}
+
+// GetMoves returns a game's moves in commit order, ready to hand to ReplayGame
+func (l *MoveLog) GetMoves(ctx context.Context, publicID string) ([]Move, error) {
+	game, err := l.gameRepo.GetGameByPublicID(ctx, publicID)
+	if err != nil {
+		if errors.Is(err, database.ErrGameNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, fmt.Errorf("failed to get game: %w", err)
+	}
+
+	logged, err := l.moveRepo.GetMoves(ctx, game.GameID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get moves: %w", err)
+	}
+
+	moves := make([]Move, len(logged))
+	for i, m := range logged {
+		moves[i] = Move{UserID: m.UserID, Action: m.Action, CardIndex: -1}
+		if m.CardIndex != nil {
+			moves[i].CardIndex = *m.CardIndex
+		}
+		if len(m.Decks) > 0 {
+			if err := json.Unmarshal(m.Decks, &moves[i].Decks); err != nil {
+				return nil, fmt.Errorf("failed to parse decks of move %d: %w", m.GameMoveID, err)
+			}
+		}
+	}
+	return moves, nil
+}
@@ -0,0 +1,102 @@
+package business
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrPasswordTooShort       = errors.New("password is too short")
+	ErrPasswordNeedsMixedCase = errors.New("password must contain both upper and lower case letters")
+	ErrPasswordNeedsDigit     = errors.New("password must contain a number")
+	ErrPasswordNeedsSymbol    = errors.New("password must contain a symbol")
+	ErrPasswordTooCommon      = errors.New("password is too common, please choose another")
+)
+
+// PasswordPolicy is the set of rules new passwords must meet
+type PasswordPolicy struct {
+	MinLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	RejectCommon     bool
+}
+
+var passwordPolicy = PasswordPolicy{MinLength: 8}
+
+// commonPasswords are rejected when RejectCommon is set. Compared case-insensitively.
+var commonPasswords = map[string]bool{
+	"password":    true,
+	"password1":   true,
+	"password123": true,
+	"12345678":    true,
+	"123456789":   true,
+	"1234567890":  true,
+	"qwerty123":   true,
+	"qwertyuiop":  true,
+	"iloveyou":    true,
+	"sunshine":    true,
+	"letmein1":    true,
+	"football":    true,
+	"baseball":    true,
+	"welcome1":    true,
+	"11111111":    true,
+	"abc12345":    true,
+	"golfgolf":    true,
+}
+
+// SetPasswordPolicy replaces the password rules. A MinLength below 8 is raised to 8.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	policy.MinLength = max(policy.MinLength, 8)
+	passwordPolicy = policy
+}
+
+// IsPasswordPolicyError reports whether err is a password rule failure, which is
+// safe to show to the user
+func IsPasswordPolicyError(err error) bool {
+	return errors.Is(err, ErrPasswordTooShort) ||
+		errors.Is(err, ErrPasswordNeedsMixedCase) ||
+		errors.Is(err, ErrPasswordNeedsDigit) ||
+		errors.Is(err, ErrPasswordNeedsSymbol) ||
+		errors.Is(err, ErrPasswordTooCommon)
+}
+
+// ValidatePassword checks a new password against the password policy and
+// returns the first rule it breaks
+func ValidatePassword(pw string) error {
+	policy := passwordPolicy
+
+	if len([]rune(pw)) < policy.MinLength {
+		return fmt.Errorf("%w, it must be at least %d characters", ErrPasswordTooShort, policy.MinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if policy.RequireMixedCase && !(hasUpper && hasLower) {
+		return ErrPasswordNeedsMixedCase
+	}
+	if policy.RequireDigit && !hasDigit {
+		return ErrPasswordNeedsDigit
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		return ErrPasswordNeedsSymbol
+	}
+	if policy.RejectCommon && commonPasswords[strings.ToLower(pw)] {
+		return ErrPasswordTooCommon
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package business
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	previous := passwordPolicy
+	t.Cleanup(func() { passwordPolicy = previous })
+
+	lenient := PasswordPolicy{}
+	strict := PasswordPolicy{MinLength: 10, RequireMixedCase: true, RequireDigit: true, RequireSymbol: true, RejectCommon: true}
+	for _, tc := range []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     error
+	}{
+		{"lenient accepts 8 characters", lenient, "abcdefgh", nil},
+		{"lenient still needs 8", lenient, "abcdefg", ErrPasswordTooShort},
+		{"lenient allows common", lenient, "password", nil},
+		{"length counts characters not bytes", lenient, "ééééééé", ErrPasswordTooShort},
+		{"strict too short", strict, "Ab1!defgh", ErrPasswordTooShort},
+		{"strict no upper case", strict, "abcdefgh1!", ErrPasswordNeedsMixedCase},
+		{"strict no lower case", strict, "ABCDEFGH1!", ErrPasswordNeedsMixedCase},
+		{"strict no digit", strict, "Abcdefghi!", ErrPasswordNeedsDigit},
+		{"strict no symbol", strict, "Abcdefghi1", ErrPasswordNeedsSymbol},
+		{"space counts as a symbol", strict, "Abcdefgh 1", nil},
+		{"strict meets every rule", strict, "Abcdefgh1!", nil},
+		{"common", PasswordPolicy{RejectCommon: true}, "Password123", ErrPasswordTooCommon},
+	} {
+		SetPasswordPolicy(tc.policy)
+		err := ValidatePassword(tc.password)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+		if err != nil && !IsPasswordPolicyError(err) {
+			t.Errorf("%s: %v isn't reported as a policy error", tc.name, err)
+		}
+	}
+}
+
+func TestPasswordPolicyNeverGoesBelowEightCharacters(t *testing.T) {
+	previous := passwordPolicy
+	t.Cleanup(func() { passwordPolicy = previous })
+
+	SetPasswordPolicy(PasswordPolicy{MinLength: 4})
+	if err := ValidatePassword("abcdefg"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("7 characters with MinLength 4: err = %v, want ErrPasswordTooShort", err)
+	}
+}
@@ -0,0 +1,89 @@
+package business
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetTTL is how long an emailed reset link keeps working
+const passwordResetTTL = time.Hour
+
+var (
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("password reset link is invalid or has expired")
+)
+
+// SetPasswordResetMailer sets how reset tokens are delivered to users
+func (s *UserService) SetPasswordResetMailer(fn func(user *database.User, token string) error) {
+	s.sendPasswordReset = fn
+}
+
+// RequestPasswordReset emails a single-use reset token to the account with this email.
+// An unknown email is not an error, so callers can't use this to probe for accounts.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.CreatePasswordResetToken(ctx, user.UserID, hashToken(token), time.Now().Add(passwordResetTTL))
+	if err != nil {
+		return fmt.Errorf("failed to save reset token: %w", err)
+	}
+
+	if s.sendPasswordReset == nil {
+		return errors.New("password reset email is not configured")
+	}
+	if err := s.sendPasswordReset(user, token); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using an emailed reset token. The token can only
+// be used once. Returns the ID of the user whose password changed.
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) (string, error) {
+	if err := ValidatePassword(newPassword); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), s.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.userRepo.ResetPasswordWithToken(ctx, hashToken(token), string(hashedPassword))
+	if errors.Is(err, database.ErrInvalidResetToken) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	// Whoever knew the old password shouldn't stay signed in
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return "", fmt.Errorf("failed to end sessions: %w", err)
+	}
+	return userID, nil
+}
+
+// hashToken is what gets stored for emailed tokens, so a leaked table can't be used to redeem them
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"testing"
+	"time"
+)
+
+// useMailbox makes s deliver reset and verification tokens into the returned slice
+func useMailbox(s *UserService) *[]string {
+	var sent []string
+	deliver := func(user *database.User, token string) error {
+		sent = append(sent, token)
+		return nil
+	}
+	s.SetPasswordResetMailer(deliver)
+	s.SetVerificationMailer(deliver)
+	return &sent
+}
+
+func TestResetTokenWorksOnceAndNotAfterExpiring(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "old password")
+	repo.users["alice"].Email = "alice@example.com"
+	sent := useMailbox(s)
+	oldSession, _, err := s.LoginUser(ctx, "alice", "old password", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody learns whether an address has an account
+	if err := s.RequestPasswordReset(ctx, "nobody@example.com"); err != nil || len(*sent) != 0 {
+		t.Fatalf("reset for an unknown email: err %v, %d emails sent", err, len(*sent))
+	}
+
+	if err := s.RequestPasswordReset(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := (*sent)[0]
+	if _, stored := repo.resetTokens[token]; stored {
+		t.Fatal("the raw token was stored rather than its hash")
+	}
+	if _, err := s.ResetPassword(ctx, token, "short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Fatalf("too short a password: err = %v", err)
+	}
+
+	userID, err := s.ResetPassword(ctx, token, "new password")
+	if err != nil || userID != "alice" {
+		t.Fatalf("ResetPassword = %q, %v", userID, err)
+	}
+	if _, _, err := s.LoginUser(ctx, "alice", "new password", "", ""); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+	if _, ok := repo.sessions[oldSession]; ok {
+		t.Error("the session from before the reset survived it")
+	}
+	if _, err := s.ResetPassword(ctx, token, "another password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("reusing the token: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	// Requesting again replaces the link that hasn't been used
+	for i := 0; i < 2; i++ {
+		if err := s.RequestPasswordReset(ctx, "alice@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replaced, latest := (*sent)[1], (*sent)[2]
+	if _, err := s.ResetPassword(ctx, replaced, "another password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("a replaced token: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	repo.resetTokens[hashToken(latest)].expiresAt = time.Now().Add(-time.Minute)
+	if _, err := s.ResetPassword(ctx, latest, "another password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("an expired token: err = %v, want ErrInvalidResetToken", err)
+	}
+}
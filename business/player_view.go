@@ -0,0 +1,54 @@
+package business
+
+// HiddenCard stands in for any card the viewer is not allowed to see
+var HiddenCard = CardDef{Suit: "back", Rank: "hidden"}
+
+// BuildPlayerView returns a copy of state that is safe to send to forUserID.
+// Every face-down card is replaced with HiddenCard (the viewer's own included, since
+// they haven't looked at them either), the deck keeps only its length, and the drawn
+// card is only shown to the player whose turn it is. The seed of a seeded game is
+// dropped, since it regenerates every shuffle.
+func BuildPlayerView(state *FullGameState, forUserID string) *FullGameState {
+	view := *state
+
+	view.Deck = make([]CardDef, len(state.Deck))
+	for i := range view.Deck {
+		view.Deck[i] = HiddenCard
+	}
+	view.Seed = nil
+
+	view.DiscardPile = append([]CardDef(nil), state.DiscardPile...)
+
+	view.Players = make([]PlayerState, len(state.Players))
+	for i, player := range state.Players {
+		for j := range player.Hand {
+			if !player.FaceUp[j] {
+				player.Hand[j] = HiddenCard
+			}
+		}
+		view.Players[i] = player
+	}
+
+	view.DrawnCard = nil
+	if state.DrawnCard != nil {
+		drawn := HiddenCard
+		if state.CurrentTurnIdx < len(state.Players) && state.Players[state.CurrentTurnIdx].UserID == forUserID {
+			drawn = *state.DrawnCard
+		}
+		view.DrawnCard = &drawn
+	}
+
+	if state.TriggerPlayerIdx != nil {
+		trigger := *state.TriggerPlayerIdx
+		view.TriggerPlayerIdx = &trigger
+	}
+
+	if state.CumulativeScores != nil {
+		view.CumulativeScores = make(map[string]int, len(state.CumulativeScores))
+		for userID, score := range state.CumulativeScores {
+			view.CumulativeScores[userID] = score
+		}
+	}
+
+	return &view
+}
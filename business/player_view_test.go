@@ -0,0 +1,127 @@
+package business
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSameSeedGivesSameDeck(t *testing.T) {
+	rules := ScoringRules{}
+	first := createDeckWithSeed(42, rules)
+	if !reflect.DeepEqual(first, createDeckWithSeed(42, rules)) {
+		t.Fatal("the same seed gave two different decks")
+	}
+	if reflect.DeepEqual(first, createDeckWithSeed(43, rules)) {
+		t.Fatal("different seeds gave the same deck")
+	}
+	if !sameCards(first, newDeck(rules.JokerCount())) {
+		t.Fatal("seeded deck isn't a full deck")
+	}
+}
+
+func TestSameSeedGivesSameDeal(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob")
+	s := NewGameService(repo, nil)
+
+	first, err := s.InitializeGameWithSeed(ctx, "game", []string{"alice", "bob"}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.InitializeGameWithSeed(ctx, "game", []string{"alice", "bob"}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := diffStates(first, second); len(diffs) != 0 {
+		t.Fatalf("deals from the same seed differ in %v", diffs)
+	}
+
+	// The first deal is dealt off the top of createDeckWithSeed(seed + round 0)
+	var dealt []CardDef
+	for _, player := range first.Players {
+		dealt = append(dealt, player.Hand[:]...)
+	}
+	dealt = append(dealt, first.DiscardPile...)
+	dealt = append(dealt, first.Deck...)
+	if !reflect.DeepEqual(dealt, createDeckWithSeed(7, first.Rules.Scoring)) {
+		t.Fatal("seeded deal didn't come from createDeckWithSeed")
+	}
+}
+
+func TestBuildPlayerViewDropsSeed(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob")
+	s := NewGameService(repo, nil)
+
+	state, err := s.InitializeGameWithSeed(ctx, "game", []string{"alice", "bob"}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if view := BuildPlayerView(state, "alice"); view.Seed != nil {
+		t.Fatal("player view kept the seed")
+	}
+	if state.Seed == nil || *state.Seed != 7 {
+		t.Fatal("BuildPlayerView changed the original state's seed")
+	}
+}
+
+func TestBuildPlayerViewHidesFaceDownCardsAndTheDeck(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", GameRules{}, "alice", "bob")
+	s := NewGameService(repo, nil)
+
+	state, err := s.InitializeGame(ctx, "game", []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Players[0].FaceUp[0] = true
+	state.Players[1].FaceUp[4] = true
+	drawn := state.Deck[0]
+	state.DrawnCard = &drawn
+	state.CurrentTurnIdx = 0
+	original := state.Players[1].Hand
+
+	for _, viewer := range []string{"alice", "bob", ""} {
+		view := BuildPlayerView(state, viewer)
+
+		for p, player := range view.Players {
+			for i, card := range player.Hand {
+				faceUp := state.Players[p].FaceUp[i]
+				if faceUp && card != state.Players[p].Hand[i] {
+					t.Errorf("%q sees %s's face-up card %d as %v", viewer, player.UserID, i, card)
+				}
+				if !faceUp && card != HiddenCard {
+					t.Errorf("%q sees %s's face-down card %d", viewer, player.UserID, i)
+				}
+			}
+		}
+
+		if len(view.Deck) != len(state.Deck) {
+			t.Errorf("%q sees a deck of %d cards, want %d", viewer, len(view.Deck), len(state.Deck))
+		}
+		for _, card := range view.Deck {
+			if card != HiddenCard {
+				t.Errorf("%q sees deck card %v", viewer, card)
+				break
+			}
+		}
+
+		// Only the player whose turn it is sees what they drew
+		wantDrawn := HiddenCard
+		if viewer == "alice" {
+			wantDrawn = drawn
+		}
+		if view.DrawnCard == nil || *view.DrawnCard != wantDrawn {
+			t.Errorf("%q sees drawn card %v, want %v", viewer, view.DrawnCard, wantDrawn)
+		}
+	}
+
+	if state.Players[1].Hand != original || state.Deck[0] == HiddenCard {
+		t.Fatal("BuildPlayerView changed the original state")
+	}
+}
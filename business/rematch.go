@@ -0,0 +1,96 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+)
+
+var ErrNotGameParticipant = errors.New("only players of this game can do this")
+
+// Rematch creates a new game with the same players and rules as a finished one, seating
+// the requester and inviting everyone else. A game gets at most one rematch: later
+// requests, including ones racing the first, get that game back with created false.
+// The game and its seats are created in one transaction, so a failure leaves no
+// half-seated rematch behind to block the next attempt.
+func (s *GameService) Rematch(ctx context.Context, publicID string, userID string) (game *database.Game, created bool, err error) {
+	original, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if original.Status != "finished" {
+		return nil, false, ErrGameNotFinished
+	}
+
+	players, err := s.gameRepo.GetGamePlayers(ctx, publicID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get game players: %w", err)
+	}
+
+	// Everyone who played, requester first
+	participants := []string{}
+	isParticipant := false
+	for _, player := range players {
+		if !player.IsActive {
+			continue
+		}
+		if player.UserID == userID {
+			isParticipant = true
+			continue
+		}
+		participants = append(participants, player.UserID)
+	}
+	if !isParticipant {
+		return nil, false, ErrNotGameParticipant
+	}
+	participants = append([]string{userID}, participants...)
+
+	rulesJSON := original.Rules
+	if len(rulesJSON) == 0 {
+		rulesJSON = []byte("{}")
+	}
+
+	err = s.gameRepo.WithTx(ctx, func(tx database.GameRepository) error {
+		created, err := tx.CreateRematchGame(ctx, original.GameID, userID, max(len(participants), MinPlayers), rulesJSON)
+		if err != nil {
+			return fmt.Errorf("failed to create rematch: %w", err)
+		}
+
+		if err := seatCreator(ctx, tx, created.PublicID, userID); err != nil {
+			return err
+		}
+
+		for i, opponentID := range participants[1:] {
+			if err := tx.AddPlayer(ctx, created.PublicID, opponentID, i+1); err != nil {
+				return fmt.Errorf("failed to invite player: %w", err)
+			}
+		}
+
+		game = created
+		return nil
+	})
+	if errors.Is(err, database.ErrRematchExists) {
+		game, err = s.gameRepo.GetRematchGame(ctx, original.GameID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get rematch: %w", err)
+		}
+		return game, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The bot doesn't wait to be asked twice
+	for _, opponentID := range participants[1:] {
+		if IsBot(opponentID) {
+			if _, err := s.AcceptInvitation(ctx, game.PublicID, opponentID); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	game, err = s.resolveGame(ctx, game.PublicID)
+	return game, true, err
+}
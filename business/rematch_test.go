@@ -0,0 +1,99 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"sync"
+	"testing"
+)
+
+func TestRematchSeatsEveryoneInOneTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	original := repo.addGame("game", "finished", GameRules{}, "alice", "bob", "carol")
+
+	// Inviting carol fails, so nothing of the rematch may be left behind
+	repo.failAddPlayer["carol"] = errors.New("connection reset")
+	if _, _, err := s.Rematch(ctx, "game", "bob"); err == nil {
+		t.Fatal("Rematch succeeded although inviting carol failed")
+	}
+	if _, err := repo.GetRematchGame(ctx, original.GameID); !errors.Is(err, database.ErrGameNotFound) {
+		t.Fatalf("half-seated rematch left behind: err = %v", err)
+	}
+
+	delete(repo.failAddPlayer, "carol")
+	game, created, err := s.Rematch(ctx, "game", "bob")
+	if err != nil {
+		t.Fatalf("Rematch: %v", err)
+	}
+	if !created {
+		t.Fatal("retried rematch reported as existing")
+	}
+
+	players, err := repo.GetGamePlayers(ctx, game.PublicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 3 || players[0].UserID != "bob" || !players[0].IsActive {
+		t.Fatalf("got %d players, want bob seated first and two invitations", len(players))
+	}
+	for _, player := range players[1:] {
+		if player.IsActive {
+			t.Fatalf("%s was seated instead of invited", player.UserID)
+		}
+	}
+
+	// A second request gets the same game back
+	again, created, err := s.Rematch(ctx, "game", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || again.PublicID != game.PublicID {
+		t.Fatalf("second Rematch = %s (created %v), want %s", again.PublicID, created, game.PublicID)
+	}
+}
+
+func TestConcurrentRematchRequestsGetTheSameGame(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	repo.addGame("game", "finished", GameRules{}, "alice", "bob")
+
+	type result struct {
+		publicID string
+		created  bool
+	}
+	results := make(chan result, 2)
+	var wg sync.WaitGroup
+	for _, userID := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			game, created, err := s.Rematch(ctx, "game", userID)
+			if err != nil {
+				t.Errorf("%s: %v", userID, err)
+				return
+			}
+			results <- result{game.PublicID, created}
+		}(userID)
+	}
+	wg.Wait()
+	close(results)
+
+	var publicIDs []string
+	created := 0
+	for res := range results {
+		publicIDs = append(publicIDs, res.publicID)
+		if res.created {
+			created++
+		}
+	}
+	if len(publicIDs) != 2 || publicIDs[0] != publicIDs[1] {
+		t.Fatalf("rematches = %v, want the same game twice", publicIDs)
+	}
+	if created != 1 {
+		t.Fatalf("%d requests created a rematch, want 1", created)
+	}
+}
@@ -0,0 +1,276 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golf-card-game/database"
+	"reflect"
+)
+
+var (
+	ErrUnknownAction     = errors.New("unknown action")
+	ErrGameNotFinished   = errors.New("game is not finished")
+	ErrReplayUnavailable = errors.New("no initial deal is stored for this game")
+
+	ErrRecordedDeckMismatch = errors.New("recorded deck doesn't match the cards being shuffled")
+	ErrDeckNotRecorded      = errors.New("move log has no deck for a shuffle")
+	ErrIllegalMove          = errors.New("logged move is illegal")
+)
+
+// Move is one committed action, enough to re-apply it to a state
+type Move struct {
+	UserID    string      `json:"userId"` // Empty for ActionDeal
+	Action    string      `json:"action"`
+	CardIndex int         `json:"cardIndex"`       // -1 when none was logged
+	Decks     [][]CardDef `json:"decks,omitempty"` // Decks the move shuffled, in order
+}
+
+// Moves that don't come from a client's action message, recorded in the move log so
+// they can be replayed
+const (
+	ActionTimeout      = "timeout"       // ForceTimeoutMove
+	ActionDiscardStuck = "discard_stuck" // ResolveStuckDraw
+	ActionResign       = "resign"        // ResignGame, at the player's request
+	ActionDeal         = "deal"          // FinishGame starting the next deal of a match
+)
+
+// IsServerAction reports whether an action is only ever made by the server, so
+// clients may not request it
+func IsServerAction(action string) bool {
+	switch action {
+	case ActionTimeout, ActionDiscardStuck, ActionDeal:
+		return true
+	default:
+		return false
+	}
+}
+
+// ActionNeedsCardIndex reports whether an action targets a card in the player's hand
+func ActionNeedsCardIndex(action string) bool {
+	switch action {
+	case "initial_flip", "swap_card", "swap", "discard_flip":
+		return true
+	default:
+		return false
+	}
+}
+
+// ApplyAction dispatches a named player action to the matching engine method
+func (s *GameService) ApplyAction(state *FullGameState, userID string, action string, cardIndex int) error {
+	switch action {
+	case "initial_flip":
+		return s.InitialFlipCard(state, userID, cardIndex)
+	case "draw_deck":
+		return s.DrawFromDeck(state, userID)
+	case "draw_discard":
+		return s.DrawFromDiscard(state, userID)
+	case "swap_card", "swap":
+		return s.SwapCard(state, userID, cardIndex)
+	case "discard_flip":
+		return s.DiscardAndFlip(state, userID, cardIndex)
+	case "knock":
+		return s.Knock(state, userID)
+	case "cancel_draw":
+		return s.CancelDraw(state, userID)
+	case ActionTimeout, ActionDiscardStuck:
+		// Server-driven moves; only used when replaying the move log
+		if state.CurrentTurnIdx >= len(state.Players) || state.Players[state.CurrentTurnIdx].UserID != userID {
+			return ErrNotYourTurn
+		}
+		if action == ActionDiscardStuck {
+			return s.ResolveStuckDraw(state)
+		}
+		_, _, err := s.forceTimeoutMove(state, cardIndex)
+		return err
+	case ActionResign:
+		_, err := s.ResignGame(state, userID)
+		return err
+	case ActionDeal:
+		// Only legal where FinishGame banks the deal and starts another
+		if _, err := s.FinishGame(state); err != nil {
+			return err
+		}
+		if state.Phase == PhaseFinished {
+			return ErrInvalidPhase
+		}
+		return nil
+	default:
+		return ErrUnknownAction
+	}
+}
+
+// ReplayGame re-applies moves to a copy of the initial deal and returns the result.
+// Every shuffle uses the deck recorded with its move, so the result is exact across
+// reshuffles and later deals. Fails on the first move the engine rejects, which means
+// the log itself is bad.
+func (s *GameService) ReplayGame(initial *FullGameState, moves []Move) (*FullGameState, error) {
+	state, err := cloneState(initial)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, move := range moves {
+		if err := s.replayMove(state, i+1, move); err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// replayMove re-applies the nth logged move, shuffling only with the decks it recorded
+func (s *GameService) replayMove(state *FullGameState, n int, move Move) error {
+	state.replaying = true
+	state.recordedDecks = move.Decks
+	err := s.ApplyAction(state, move.UserID, move.Action, move.CardIndex)
+	if err == nil && len(state.recordedDecks) > 0 {
+		// The move recorded a shuffle that didn't happen
+		err = ErrRecordedDeckMismatch
+	}
+	state.recordedDecks = nil
+
+	if err != nil {
+		return fmt.Errorf("%w: move %d (%s by %s): %w", ErrIllegalMove, n, move.Action, move.UserID, err)
+	}
+	return nil
+}
+
+// ReplaySnapshots re-applies moves to the stored initial deal of a finished game and
+// returns the state before any move followed by the state after each one. Snapshots
+// are not redacted, since the game is over.
+func (s *GameService) ReplaySnapshots(ctx context.Context, publicID string, moves []Move) ([]*FullGameState, error) {
+	game, err := s.resolveGame(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+	if game.Status != "finished" {
+		return nil, ErrGameNotFinished
+	}
+
+	state, err := s.loadInitialState(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*FullGameState, 0, len(moves)+1)
+	for i := 0; ; i++ {
+		snapshot, err := cloneState(state)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+
+		if i == len(moves) {
+			break
+		}
+		if err := s.replayMove(state, i+1, moves[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Scoring flips whatever is still face-down
+	if last := snapshots[len(snapshots)-1]; last.Phase == PhaseFinished {
+		flipRemainingCards(last)
+	}
+
+	return snapshots, nil
+}
+
+// loadInitialState returns the deal a game started from
+func (s *GameService) loadInitialState(ctx context.Context, publicID string) (*FullGameState, error) {
+	initialJSON, err := s.gameRepo.LoadInitialGameState(ctx, publicID)
+	if err != nil {
+		if errors.Is(err, database.ErrStateNotFound) {
+			return nil, ErrReplayUnavailable
+		}
+		return nil, fmt.Errorf("failed to load initial state: %w", err)
+	}
+
+	var initial FullGameState
+	if err := json.Unmarshal(initialJSON, &initial); err != nil {
+		return nil, fmt.Errorf("failed to parse initial state: %w", err)
+	}
+	initial.PublicID = publicID
+	return &initial, nil
+}
+
+// SetMoveLog sets the move log VerifyGameState replays games from
+func (s *GameService) SetMoveLog(l *MoveLog) {
+	s.moveLog = l
+}
+
+// VerifyGameState replays a game's logged moves over its stored initial deal and
+// compares the result with the persisted state. Returns the names of fields that differ.
+func (s *GameService) VerifyGameState(ctx context.Context, publicID string) ([]string, error) {
+	if s.moveLog == nil {
+		return nil, ErrReplayUnavailable
+	}
+	moves, err := s.moveLog.GetMoves(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	initial, err := s.loadInitialState(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	persisted, _, err := s.LoadState(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed, err := s.ReplayGame(initial, moves)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finished games have their remaining cards flipped when scored
+	if replayed.Phase == PhaseFinished {
+		flipRemainingCards(replayed)
+	}
+
+	return diffStates(replayed, persisted), nil
+}
+
+// cloneState deep-copies a state through its JSON form
+func cloneState(state *FullGameState) (*FullGameState, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy state: %w", err)
+	}
+
+	var clone FullGameState
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy state: %w", err)
+	}
+	return &clone, nil
+}
+
+// diffStates lists the game-relevant fields that differ between two states.
+// Bookkeeping fields (IDs and versions) are ignored.
+func diffStates(expected, actual *FullGameState) []string {
+	var diffs []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			diffs = append(diffs, name)
+		}
+	}
+
+	check("phase", expected.Phase, actual.Phase)
+	check("deck", expected.Deck, actual.Deck)
+	check("discardPile", expected.DiscardPile, actual.DiscardPile)
+	check("players", expected.Players, actual.Players)
+	check("currentTurnIdx", expected.CurrentTurnIdx, actual.CurrentTurnIdx)
+	check("drawnCard", expected.DrawnCard, actual.DrawnCard)
+	check("triggerPlayerIdx", expected.TriggerPlayerIdx, actual.TriggerPlayerIdx)
+	check("finalRoundTurns", expected.FinalRoundTurns, actual.FinalRoundTurns)
+	check("turnsPlayed", expected.TurnsPlayed, actual.TurnsPlayed)
+	check("rules", expected.Rules, actual.Rules)
+	check("cumulativeScores", expected.CumulativeScores, actual.CumulativeScores)
+	check("round", expected.Round, actual.Round)
+
+	return diffs
+}
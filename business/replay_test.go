@@ -0,0 +1,241 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// playGame plays publicID to the end the way the game handler does, with every
+// player using the bot strategy: each action is saved and then logged, and finished
+// deals are scored, saved and (when a new deal starts) logged.
+func playGame(t *testing.T, s *GameService, moveLog *MoveLog, publicID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i := 0; i < 10000; i++ {
+		state, version, err := s.LoadState(ctx, publicID)
+		if err != nil {
+			t.Fatalf("LoadState: %v", err)
+		}
+
+		if state.Phase == PhaseFinished {
+			winnerUserIDs, err := s.FinishGame(state)
+			if err != nil {
+				t.Fatalf("FinishGame: %v", err)
+			}
+			if err := s.SaveState(ctx, state, version); err != nil {
+				t.Fatalf("SaveState after deal: %v", err)
+			}
+			if state.Phase != PhaseFinished {
+				moveLog.Record(ctx, publicID, "", ActionDeal, nil, state)
+				continue
+			}
+			if err := s.RecordResult(ctx, state, winnerUserIDs); err != nil {
+				t.Fatalf("RecordResult: %v", err)
+			}
+			return
+		}
+
+		userID := nextActor(state)
+		action, cardIndex := ComputeBotMove(state, userID)
+		if action == "" {
+			t.Fatalf("no move for %s in phase %s", userID, state.Phase)
+		}
+		if err := s.ApplyAction(state, userID, action, cardIndex); err != nil {
+			t.Fatalf("%s by %s: %v", action, userID, err)
+		}
+		if err := s.SaveState(ctx, state, version); err != nil {
+			t.Fatalf("SaveState: %v", err)
+		}
+
+		var loggedIndex *int
+		if ActionNeedsCardIndex(action) {
+			loggedIndex = &cardIndex
+		}
+		moveLog.Record(ctx, publicID, userID, action, loggedIndex, state)
+	}
+	t.Fatal("game did not finish")
+}
+
+// nextActor returns who should move: anyone still owing initial flips, else the current player
+func nextActor(state *FullGameState) string {
+	if state.Phase == PhaseInitialFlip {
+		for _, player := range state.Players {
+			if player.InitialFlips < 2 {
+				return player.UserID
+			}
+		}
+	}
+	return state.Players[state.CurrentTurnIdx].UserID
+}
+
+// newShortDeckGame deals an unseeded game whose first deck only has deckSize cards
+// left, so the discard pile is reshuffled early
+func newShortDeckGame(t *testing.T, repo *fakeGameRepo, s *GameService, publicID string, rules GameRules, deckSize int) {
+	t.Helper()
+	ctx := context.Background()
+
+	repo.addGame(publicID, "in_progress", rules, "alice", "bob")
+	state, err := s.InitializeGame(ctx, publicID, []string{"alice", "bob"})
+	if err != nil {
+		t.Fatalf("InitializeGame: %v", err)
+	}
+	state.Deck = state.Deck[:deckSize]
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveGameState(ctx, publicID, stateJSON); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayIsExactAcrossReshufflesAndDeals(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	moveLog := NewMoveLog(&fakeMoveRepo{}, repo)
+	s.SetMoveLog(moveLog)
+
+	// No six-card hand scores 100, so the match takes at least two deals
+	newShortDeckGame(t, repo, s, "match", GameRules{TargetScore: 100}, 3)
+	playGame(t, s, moveLog, "match")
+
+	moves, err := moveLog.GetMoves(ctx, "match")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deals, reshuffles int
+	for _, move := range moves {
+		switch {
+		case move.Action == ActionDeal:
+			deals++
+		case len(move.Decks) > 0:
+			reshuffles++
+		}
+	}
+	if deals == 0 || reshuffles == 0 {
+		t.Fatalf("want a match with reshuffles and several deals, got %d reshuffles and %d deals", reshuffles, deals)
+	}
+
+	diffs, err := s.VerifyGameState(ctx, "match")
+	if err != nil {
+		t.Fatalf("VerifyGameState: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("honest game reported differences: %v", diffs)
+	}
+
+	snapshots, err := s.ReplaySnapshots(ctx, "match", moves)
+	if err != nil {
+		t.Fatalf("ReplaySnapshots: %v", err)
+	}
+	persisted, _, err := s.LoadState(ctx, "match")
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := snapshots[len(snapshots)-1]
+	want, got := GetFinalScores(persisted), GetFinalScores(final)
+	for userID, score := range want {
+		if got[userID] != score {
+			t.Errorf("replayed score for %s = %d, want %d", userID, got[userID], score)
+		}
+	}
+}
+
+func TestVerifyGameStateDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	moveLog := NewMoveLog(&fakeMoveRepo{}, repo)
+	s.SetMoveLog(moveLog)
+
+	newShortDeckGame(t, repo, s, "game", GameRules{}, 3)
+	playGame(t, s, moveLog, "game")
+
+	// Rearrange alice's hand
+	state, version, err := s.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hand := &state.Players[0].Hand
+	for i := 1; i < len(hand); i++ {
+		if hand[i] != hand[0] {
+			hand[0], hand[i] = hand[i], hand[0]
+			break
+		}
+	}
+	if err := s.SaveState(ctx, state, version); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := s.VerifyGameState(ctx, "game")
+	if err != nil {
+		t.Fatalf("VerifyGameState: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0] != "players" {
+		t.Fatalf("diffs = %v, want [players]", diffs)
+	}
+}
+
+func TestReplayRejectsTamperedRecordedDeck(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	moveRepo := &fakeMoveRepo{}
+	moveLog := NewMoveLog(moveRepo, repo)
+	s.SetMoveLog(moveLog)
+
+	newShortDeckGame(t, repo, s, "game", GameRules{}, 3)
+	playGame(t, s, moveLog, "game")
+
+	// Swap a card in the first logged reshuffle for one that was never in the deck
+	tampered := false
+	for _, move := range moveRepo.moves {
+		if len(move.Decks) > 0 {
+			var decks [][]CardDef
+			if err := json.Unmarshal(move.Decks, &decks); err != nil {
+				t.Fatal(err)
+			}
+			decks[0][0] = CardDef{Suit: "hearts", Rank: "Joker"}
+			tamperedJSON, err := json.Marshal(decks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			move.Decks = tamperedJSON
+			tampered = true
+			break
+		}
+	}
+	if !tampered {
+		t.Fatal("game never reshuffled")
+	}
+
+	if _, err := s.VerifyGameState(ctx, "game"); !errors.Is(err, ErrRecordedDeckMismatch) {
+		t.Fatalf("err = %v, want ErrRecordedDeckMismatch", err)
+	}
+}
+
+func TestReplayWithoutRecordedDeckFails(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeGameRepo()
+	s := NewGameService(repo, nil)
+	moveRepo := &fakeMoveRepo{}
+	moveLog := NewMoveLog(moveRepo, repo)
+	s.SetMoveLog(moveLog)
+
+	newShortDeckGame(t, repo, s, "game", GameRules{}, 3)
+	playGame(t, s, moveLog, "game")
+
+	for _, move := range moveRepo.moves {
+		move.Decks = nil
+	}
+
+	// An unseeded shuffle can't be reproduced without its deck
+	if _, err := s.VerifyGameState(ctx, "game"); !errors.Is(err, ErrDeckNotRecorded) {
+		t.Fatalf("err = %v, want ErrDeckNotRecorded", err)
+	}
+}
@@ -0,0 +1,91 @@
+package business
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrUnknownRuleset = errors.New("unknown ruleset")
+
+// DefaultTargetScore is the match target used when a game doesn't choose one
+const DefaultTargetScore = 100
+
+// Named rulesets that can be picked when creating a game
+const (
+	RulesetStandard   = "standard"
+	RulesetKingsZero  = "kings_zero"
+	RulesetFourJokers = "four_jokers"
+)
+
+// DefaultJokerCount is the number of jokers in a standard deck
+const DefaultJokerCount = 2
+
+// GameRules holds the optional rules chosen when a game is created.
+// The zero value is the standard game.
+type GameRules struct {
+	Ruleset           string       `json:"ruleset"`
+	Scoring           ScoringRules `json:"scoring"`
+	HideOpponentScore bool         `json:"hideOpponentScore"` // Opponent's visible score is withheld until game end
+
+	ForbidFirstTurnDiscardDraw bool `json:"forbidFirstTurnDiscardDraw"` // The first player may not take the seeded discard
+
+	SpectatorsAllowed bool `json:"spectatorsAllowed"` // Non-players may watch while the game is in progress
+	HideReplay        bool `json:"hideReplay"`        // Non-players may not watch once the game is finished
+
+	TargetScore int `json:"targetScore"` // Play deals until a cumulative score reaches this (0 = single deal)
+
+	TimeoutStrategy string `json:"timeoutStrategy"` // Which card a timed-out turn flips, see TimeoutStrategyFor
+}
+
+// ScoringRules controls which cards are in the deck and how they are valued at the
+// end of a game. The zero value is the standard deck and scoring.
+type ScoringRules struct {
+	KingsZero  bool           `json:"kingsZero"`            // Kings score 0 instead of 10
+	Jokers     *int           `json:"jokers,omitempty"`     // Jokers in the deck (nil = DefaultJokerCount)
+	RankValues map[string]int `json:"rankValues,omitempty"` // Per-rank overrides, applied before everything else
+
+	FourOfAKind      bool `json:"fourOfAKind"`      // Two adjacent matched columns of one rank form a block
+	FourOfAKindBonus int  `json:"fourOfAKindBonus"` // Added per block, e.g. -10 (0 = the block just scores 0)
+}
+
+// JokerCount returns how many jokers go in the deck
+func (r ScoringRules) JokerCount() int {
+	if r.Jokers == nil {
+		return DefaultJokerCount
+	}
+	return *r.Jokers
+}
+
+// RulesForRuleset returns the rules for a named ruleset ("" means standard)
+func RulesForRuleset(name string) (GameRules, error) {
+	switch name {
+	case "", RulesetStandard:
+		return GameRules{Ruleset: RulesetStandard}, nil
+	case RulesetKingsZero:
+		return GameRules{
+			Ruleset: RulesetKingsZero,
+			Scoring: ScoringRules{KingsZero: true},
+		}, nil
+	case RulesetFourJokers:
+		jokers := 4
+		return GameRules{
+			Ruleset: RulesetFourJokers,
+			Scoring: ScoringRules{Jokers: &jokers},
+		}, nil
+	default:
+		return GameRules{}, ErrUnknownRuleset
+	}
+}
+
+// parseGameRules decodes rules stored with a game, falling back to standard rules
+// for games created before rules existed
+func parseGameRules(raw []byte) GameRules {
+	var rules GameRules
+	if len(raw) == 0 {
+		return rules
+	}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return GameRules{}
+	}
+	return rules
+}
@@ -0,0 +1,84 @@
+package business
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestKingsZeroScoresKingsAsNothing(t *testing.T) {
+	kingsZero, err := RulesForRuleset(RulesetKingsZero)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Matched kings cancel either way; the unmatched king is where the rules differ
+	player := faceUpPlayer("alice", "K", "K", "3", "K", "4", "2")
+	for _, tc := range []struct {
+		name  string
+		rules ScoringRules
+		want  int
+	}{
+		{"standard", ScoringRules{}, 19},
+		{"kings_zero", kingsZero.Scoring, 9},
+	} {
+		if got := CalculateScore(&player, tc.rules); got != tc.want {
+			t.Errorf("%s: score = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	matched := faceUpPlayer("alice", "K", "2", "3", "K", "2", "3")
+	if standard, zero := CalculateScore(&matched, ScoringRules{}), CalculateScore(&matched, kingsZero.Scoring); standard != 0 || zero != 0 {
+		t.Errorf("a matched column of kings scores %d standard and %d under kings_zero, want 0 both", standard, zero)
+	}
+}
+
+func TestRulesetsScoreTheSameHand(t *testing.T) {
+	player := faceUpPlayer("alice", "Joker", "K", "5", "5", "Q", "A")
+	for _, tc := range []struct {
+		ruleset   string
+		score     int
+		deckCards int
+	}{
+		{"", 29, 54},
+		{RulesetStandard, 29, 54},
+		{RulesetKingsZero, 19, 54},
+		{RulesetFourJokers, 29, 56},
+	} {
+		rules, err := RulesForRuleset(tc.ruleset)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.ruleset, err)
+		}
+
+		// Rules go through the games table as JSON
+		stored, err := json.Marshal(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = parseGameRules(stored)
+
+		if got := CalculateScore(&player, rules.Scoring); got != tc.score {
+			t.Errorf("%q: score = %d, want %d", tc.ruleset, got, tc.score)
+		}
+		if got := len(newDeck(rules.Scoring.JokerCount())); got != tc.deckCards {
+			t.Errorf("%q: deck has %d cards, want %d", tc.ruleset, got, tc.deckCards)
+		}
+	}
+}
+
+func TestUnknownRulesetIsRejected(t *testing.T) {
+	for _, name := range []string{"kings-zero", "Standard", "six_jokers"} {
+		if _, err := RulesForRuleset(name); !errors.Is(err, ErrUnknownRuleset) {
+			t.Errorf("%q: err = %v, want ErrUnknownRuleset", name, err)
+		}
+	}
+}
+
+func TestUnreadableStoredRulesAreStandard(t *testing.T) {
+	for _, raw := range []string{"", "not json", `{"scoring": 3}`} {
+		rules := parseGameRules([]byte(raw))
+		if rules.Scoring.JokerCount() != DefaultJokerCount || rules.Scoring.KingsZero {
+			t.Errorf("%q parsed as %+v, want standard rules", raw, rules)
+		}
+	}
+}
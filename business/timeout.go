@@ -0,0 +1,88 @@
+package business
+
+import "errors"
+
+var ErrUnknownTimeoutStrategy = errors.New("unknown timeout strategy")
+
+// Named strategies for choosing which card to flip when a turn times out
+const (
+	TimeoutFlipLeftmost   = "leftmost"    // Lowest-index face-down card
+	TimeoutFlipRandom     = "random"      // Any face-down card
+	TimeoutFlipLowestRisk = "lowest_risk" // Card most likely to help, see lowestRiskFlip
+)
+
+// TimeoutStrategy picks the face-down card to flip after a timed-out player's drawn
+// card is discarded. It is only called when the player has at least one face-down card.
+type TimeoutStrategy interface {
+	ChooseFlip(player *PlayerState, rules ScoringRules) int
+}
+
+// TimeoutStrategyFunc adapts a plain function to TimeoutStrategy
+type TimeoutStrategyFunc func(player *PlayerState, rules ScoringRules) int
+
+func (f TimeoutStrategyFunc) ChooseFlip(player *PlayerState, rules ScoringRules) int {
+	return f(player, rules)
+}
+
+var timeoutStrategies = map[string]TimeoutStrategy{
+	TimeoutFlipLeftmost:   TimeoutStrategyFunc(leftmostFlip),
+	TimeoutFlipRandom:     randomFlip{intn: randInt},
+	TimeoutFlipLowestRisk: TimeoutStrategyFunc(lowestRiskFlip),
+}
+
+// TimeoutStrategyFor returns the named strategy ("" means leftmost)
+func TimeoutStrategyFor(name string) (TimeoutStrategy, error) {
+	if name == "" {
+		name = TimeoutFlipLeftmost
+	}
+	strategy, ok := timeoutStrategies[name]
+	if !ok {
+		return nil, ErrUnknownTimeoutStrategy
+	}
+	return strategy, nil
+}
+
+func faceDownIndexes(player *PlayerState) []int {
+	var indexes []int
+	for i, faceUp := range player.FaceUp {
+		if !faceUp {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func leftmostFlip(player *PlayerState, _ ScoringRules) int {
+	return faceDownIndexes(player)[0]
+}
+
+// randomFlip flips any face-down card, picked with intn
+type randomFlip struct {
+	intn func(n int) int // Returns a number in [0, n)
+}
+
+func (f randomFlip) ChooseFlip(player *PlayerState, _ ScoringRules) int {
+	indexes := faceDownIndexes(player)
+	return indexes[f.intn(len(indexes))]
+}
+
+// lowestRiskFlip flips the card under (or over) the highest face-up card, since a
+// match would cancel it and a miss leaves a column that was already bad. Columns
+// with no face-up card are only used when there is nothing better.
+func lowestRiskFlip(player *PlayerState, rules ScoringRules) int {
+	best, bestValue := -1, 0
+	for _, i := range faceDownIndexes(player) {
+		partner := (i + 3) % 6
+		if !player.FaceUp[partner] {
+			continue
+		}
+		value := getCardValue(player.Hand[partner], rules)
+		if best == -1 || value > bestValue {
+			best, bestValue = i, value
+		}
+	}
+	if best == -1 {
+		return leftmostFlip(player, rules)
+	}
+	return best
+}
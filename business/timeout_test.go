@@ -0,0 +1,129 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTimeoutStrategiesChooseTheirCard(t *testing.T) {
+	// Face-up 2, 9 and 5 sit over face-down cards 3, 4 and 5
+	overBadColumn := faceDown(faceUpPlayer("alice", "2", "9", "5", "K", "K", "K"), 3, 4, 5)
+	// Only column 0 is face up, and its cards are both up
+	noOpenColumn := faceDown(faceUpPlayer("alice", "9", "2", "3", "9", "4", "5"), 1, 2, 4, 5)
+
+	var asked []int
+	lastOfN := randomFlip{intn: func(n int) int {
+		asked = append(asked, n)
+		return n - 1
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		strategy TimeoutStrategy
+		player   PlayerState
+		want     int
+	}{
+		{"leftmost", TimeoutStrategyFunc(leftmostFlip), overBadColumn, 3},
+		{"random", lastOfN, overBadColumn, 5},
+		{"lowest risk", TimeoutStrategyFunc(lowestRiskFlip), overBadColumn, 4},
+		{"lowest risk without a face-up partner", TimeoutStrategyFunc(lowestRiskFlip), noOpenColumn, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.strategy.ChooseFlip(&tc.player, ScoringRules{}); got != tc.want {
+				t.Errorf("flips %d, want %d", got, tc.want)
+			}
+		})
+	}
+	if len(asked) != 1 || asked[0] != 3 {
+		t.Errorf("random flip asked for %v, want one pick out of the 3 face-down cards", asked)
+	}
+}
+
+func TestTimeoutStrategyFor(t *testing.T) {
+	strategy, err := TimeoutStrategyFor("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := faceDown(faceUpPlayer("alice", "2", "9", "5", "K", "K", "K"), 3, 4, 5)
+	if got := strategy.ChooseFlip(&player, ScoringRules{}); got != 3 {
+		t.Errorf("default strategy flips %d, want leftmost 3", got)
+	}
+
+	if _, err := TimeoutStrategyFor("psychic"); !errors.Is(err, ErrUnknownTimeoutStrategy) {
+		t.Errorf("err = %v, want ErrUnknownTimeoutStrategy", err)
+	}
+}
+
+// useRandomFlip makes the random timeout strategy pick with intn for one test
+func useRandomFlip(t *testing.T, intn func(n int) int) {
+	t.Helper()
+
+	prev := timeoutStrategies[TimeoutFlipRandom]
+	t.Cleanup(func() { timeoutStrategies[TimeoutFlipRandom] = prev })
+	timeoutStrategies[TimeoutFlipRandom] = randomFlip{intn: intn}
+}
+
+func TestTimedOutTurnReplaysExactly(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy string
+		logIndex bool
+	}{
+		// Older logs have no card index, so the strategy picks again
+		{"leftmost with no logged index", TimeoutFlipLeftmost, false},
+		{"lowest risk with no logged index", TimeoutFlipLowestRisk, false},
+		{"random with the logged index", TimeoutFlipRandom, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeGameRepo()
+			s := NewGameService(repo, nil)
+			moveLog := NewMoveLog(&fakeMoveRepo{}, repo)
+			s.SetMoveLog(moveLog)
+			useRandomFlip(t, func(n int) int { return n - 1 })
+
+			repo.addGame("game", "in_progress", GameRules{TimeoutStrategy: tc.strategy}, "alice", "bob")
+			state := mainGameState(
+				faceDown(faceUpPlayer("alice", "2", "9", "5", "K", "K", "K"), 3, 4, 5),
+				faceDown(faceUpPlayer("bob", "2", "3", "4", "5", "6", "8"), 0, 1),
+			)
+			state.Rules.TimeoutStrategy = tc.strategy
+			stateJSON, err := json.Marshal(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.SaveGameState(ctx, "game", stateJSON); err != nil {
+				t.Fatal(err)
+			}
+
+			state, version, err := s.LoadState(ctx, "game")
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID, cardIndex, err := s.ForceTimeoutMove(state)
+			if err != nil {
+				t.Fatalf("ForceTimeoutMove: %v", err)
+			}
+			if err := s.SaveState(ctx, state, version); err != nil {
+				t.Fatal(err)
+			}
+			var loggedIndex *int
+			if tc.logIndex {
+				loggedIndex = &cardIndex
+			}
+			moveLog.Record(ctx, "game", userID, ActionTimeout, loggedIndex, state)
+
+			// Replaying, a random flip would pick another card
+			useRandomFlip(t, func(n int) int { return 0 })
+			diffs, err := s.VerifyGameState(ctx, "game")
+			if err != nil {
+				t.Fatalf("VerifyGameState: %v", err)
+			}
+			if len(diffs) != 0 {
+				t.Errorf("replay of the timeout differs in %v", diffs)
+			}
+		})
+	}
+}
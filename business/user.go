@@ -5,18 +5,85 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"golf-card-game/database"
+	"log"
+	"regexp"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrInvalidUsername = errors.New("username must be 3-20 characters of letters, numbers and underscores")
+
+// ErrInvalidCredentials is returned by LoginUser for an unknown username or a wrong password
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,20}$`)
+
 type UserService struct {
 	userRepo database.UserRepository // Interface, not concrete type
+
+	// sendPasswordReset delivers a reset token, see SetPasswordResetMailer
+	sendPasswordReset func(user *database.User, token string) error
+
+	// sendVerification delivers an email verification token, see SetVerificationMailer
+	sendVerification func(user *database.User, token string) error
+
+	// Sessions last sessionTTL and are renewed once less than sessionRenewWithin of it is left
+	sessionTTL         time.Duration
+	sessionRenewWithin time.Duration
+
+	// loginThrottle locks out repeated failed logins, see SetLoginLockout
+	loginThrottle *loginThrottle
+
+	// bcryptCost is the work factor for new password hashes, see SetBcryptCost
+	bcryptCost int
+
+	// users caches lookups by ID, see GetUserByID
+	users *userCache
+
+	// onAccountDeleted is told about deleted accounts, see SetAccountDeletedHandler
+	onAccountDeleted func(userID string, abandonedGames []string)
 }
 
 func NewUserService(userRepo database.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+	return &UserService{
+		userRepo:           userRepo,
+		sessionTTL:         24 * time.Hour,
+		sessionRenewWithin: 6 * time.Hour,
+		loginThrottle:      newLoginThrottle(),
+		bcryptCost:         bcrypt.DefaultCost,
+		users:              newUserCache(),
+	}
+}
+
+// SetBcryptCost sets the work factor for new password hashes. Costs outside
+// bcrypt's allowed range are rejected and the current cost is kept.
+func (s *UserService) SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	s.bcryptCost = cost
+	return nil
+}
+
+// SetSessionPolicy sets how long sessions last and what fraction of that may remain
+// before an active session is renewed. Invalid values are ignored.
+func (s *UserService) SetSessionPolicy(ttl time.Duration, renewThreshold float64) {
+	if ttl > 0 {
+		s.sessionTTL = ttl
+	}
+	if renewThreshold > 0 && renewThreshold <= 1 {
+		s.sessionRenewWithin = time.Duration(float64(s.sessionTTL) * renewThreshold)
+	} else {
+		s.sessionRenewWithin = s.sessionTTL / 4
+	}
+}
+
+// SessionTTL is how long a new or renewed session lasts
+func (s *UserService) SessionTTL() time.Duration {
+	return s.sessionTTL
 }
 
 func (s *UserService) GetUser(ctx context.Context, username string) (*database.User, error) {
@@ -24,8 +91,45 @@ func (s *UserService) GetUser(ctx context.Context, username string) (*database.U
 	return s.userRepo.GetUserByUsername(ctx, username)
 }
 
+// GetUserByID retrieves a user, from the cache when it was looked up recently
 func (s *UserService) GetUserByID(ctx context.Context, userID string) (*database.User, error) {
-	return s.userRepo.GetUserByID(ctx, userID)
+	if user, ok := s.users.get(userID); ok {
+		return user, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.users.set(user)
+	return user, nil
+}
+
+// GetUsersByIDs retrieves many users at once, keyed by userID. Only the users
+// missing from the cache are read from the database.
+func (s *UserService) GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*database.User, error) {
+	users := make(map[string]*database.User, len(userIDs))
+	var missing []string
+	for _, userID := range userIDs {
+		if user, ok := s.users.get(userID); ok {
+			users[userID] = user
+		} else {
+			missing = append(missing, userID)
+		}
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	fetched, err := s.userRepo.GetUsersByIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for userID, user := range fetched {
+		s.users.set(user)
+		users[userID] = user
+	}
+	return users, nil
 }
 
 // RegisterUser creates a new user with a hashed password
@@ -35,11 +139,15 @@ func (s *UserService) RegisterUser(ctx context.Context, username, password, emai
 		return nil, errors.New("username and password are required")
 	}
 
-	if len(password) < 8 {
-		return nil, errors.New("password must be at least 8 characters")
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
 	}
 
-	// Check if username already exists
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	// Check if username already exists, ignoring case
 	exists, err := s.userRepo.UserExists(ctx, username)
 	if err != nil {
 		return nil, err
@@ -58,7 +166,7 @@ func (s *UserService) RegisterUser(ctx context.Context, username, password, emai
 	}
 
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
 	if err != nil {
 		return nil, err
 	}
@@ -76,39 +184,119 @@ func (s *UserService) RegisterUser(ctx context.Context, username, password, emai
 	return user, nil
 }
 
-// LoginUser validates credentials and returns a session token
-func (s *UserService) LoginUser(ctx context.Context, username, password string) (string, error) {
+// LoginUser validates credentials and returns a session token and the user it belongs
+// to. userAgent and ipAddress are kept with the session so the user can recognize it later.
+// Bad credentials give ErrInvalidCredentials and too many of them ErrAccountLocked.
+func (s *UserService) LoginUser(ctx context.Context, username, password, userAgent, ipAddress string) (token, userID string, err error) {
+	// The attempt counts as a failure until the password checks out
+	if !s.loginThrottle.reserve(username, ipAddress) {
+		return "", "", ErrAccountLocked
+	}
+
 	// Get user from database
 	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
-		return "", errors.New("invalid username or password")
+		compareDummyPassword(password, s.bcryptCost)
+		return "", "", ErrInvalidCredentials
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", errors.New("invalid username or password")
+		return "", "", ErrInvalidCredentials
 	}
+	s.loginThrottle.reset(username, ipAddress)
+	s.upgradePasswordHash(ctx, user, password)
 
 	// Generate session token
-	token, err := generateSecureToken()
+	token, err = generateSecureToken()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// Create session (expires in 24 hours)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	err = s.userRepo.CreateSession(ctx, user.UserID, token, expiresAt)
+	// Create session
+	expiresAt := time.Now().Add(s.sessionTTL)
+	err = s.userRepo.CreateSession(ctx, user.UserID, token, expiresAt, userAgent, ipAddress)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	return token, user.UserID, nil
+}
+
+// upgradePasswordHash rehashes a just-verified password if its stored hash was made
+// with a lower cost than the one now configured. Failures only mean the upgrade
+// waits for the next login.
+func (s *UserService) upgradePasswordHash(ctx context.Context, user *database.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil || cost >= s.bcryptCost {
+		return
 	}
 
-	return token, nil
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.UserID, err)
+		return
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.UserID, string(hashedPassword)); err != nil {
+		log.Printf("Failed to store rehashed password for user %s: %v", user.UserID, err)
+		return
+	}
+	s.users.forget(user.UserID)
+}
+
+// ChangePassword replaces a logged-in user's password after checking their current one
+func (s *UserService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)); err != nil {
+		return ErrWrongPassword
+	}
+
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), s.bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// Anyone holding a session from before the change loses it
+	return s.LogoutAll(ctx, userID)
 }
 
 // ValidateSession checks if a session token is valid and returns the user ID
 func (s *UserService) ValidateSession(ctx context.Context, token string) (string, error) {
-	return s.userRepo.ValidateSession(ctx, token)
+	userID, _, err := s.userRepo.ValidateSession(ctx, token)
+	return userID, err
+}
+
+// ValidateAndRenewSession checks a session like ValidateSession and, if it is near
+// the end of its lifetime, extends it by a full TTL so active users stay signed in.
+// renewed reports whether the caller should refresh the session cookie.
+func (s *UserService) ValidateAndRenewSession(ctx context.Context, token string) (userID string, renewed bool, err error) {
+	userID, expiresAt, err := s.userRepo.ValidateSession(ctx, token)
+	if err != nil {
+		return "", false, err
+	}
+
+	if time.Until(expiresAt) > s.sessionRenewWithin {
+		return userID, false, nil
+	}
+	if err := s.userRepo.RefreshSession(ctx, token, time.Now().Add(s.sessionTTL)); err != nil {
+		// The session is still valid until it expires, so don't fail the request
+		log.Printf("Failed to renew session for user %s: %v", userID, err)
+		return userID, false, nil
+	}
+	return userID, true, nil
 }
 
 // LogoutUser deletes the session
@@ -116,6 +304,28 @@ func (s *UserService) LogoutUser(ctx context.Context, token string) error {
 	return s.userRepo.DeleteSession(ctx, token)
 }
 
+// GetSessions lists the user's signed-in sessions
+func (s *UserService) GetSessions(ctx context.Context, userID string) ([]*database.Session, error) {
+	return s.userRepo.GetSessions(ctx, userID)
+}
+
+// RevokeSession signs out one of the user's sessions
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return s.userRepo.RevokeSession(ctx, userID, sessionID)
+}
+
+// LogoutAll deletes every session the user has, on every device
+func (s *UserService) LogoutAll(ctx context.Context, userID string) error {
+	s.users.forget(userID)
+	return s.userRepo.DeleteAllSessions(ctx, userID)
+}
+
+// MarkEmailUndeliverable records that mail to the user's address can't be delivered
+func (s *UserService) MarkEmailUndeliverable(ctx context.Context, userID string) error {
+	defer s.users.forget(userID)
+	return s.userRepo.MarkEmailUndeliverable(ctx, userID)
+}
+
 // generateSecureToken creates a cryptographically secure random token
 // TODO - replace with specific token generation methods discussed in class
 func generateSecureToken() (string, error) {
@@ -0,0 +1,57 @@
+package business
+
+import (
+	"sync"
+	"time"
+
+	"golf-card-game/database"
+)
+
+// userCacheTTL bounds how stale a cached user can get if an invalidation is missed
+const userCacheTTL = time.Minute
+
+type cachedUser struct {
+	user      database.User
+	expiresAt time.Time
+}
+
+// userCache keeps recently looked-up users in memory, so the hubs don't query the
+// database for the same users on every broadcast. It hands out copies, so callers
+// can't change what's cached.
+type userCache struct {
+	mu    sync.RWMutex
+	users map[string]cachedUser
+}
+
+func newUserCache() *userCache {
+	return &userCache{users: make(map[string]cachedUser)}
+}
+
+func (c *userCache) get(userID string) (*database.User, bool) {
+	c.mu.RLock()
+	entry, ok := c.users[userID]
+	c.mu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	user := entry.user
+	return &user, true
+}
+
+func (c *userCache) set(user *database.User) {
+	c.mu.Lock()
+	c.users[user.UserID] = cachedUser{user: *user, expiresAt: time.Now().Add(userCacheTTL)}
+	c.mu.Unlock()
+}
+
+func (c *userCache) forget(userID string) {
+	c.mu.Lock()
+	delete(c.users, userID)
+	c.mu.Unlock()
+}
+
+// ForgetUser drops a user from the lookup cache, so the next GetUserByID reads
+// them fresh from the database
+func (s *UserService) ForgetUser(userID string) {
+	s.users.forget(userID)
+}
@@ -0,0 +1,80 @@
+package business
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestGetUsersByIDsReadsOnlyWhatIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo("alice", "bob", "carol")
+	s := NewUserService(repo)
+	fetch := func(userIDs ...string) map[string]string {
+		t.Helper()
+		users, err := s.GetUsersByIDs(ctx, userIDs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		usernames := make(map[string]string, len(users))
+		for userID, user := range users {
+			usernames[userID] = user.Username
+		}
+		return usernames
+	}
+	lastFetch := func() []string {
+		if len(repo.fetched) == 0 {
+			return nil
+		}
+		fetched := slices.Clone(repo.fetched[len(repo.fetched)-1])
+		slices.Sort(fetched)
+		return fetched
+	}
+
+	// A single read looks alice up and caches her
+	if _, err := s.GetUserByID(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the misses go to the database, and unknown users come back absent
+	if got := fetch("alice", "bob", "nobody"); len(got) != 2 || got["alice"] != "alice" || got["bob"] != "bob" {
+		t.Fatalf("first fetch = %v, want alice and bob", got)
+	}
+	if want := []string{"bob", "nobody"}; !slices.Equal(lastFetch(), want) {
+		t.Fatalf("first fetch read %v, want %v", lastFetch(), want)
+	}
+
+	// Everything cached means no read at all
+	calls := len(repo.fetched)
+	fetch("alice", "bob")
+	if len(repo.fetched) != calls {
+		t.Fatalf("fetching cached users read %v", lastFetch())
+	}
+
+	// Changing a returned user doesn't change the cache
+	users, _ := s.GetUsersByIDs(ctx, []string{"alice"})
+	users["alice"].Username = "mallory"
+	if got := fetch("alice"); got["alice"] != "alice" {
+		t.Fatalf("cached username = %q after a caller changed its copy", got["alice"])
+	}
+
+	// Logging out everywhere drops the user, so the next fetch sees their new name
+	repo.users["bob"].Username = "robert"
+	if err := s.LogoutAll(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fetch("alice", "bob"); got["bob"] != "robert" || !slices.Equal(lastFetch(), []string{"bob"}) {
+		t.Fatalf("after logout: %v, read %v; want robert read fresh", got, lastFetch())
+	}
+
+	// Until it's forgotten, a cached user hides changes made underneath it
+	fetch("carol")
+	repo.users["carol"].Username = "caz"
+	if got := fetch("carol"); got["carol"] != "carol" {
+		t.Fatalf("cached carol = %q, want the cached %q", got["carol"], "carol")
+	}
+	s.ForgetUser("carol")
+	if got := fetch("carol"); got["carol"] != "caz" {
+		t.Errorf("after ForgetUser carol = %q, want the fresh %q", got["carol"], "caz")
+	}
+}
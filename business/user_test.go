@@ -0,0 +1,213 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLogoutAllEndsEverySession(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	repo.users["bob"] = &database.User{UserID: "bob", Username: "bob", Password: repo.users["alice"].Password}
+
+	var aliceTokens []string
+	for _, device := range []string{"phone", "laptop"} {
+		token, _, err := s.LoginUser(ctx, "alice", "correct horse", device, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		aliceTokens = append(aliceTokens, token)
+	}
+	bobToken, _, err := s.LoginUser(ctx, "bob", "correct horse", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.LogoutAll(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for i, token := range aliceTokens {
+		if userID, err := s.ValidateSession(ctx, token); err == nil {
+			t.Errorf("alice's session %d still belongs to %q", i+1, userID)
+		}
+	}
+	if userID, err := s.ValidateSession(ctx, bobToken); err != nil || userID != "bob" {
+		t.Errorf("bob's session = %q, %v, want it untouched", userID, err)
+	}
+}
+
+func TestActiveSessionsSlideWhileIdleOnesLapse(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	s.SetSessionPolicy(time.Hour, 0.25)
+	login := func() (string, *fakeSession) {
+		t.Helper()
+		token, _, err := s.LoginUser(ctx, "alice", "correct horse", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token, repo.sessions[token]
+	}
+
+	// Early in its life a session is left alone
+	active, activeSession := login()
+	expiry := activeSession.expiresAt
+	if userID, renewed, err := s.ValidateAndRenewSession(ctx, active); err != nil || userID != "alice" || renewed {
+		t.Fatalf("fresh session: %q, renewed %v, %v", userID, renewed, err)
+	}
+	if !activeSession.expiresAt.Equal(expiry) {
+		t.Fatal("a fresh session's expiry moved")
+	}
+
+	// In its last quarter, use pushes it a full TTL out
+	activeSession.expiresAt = time.Now().Add(10 * time.Minute)
+	if _, renewed, err := s.ValidateAndRenewSession(ctx, active); err != nil || !renewed {
+		t.Fatalf("ageing session: renewed %v, %v", renewed, err)
+	}
+	if left := time.Until(activeSession.expiresAt); left < 59*time.Minute || left > time.Hour {
+		t.Fatalf("renewed session has %v left, want the full hour", left)
+	}
+
+	// One nobody used in time stays expired
+	idle, idleSession := login()
+	idleSession.expiresAt = time.Now().Add(-time.Second)
+	if _, _, err := s.ValidateAndRenewSession(ctx, idle); err == nil {
+		t.Fatal("an expired session was accepted")
+	}
+	if !idleSession.expiresAt.Before(time.Now()) {
+		t.Fatal("an expired session was renewed")
+	}
+}
+
+func TestRevokingOneSessionLeavesTheOthers(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	repo.users["bob"] = &database.User{UserID: "bob", Username: "bob", Password: repo.users["alice"].Password}
+
+	tokens := make(map[string]string) // user agent -> token
+	for _, device := range []string{"phone", "laptop"} {
+		token, _, err := s.LoginUser(ctx, "alice", "correct horse", device, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[device] = token
+	}
+	bobToken, _, err := s.LoginUser(ctx, "bob", "correct horse", "tablet", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.GetSessions(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("alice has %d sessions listed, want 2", len(sessions))
+	}
+	ids := make(map[string]string) // user agent -> session ID
+	for _, session := range sessions {
+		if session.ID == tokens[session.UserAgent] || session.ID != database.SessionIDForToken(tokens[session.UserAgent]) {
+			t.Fatalf("session from %q has ID %q, want one derived from its token", session.UserAgent, session.ID)
+		}
+		ids[session.UserAgent] = session.ID
+	}
+
+	// Nobody can revoke a session that isn't theirs
+	if err := s.RevokeSession(ctx, "alice", database.SessionIDForToken(bobToken)); !errors.Is(err, database.ErrSessionNotFound) {
+		t.Fatalf("revoking bob's session as alice: err = %v, want ErrSessionNotFound", err)
+	}
+
+	if err := s.RevokeSession(ctx, "alice", ids["phone"]); err != nil {
+		t.Fatal(err)
+	}
+	for token, want := range map[string]string{tokens["phone"]: "", tokens["laptop"]: "alice", bobToken: "bob"} {
+		if userID, _ := s.ValidateSession(ctx, token); userID != want {
+			t.Errorf("session belongs to %q after revoking the phone, want %q", userID, want)
+		}
+	}
+}
+
+func TestRegisterUserValidatesUsernamesAndIgnoresCase(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	repo.users["alice"].Username = "Alice"
+	const password = "Tr0ub4dor&3x"
+
+	for _, username := range []string{"al", strings.Repeat("a", 21), "bob smith", "bob-smith", "bób"} {
+		if _, err := s.RegisterUser(ctx, username, password, ""); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: err = %v, want ErrInvalidUsername", username, err)
+		}
+	}
+
+	for _, username := range []string{"alice", "ALICE", "aLiCe"} {
+		if _, err := s.RegisterUser(ctx, username, password, ""); !errors.Is(err, database.ErrUserAlreadyExists) {
+			t.Errorf("%q: err = %v, want ErrUserAlreadyExists", username, err)
+		}
+	}
+
+	user, err := s.RegisterUser(ctx, "Bob_42", password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "Bob_42" {
+		t.Errorf("username stored as %q, want its display casing kept", user.Username)
+	}
+	if found, err := s.GetUser(ctx, "bob_42"); err != nil || found.UserID != user.UserID {
+		t.Errorf("GetUser(bob_42) = %v, %v, want Bob_42", found, err)
+	}
+}
+
+func TestLoginUpgradesAHashMadeWithALowerCost(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newLoginTestService(t, "correct horse")
+	for _, cost := range []int{bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if err := s.SetBcryptCost(cost); err == nil {
+			t.Errorf("cost %d was accepted", cost)
+		}
+	}
+	storedCost := func() int {
+		t.Helper()
+		cost, err := bcrypt.Cost([]byte(repo.users["alice"].Password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cost
+	}
+
+	if err := s.SetBcryptCost(bcrypt.MinCost + 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LoginUser(ctx, "alice", "wrong", "", ""); err == nil {
+		t.Fatal("logged in with the wrong password")
+	}
+	if cost := storedCost(); cost != bcrypt.MinCost {
+		t.Fatalf("a failed login rehashed the password to cost %d", cost)
+	}
+
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if cost := storedCost(); cost != bcrypt.MinCost+1 {
+		t.Fatalf("stored cost after login = %d, want %d", cost, bcrypt.MinCost+1)
+	}
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", ""); err != nil {
+		t.Fatalf("login with the rehashed password: %v", err)
+	}
+
+	// Lowering the cost never downgrades an existing hash
+	if err := s.SetBcryptCost(bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LoginUser(ctx, "alice", "correct horse", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if cost := storedCost(); cost != bcrypt.MinCost+1 {
+		t.Errorf("stored cost after lowering the setting = %d, want it kept at %d", cost, bcrypt.MinCost+1)
+	}
+}
@@ -2,8 +2,12 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,63 +18,151 @@ import (
 var (
 	ErrUserAlreadyExists  = errors.New("username already exists")
 	ErrEmailAlreadyExists = errors.New("email already exists")
+	ErrStateNotFound      = errors.New("game state not found")
+	ErrStateExists        = errors.New("game state already exists")
+	ErrRematchExists      = errors.New("a rematch already exists for this game")
+	ErrVersionConflict    = errors.New("version mismatch: game state was modified by another process")
+	ErrGameNotFound       = errors.New("game not found")
+	ErrMessageNotFound    = errors.New("chat message not found")
+	ErrNotMessageSender   = errors.New("only the sender can change a chat message")
+	ErrTooManyReactions   = errors.New("message already has the maximum number of reaction types")
+	ErrInvalidResetToken  = errors.New("password reset token is invalid or expired")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidVerifyToken = errors.New("email verification token is invalid or expired")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrFriendRequestSent  = errors.New("friend request already sent")
+	ErrAlreadyFriends     = errors.New("already friends")
+	ErrFriendshipNotFound = errors.New("friendship not found")
 )
 
+// MaxReactionTypes caps how many different emojis one message can collect
+const MaxReactionTypes = 6
+
+// LeaderboardMinGames is how many finished games a player needs to appear on the
+// leaderboard, so one lucky game can't top it
+const LeaderboardMinGames = 5
+
 // Interface - this is what other layers depend on
 type UserRepository interface {
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
 	GetUserByID(ctx context.Context, userID string) (*User, error)
+	GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*User, error)
 	UserExists(ctx context.Context, username string) (bool, error)
 	EmailExists(ctx context.Context, email string) (bool, error)
 	CreateUser(ctx context.Context, username, hashedPassword, email string) (*User, error)
-	CreateSession(ctx context.Context, userID, token string, expiresAt time.Time) error
-	ValidateSession(ctx context.Context, token string) (string, error) // Returns userID if valid
+	CreateSession(ctx context.Context, userID, token string, expiresAt time.Time, userAgent, ipAddress string) error
+	GetSessions(ctx context.Context, userID string) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	ValidateSession(ctx context.Context, token string) (string, time.Time, error) // Returns userID and expiry if valid
+	RefreshSession(ctx context.Context, token string, newExpiry time.Time) error
 	DeleteSession(ctx context.Context, token string) error
+	DeleteAllSessions(ctx context.Context, userID string) error
+	MarkEmailUndeliverable(ctx context.Context, userID string) error
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, userID, hashedPassword string) error
+	CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error
+	ResetPasswordWithToken(ctx context.Context, tokenHash, hashedPassword string) (string, error) // Returns userID
+	CreateEmailVerificationToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error
+	VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, error)         // Returns userID
+	DeleteUser(ctx context.Context, userID, placeholderUserID string) ([]string, error) // Returns public IDs of the games it abandoned
 }
 
 type ChatRepository interface {
 	SaveMessage(ctx context.Context, senderUserID, scope, messageText string) (*ChatMessage, error)
 	GetMessagesByScope(ctx context.Context, scope string, limit int) ([]*ChatMessage, error)
+	GetMessagesBefore(ctx context.Context, scope string, beforeID int, limit int) ([]*ChatMessage, error)
+	GetMessageScope(ctx context.Context, messageID int) (string, error)
+	UpdateMessage(ctx context.Context, messageID int, senderUserID, newText string) error
+	DeleteMessage(ctx context.Context, messageID int, senderUserID string) error
+	ToggleReaction(ctx context.Context, messageID int, userID, emoji string) (added bool, err error)
+	GetReactionCounts(ctx context.Context, messageID int) (map[string]int, error)
+	MarkScopeRead(ctx context.Context, userID, scope string, upToID int) error
+	GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error)
 }
 
 type GameRepository interface {
-	CreateGame(ctx context.Context, createdByUserID string, maxPlayers int) (*Game, error)
+	CreateGame(ctx context.Context, createdByUserID string, maxPlayers int, rulesJSON []byte) (*Game, error)
 	GetGameByPublicID(ctx context.Context, publicID string) (*Game, error)
+	GetGameByJoinToken(ctx context.Context, token string) (*Game, error)
+	SetJoinToken(ctx context.Context, publicID string, token string) error
 	AddPlayer(ctx context.Context, publicID string, userID string, orderIndex int) error
 	DeletePlayer(ctx context.Context, publicID string, userID string) error
 	UpdatePlayerStatus(ctx context.Context, publicID string, userID string, isActive bool, joinedAt *time.Time) error
 	UpdatePlayerScore(ctx context.Context, publicID string, userID string, score int) error
 	GetGamePlayers(ctx context.Context, publicID string) ([]*GamePlayer, error)
+	GetActivePlayerIDs(ctx context.Context, publicID string) ([]string, error)
 	GetPendingInvitations(ctx context.Context, userID string) ([]*GameInvitation, error)
 	GetActiveGames(ctx context.Context, userID string) ([]*Game, error)
 	UpdateGameStatus(ctx context.Context, publicID string, status string) error
-	FinishGame(ctx context.Context, publicID string, winnerUserID string) error
+	FinishGame(ctx context.Context, publicID string, winnerUserID *string) error
 	SaveGameState(ctx context.Context, publicID string, stateJSON []byte) error
 	LoadGameState(ctx context.Context, publicID string) ([]byte, int, error)
+	LoadInitialGameState(ctx context.Context, publicID string) ([]byte, error)
 	UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error
 	GetInactiveGames(ctx context.Context, inactiveDuration time.Duration) ([]*Game, error)
+	GetStaleInProgressGames(ctx context.Context, cutoff time.Time) ([]*Game, error)
+	CreateRematchGame(ctx context.Context, originalGameID int, createdByUserID string, maxPlayers int, rulesJSON []byte) (*Game, error)
+	GetRematchGame(ctx context.Context, originalGameID int) (*Game, error)
+	AbandonGame(ctx context.Context, publicID string) error
 	DeleteGame(ctx context.Context, publicID string) error
+	GetStreak(ctx context.Context, userID string) (int, error)
+	GetPlayerStats(ctx context.Context, userID string) (*PlayerStats, error)
+	GetLeaderboard(ctx context.Context, metric string, limit int) ([]*LeaderboardEntry, error)
+	GetCompletedGames(ctx context.Context, userID string, after *GameCursor, limit int) ([]*Game, error)
+	GetPlayersForGames(ctx context.Context, gameIDs []int) (map[int][]*GamePlayer, error)
+
+	// WithTx runs fn in a transaction. Methods on the repository passed to fn join it;
+	// returning an error from fn rolls everything back.
+	WithTx(ctx context.Context, fn func(tx GameRepository) error) error
+}
+
+type MoveLogRepository interface {
+	RecordMove(ctx context.Context, gameID int, userID string, action string, cardIndex *int, decksJSON []byte, resultingVersion int) error
+	GetMoves(ctx context.Context, gameID int) ([]*Move, error)
+}
+
+type GameEventRepository interface {
+	AppendGameEvent(ctx context.Context, publicID string, event *GameEvent) error
+	GetGameEvents(ctx context.Context, publicID string, limit int) ([]*GameEvent, error)
+}
+
+type AuditRepository interface {
+	AppendEvent(ctx context.Context, event *AuditEvent) error
+	GetEvents(ctx context.Context, userID string, eventType string, limit int) ([]*AuditEvent, error)
+}
+
+type FriendRepository interface {
+	SendRequest(ctx context.Context, requesterID, addresseeID string) error
+	AcceptRequest(ctx context.Context, requesterID, addresseeID string) error
+	RemoveFriend(ctx context.Context, userID, otherUserID string) error
+	ListFriends(ctx context.Context, userID string) ([]*User, error)
+	ListFriendRequests(ctx context.Context, userID string) ([]*User, error) // Pending requests sent to userID
 }
 
 type ChatMessage struct {
-	ChatMessageID  int
-	SenderUserID   string
-	SenderUsername string
-	Scope          string
-	MessageText    string
-	CreatedAt      time.Time
+	ChatMessageID  int            `json:"chatMessageId"`
+	SenderUserID   string         `json:"senderUserId"`
+	SenderUsername string         `json:"senderUsername"`
+	Scope          string         `json:"scope"`
+	MessageText    string         `json:"messageText"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	EditedAt       *time.Time     `json:"editedAt,omitempty"`
+	Deleted        bool           `json:"deleted,omitempty"`
+	Reactions      map[string]int `json:"reactions,omitempty"` // Emoji -> count
+	System         bool           `json:"system,omitempty"`    // Posted by the server, with no sender
 }
 
 type Game struct {
-	GameID       int        `json:"-"`
-	PublicID     string     `json:"publicId"`
-	CreatedBy    string     `json:"createdBy"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	Status       string     `json:"status"`
-	MaxPlayers   int        `json:"maxPlayers"`
-	PlayerCount  int        `json:"playerCount"`
-	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
-	WinnerUserID *string    `json:"winnerUserId,omitempty"`
+	GameID       int             `json:"-"`
+	PublicID     string          `json:"publicId"`
+	CreatedBy    string          `json:"createdBy"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	Status       string          `json:"status"`
+	MaxPlayers   int             `json:"maxPlayers"`
+	PlayerCount  int             `json:"playerCount"`
+	FinishedAt   *time.Time      `json:"finishedAt,omitempty"`
+	WinnerUserID *string         `json:"winnerUserId,omitempty"`
+	Rules        json.RawMessage `json:"rules,omitempty"`
 }
 
 type GamePlayer struct {
@@ -85,6 +177,24 @@ type GamePlayer struct {
 	IsActive     bool
 }
 
+// PlayerStats summarizes a user's finished games. Lower scores are better in golf.
+type PlayerStats struct {
+	GamesPlayed  int     `json:"gamesPlayed"`
+	Wins         int     `json:"wins"`
+	AverageScore float64 `json:"averageScore"`
+	BestScore    int     `json:"bestScore"`
+}
+
+// LeaderboardEntry is one player's row on the leaderboard. Players tied on the
+// ranked metric share a rank.
+type LeaderboardEntry struct {
+	Rank         int     `json:"rank"`
+	Username     string  `json:"username"`
+	GamesPlayed  int     `json:"gamesPlayed"`
+	Wins         int     `json:"wins"`
+	AverageScore float64 `json:"averageScore"`
+}
+
 type GameInvitation struct {
 	GameID            int       `json:"-"`
 	PublicID          string    `json:"publicId"`
@@ -94,6 +204,59 @@ type GameInvitation struct {
 	CreatedAt         time.Time `json:"createdAt"`
 }
 
+// Move is one committed engine action from a game's move log
+type Move struct {
+	GameMoveID       int             `json:"gameMoveId"`
+	GameID           int             `json:"-"`
+	UserID           string          `json:"userId"` // Empty for moves the server made on nobody's behalf
+	Action           string          `json:"action"`
+	CardIndex        *int            `json:"cardIndex,omitempty"`
+	Decks            json.RawMessage `json:"decks,omitempty"` // Decks the move shuffled, if any
+	ResultingVersion int             `json:"resultingVersion"`
+	CreatedAt        time.Time       `json:"createdAt"`
+}
+
+type GameEvent struct {
+	GameEventID int               `json:"gameEventId"`
+	GameID      int               `json:"-"`
+	EventType   string            `json:"eventType"`
+	UserID      *string           `json:"userId,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+type AuditEvent struct {
+	AuditLogID int               `json:"auditLogId"`
+	EventType  string            `json:"eventType"`
+	UserID     *string           `json:"userId,omitempty"`
+	IPAddress  string            `json:"ipAddress"`
+	UserAgent  string            `json:"userAgent"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// Session is a signed-in device as shown to its user. ID is derived from the token,
+// which itself is never exposed.
+type Session struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastActive time.Time `json:"lastActive"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // The session making the request
+}
+
+// sessionIDSQL derives a session's public ID from its token, matching SessionIDForToken
+const sessionIDSQL = `left(encode(sha256(convert_to(token, 'UTF8')), 'hex'), 32)`
+
+// SessionIDForToken returns the public ID of the session with this token
+func SessionIDForToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
 type postgresUserRepo struct {
 	pool *pgxpool.Pool
 }
@@ -103,6 +266,9 @@ type User struct {
 	Username string
 	Password string
 	Email    string
+
+	EmailUndeliverable bool // Set when a send to Email failed permanently
+	EmailVerified      bool // Set once the user follows the link in their verification email
 }
 
 func NewUserRepository(pool *pgxpool.Pool) UserRepository {
@@ -112,8 +278,8 @@ func NewUserRepository(pool *pgxpool.Pool) UserRepository {
 func (r *postgresUserRepo) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	var user User
 	err := r.pool.QueryRow(ctx,
-		"SELECT user_id, username, password, email FROM users WHERE username = $1", username).
-		Scan(&user.UserID, &user.Username, &user.Password, &user.Email)
+		"SELECT user_id, username, password, email, email_undeliverable, email_verified FROM users WHERE username_lower = lower($1)", username).
+		Scan(&user.UserID, &user.Username, &user.Password, &user.Email, &user.EmailUndeliverable, &user.EmailVerified)
 	if err != nil {
 		return nil, err
 	}
@@ -123,18 +289,45 @@ func (r *postgresUserRepo) GetUserByUsername(ctx context.Context, username strin
 func (r *postgresUserRepo) GetUserByID(ctx context.Context, userID string) (*User, error) {
 	var user User
 	err := r.pool.QueryRow(ctx,
-		"SELECT user_id, username, password, email FROM users WHERE user_id = $1", userID).
-		Scan(&user.UserID, &user.Username, &user.Password, &user.Email)
+		"SELECT user_id, username, password, email, email_undeliverable, email_verified FROM users WHERE user_id = $1", userID).
+		Scan(&user.UserID, &user.Username, &user.Password, &user.Email, &user.EmailUndeliverable, &user.EmailVerified)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// GetUsersByIDs looks up many users in one query, keyed by userID. IDs with no user are left out.
+func (r *postgresUserRepo) GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*User, error) {
+	users := make(map[string]*User, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT user_id, username, COALESCE(password, ''), COALESCE(email, ''), email_undeliverable, email_verified
+		 FROM users WHERE user_id = ANY($1)`,
+		userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.UserID, &user.Username, &user.Password, &user.Email, &user.EmailUndeliverable, &user.EmailVerified); err != nil {
+			return nil, err
+		}
+		users[user.UserID] = &user
+	}
+
+	return users, rows.Err()
+}
+
 func (r *postgresUserRepo) UserExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 	err := r.pool.QueryRow(ctx,
-		"SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).
+		"SELECT EXISTS(SELECT 1 FROM users WHERE username_lower = lower($1))", username).
 		Scan(&exists)
 	if err != nil {
 		return false, err
@@ -164,7 +357,7 @@ func (r *postgresUserRepo) CreateUser(ctx context.Context, username, hashedPassw
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			// 23505 is the PostgreSQL error code for unique_violation
 			if pgErr.Code == "23505" {
-				if pgErr.ConstraintName == "users_username_key" {
+				if pgErr.ConstraintName == "users_username_key" || pgErr.ConstraintName == "users_username_lower_key" {
 					return nil, ErrUserAlreadyExists
 				}
 				if pgErr.ConstraintName == "users_email_key" {
@@ -177,20 +370,67 @@ func (r *postgresUserRepo) CreateUser(ctx context.Context, username, hashedPassw
 	return &user, nil
 }
 
-func (r *postgresUserRepo) CreateSession(ctx context.Context, userID, token string, expiresAt time.Time) error {
-	_, err := r.pool.Exec(ctx,
-		"INSERT INTO sessions (user_id, token, expires_at, type) VALUES ($1, $2, $3, 'web')",
-		userID, token, expiresAt)
+func (r *postgresUserRepo) CreateSession(ctx context.Context, userID, token string, expiresAt time.Time, userAgent, ipAddress string) error {
+	metadata, err := json.Marshal(map[string]string{"userAgent": userAgent, "ipAddress": ipAddress})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.pool.Exec(ctx,
+		"INSERT INTO sessions (user_id, token, expires_at, type, metadata) VALUES ($1, $2, $3, 'web', $4)",
+		userID, token, expiresAt, metadata)
 	return err
 }
 
-func (r *postgresUserRepo) ValidateSession(ctx context.Context, token string) (string, error) {
+// GetSessions lists the user's unexpired sessions, most recently active first
+func (r *postgresUserRepo) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+sessionIDSQL+`, COALESCE(type, ''),
+		        COALESCE(metadata->>'userAgent', ''), COALESCE(metadata->>'ipAddress', ''),
+		        created_at, last_active, expires_at
+		 FROM sessions
+		 WHERE user_id = $1 AND expires_at > now()
+		 ORDER BY last_active DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.Type, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastActive, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession signs out one of the user's sessions by its public ID
+func (r *postgresUserRepo) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM sessions WHERE user_id = $1 AND `+sessionIDSQL+` = $2`,
+		userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *postgresUserRepo) ValidateSession(ctx context.Context, token string) (string, time.Time, error) {
 	var userID string
+	var expiresAt time.Time
 	err := r.pool.QueryRow(ctx,
-		"SELECT user_id FROM sessions WHERE token = $1 AND expires_at > now()",
-		token).Scan(&userID)
+		"SELECT user_id, expires_at FROM sessions WHERE token = $1 AND expires_at > now()",
+		token).Scan(&userID, &expiresAt)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	// Update last_active
@@ -198,7 +438,15 @@ func (r *postgresUserRepo) ValidateSession(ctx context.Context, token string) (s
 		"UPDATE sessions SET last_active = now() WHERE token = $1",
 		token)
 
-	return userID, nil
+	return userID, expiresAt, nil
+}
+
+// RefreshSession pushes back a live session's expiry
+func (r *postgresUserRepo) RefreshSession(ctx context.Context, token string, newExpiry time.Time) error {
+	_, err := r.pool.Exec(ctx,
+		"UPDATE sessions SET expires_at = $2 WHERE token = $1 AND expires_at > now()",
+		token, newExpiry)
+	return err
 }
 
 func (r *postgresUserRepo) DeleteSession(ctx context.Context, token string) error {
@@ -208,189 +456,868 @@ func (r *postgresUserRepo) DeleteSession(ctx context.Context, token string) erro
 	return err
 }
 
-// Chat Repository Implementation
-type postgresChatRepo struct {
-	pool *pgxpool.Pool
+// DeleteAllSessions signs the user out everywhere
+func (r *postgresUserRepo) DeleteAllSessions(ctx context.Context, userID string) error {
+	_, err := r.pool.Exec(ctx,
+		"DELETE FROM sessions WHERE user_id = $1",
+		userID)
+	return err
 }
 
-func NewChatRepository(pool *pgxpool.Pool) ChatRepository {
-	return &postgresChatRepo{pool: pool}
-}
+// DeleteUser removes a user and everything that identifies them, in one transaction.
+// Unfinished games they were playing are abandoned. Their place in finished games,
+// and their chat messages (blanked as deleted), are handed to placeholderUserID so
+// opponents keep their history. Direct messages with them are removed outright.
+func (r *postgresUserRepo) DeleteUser(ctx context.Context, userID, placeholderUserID string) ([]string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
-func (r *postgresChatRepo) SaveMessage(ctx context.Context, senderUserID, scope, messageText string) (*ChatMessage, error) {
-	var msg ChatMessage
-	var gameID *int
-	var dbScope string
+	rows, err := tx.Query(ctx,
+		`UPDATE games SET status = 'abandoned', finished_at = now()
+		 WHERE status IN ('waiting_for_players', 'in_progress')
+		   AND game_id IN (SELECT game_id FROM game_players WHERE user_id = $1 AND is_active = true)
+		 RETURNING public_id`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	var abandoned []string
+	for rows.Next() {
+		var publicID string
+		if err := rows.Scan(&publicID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		abandoned = append(abandoned, publicID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	// Parse scope - "global" or "game:123"
-	if scope == "global" {
-		dbScope = "global"
-	} else {
-		// Extract game ID from "game:123" format
-		var gid int
-		if _, err := fmt.Sscanf(scope, "game:%d", &gid); err == nil {
-			dbScope = "game"
-			gameID = &gid
-		} else {
-			dbScope = "global"
+	statements := []struct {
+		sql  string
+		args []any
+	}{
+		// Stop any further moves in the games just abandoned
+		{`UPDATE game_states SET state_json = jsonb_set(state_json, '{phase}', '"finished"')
+		  WHERE game_id IN (SELECT game_id FROM games WHERE public_id = ANY($1::uuid[]))`, []any{abandoned}},
+		// Outstanding invitations are simply withdrawn
+		{`DELETE FROM game_players WHERE user_id = $1
+		    AND game_id IN (SELECT game_id FROM games WHERE status = 'waiting_for_players')`, []any{userID}},
+		{`UPDATE game_players SET user_id = $2 WHERE user_id = $1`, []any{userID, placeholderUserID}},
+		{`UPDATE game_moves SET user_id = $2 WHERE user_id = $1`, []any{userID, placeholderUserID}},
+		{`UPDATE games SET created_by = $2 WHERE created_by = $1`, []any{userID, placeholderUserID}},
+		{`UPDATE games SET winner_user_id = $2 WHERE winner_user_id = $1`, []any{userID, placeholderUserID}},
+		{`DELETE FROM chat_messages WHERE dm_user_a = $1 OR dm_user_b = $1`, []any{userID}},
+		{`UPDATE chat_messages SET sender_user_id = $2, message_text = '', deleted_at = COALESCE(deleted_at, now())
+		  WHERE sender_user_id = $1`, []any{userID, placeholderUserID}},
+		{`DELETE FROM message_reactions WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM chat_read_state WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM sessions WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM password_reset_tokens WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM email_verification_tokens WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM users WHERE user_id = $1`, []any{userID}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			return nil, err
 		}
 	}
 
-	err := r.pool.QueryRow(ctx,
-		`INSERT INTO chat_messages (sender_user_id, scope, game_id, message_text) 
-		 VALUES ($1, $2, $3, $4) 
-		 RETURNING chat_message_id, sender_user_id, scope, message_text, created_at`,
-		senderUserID, dbScope, gameID, messageText).
-		Scan(&msg.ChatMessageID, &msg.SenderUserID, &msg.Scope, &msg.MessageText, &msg.CreatedAt)
-	if err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
-	return &msg, nil
+	return abandoned, nil
 }
 
-func (r *postgresChatRepo) GetMessagesByScope(ctx context.Context, scope string, limit int) ([]*ChatMessage, error) {
-	var rows pgx.Rows
-	var err error
+// MarkEmailUndeliverable flags the user's address so no more mail is sent to it
+func (r *postgresUserRepo) MarkEmailUndeliverable(ctx context.Context, userID string) error {
+	_, err := r.pool.Exec(ctx,
+		"UPDATE users SET email_undeliverable = TRUE WHERE user_id = $1",
+		userID)
+	return err
+}
 
-	// Parse scope - "global" or "game:123"
-	if scope == "global" {
-		rows, err = r.pool.Query(ctx,
-			`SELECT cm.chat_message_id, cm.sender_user_id, u.username, cm.scope, cm.message_text, cm.created_at
-			 FROM chat_messages cm
-			 JOIN users u ON cm.sender_user_id = u.user_id
-			 WHERE cm.scope = 'global'
-			 ORDER BY cm.created_at DESC
-			 LIMIT $1`,
-			limit)
-	} else {
-		// Extract game ID from "game:123" format
-		var gameID int
-		if _, err := fmt.Sscanf(scope, "game:%d", &gameID); err == nil {
-			rows, err = r.pool.Query(ctx,
-				`SELECT cm.chat_message_id, cm.sender_user_id, u.username, cm.scope, cm.message_text, cm.created_at
-				 FROM chat_messages cm
-				 JOIN users u ON cm.sender_user_id = u.user_id
-				 WHERE cm.scope = 'game' AND cm.game_id = $1
-				 ORDER BY cm.created_at DESC
-				 LIMIT $2`,
-				gameID, limit)
-		} else {
-			// Invalid scope format, return empty
-			return []*ChatMessage{}, nil
-		}
+func (r *postgresUserRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	err := r.pool.QueryRow(ctx,
+		"SELECT user_id, username, password, email, email_undeliverable, email_verified FROM users WHERE email = $1", email).
+		Scan(&user.UserID, &user.Username, &user.Password, &user.Email, &user.EmailUndeliverable, &user.EmailVerified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &user, nil
+}
 
-	var messages []*ChatMessage
-	for rows.Next() {
-		var msg ChatMessage
-		err := rows.Scan(&msg.ChatMessageID, &msg.SenderUserID, &msg.SenderUsername, &msg.Scope, &msg.MessageText, &msg.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, &msg)
+func (r *postgresUserRepo) UpdatePassword(ctx context.Context, userID, hashedPassword string) error {
+	_, err := r.pool.Exec(ctx,
+		"UPDATE users SET password = $2 WHERE user_id = $1",
+		userID, hashedPassword)
+	return err
+}
+
+// CreatePasswordResetToken stores a reset token's hash. Any earlier unused tokens for
+// the user stop working, so only the newest email's link is valid.
+func (r *postgresUserRepo) CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		"UPDATE password_reset_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL",
+		userID)
+	if err != nil {
+		return err
 	}
 
-	// Reverse to get chronological order (oldest first)
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	_, err = tx.Exec(ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt)
+	if err != nil {
+		return err
 	}
 
-	return messages, rows.Err()
+	return tx.Commit(ctx)
 }
 
-// Game Repository Implementation
-type postgresGameRepo struct {
-	pool *pgxpool.Pool
+// ResetPasswordWithToken uses up a reset token and sets the new password in one
+// statement, so a token can't be spent without the password changing or vice versa
+func (r *postgresUserRepo) ResetPasswordWithToken(ctx context.Context, tokenHash, hashedPassword string) (string, error) {
+	var userID string
+	err := r.pool.QueryRow(ctx,
+		`WITH used AS (
+			UPDATE password_reset_tokens SET used_at = now()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			RETURNING user_id
+		 )
+		 UPDATE users SET password = $2
+		 FROM used WHERE users.user_id = used.user_id
+		 RETURNING users.user_id`,
+		tokenHash, hashedPassword).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
 }
 
-func NewGameRepository(pool *pgxpool.Pool) GameRepository {
-	return &postgresGameRepo{pool: pool}
+// CreateEmailVerificationToken stores a verification token's hash. Earlier unused
+// tokens for the user stop working, so only the newest email's link is valid.
+func (r *postgresUserRepo) CreateEmailVerificationToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		"UPDATE email_verification_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL",
+		userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO email_verification_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *postgresGameRepo) CreateGame(ctx context.Context, createdByUserID string, maxPlayers int) (*Game, error) {
-	var game Game
+// VerifyEmailWithToken uses up a verification token and marks its user's email verified
+func (r *postgresUserRepo) VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
 	err := r.pool.QueryRow(ctx,
-		`INSERT INTO games (created_by, max_players, player_count, status) 
-		 VALUES ($1, $2, 0, 'waiting_for_players') 
-		 RETURNING game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id`,
-		createdByUserID, maxPlayers).
-		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID)
+		`WITH used AS (
+			UPDATE email_verification_tokens SET used_at = now()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			RETURNING user_id
+		 )
+		 UPDATE users SET email_verified = TRUE
+		 FROM used WHERE users.user_id = used.user_id
+		 RETURNING users.user_id`,
+		tokenHash).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInvalidVerifyToken
+	}
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &game, nil
+	return userID, nil
 }
 
-func (r *postgresGameRepo) GetGameByPublicID(ctx context.Context, publicID string) (*Game, error) {
-	var game Game
+// Chat Repository Implementation
+type postgresChatRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewChatRepository(pool *pgxpool.Pool) ChatRepository {
+	return &postgresChatRepo{pool: pool}
+}
+
+// DMScope returns the chat scope for direct messages between two users. The IDs are
+// put in a fixed order so both users get the same scope.
+func DMScope(userA, userB string) string {
+	if userB < userA {
+		userA, userB = userB, userA
+	}
+	return "dm:" + userA + ":" + userB
+}
+
+// DMParticipants returns the two users of a "dm:<userA>:<userB>" scope. ok is false
+// if scope is not a well-formed direct message scope.
+func DMParticipants(scope string) (userA, userB string, ok bool) {
+	rest, found := strings.CutPrefix(scope, "dm:")
+	if !found {
+		return "", "", false
+	}
+	userA, userB, found = strings.Cut(rest, ":")
+	if !found || userA == "" || userB == "" || userA == userB || DMScope(userA, userB) != scope {
+		return "", "", false
+	}
+	return userA, userB, true
+}
+
+// chatScope is a parsed scope string
+type chatScope struct {
+	dbScope  string // "global", "game" or "dm"
+	publicID string // Game scopes only
+	userA    string // DM scopes only
+	userB    string
+}
+
+// parseChatScope splits a scope string ("global", "game:<publicId>" or
+// "dm:<userA>:<userB>") into its parts. ok is false for unrecognized scopes.
+func parseChatScope(scope string) (parsed chatScope, ok bool) {
+	if scope == "global" {
+		return chatScope{dbScope: "global"}, true
+	}
+	if id, found := strings.CutPrefix(scope, "game:"); found && id != "" {
+		return chatScope{dbScope: "game", publicID: id}, true
+	}
+	if userA, userB, found := DMParticipants(scope); found {
+		return chatScope{dbScope: "dm", userA: userA, userB: userB}, true
+	}
+	return chatScope{}, false
+}
+
+// nullableUUID turns "" into NULL for optional UUID columns
+func nullableUUID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// SaveMessage stores a chat message. An empty senderUserID stores a system message.
+func (r *postgresChatRepo) SaveMessage(ctx context.Context, senderUserID, scope, messageText string) (*ChatMessage, error) {
+	var msg ChatMessage
+
+	parsed, ok := parseChatScope(scope)
+	if !ok {
+		return nil, fmt.Errorf("invalid chat scope: %s", scope)
+	}
+
 	err := r.pool.QueryRow(ctx,
-		`SELECT game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id
-		 FROM games WHERE public_id = $1`,
-		publicID).
-		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID)
+		`INSERT INTO chat_messages (sender_user_id, scope, game_id, dm_user_a, dm_user_b, message_text, is_system) 
+		 VALUES ($1, $2, (SELECT game_id FROM games WHERE public_id::text = $3), $4, $5, $6, $1::uuid IS NULL) 
+		 RETURNING chat_message_id, COALESCE(sender_user_id::text, ''), scope, message_text, created_at, is_system`,
+		nullableUUID(senderUserID), parsed.dbScope, parsed.publicID, nullableUUID(parsed.userA), nullableUUID(parsed.userB), messageText).
+		Scan(&msg.ChatMessageID, &msg.SenderUserID, &msg.Scope, &msg.MessageText, &msg.CreatedAt, &msg.System)
 	if err != nil {
 		return nil, err
 	}
-	return &game, nil
+	return &msg, nil
 }
 
-func (r *postgresGameRepo) AddPlayer(ctx context.Context, publicID string, userID string, orderIndex int) error {
-	_, err := r.pool.Exec(ctx,
-		`INSERT INTO game_players (game_id, user_id, order_index, is_active, joined_at) 
-		 VALUES ((SELECT game_id FROM games WHERE public_id = $1), $2, $3, false, NULL)`,
-		publicID, userID, orderIndex)
-	return err
+func (r *postgresChatRepo) GetMessagesByScope(ctx context.Context, scope string, limit int) ([]*ChatMessage, error) {
+	return r.getMessages(ctx, scope, 0, limit)
 }
 
-func (r *postgresGameRepo) UpdatePlayerStatus(ctx context.Context, publicID string, userID string, isActive bool, joinedAt *time.Time) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE game_players 
-		 SET is_active = $3, joined_at = $4
-		 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
-		publicID, userID, isActive, joinedAt)
-	return err
+// GetMessagesBefore returns up to limit messages older than beforeID, for scrolling back
+// through history a page at a time
+func (r *postgresChatRepo) GetMessagesBefore(ctx context.Context, scope string, beforeID int, limit int) ([]*ChatMessage, error) {
+	return r.getMessages(ctx, scope, beforeID, limit)
 }
 
-func (r *postgresGameRepo) DeletePlayer(ctx context.Context, publicID string, userID string) error {
-	_, err := r.pool.Exec(ctx,
-		`DELETE FROM game_players 
-		 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
-		publicID, userID)
-	return err
-}
+// getMessages returns the latest limit messages in scope, oldest first. A beforeID
+// above 0 only considers messages with a lower ID.
+func (r *postgresChatRepo) getMessages(ctx context.Context, scope string, beforeID int, limit int) ([]*ChatMessage, error) {
+	parsed, ok := parseChatScope(scope)
+	if !ok {
+		// Invalid scope format, return empty
+		return []*ChatMessage{}, nil
+	}
+
+	var scopeFilter string
+	args := []any{beforeID, limit}
+	switch parsed.dbScope {
+	case "global":
+		scopeFilter = `cm.scope = 'global'`
+	case "dm":
+		scopeFilter = `cm.scope = 'dm' AND cm.dm_user_a = $3 AND cm.dm_user_b = $4`
+		args = append(args, parsed.userA, parsed.userB)
+	default:
+		scopeFilter = `cm.scope = 'game' AND cm.game_id = (SELECT game_id FROM games WHERE public_id::text = $3)`
+		args = append(args, parsed.publicID)
+	}
 
-func (r *postgresGameRepo) GetGamePlayers(ctx context.Context, publicID string) ([]*GamePlayer, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT gp.game_player_id, gp.game_id, gp.user_id, u.username, gp.order_index, 
-		        gp.joined_at, gp.left_at, gp.score, gp.is_active
-		 FROM game_players gp
-		 JOIN users u ON gp.user_id = u.user_id
-		 WHERE gp.game_id = (SELECT game_id FROM games WHERE public_id = $1)
-		 ORDER BY gp.order_index`,
-		publicID)
+		`SELECT cm.chat_message_id, COALESCE(cm.sender_user_id::text, ''), COALESCE(u.username, ''), cm.scope,
+		        CASE WHEN cm.deleted_at IS NULL THEN cm.message_text ELSE '[deleted]' END,
+		        cm.created_at, cm.edited_at, cm.deleted_at IS NOT NULL, cm.is_system
+		 FROM chat_messages cm
+		 LEFT JOIN users u ON cm.sender_user_id = u.user_id
+		 WHERE `+scopeFilter+` AND ($1::int = 0 OR cm.chat_message_id < $1)
+		 ORDER BY cm.chat_message_id DESC
+		 LIMIT $2`,
+		args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var players []*GamePlayer
+	var messages []*ChatMessage
 	for rows.Next() {
-		var player GamePlayer
-		err := rows.Scan(&player.GamePlayerID, &player.GameID, &player.UserID, &player.Username,
-			&player.OrderIndex, &player.JoinedAt, &player.LeftAt, &player.Score, &player.IsActive)
+		var msg ChatMessage
+		err := rows.Scan(&msg.ChatMessageID, &msg.SenderUserID, &msg.SenderUsername, &msg.Scope, &msg.MessageText,
+			&msg.CreatedAt, &msg.EditedAt, &msg.Deleted, &msg.System)
 		if err != nil {
 			return nil, err
 		}
-		players = append(players, &player)
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reverse to get chronological order (oldest first)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	if err := r.attachReactions(ctx, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// attachReactions fills in reaction counts for a page of messages in one query
+func (r *postgresChatRepo) attachReactions(ctx context.Context, messages []*ChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*ChatMessage, len(messages))
+	ids := make([]int, 0, len(messages))
+	for _, msg := range messages {
+		byID[msg.ChatMessageID] = msg
+		ids = append(ids, msg.ChatMessageID)
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT chat_message_id, emoji, COUNT(*)
+		 FROM message_reactions
+		 WHERE chat_message_id = ANY($1)
+		 GROUP BY chat_message_id, emoji`,
+		ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID, count int
+		var emoji string
+		if err := rows.Scan(&messageID, &emoji, &count); err != nil {
+			return err
+		}
+		msg := byID[messageID]
+		if msg.Reactions == nil {
+			msg.Reactions = make(map[string]int)
+		}
+		msg.Reactions[emoji] = count
+	}
+	return rows.Err()
+}
+
+// ToggleReaction adds userID's emoji to a message, or takes it back off if they had
+// already reacted with it. A new emoji is refused once the message has MaxReactionTypes.
+func (r *postgresChatRepo) ToggleReaction(ctx context.Context, messageID int, userID, emoji string) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM message_reactions WHERE chat_message_id = $1 AND user_id = $2 AND emoji = $3`,
+		messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() > 0 {
+		return false, tx.Commit(ctx)
+	}
+
+	// Lock the message so concurrent reactions can't both squeeze past the cap
+	var deleted bool
+	err = tx.QueryRow(ctx,
+		`SELECT deleted_at IS NOT NULL FROM chat_messages WHERE chat_message_id = $1 FOR UPDATE`,
+		messageID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && deleted) {
+		return false, ErrMessageNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var types int
+	var alreadyUsed bool
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(DISTINCT emoji), COALESCE(bool_or(emoji = $2), false)
+		 FROM message_reactions WHERE chat_message_id = $1`,
+		messageID, emoji).Scan(&types, &alreadyUsed)
+	if err != nil {
+		return false, err
+	}
+	if !alreadyUsed && types >= MaxReactionTypes {
+		return false, ErrTooManyReactions
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO message_reactions (chat_message_id, user_id, emoji) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// GetReactionCounts returns how many users reacted to a message with each emoji
+func (r *postgresChatRepo) GetReactionCounts(ctx context.Context, messageID int) (map[string]int, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT emoji, COUNT(*) FROM message_reactions WHERE chat_message_id = $1 GROUP BY emoji`,
+		messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var emoji string
+		var count int
+		if err := rows.Scan(&emoji, &count); err != nil {
+			return nil, err
+		}
+		counts[emoji] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetMessageScope returns the full scope string ("global", "game:<publicId>" or
+// "dm:<userA>:<userB>") a message was posted in, for routing live updates
+func (r *postgresChatRepo) GetMessageScope(ctx context.Context, messageID int) (string, error) {
+	var dbScope string
+	var publicID, userA, userB *string
+	err := r.pool.QueryRow(ctx,
+		`SELECT cm.scope, g.public_id::text, cm.dm_user_a::text, cm.dm_user_b::text
+		 FROM chat_messages cm
+		 LEFT JOIN games g ON cm.game_id = g.game_id
+		 WHERE cm.chat_message_id = $1`,
+		messageID).Scan(&dbScope, &publicID, &userA, &userB)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrMessageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case dbScope == "game" && publicID != nil:
+		return "game:" + *publicID, nil
+	case dbScope == "dm" && userA != nil && userB != nil:
+		return DMScope(*userA, *userB), nil
+	default:
+		return "global", nil
+	}
+}
+
+// UpdateMessage replaces the text of a message. Only its sender may edit it, and
+// deleted messages can't be edited.
+func (r *postgresChatRepo) UpdateMessage(ctx context.Context, messageID int, senderUserID, newText string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE chat_messages SET message_text = $3, edited_at = now()
+		 WHERE chat_message_id = $1 AND sender_user_id = $2 AND deleted_at IS NULL`,
+		messageID, senderUserID, newText)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return r.explainUnchangedMessage(ctx, messageID, senderUserID)
+	}
+	return nil
+}
+
+// DeleteMessage soft-deletes a message so it keeps its place in history. Only its
+// sender may delete it.
+func (r *postgresChatRepo) DeleteMessage(ctx context.Context, messageID int, senderUserID string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE chat_messages SET deleted_at = now()
+		 WHERE chat_message_id = $1 AND sender_user_id = $2 AND deleted_at IS NULL`,
+		messageID, senderUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return r.explainUnchangedMessage(ctx, messageID, senderUserID)
+	}
+	return nil
+}
+
+// explainUnchangedMessage works out why an edit or delete matched no rows
+func (r *postgresChatRepo) explainUnchangedMessage(ctx context.Context, messageID int, senderUserID string) error {
+	var sender string
+	var deleted bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT COALESCE(sender_user_id::text, ''), deleted_at IS NOT NULL FROM chat_messages WHERE chat_message_id = $1`,
+		messageID).Scan(&sender, &deleted)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && deleted) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if sender != senderUserID {
+		return ErrNotMessageSender
+	}
+	return ErrMessageNotFound
+}
+
+// MarkScopeRead records that userID has read scope up to and including message upToID.
+// Marking an older message never moves the read position back.
+func (r *postgresChatRepo) MarkScopeRead(ctx context.Context, userID, scope string, upToID int) error {
+	if _, ok := parseChatScope(scope); !ok {
+		return fmt.Errorf("invalid chat scope: %s", scope)
+	}
+
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO chat_read_state (user_id, scope, last_read_id) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, scope) DO UPDATE
+		 SET last_read_id = GREATEST(chat_read_state.last_read_id, EXCLUDED.last_read_id)`,
+		userID, scope, upToID)
+	return err
+}
+
+// GetUnreadCounts returns, for each game userID plays in and each private conversation
+// they are part of, how many messages from others they haven't read. Scopes with
+// nothing unread are left out.
+func (r *postgresChatRepo) GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT 'game:' || g.public_id::text AS scope, COUNT(*)
+		 FROM game_players gp
+		 JOIN games g ON gp.game_id = g.game_id
+		 JOIN chat_messages cm ON cm.scope = 'game' AND cm.game_id = g.game_id
+		 LEFT JOIN chat_read_state rs ON rs.user_id = gp.user_id AND rs.scope = 'game:' || g.public_id::text
+		 WHERE gp.user_id = $1 AND gp.is_active = true
+		   AND cm.deleted_at IS NULL
+		   AND cm.sender_user_id IS DISTINCT FROM gp.user_id
+		   AND cm.chat_message_id > COALESCE(rs.last_read_id, 0)
+		 GROUP BY g.public_id
+		 UNION ALL
+		 SELECT 'dm:' || cm.dm_user_a::text || ':' || cm.dm_user_b::text AS scope, COUNT(*)
+		 FROM chat_messages cm
+		 LEFT JOIN chat_read_state rs ON rs.user_id = $1
+		   AND rs.scope = 'dm:' || cm.dm_user_a::text || ':' || cm.dm_user_b::text
+		 WHERE cm.scope = 'dm' AND (cm.dm_user_a = $1 OR cm.dm_user_b = $1)
+		   AND cm.deleted_at IS NULL
+		   AND cm.sender_user_id <> $1
+		   AND cm.chat_message_id > COALESCE(rs.last_read_id, 0)
+		 GROUP BY cm.dm_user_a, cm.dm_user_b`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var scope string
+		var count int
+		if err := rows.Scan(&scope, &count); err != nil {
+			return nil, err
+		}
+		counts[scope] = count
+	}
+	return counts, rows.Err()
+}
+
+// Game Repository Implementation
+// dbtx is the subset of pgx shared by a pool and a transaction, so repository
+// methods can run either standalone or inside WithTx
+type dbtx interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type postgresGameRepo struct {
+	pool dbtx
+}
+
+func NewGameRepository(pool *pgxpool.Pool) GameRepository {
+	return &postgresGameRepo{pool: pool}
+}
+
+func (r *postgresGameRepo) WithTx(ctx context.Context, fn func(tx GameRepository) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&postgresGameRepo{pool: tx}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *postgresGameRepo) CreateGame(ctx context.Context, createdByUserID string, maxPlayers int, rulesJSON []byte) (*Game, error) {
+	var game Game
+	err := r.write(ctx, func() error {
+		return r.pool.QueryRow(ctx,
+			`INSERT INTO games (created_by, max_players, player_count, status, rules) 
+			 VALUES ($1, $2, 0, 'waiting_for_players', $3) 
+			 RETURNING game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id, rules`,
+			createdByUserID, maxPlayers, rulesJSON).
+			Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// CreateRematchGame creates a game linked to the one it is a rematch of. Returns
+// ErrRematchExists if that game already has a rematch.
+func (r *postgresGameRepo) CreateRematchGame(ctx context.Context, originalGameID int, createdByUserID string, maxPlayers int, rulesJSON []byte) (*Game, error) {
+	var game Game
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO games (created_by, max_players, player_count, status, rules, original_game_id) 
+		 VALUES ($1, $2, 0, 'waiting_for_players', $3, $4) 
+		 ON CONFLICT (original_game_id) DO NOTHING
+		 RETURNING game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id, rules`,
+		createdByUserID, maxPlayers, rulesJSON, originalGameID).
+		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRematchExists
+		}
+		return nil, err
+	}
+	return &game, nil
+}
+
+// GetRematchGame returns the rematch of a game, or ErrGameNotFound if there is none
+func (r *postgresGameRepo) GetRematchGame(ctx context.Context, originalGameID int) (*Game, error) {
+	var game Game
+	err := r.pool.QueryRow(ctx,
+		`SELECT game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id, rules
+		 FROM games WHERE original_game_id = $1`,
+		originalGameID).
+		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+	return &game, nil
+}
+
+func (r *postgresGameRepo) GetGameByPublicID(ctx context.Context, publicID string) (*Game, error) {
+	var game Game
+	err := r.pool.QueryRow(ctx,
+		`SELECT game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id, rules
+		 FROM games WHERE public_id = $1`,
+		publicID).
+		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrGameNotFound
+		}
+		// 22P02 is invalid_text_representation, i.e. the public ID isn't a UUID
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "22P02" {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+	return &game, nil
+}
+
+// GetGameByJoinToken finds the game a join link points to
+func (r *postgresGameRepo) GetGameByJoinToken(ctx context.Context, token string) (*Game, error) {
+	var game Game
+	err := r.pool.QueryRow(ctx,
+		`SELECT game_id, public_id, created_by, created_at, status, max_players, player_count, finished_at, winner_user_id, rules
+		 FROM games WHERE join_token = $1`,
+		token).
+		Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt, &game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+	return &game, nil
+}
+
+// SetJoinToken replaces a game's join token; the previous token stops working immediately
+func (r *postgresGameRepo) SetJoinToken(ctx context.Context, publicID string, token string) error {
+	result, err := r.pool.Exec(ctx,
+		`UPDATE games SET join_token = $2 WHERE public_id = $1`,
+		publicID, token)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrGameNotFound
+	}
+	return nil
+}
+
+func (r *postgresGameRepo) AddPlayer(ctx context.Context, publicID string, userID string, orderIndex int) error {
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`INSERT INTO game_players (game_id, user_id, order_index, is_active, joined_at) 
+			 VALUES ((SELECT game_id FROM games WHERE public_id = $1), $2, $3, false, NULL)`,
+			publicID, userID, orderIndex)
+		return err
+	})
+}
+
+func (r *postgresGameRepo) UpdatePlayerStatus(ctx context.Context, publicID string, userID string, isActive bool, joinedAt *time.Time) error {
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`UPDATE game_players 
+			 SET is_active = $3, joined_at = $4
+			 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
+			publicID, userID, isActive, joinedAt)
+		return err
+	})
+}
+
+func (r *postgresGameRepo) DeletePlayer(ctx context.Context, publicID string, userID string) error {
+	_, err := r.pool.Exec(ctx,
+		`DELETE FROM game_players 
+		 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
+		publicID, userID)
+	return err
+}
+
+func (r *postgresGameRepo) GetGamePlayers(ctx context.Context, publicID string) ([]*GamePlayer, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT gp.game_player_id, gp.game_id, gp.user_id, u.username, gp.order_index, 
+		        gp.joined_at, gp.left_at, gp.score, gp.is_active
+		 FROM game_players gp
+		 JOIN users u ON gp.user_id = u.user_id
+		 WHERE gp.game_id = (SELECT game_id FROM games WHERE public_id = $1)
+		 ORDER BY gp.order_index`,
+		publicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []*GamePlayer
+	for rows.Next() {
+		var player GamePlayer
+		err := rows.Scan(&player.GamePlayerID, &player.GameID, &player.UserID, &player.Username,
+			&player.OrderIndex, &player.JoinedAt, &player.LeftAt, &player.Score, &player.IsActive)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, &player)
+	}
+
+	return players, rows.Err()
+}
+
+// GetPlayersForGames returns the active players of many games at once, keyed by game_id
+func (r *postgresGameRepo) GetPlayersForGames(ctx context.Context, gameIDs []int) (map[int][]*GamePlayer, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT gp.game_player_id, gp.game_id, gp.user_id, u.username, gp.order_index,
+		        gp.joined_at, gp.left_at, gp.score, gp.is_active
+		 FROM game_players gp
+		 JOIN users u ON gp.user_id = u.user_id
+		 WHERE gp.game_id = ANY($1)
+		   AND gp.is_active = true
+		 ORDER BY gp.game_id, gp.order_index`,
+		gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := make(map[int][]*GamePlayer)
+	for rows.Next() {
+		var player GamePlayer
+		err := rows.Scan(&player.GamePlayerID, &player.GameID, &player.UserID, &player.Username,
+			&player.OrderIndex, &player.JoinedAt, &player.LeftAt, &player.Score, &player.IsActive)
+		if err != nil {
+			return nil, err
+		}
+		players[player.GameID] = append(players[player.GameID], &player)
 	}
 
 	return players, rows.Err()
 }
 
+// GetActivePlayerIDs returns the user IDs of active players who haven't left, ordered by order_index
+func (r *postgresGameRepo) GetActivePlayerIDs(ctx context.Context, publicID string) ([]string, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT gp.user_id
+		 FROM game_players gp
+		 WHERE gp.game_id = (SELECT game_id FROM games WHERE public_id = $1)
+		   AND gp.is_active = true
+		   AND gp.left_at IS NULL
+		 ORDER BY gp.order_index`,
+		publicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
 func (r *postgresGameRepo) GetPendingInvitations(ctx context.Context, userID string) ([]*GameInvitation, error) {
 	rows, err := r.pool.Query(ctx,
 		`SELECT g.game_id, g.public_id, gp.game_player_id, g.created_by, u.username, g.created_at
@@ -426,7 +1353,7 @@ func (r *postgresGameRepo) GetActiveGames(ctx context.Context, userID string) ([
 		`SELECT g.game_id, g.public_id, g.created_by, g.created_at, g.status, 
 		        g.max_players, 
 		        (SELECT COUNT(*) FROM game_players WHERE game_id = g.game_id AND is_active = true)::int as player_count,
-		        g.finished_at, g.winner_user_id
+		        g.finished_at, g.winner_user_id, g.rules
 		 FROM games g
 		 JOIN game_players gp ON g.game_id = gp.game_id
 		 WHERE gp.user_id = $1 
@@ -443,7 +1370,7 @@ func (r *postgresGameRepo) GetActiveGames(ctx context.Context, userID string) ([
 	for rows.Next() {
 		var game Game
 		err := rows.Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt,
-			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID)
+			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
 		if err != nil {
 			return nil, err
 		}
@@ -454,35 +1381,72 @@ func (r *postgresGameRepo) GetActiveGames(ctx context.Context, userID string) ([
 }
 
 func (r *postgresGameRepo) UpdateGameStatus(ctx context.Context, publicID string, status string) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE games SET status = $2 WHERE public_id = $1`,
-		publicID, status)
-	return err
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`UPDATE games SET status = $2 WHERE public_id = $1`,
+			publicID, status)
+		return err
+	})
 }
 
 // UpdatePlayerScore updates a player's final score
 func (r *postgresGameRepo) UpdatePlayerScore(ctx context.Context, publicID string, userID string, score int) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE game_players SET score = $3 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
-		publicID, userID, score)
-	return err
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`UPDATE game_players SET score = $3 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND user_id = $2`,
+			publicID, userID, score)
+		return err
+	})
+}
+
+// FinishGame marks a game as finished with winner and timestamp. A nil winner records a draw.
+func (r *postgresGameRepo) FinishGame(ctx context.Context, publicID string, winnerUserID *string) error {
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`UPDATE games SET status = 'finished', finished_at = now(), winner_user_id = $2 WHERE public_id = $1`,
+			publicID, winnerUserID)
+		return err
+	})
 }
 
-// FinishGame marks a game as finished with winner and timestamp
-func (r *postgresGameRepo) FinishGame(ctx context.Context, publicID string, winnerUserID string) error {
+// AbandonGame ends an in-progress game with no winner. Games that have already
+// finished are left alone.
+func (r *postgresGameRepo) AbandonGame(ctx context.Context, publicID string) error {
 	_, err := r.pool.Exec(ctx,
-		`UPDATE games SET status = 'finished', finished_at = now(), winner_user_id = $2 WHERE public_id = $1`,
-		publicID, winnerUserID)
+		`UPDATE games SET status = 'abandoned', finished_at = now() WHERE public_id = $1 AND status = 'in_progress'`,
+		publicID)
 	return err
 }
 
+// touchLastMove records that a game's state just changed
+func (r *postgresGameRepo) touchLastMove(ctx context.Context, publicID string) error {
+	return r.write(ctx, func() error {
+		_, err := r.pool.Exec(ctx,
+			`UPDATE games SET last_move_at = now() WHERE public_id = $1`,
+			publicID)
+		return err
+	})
+}
+
 // SaveGameState creates the initial game state record
 func (r *postgresGameRepo) SaveGameState(ctx context.Context, publicID string, stateJSON []byte) error {
-	_, err := r.pool.Exec(ctx,
-		`INSERT INTO game_states (game_id, state_json, version) 
-		 VALUES ((SELECT game_id FROM games WHERE public_id = $1), $2, 1)`,
-		publicID, stateJSON)
-	return err
+	var tag pgconn.CommandTag
+	err := r.write(ctx, func() error {
+		var err error
+		tag, err = r.pool.Exec(ctx,
+			`INSERT INTO game_states (game_id, state_json, initial_state_json, version) 
+			 VALUES ((SELECT game_id FROM games WHERE public_id = $1), $2, $2, 1)
+			 ON CONFLICT (game_id) DO NOTHING`,
+			publicID, stateJSON)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrStateExists
+	}
+	return r.touchLastMove(ctx, publicID)
 }
 
 // LoadGameState retrieves the current game state and version
@@ -499,43 +1463,70 @@ func (r *postgresGameRepo) LoadGameState(ctx context.Context, publicID string) (
 		Scan(&stateJSON, &version)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, 0, errors.New("game state not found")
+			return nil, 0, ErrStateNotFound
 		}
 		return nil, 0, err
 	}
 	return stateJSON, version, nil
 }
 
+// LoadInitialGameState retrieves the state as it was first dealt
+func (r *postgresGameRepo) LoadInitialGameState(ctx context.Context, publicID string) ([]byte, error) {
+	var stateJSON []byte
+	err := r.pool.QueryRow(ctx,
+		`SELECT initial_state_json
+		 FROM game_states
+		 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1)
+		   AND initial_state_json IS NOT NULL
+		 ORDER BY last_updated DESC
+		 LIMIT 1`,
+		publicID).
+		Scan(&stateJSON)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrStateNotFound
+		}
+		return nil, err
+	}
+	return stateJSON, nil
+}
+
 // UpdateGameState updates the game state with optimistic locking
 func (r *postgresGameRepo) UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error {
-	result, err := r.pool.Exec(ctx,
-		`UPDATE game_states 
-		 SET state_json = $2, version = version + 1, last_updated = now() 
-		 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND version = $3`,
-		publicID, stateJSON, expectedVersion)
+	var result pgconn.CommandTag
+	err := r.write(ctx, func() error {
+		var err error
+		result, err = r.pool.Exec(ctx,
+			`UPDATE game_states 
+			 SET state_json = $2, version = version + 1, last_updated = now() 
+			 WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1) AND version = $3`,
+			publicID, stateJSON, expectedVersion)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		return errors.New("version mismatch: game state was modified by another process")
+		return ErrVersionConflict
 	}
 
-	return nil
+	return r.touchLastMove(ctx, publicID)
 }
 
 // GetInactiveGames returns games that haven't been updated in the specified duration
-// This queries games that are not finished and haven't had state updates recently
+// This queries games still waiting for players; stalled games in progress are
+// abandoned instead (see GetStaleInProgressGames)
 func (r *postgresGameRepo) GetInactiveGames(ctx context.Context, inactiveDuration time.Duration) ([]*Game, error) {
 	cutoffTime := time.Now().Add(-inactiveDuration)
 
 	rows, err := r.pool.Query(ctx,
 		`SELECT g.game_id, g.public_id, g.created_by, g.created_at, g.status, 
-		        g.max_players, g.player_count, g.finished_at, g.winner_user_id
+		        g.max_players, g.player_count, g.finished_at, g.winner_user_id, g.rules
 		 FROM games g
 		 LEFT JOIN game_states gs ON g.game_id = gs.game_id
-		 WHERE g.status != 'finished' 
+		 WHERE g.status = 'waiting_for_players' 
 		   AND (gs.last_updated < $1 OR (gs.last_updated IS NULL AND g.created_at < $1))
 		 ORDER BY g.created_at`,
 		cutoffTime)
@@ -548,7 +1539,85 @@ func (r *postgresGameRepo) GetInactiveGames(ctx context.Context, inactiveDuratio
 	for rows.Next() {
 		var game Game
 		err := rows.Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt,
-			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID)
+			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, &game)
+	}
+
+	return games, rows.Err()
+}
+
+// GetStaleInProgressGames returns in-progress games with no move since cutoff.
+// A game that was never dealt counts from when it was created.
+func (r *postgresGameRepo) GetStaleInProgressGames(ctx context.Context, cutoff time.Time) ([]*Game, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT game_id, public_id, created_by, created_at, status, 
+		        max_players, player_count, finished_at, winner_user_id, rules
+		 FROM games
+		 WHERE status = 'in_progress'
+		   AND COALESCE(last_move_at, created_at) < $1
+		 ORDER BY created_at`,
+		cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []*Game
+	for rows.Next() {
+		var game Game
+		err := rows.Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt,
+			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, &game)
+	}
+
+	return games, rows.Err()
+}
+
+// GameCursor marks a position in a user's completed games, which are ordered newest first
+type GameCursor struct {
+	FinishedAt time.Time
+	GameID     int
+}
+
+// GetCompletedGames returns up to limit finished or abandoned games for a user, newest
+// first, starting after the cursor (nil for the first page). Uses keyset paging so
+// deep pages cost the same as the first.
+func (r *postgresGameRepo) GetCompletedGames(ctx context.Context, userID string, after *GameCursor, limit int) ([]*Game, error) {
+	var afterFinishedAt *time.Time
+	var afterGameID int
+	if after != nil {
+		afterFinishedAt = &after.FinishedAt
+		afterGameID = after.GameID
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT g.game_id, g.public_id, g.created_by, g.created_at, g.status,
+		        g.max_players, g.player_count, g.finished_at, g.winner_user_id, g.rules
+		 FROM games g
+		 JOIN game_players gp ON g.game_id = gp.game_id
+		 WHERE gp.user_id = $1
+		   AND gp.is_active = true
+		   AND g.status IN ('finished', 'abandoned')
+		   AND ($2::timestamptz IS NULL OR (g.finished_at, g.game_id) < ($2, $3))
+		 ORDER BY g.finished_at DESC, g.game_id DESC
+		 LIMIT $4`,
+		userID, afterFinishedAt, afterGameID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []*Game
+	for rows.Next() {
+		var game Game
+		err := rows.Scan(&game.GameID, &game.PublicID, &game.CreatedBy, &game.CreatedAt,
+			&game.Status, &game.MaxPlayers, &game.PlayerCount, &game.FinishedAt, &game.WinnerUserID, &game.Rules)
 		if err != nil {
 			return nil, err
 		}
@@ -558,7 +1627,123 @@ func (r *postgresGameRepo) GetInactiveGames(ctx context.Context, inactiveDuratio
 	return games, rows.Err()
 }
 
-// DeleteGame removes a game and all related records (players, state, chat messages)
+// GetStreak returns the user's current streak over finished games, most recent first.
+// Positive values are consecutive wins, negative values consecutive losses.
+// A draw (finished game with no winner) ends the streak.
+func (r *postgresGameRepo) GetStreak(ctx context.Context, userID string) (int, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT g.winner_user_id
+		 FROM games g
+		 JOIN game_players gp ON g.game_id = gp.game_id
+		 WHERE gp.user_id = $1
+		   AND gp.is_active = true
+		   AND g.status = 'finished'
+		 ORDER BY g.finished_at DESC, g.game_id DESC`,
+		userID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	streak := 0
+	for rows.Next() {
+		var winnerUserID *string
+		if err := rows.Scan(&winnerUserID); err != nil {
+			return 0, err
+		}
+
+		if winnerUserID == nil {
+			// Draw breaks the streak
+			break
+		}
+
+		won := *winnerUserID == userID
+		if won && streak >= 0 {
+			streak++
+		} else if !won && streak <= 0 {
+			streak--
+		} else {
+			// Result changed
+			break
+		}
+	}
+
+	return streak, rows.Err()
+}
+
+// GetPlayerStats aggregates the user's finished games. A user with no finished games
+// gets all zeros.
+func (r *postgresGameRepo) GetPlayerStats(ctx context.Context, userID string) (*PlayerStats, error) {
+	var stats PlayerStats
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*)::int,
+		        COUNT(*) FILTER (WHERE g.winner_user_id = $1)::int,
+		        COALESCE(AVG(gp.score), 0)::float8,
+		        COALESCE(MIN(gp.score), 0)::int
+		 FROM games g
+		 JOIN game_players gp ON g.game_id = gp.game_id
+		 WHERE gp.user_id = $1
+		   AND gp.is_active = true
+		   AND g.status = 'finished'`,
+		userID).Scan(&stats.GamesPlayed, &stats.Wins, &stats.AverageScore, &stats.BestScore)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetLeaderboard ranks players with at least LeaderboardMinGames finished games by
+// metric: "wins" (most first) or "avg_score" (lowest first). Accounts without a
+// password, like the bot, are left out.
+func (r *postgresGameRepo) GetLeaderboard(ctx context.Context, metric string, limit int) ([]*LeaderboardEntry, error) {
+	var orderBy string
+	switch metric {
+	case "wins":
+		orderBy = "wins DESC"
+	case "avg_score":
+		orderBy = "average_score ASC"
+	default:
+		return nil, fmt.Errorf("unknown leaderboard metric %q", metric)
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`WITH player_stats AS (
+		     SELECT u.username,
+		            COUNT(*)::int AS games_played,
+		            COUNT(*) FILTER (WHERE g.winner_user_id = u.user_id)::int AS wins,
+		            COALESCE(AVG(gp.score), 0)::float8 AS average_score
+		     FROM games g
+		     JOIN game_players gp ON g.game_id = gp.game_id
+		     JOIN users u ON u.user_id = gp.user_id
+		     WHERE g.status = 'finished'
+		       AND gp.is_active = true
+		       AND u.password IS NOT NULL
+		     GROUP BY u.user_id, u.username
+		     HAVING COUNT(*) >= $1
+		 )
+		 SELECT RANK() OVER (ORDER BY `+orderBy+`)::int, username, games_played, wins, average_score
+		 FROM player_stats
+		 ORDER BY `+orderBy+`, username
+		 LIMIT $2`,
+		LeaderboardMinGames, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		if err := rows.Scan(&entry.Rank, &entry.Username, &entry.GamesPlayed, &entry.Wins, &entry.AverageScore); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// DeleteGame removes a game and all related records (players, state, moves, events, chat messages)
 func (r *postgresGameRepo) DeleteGame(ctx context.Context, publicID string) error {
 	// Start a transaction to ensure all deletes succeed together
 	tx, err := r.pool.Begin(ctx)
@@ -572,7 +1757,7 @@ func (r *postgresGameRepo) DeleteGame(ctx context.Context, publicID string) erro
 	err = tx.QueryRow(ctx, `SELECT game_id FROM games WHERE public_id = $1`, publicID).Scan(&gameID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return errors.New("game not found")
+			return ErrGameNotFound
 		}
 		return err
 	}
@@ -584,19 +1769,31 @@ func (r *postgresGameRepo) DeleteGame(ctx context.Context, publicID string) erro
 		return err
 	}
 
-	// 2. Game state
+	// 2. Activity feed
+	_, err = tx.Exec(ctx, `DELETE FROM game_events WHERE game_id = $1`, gameID)
+	if err != nil {
+		return err
+	}
+
+	// 3. Move log
+	_, err = tx.Exec(ctx, `DELETE FROM game_moves WHERE game_id = $1`, gameID)
+	if err != nil {
+		return err
+	}
+
+	// 4. Game state
 	_, err = tx.Exec(ctx, `DELETE FROM game_states WHERE game_id = $1`, gameID)
 	if err != nil {
 		return err
 	}
 
-	// 3. Game players
+	// 5. Game players
 	_, err = tx.Exec(ctx, `DELETE FROM game_players WHERE game_id = $1`, gameID)
 	if err != nil {
 		return err
 	}
 
-	// 4. Finally, the game itself
+	// 6. Finally, the game itself
 	_, err = tx.Exec(ctx, `DELETE FROM games WHERE game_id = $1`, gameID)
 	if err != nil {
 		return err
@@ -605,3 +1802,263 @@ func (r *postgresGameRepo) DeleteGame(ctx context.Context, publicID string) erro
 	// Commit the transaction
 	return tx.Commit(ctx)
 }
+
+// Audit Repository Implementation
+type postgresAuditRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewAuditRepository(pool *pgxpool.Pool) AuditRepository {
+	return &postgresAuditRepo{pool: pool}
+}
+
+// AppendEvent inserts a new audit log entry
+func (r *postgresAuditRepo) AppendEvent(ctx context.Context, event *AuditEvent) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO audit_log (event_type, user_id, ip_address, user_agent, metadata)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		event.EventType, event.UserID, event.IPAddress, event.UserAgent, event.Metadata)
+	return err
+}
+
+// GetEvents returns the most recent audit events, newest first.
+// Empty userID or eventType means no filtering on that column.
+func (r *postgresAuditRepo) GetEvents(ctx context.Context, userID string, eventType string, limit int) ([]*AuditEvent, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT audit_log_id, event_type, user_id, ip_address, user_agent, metadata, created_at
+		 FROM audit_log
+		 WHERE ($1 = '' OR user_id::text = $1)
+		   AND ($2 = '' OR event_type = $2)
+		 ORDER BY created_at DESC, audit_log_id DESC
+		 LIMIT $3`,
+		userID, eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(&event.AuditLogID, &event.EventType, &event.UserID, &event.IPAddress,
+			&event.UserAgent, &event.Metadata, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// Friend Repository Implementation
+type postgresFriendRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewFriendRepository(pool *pgxpool.Pool) FriendRepository {
+	return &postgresFriendRepo{pool: pool}
+}
+
+// SendRequest records a pending friend request. Fails with ErrFriendRequestSent or
+// ErrAlreadyFriends if the pair already has a row, whichever of them asked.
+func (r *postgresFriendRepo) SendRequest(ctx context.Context, requesterID, addresseeID string) error {
+	tag, err := r.pool.Exec(ctx,
+		`INSERT INTO friendships (requester_id, addressee_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		requesterID, addresseeID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var status string
+	err = r.pool.QueryRow(ctx,
+		`SELECT status FROM friendships
+		 WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)`,
+		requesterID, addresseeID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status == "accepted" {
+		return ErrAlreadyFriends
+	}
+	return ErrFriendRequestSent
+}
+
+// AcceptRequest accepts a pending request from requesterID to addresseeID
+func (r *postgresFriendRepo) AcceptRequest(ctx context.Context, requesterID, addresseeID string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE friendships SET status = 'accepted', accepted_at = now()
+		 WHERE requester_id = $1 AND addressee_id = $2 AND status = 'pending'`,
+		requesterID, addresseeID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFriendshipNotFound
+	}
+	return nil
+}
+
+// RemoveFriend deletes the pair's friendship or pending request, in either direction
+func (r *postgresFriendRepo) RemoveFriend(ctx context.Context, userID, otherUserID string) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM friendships
+		 WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)`,
+		userID, otherUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFriendshipNotFound
+	}
+	return nil
+}
+
+// ListFriends returns the user's accepted friends, ordered by username
+func (r *postgresFriendRepo) ListFriends(ctx context.Context, userID string) ([]*User, error) {
+	return r.listUsers(ctx,
+		`SELECT u.user_id, u.username
+		 FROM friendships f
+		 JOIN users u ON u.user_id = CASE WHEN f.requester_id = $1 THEN f.addressee_id ELSE f.requester_id END
+		 WHERE (f.requester_id = $1 OR f.addressee_id = $1)
+		   AND f.status = 'accepted'
+		 ORDER BY u.username`,
+		userID)
+}
+
+// ListFriendRequests returns the users waiting for userID to accept them, oldest first
+func (r *postgresFriendRepo) ListFriendRequests(ctx context.Context, userID string) ([]*User, error) {
+	return r.listUsers(ctx,
+		`SELECT u.user_id, u.username
+		 FROM friendships f
+		 JOIN users u ON u.user_id = f.requester_id
+		 WHERE f.addressee_id = $1
+		   AND f.status = 'pending'
+		 ORDER BY f.created_at`,
+		userID)
+}
+
+// listUsers runs a query selecting user_id and username
+func (r *postgresFriendRepo) listUsers(ctx context.Context, query string, args ...any) ([]*User, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.UserID, &user.Username); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// Move Log Repository Implementation
+type postgresMoveLogRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewMoveLogRepository(pool *pgxpool.Pool) MoveLogRepository {
+	return &postgresMoveLogRepo{pool: pool}
+}
+
+// RecordMove appends a committed action to the game's move log. decksJSON may be nil.
+func (r *postgresMoveLogRepo) RecordMove(ctx context.Context, gameID int, userID string, action string, cardIndex *int, decksJSON []byte, resultingVersion int) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO game_moves (game_id, user_id, action, card_index, decks_json, resulting_version)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		gameID, nullableUUID(userID), action, cardIndex, decksJSON, resultingVersion)
+	return err
+}
+
+// GetMoves returns every logged move for a game in the order they were committed
+func (r *postgresMoveLogRepo) GetMoves(ctx context.Context, gameID int) ([]*Move, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT game_move_id, game_id, COALESCE(user_id::text, ''), action, card_index, decks_json,
+		        resulting_version, created_at
+		 FROM game_moves
+		 WHERE game_id = $1
+		 ORDER BY resulting_version ASC`,
+		gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []*Move{}
+	for rows.Next() {
+		var move Move
+		err := rows.Scan(&move.GameMoveID, &move.GameID, &move.UserID, &move.Action,
+			&move.CardIndex, &move.Decks, &move.ResultingVersion, &move.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, &move)
+	}
+
+	return moves, rows.Err()
+}
+
+// Game Event Repository Implementation
+type postgresGameEventRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewGameEventRepository(pool *pgxpool.Pool) GameEventRepository {
+	return &postgresGameEventRepo{pool: pool}
+}
+
+// AppendGameEvent adds an entry to a game's activity feed
+func (r *postgresGameEventRepo) AppendGameEvent(ctx context.Context, publicID string, event *GameEvent) error {
+	result, err := r.pool.Exec(ctx,
+		`INSERT INTO game_events (game_id, event_type, user_id, metadata)
+		 SELECT game_id, $2, $3, $4 FROM games WHERE public_id = $1`,
+		publicID, event.EventType, event.UserID, event.Metadata)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrGameNotFound
+	}
+	return nil
+}
+
+// GetGameEvents returns a game's most recent events, oldest first
+func (r *postgresGameEventRepo) GetGameEvents(ctx context.Context, publicID string, limit int) ([]*GameEvent, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT * FROM (
+		     SELECT e.game_event_id, e.game_id, e.event_type, e.user_id, e.metadata, e.created_at
+		     FROM game_events e
+		     JOIN games g ON g.game_id = e.game_id
+		     WHERE g.public_id = $1
+		     ORDER BY e.game_event_id DESC
+		     LIMIT $2
+		 ) recent
+		 ORDER BY game_event_id ASC`,
+		publicID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*GameEvent{}
+	for rows.Next() {
+		var event GameEvent
+		err := rows.Scan(&event.GameEventID, &event.GameID, &event.EventType, &event.UserID,
+			&event.Metadata, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
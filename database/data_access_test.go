@@ -0,0 +1,826 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to the database named by TEST_DATABASE_URL, which must already
+// have ddl/createTables.sql applied. Tests that need it are skipped without one.
+func testPool(t *testing.T) (*pgxpool.Pool, func(sql string, args ...any)) {
+	t.Helper()
+
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	pool, err := NewPool(ctx, connString)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		if _, err := pool.Exec(ctx, sql, args...); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	return pool, exec
+}
+
+// testGameRepo is a game repository on the test database, see testPool
+func testGameRepo(t *testing.T) (GameRepository, func(sql string, args ...any)) {
+	t.Helper()
+
+	pool, exec := testPool(t)
+	return NewGameRepository(pool), exec
+}
+
+func TestGetCompletedGamesPagesWithoutGapsOrDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+
+	const games = 300
+	userID := "00000000-0000-0000-0000-0000000c0501"
+	exec(`INSERT INTO users (user_id, username) VALUES ($1, $2)`, userID, fmt.Sprintf("cursor-test-%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		exec(`DELETE FROM game_players WHERE game_id IN (SELECT game_id FROM games WHERE created_by = $1)`, userID)
+		exec(`DELETE FROM games WHERE created_by = $1`, userID)
+		exec(`DELETE FROM users WHERE user_id = $1`, userID)
+	})
+
+	// Several games share each finished_at, so the game ID has to break ties
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < games; i++ {
+		status := "finished"
+		if i%5 == 0 {
+			status = "abandoned"
+		}
+		exec(`WITH g AS (
+		          INSERT INTO games (created_by, status, max_players, player_count, finished_at)
+		          VALUES ($1, $2, 2, 1, $3) RETURNING game_id)
+		      INSERT INTO game_players (game_id, user_id, order_index, is_active)
+		      SELECT game_id, $1, 0, true FROM g`,
+			userID, status, base.Add(time.Duration(i/7)*time.Second))
+	}
+	// Neither a game still under way nor one the user was only invited to is history
+	exec(`WITH g AS (INSERT INTO games (created_by, status, max_players, player_count) VALUES ($1, 'in_progress', 2, 1) RETURNING game_id)
+	      INSERT INTO game_players (game_id, user_id, order_index, is_active) SELECT game_id, $1, 0, true FROM g`, userID)
+	exec(`WITH g AS (INSERT INTO games (created_by, status, max_players, player_count, finished_at) VALUES ($1, 'finished', 2, 1, now()) RETURNING game_id)
+	      INSERT INTO game_players (game_id, user_id, order_index, is_active) SELECT game_id, $1, 0, false FROM g`, userID)
+
+	seen := make(map[int]bool)
+	var cursor *GameCursor
+	var previous *Game
+	for page := 0; ; page++ {
+		if page > games {
+			t.Fatal("paging did not end")
+		}
+		batch, err := repo.GetCompletedGames(ctx, userID, cursor, 25)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, game := range batch {
+			if seen[game.GameID] {
+				t.Fatalf("game %d returned twice", game.GameID)
+			}
+			seen[game.GameID] = true
+
+			if previous != nil && (game.FinishedAt.After(*previous.FinishedAt) ||
+				game.FinishedAt.Equal(*previous.FinishedAt) && game.GameID > previous.GameID) {
+				t.Fatalf("game %d is out of order after game %d", game.GameID, previous.GameID)
+			}
+			previous = game
+		}
+		last := batch[len(batch)-1]
+		cursor = &GameCursor{FinishedAt: *last.FinishedAt, GameID: last.GameID}
+	}
+
+	if len(seen) != games {
+		t.Fatalf("paged through %d games, want %d", len(seen), games)
+	}
+}
+
+func TestGetActivePlayerIDsSkipsLeftAndPendingPlayers(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+
+	suffix := time.Now().UnixNano()
+	users := []string{
+		"00000000-0000-0000-0000-0000000c1990",
+		"00000000-0000-0000-0000-0000000c1991",
+		"00000000-0000-0000-0000-0000000c1992",
+		"00000000-0000-0000-0000-0000000c1993",
+	}
+	for i, userID := range users {
+		exec(`INSERT INTO users (user_id, username) VALUES ($1, $2)`, userID, fmt.Sprintf("active-ids-%d-%d", suffix, i))
+	}
+	publicID := "00000000-0000-0000-0000-00000001990a"
+	exec(`INSERT INTO games (public_id, created_by, status, max_players, player_count) VALUES ($1, $2, 'in_progress', 4, 4)`, publicID, users[0])
+	t.Cleanup(func() {
+		exec(`DELETE FROM game_players WHERE game_id = (SELECT game_id FROM games WHERE public_id = $1)`, publicID)
+		exec(`DELETE FROM games WHERE public_id = $1`, publicID)
+		for _, userID := range users {
+			exec(`DELETE FROM users WHERE user_id = $1`, userID)
+		}
+	})
+
+	// Seated out of order: an active player, one who left, a pending invite, another active player
+	for _, seat := range []struct {
+		userID string
+		order  int
+		active bool
+		left   bool
+	}{
+		{users[3], 3, true, false},
+		{users[1], 1, true, true},
+		{users[2], 2, false, false},
+		{users[0], 0, true, false},
+	} {
+		exec(`INSERT INTO game_players (game_id, user_id, order_index, is_active, left_at)
+		      SELECT game_id, $2, $3, $4, CASE WHEN $5 THEN now() END FROM games WHERE public_id = $1`,
+			publicID, seat.userID, seat.order, seat.active, seat.left)
+	}
+
+	ids, err := repo.GetActivePlayerIDs(ctx, publicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != users[0] || ids[1] != users[3] {
+		t.Fatalf("active players = %v, want [%s %s]", ids, users[0], users[3])
+	}
+}
+
+// seedPlayers creates users with passwords and returns their usernames. The users and
+// the games they created are removed after the test.
+func seedPlayers(t *testing.T, exec func(sql string, args ...any), userIDs ...string) []string {
+	t.Helper()
+
+	suffix := time.Now().UnixNano()
+	usernames := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		usernames[i] = fmt.Sprintf("seeded-%d-%d", suffix, i)
+		exec(`INSERT INTO users (user_id, username, password) VALUES ($1, $2, 'x')`, userID, usernames[i])
+	}
+	t.Cleanup(func() {
+		for _, userID := range userIDs {
+			exec(`DELETE FROM game_players WHERE game_id IN (SELECT game_id FROM games WHERE created_by = $1)`, userID)
+			exec(`DELETE FROM games WHERE created_by = $1`, userID)
+			exec(`DELETE FROM users WHERE user_id = $1`, userID)
+		}
+	})
+	return usernames
+}
+
+// seedSoloGame records a finished one-player game with the player's final score
+func seedSoloGame(exec func(sql string, args ...any), userID string, score int, won bool) {
+	exec(`WITH g AS (
+	          INSERT INTO games (created_by, status, max_players, player_count, finished_at, winner_user_id)
+	          VALUES ($1, 'finished', 1, 1, now(), CASE WHEN $3 THEN $1::uuid END) RETURNING game_id)
+	      INSERT INTO game_players (game_id, user_id, order_index, is_active, score)
+	      SELECT game_id, $1, 0, true, $2 FROM g`,
+		userID, score, won)
+}
+
+func TestGetPlayerStats(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+	userID := "00000000-0000-0000-0000-0000000c2067"
+	seedPlayers(t, exec, userID)
+
+	stats, err := repo.GetPlayerStats(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (PlayerStats{}) {
+		t.Fatalf("stats with no games = %+v, want zeros", stats)
+	}
+
+	seedSoloGame(exec, userID, 10, false)
+	seedSoloGame(exec, userID, 4, true)
+	seedSoloGame(exec, userID, 7, false)
+	stats, err = repo.GetPlayerStats(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PlayerStats{GamesPlayed: 3, Wins: 1, AverageScore: 7, BestScore: 4}); *stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+	userIDs := []string{
+		"00000000-0000-0000-0000-0000000c2068", // steady: 3 wins, averages 5
+		"00000000-0000-0000-0000-0000000c2069", // erratic: 3 wins, averages 8
+		"00000000-0000-0000-0000-0000000c2070", // careful: 1 win, averages 3
+		"00000000-0000-0000-0000-0000000c2071", // lucky: wins everything, but too few games
+	}
+	names := seedPlayers(t, exec, userIDs...)
+	steady, erratic, careful, lucky := names[0], names[1], names[2], names[3]
+
+	for i := 0; i < LeaderboardMinGames; i++ {
+		seedSoloGame(exec, userIDs[0], 5, i < 3)
+		seedSoloGame(exec, userIDs[1], 8, i < 3)
+		seedSoloGame(exec, userIDs[2], 3, i < 1)
+	}
+	for i := 0; i < LeaderboardMinGames-1; i++ {
+		seedSoloGame(exec, userIDs[3], 0, true)
+	}
+
+	// Other players may be in the database, so only compare the seeded ones
+	ranks := func(metric string) map[string]int {
+		t.Helper()
+		entries, err := repo.GetLeaderboard(ctx, metric, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byName := make(map[string]int)
+		for _, entry := range entries {
+			byName[entry.Username] = entry.Rank
+		}
+		return byName
+	}
+
+	wins := ranks("wins")
+	if _, ok := wins[lucky]; ok {
+		t.Error("a player under the minimum games is ranked by wins")
+	}
+	if wins[steady] == 0 || wins[steady] != wins[erratic] || wins[careful] <= wins[steady] {
+		t.Errorf("wins ranks = steady %d, erratic %d, careful %d; want a tie ahead of careful",
+			wins[steady], wins[erratic], wins[careful])
+	}
+
+	average := ranks("avg_score")
+	if _, ok := average[lucky]; ok {
+		t.Error("a player under the minimum games is ranked by average score")
+	}
+	if average[careful] == 0 || !(average[careful] < average[steady] && average[steady] < average[erratic]) {
+		t.Errorf("average ranks = careful %d, steady %d, erratic %d; want lowest average first",
+			average[careful], average[steady], average[erratic])
+	}
+
+	if _, err := repo.GetLeaderboard(ctx, "losses", 10); err == nil {
+		t.Error("an unknown metric was accepted")
+	}
+}
+
+// seedDuel records a finished two-player game that userID created against opponentID,
+// won by winnerUserID (nil for a draw)
+func seedDuel(exec func(sql string, args ...any), userID, opponentID string, winnerUserID *string) {
+	exec(`WITH g AS (
+	          INSERT INTO games (created_by, status, max_players, player_count, finished_at, winner_user_id)
+	          VALUES ($1, 'finished', 2, 2, now(), $3::uuid) RETURNING game_id)
+	      INSERT INTO game_players (game_id, user_id, order_index, is_active)
+	      SELECT game_id, $1, 0, true FROM g
+	      UNION ALL SELECT game_id, $2, 1, true FROM g`,
+		userID, opponentID, winnerUserID)
+}
+
+func TestGetStreak(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+	userID := "00000000-0000-0000-0000-0000000c1994"
+	opponentID := "00000000-0000-0000-0000-0000000c1995"
+	seedPlayers(t, exec, userID, opponentID)
+
+	requireStreak := func(want int) {
+		t.Helper()
+		streak, err := repo.GetStreak(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if streak != want {
+			t.Fatalf("streak = %d, want %d", streak, want)
+		}
+	}
+
+	requireStreak(0)
+
+	// Oldest first: a loss, then two wins
+	seedDuel(exec, userID, opponentID, &opponentID)
+	seedDuel(exec, userID, opponentID, &userID)
+	seedDuel(exec, userID, opponentID, &userID)
+	requireStreak(2)
+
+	seedDuel(exec, userID, opponentID, nil)
+	requireStreak(0)
+
+	seedDuel(exec, userID, opponentID, &opponentID)
+	seedDuel(exec, userID, opponentID, &opponentID)
+	requireStreak(-2)
+}
+
+func TestCreateRematchGameMakesOneRematchPerGame(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+	userID := "00000000-0000-0000-0000-0000000c2030"
+	seedPlayers(t, exec, userID)
+
+	original, err := repo.CreateGame(ctx, userID, 2, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two players ask for the rematch at once
+	type result struct {
+		game *Game
+		err  error
+	}
+	results := make(chan result, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			game, err := repo.CreateRematchGame(ctx, original.GameID, userID, 2, []byte("{}"))
+			results <- result{game, err}
+		}()
+	}
+
+	var created *Game
+	for i := 0; i < 2; i++ {
+		res := <-results
+		switch {
+		case res.err == nil && created == nil:
+			created = res.game
+		case errors.Is(res.err, ErrRematchExists):
+		default:
+			t.Fatalf("CreateRematchGame: game %v, err %v", res.game, res.err)
+		}
+	}
+	if created == nil {
+		t.Fatal("neither request created the rematch")
+	}
+
+	rematch, err := repo.GetRematchGame(ctx, original.GameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rematch.PublicID != created.PublicID {
+		t.Fatalf("rematch = %s, want %s", rematch.PublicID, created.PublicID)
+	}
+}
+
+func TestGetGameEventsReturnsTheMostRecentOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	games, events := NewGameRepository(pool), NewGameEventRepository(pool)
+	userID := "00000000-0000-0000-0000-0000000c2013"
+	seedPlayers(t, exec, userID)
+
+	game, err := games.CreateGame(ctx, userID, 2, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { exec(`DELETE FROM game_events WHERE game_id = $1`, game.GameID) })
+
+	for i := 0; i < 5; i++ {
+		event := &GameEvent{EventType: "turn_taken", UserID: &userID, Metadata: map[string]string{"n": fmt.Sprint(i)}}
+		if err := events.AppendGameEvent(ctx, game.PublicID, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := events.AppendGameEvent(ctx, "no-such-game", &GameEvent{EventType: "turn_taken"}); !errors.Is(err, ErrGameNotFound) {
+		t.Fatalf("append to a missing game: err = %v, want ErrGameNotFound", err)
+	}
+
+	feed, err := events.GetGameEvents(ctx, game.PublicID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, event := range feed {
+		got = append(got, event.Metadata["n"])
+	}
+	if want := []string{"2", "3", "4"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("feed = %v, want the last three oldest first %v", got, want)
+	}
+}
+
+func TestParseChatScope(t *testing.T) {
+	for _, tc := range []struct {
+		scope string
+		want  chatScope
+		ok    bool
+	}{
+		{"global", chatScope{dbScope: "global"}, true},
+		{"game:abc123", chatScope{dbScope: "game", publicID: "abc123"}, true},
+		{"dm:alice:bob", chatScope{dbScope: "dm", userA: "alice", userB: "bob"}, true},
+		{DMScope("bob", "alice"), chatScope{dbScope: "dm", userA: "alice", userB: "bob"}, true},
+		{"dm:bob:alice", chatScope{}, false},
+		{"dm:alice:alice", chatScope{}, false},
+		{"dm:alice", chatScope{}, false},
+		{"dm::bob", chatScope{}, false},
+		{"game:", chatScope{}, false},
+		{"Global", chatScope{}, false},
+		{"", chatScope{}, false},
+	} {
+		got, ok := parseChatScope(tc.scope)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseChatScope(%q) = %+v, %v, want %+v, %v", tc.scope, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestToggleReactionTogglesAndCapsKinds(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	chat := NewChatRepository(pool)
+	alice, bob := "00000000-0000-0000-0000-0000000c2051", "00000000-0000-0000-0000-0000000c2052"
+	seedPlayers(t, exec, alice, bob)
+	t.Cleanup(func() { exec(`DELETE FROM chat_messages WHERE sender_user_id = $1`, alice) })
+
+	msg, err := chat.SaveMessage(ctx, alice, "global", "nice round")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{true, false, true} {
+		added, err := chat.ToggleReaction(ctx, msg.ChatMessageID, bob, "👍")
+		if err != nil || added != want {
+			t.Fatalf("toggle = %v, %v, want %v", added, err, want)
+		}
+	}
+
+	for _, emoji := range []string{"👎", "😂", "😮", "😢", "🎉"} {
+		if _, err := chat.ToggleReaction(ctx, msg.ChatMessageID, alice, emoji); err != nil {
+			t.Fatalf("%s: %v", emoji, err)
+		}
+	}
+	if _, err := chat.ToggleReaction(ctx, msg.ChatMessageID, alice, "⛳"); !errors.Is(err, ErrTooManyReactions) {
+		t.Fatalf("seventh kind: err = %v, want ErrTooManyReactions", err)
+	}
+	if added, err := chat.ToggleReaction(ctx, msg.ChatMessageID, alice, "👍"); err != nil || !added {
+		t.Fatalf("another 👍 = %v, %v, want added", added, err)
+	}
+
+	counts, err := chat.GetReactionCounts(ctx, msg.ChatMessageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != MaxReactionTypes || counts["👍"] != 2 {
+		t.Fatalf("counts = %v, want %d kinds with two 👍", counts, MaxReactionTypes)
+	}
+}
+
+func TestUnreadCountsCountOthersMessagesUntilRead(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	games, chat := NewGameRepository(pool), NewChatRepository(pool)
+	alice, bob := "00000000-0000-0000-0000-0000000c2053", "00000000-0000-0000-0000-0000000c2054"
+	seedPlayers(t, exec, alice, bob)
+
+	game, err := games.CreateGame(ctx, alice, 2, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seat, userID := range []string{alice, bob} {
+		exec(`INSERT INTO game_players (game_id, user_id, order_index, is_active) VALUES ($1, $2, $3, true)`, game.GameID, userID, seat)
+	}
+	t.Cleanup(func() {
+		exec(`DELETE FROM chat_read_state WHERE user_id = $1`, alice)
+		exec(`DELETE FROM chat_messages WHERE game_id = $1 OR dm_user_a = $2 OR dm_user_b = $2`, game.GameID, alice)
+	})
+
+	gameScope, dmScope := "game:"+game.PublicID, DMScope(alice, bob)
+	save := func(senderUserID, scope string) *ChatMessage {
+		t.Helper()
+		msg, err := chat.SaveMessage(ctx, senderUserID, scope, "hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	requireUnread := func(want map[string]int) {
+		t.Helper()
+		counts, err := chat.GetUnreadCounts(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(counts) != fmt.Sprint(want) {
+			t.Fatalf("unread = %v, want %v", counts, want)
+		}
+	}
+
+	// Her own messages and deleted ones don't count; system messages do
+	first := save(bob, gameScope)
+	second := save(bob, gameScope)
+	save(alice, gameScope)
+	save("", gameScope)
+	deleted := save(bob, gameScope)
+	if err := chat.DeleteMessage(ctx, deleted.ChatMessageID, bob); err != nil {
+		t.Fatal(err)
+	}
+	save(bob, dmScope)
+	save(bob, dmScope)
+	requireUnread(map[string]int{gameScope: 3, dmScope: 2})
+
+	if err := chat.MarkScopeRead(ctx, alice, gameScope, second.ChatMessageID); err != nil {
+		t.Fatal(err)
+	}
+	requireUnread(map[string]int{gameScope: 1, dmScope: 2})
+
+	// Marking an older message doesn't move the read position back
+	if err := chat.MarkScopeRead(ctx, alice, gameScope, first.ChatMessageID); err != nil {
+		t.Fatal(err)
+	}
+	last := save(bob, dmScope)
+	if err := chat.MarkScopeRead(ctx, alice, dmScope, last.ChatMessageID); err != nil {
+		t.Fatal(err)
+	}
+	requireUnread(map[string]int{gameScope: 1})
+
+	if err := chat.MarkScopeRead(ctx, alice, "lobby", 1); err == nil {
+		t.Fatal("marked an invalid scope read")
+	}
+}
+
+func TestPasswordResetTokenWorksOnceBeforeItExpires(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	users := NewUserRepository(pool)
+	userID := "00000000-0000-0000-0000-0000000c2055"
+	seedPlayers(t, exec, userID)
+	t.Cleanup(func() { exec(`DELETE FROM password_reset_tokens WHERE user_id = $1`, userID) })
+
+	if err := users.CreatePasswordResetToken(ctx, userID, "reset-expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.ResetPasswordWithToken(ctx, "reset-expired", "hash"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expired token: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	// A newer token replaces one that hasn't been used
+	for _, hash := range []string{"reset-replaced", "reset-latest"} {
+		if err := users.CreatePasswordResetToken(ctx, userID, hash, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := users.ResetPasswordWithToken(ctx, "reset-replaced", "hash"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("replaced token: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	if got, err := users.ResetPasswordWithToken(ctx, "reset-latest", "new-hash"); err != nil || got != userID {
+		t.Fatalf("ResetPasswordWithToken = %q, %v", got, err)
+	}
+	var password string
+	if err := pool.QueryRow(ctx, `SELECT password FROM users WHERE user_id = $1`, userID).Scan(&password); err != nil || password != "new-hash" {
+		t.Fatalf("password after reset = %q, %v", password, err)
+	}
+	if _, err := users.ResetPasswordWithToken(ctx, "reset-latest", "other-hash"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("second use: err = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestEmailVerificationTokenWorksOnceBeforeItExpires(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	users := NewUserRepository(pool)
+	userID := "00000000-0000-0000-0000-0000000c2056"
+	seedPlayers(t, exec, userID)
+	t.Cleanup(func() { exec(`DELETE FROM email_verification_tokens WHERE user_id = $1`, userID) })
+
+	if err := users.CreateEmailVerificationToken(ctx, userID, "verify-expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.VerifyEmailWithToken(ctx, "verify-expired"); !errors.Is(err, ErrInvalidVerifyToken) {
+		t.Fatalf("expired token: err = %v, want ErrInvalidVerifyToken", err)
+	}
+
+	if err := users.CreateEmailVerificationToken(ctx, userID, "verify-live", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := users.VerifyEmailWithToken(ctx, "verify-live"); err != nil || got != userID {
+		t.Fatalf("VerifyEmailWithToken = %q, %v", got, err)
+	}
+	var verified bool
+	if err := pool.QueryRow(ctx, `SELECT email_verified FROM users WHERE user_id = $1`, userID).Scan(&verified); err != nil || !verified {
+		t.Fatalf("email_verified = %v, %v", verified, err)
+	}
+	if _, err := users.VerifyEmailWithToken(ctx, "verify-live"); !errors.Is(err, ErrInvalidVerifyToken) {
+		t.Fatalf("second use: err = %v, want ErrInvalidVerifyToken", err)
+	}
+}
+
+func TestRevokeSessionByItsListedID(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	users := NewUserRepository(pool)
+	alice, bob := "00000000-0000-0000-0000-0000000c2059", "00000000-0000-0000-0000-0000000c205a"
+	seedPlayers(t, exec, alice, bob)
+	t.Cleanup(func() { exec(`DELETE FROM sessions WHERE user_id IN ($1, $2)`, alice, bob) })
+
+	expiresAt := time.Now().Add(time.Hour)
+	for token, userID := range map[string]string{"session-phone": alice, "session-laptop": alice, "session-bob": bob} {
+		if err := users.CreateSession(ctx, userID, token, expiresAt, token, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := users.GetSessions(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("listed %d sessions, want alice's 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.ID != SessionIDForToken(session.UserAgent) {
+			t.Fatalf("session %q has ID %q, want SessionIDForToken of its token", session.UserAgent, session.ID)
+		}
+	}
+
+	if err := users.RevokeSession(ctx, alice, SessionIDForToken("session-bob")); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking bob's session as alice: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := users.RevokeSession(ctx, alice, SessionIDForToken("session-phone")); err != nil {
+		t.Fatal(err)
+	}
+	for token, valid := range map[string]bool{"session-phone": false, "session-laptop": true, "session-bob": true} {
+		if _, _, err := users.ValidateSession(ctx, token); (err == nil) != valid {
+			t.Errorf("%s: validate err = %v, want valid %v", token, err, valid)
+		}
+	}
+}
+
+func TestUsernamesAreUniqueIgnoringCase(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	users := NewUserRepository(pool)
+	username := fmt.Sprintf("Case_%d", time.Now().UnixNano()%1e12)
+	t.Cleanup(func() { exec(`DELETE FROM users WHERE username_lower = lower($1)`, username) })
+
+	created, err := users.CreateUser(ctx, username, "x", username+"@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.CreateUser(ctx, strings.ToUpper(username), "x", "other-"+username+"@example.com"); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Fatalf("registering the same name in capitals: err = %v, want ErrUserAlreadyExists", err)
+	}
+	if exists, err := users.UserExists(ctx, strings.ToLower(username)); err != nil || !exists {
+		t.Fatalf("UserExists(lowercase) = %v, %v", exists, err)
+	}
+	found, err := users.GetUserByUsername(ctx, strings.ToLower(username))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.UserID != created.UserID || found.Username != username {
+		t.Errorf("found %q (%s), want %q (%s) with its display casing", found.Username, found.UserID, username, created.UserID)
+	}
+}
+
+func TestDeleteUserAbandonsLiveGamesAndHandsHistoryToThePlaceholder(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	games, users, chat := NewGameRepository(pool), NewUserRepository(pool), NewChatRepository(pool)
+	alice, bob := "00000000-0000-0000-0000-0000000c2065", "00000000-0000-0000-0000-0000000c2066"
+	placeholder := "00000000-0000-0000-0000-0000000de1e7" // business.DeletedUserID
+	seedPlayers(t, exec, alice, bob)
+
+	var gameIDs []int
+	newGame := func(status string) *Game {
+		t.Helper()
+		game, err := games.CreateGame(ctx, alice, 2, []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gameIDs = append(gameIDs, game.GameID)
+		for seat, userID := range []string{alice, bob} {
+			exec(`INSERT INTO game_players (game_id, user_id, order_index, is_active) VALUES ($1, $2, $3, true)`, game.GameID, userID, seat)
+		}
+		exec(`UPDATE games SET status = $2 WHERE game_id = $1`, game.GameID, status)
+		return game
+	}
+	live, done := newGame("in_progress"), newGame("finished")
+	t.Cleanup(func() {
+		for _, gameID := range gameIDs {
+			exec(`DELETE FROM chat_messages WHERE game_id = $1`, gameID)
+			exec(`DELETE FROM game_states WHERE game_id = $1`, gameID)
+			exec(`DELETE FROM game_players WHERE game_id = $1`, gameID)
+			exec(`DELETE FROM games WHERE game_id = $1`, gameID)
+		}
+	})
+	exec(`INSERT INTO game_states (game_id, state_json, version) VALUES ($1, '{"phase": "playing"}', 1)`, live.GameID)
+	exec(`UPDATE games SET winner_user_id = $2, finished_at = now() WHERE game_id = $1`, done.GameID, alice)
+	if err := users.CreateSession(ctx, alice, "session-deleted", time.Now().Add(time.Hour), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := chat.SaveMessage(ctx, alice, "game:"+done.PublicID, "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abandoned, err := users.DeleteUser(ctx, alice, placeholder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abandoned) != 1 || abandoned[0] != live.PublicID {
+		t.Fatalf("abandoned %v, want just the live game %s", abandoned, live.PublicID)
+	}
+
+	var status, phase string
+	if err := pool.QueryRow(ctx,
+		`SELECT g.status::text, s.state_json->>'phase' FROM games g JOIN game_states s USING (game_id) WHERE g.game_id = $1`,
+		live.GameID).Scan(&status, &phase); err != nil {
+		t.Fatal(err)
+	}
+	if status != "abandoned" || phase != "finished" {
+		t.Errorf("live game is %s in phase %s, want abandoned and finished", status, phase)
+	}
+
+	var winner string
+	var seated []string
+	if err := pool.QueryRow(ctx,
+		`SELECT winner_user_id::text, ARRAY(SELECT user_id::text FROM game_players WHERE game_id = $1 ORDER BY order_index)
+		 FROM games WHERE game_id = $1`, done.GameID).Scan(&winner, &seated); err != nil {
+		t.Fatal(err)
+	}
+	if winner != placeholder || len(seated) != 2 || seated[0] != placeholder || seated[1] != bob {
+		t.Errorf("finished game won by %s with players %v, want the placeholder in alice's place", winner, seated)
+	}
+
+	var sender, text string
+	var deleted bool
+	if err := pool.QueryRow(ctx,
+		`SELECT sender_user_id::text, message_text, deleted_at IS NOT NULL FROM chat_messages WHERE chat_message_id = $1`,
+		msg.ChatMessageID).Scan(&sender, &text, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if sender != placeholder || text != "" || !deleted {
+		t.Errorf("alice's message is from %s, text %q, deleted %v; want the placeholder's, blanked", sender, text, deleted)
+	}
+
+	var sessions, rows int
+	if err := pool.QueryRow(ctx,
+		`SELECT (SELECT count(*) FROM sessions WHERE user_id = $1), (SELECT count(*) FROM users WHERE user_id = $1)`,
+		alice).Scan(&sessions, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 0 || rows != 0 {
+		t.Errorf("%d sessions and %d user rows left, want none", sessions, rows)
+	}
+}
+
+func TestSetJoinTokenRetiresThePreviousToken(t *testing.T) {
+	ctx := context.Background()
+	repo, exec := testGameRepo(t)
+	alice := "00000000-0000-0000-0000-0000000c2011"
+	seedPlayers(t, exec, alice)
+
+	game, err := repo.CreateGame(ctx, alice, 2, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	for _, token := range []string{"old-" + suffix, "new-" + suffix} {
+		if err := repo.SetJoinToken(ctx, game.PublicID, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.GetGameByJoinToken(ctx, "old-"+suffix); !errors.Is(err, ErrGameNotFound) {
+		t.Fatalf("old token: err = %v, want ErrGameNotFound", err)
+	}
+	found, err := repo.GetGameByJoinToken(ctx, "new-"+suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.PublicID != game.PublicID {
+		t.Errorf("new token leads to %s, want %s", found.PublicID, game.PublicID)
+	}
+	if err := repo.SetJoinToken(ctx, "00000000-0000-0000-0000-000000000000", "x-"+suffix); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("unknown game: err = %v, want ErrGameNotFound", err)
+	}
+}
+
+func TestMarkEmailUndeliverableFlagsOnlyThatUser(t *testing.T) {
+	ctx := context.Background()
+	pool, exec := testPool(t)
+	users := NewUserRepository(pool)
+	alice, bob := "00000000-0000-0000-0000-0000000c2012", "00000000-0000-0000-0000-0000000c2013"
+	seedPlayers(t, exec, alice, bob)
+
+	if err := users.MarkEmailUndeliverable(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	for userID, want := range map[string]bool{alice: true, bob: false} {
+		var undeliverable bool
+		if err := pool.QueryRow(ctx, `SELECT email_undeliverable FROM users WHERE user_id = $1`, userID).Scan(&undeliverable); err != nil {
+			t.Fatal(err)
+		}
+		if undeliverable != want {
+			t.Errorf("%s: email_undeliverable = %v, want %v", userID, undeliverable, want)
+		}
+	}
+}
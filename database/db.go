@@ -2,10 +2,71 @@ package database
 
 import (
 	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Pool defaults, each overridable with the env var named alongside
+const (
+	defaultMaxConns          = 20               // DB_MAX_CONNS
+	defaultMinConns          = 2                // DB_MIN_CONNS
+	defaultMaxConnLifetime   = time.Hour        // DB_MAX_CONN_LIFETIME_MINUTES
+	defaultMaxConnIdleTime   = 30 * time.Minute // DB_MAX_CONN_IDLE_MINUTES
+	defaultHealthCheckPeriod = time.Minute      // DB_HEALTH_CHECK_SECONDS
+	defaultConnectTimeout    = 5 * time.Second  // DB_CONNECT_TIMEOUT_SECONDS
+)
+
+// NewPool connects to Postgres with pool limits read from the environment.
+// Missing or invalid values fall back to the defaults above.
 func NewPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
-	return pgxpool.New(ctx, connString)
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	config.MaxConns = int32(envInt("DB_MAX_CONNS", defaultMaxConns))
+	config.MinConns = int32(min(envInt("DB_MIN_CONNS", defaultMinConns), int(config.MaxConns)))
+	config.MaxConnLifetime = envDuration("DB_MAX_CONN_LIFETIME_MINUTES", time.Minute, defaultMaxConnLifetime)
+	config.MaxConnIdleTime = envDuration("DB_MAX_CONN_IDLE_MINUTES", time.Minute, defaultMaxConnIdleTime)
+	config.HealthCheckPeriod = envDuration("DB_HEALTH_CHECK_SECONDS", time.Second, defaultHealthCheckPeriod)
+	config.ConnConfig.ConnectTimeout = envDuration("DB_CONNECT_TIMEOUT_SECONDS", time.Second, defaultConnectTimeout)
+	maxRetryAttempts = envInt("DB_RETRY_ATTEMPTS", maxRetryAttempts)
+
+	log.Printf("Database pool: max %d, min %d connections, lifetime %s, idle %s, health check every %s, connect timeout %s, %d write attempts",
+		config.MaxConns, config.MinConns, config.MaxConnLifetime, config.MaxConnIdleTime,
+		config.HealthCheckPeriod, config.ConnConfig.ConnectTimeout, maxRetryAttempts)
+
+	return pgxpool.NewWithConfig(ctx, config)
+}
+
+// envInt reads a positive integer from the environment, or returns def
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		log.Printf("Invalid %s %q, using %d", name, raw, def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a positive whole number of units from the environment, or returns def
+func envDuration(name string, unit, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		log.Printf("Invalid %s %q, using %s", name, raw, def)
+		return def
+	}
+	return time.Duration(n) * unit
 }
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvIntFallsBackOnInvalidValues(t *testing.T) {
+	for raw, want := range map[string]int{
+		"":     7,
+		"12":   12,
+		"0":    7,
+		"-3":   7,
+		"ten":  7,
+		"4.5":  7,
+		" 12 ": 7,
+	} {
+		t.Setenv("DB_TEST_INT", raw)
+		if got := envInt("DB_TEST_INT", 7); got != want {
+			t.Errorf("envInt(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
+
+func TestEnvDurationFallsBackOnInvalidValues(t *testing.T) {
+	for raw, want := range map[string]time.Duration{
+		"":    30 * time.Second,
+		"5":   5 * time.Minute,
+		"0":   30 * time.Second,
+		"-1":  30 * time.Second,
+		"5m":  30 * time.Second,
+		"1.5": 30 * time.Second,
+	} {
+		t.Setenv("DB_TEST_DURATION", raw)
+		if got := envDuration("DB_TEST_DURATION", time.Minute, 30*time.Second); got != want {
+			t.Errorf("envDuration(%q) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestNewPoolUsesDefaultsForInvalidSettings(t *testing.T) {
+	t.Setenv("DB_MAX_CONNS", "lots")
+	t.Setenv("DB_MIN_CONNS", "-2")
+	t.Setenv("DB_MAX_CONN_LIFETIME_MINUTES", "forever")
+	t.Setenv("DB_CONNECT_TIMEOUT_SECONDS", "0")
+	t.Setenv("DB_RETRY_ATTEMPTS", "many")
+
+	prevAttempts := maxRetryAttempts
+	t.Cleanup(func() { maxRetryAttempts = prevAttempts })
+
+	// Connecting is lazy, so no server is needed to inspect the configuration
+	pool, err := NewPool(t.Context(), "postgres://user@127.0.0.1:1/golf")
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer pool.Close()
+
+	config := pool.Config()
+	if config.MaxConns != defaultMaxConns || config.MinConns != defaultMinConns {
+		t.Errorf("connections = max %d, min %d, want %d and %d", config.MaxConns, config.MinConns, defaultMaxConns, defaultMinConns)
+	}
+	if config.MaxConnLifetime != defaultMaxConnLifetime {
+		t.Errorf("lifetime = %s, want %s", config.MaxConnLifetime, defaultMaxConnLifetime)
+	}
+	if config.ConnConfig.ConnectTimeout != defaultConnectTimeout {
+		t.Errorf("connect timeout = %s, want %s", config.ConnConfig.ConnectTimeout, defaultConnectTimeout)
+	}
+	if maxRetryAttempts != prevAttempts {
+		t.Errorf("retry attempts = %d, want %d", maxRetryAttempts, prevAttempts)
+	}
+}
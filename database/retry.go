@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Backoff between retries doubles from retryBaseDelay up to retryMaxDelay
+const (
+	retryBaseDelay = 50 * time.Millisecond
+	retryMaxDelay  = time.Second
+)
+
+// maxRetryAttempts is how many times withRetry tries a write, including the first.
+// Set from DB_RETRY_ATTEMPTS in NewPool.
+var maxRetryAttempts = 3
+
+// withRetry runs fn, retrying with backoff while it fails with a transient error.
+// Any other error, such as a unique violation, is returned straight away.
+func withRetry(ctx context.Context, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetryAttempts || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// isTransient reports whether err is worth retrying: a serialization failure, a
+// deadlock, a connection problem reported by the server, or a failure pgx knows
+// happened before anything was sent
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || // serialization_failure
+			pgErr.Code == "40P01" || // deadlock_detected
+			strings.HasPrefix(pgErr.Code, "08") // connection_exception
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+// write runs a write with withRetry. Inside WithTx it runs once, since a failed
+// statement aborts the whole transaction and only the caller can start it again.
+func (r *postgresGameRepo) write(ctx context.Context, fn func() error) error {
+	if _, inTx := r.pool.(pgx.Tx); inTx {
+		return fn()
+	}
+	return withRetry(ctx, fn)
+}
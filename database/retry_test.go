@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// flakyWrite fails with the given errors in turn, then succeeds
+type flakyWrite struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyWrite) run() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func pgError(code string) error {
+	return &pgconn.PgError{Code: code}
+}
+
+func TestWithRetryRetriesTransientErrors(t *testing.T) {
+	for _, code := range []string{"40001", "40P01", "08006"} {
+		write := &flakyWrite{errs: []error{pgError(code), pgError(code)}}
+		if err := withRetry(context.Background(), write.run); err != nil {
+			t.Errorf("%s: err = %v after retrying", code, err)
+		}
+		if write.calls != 3 {
+			t.Errorf("%s: %d attempts, want 3", code, write.calls)
+		}
+	}
+}
+
+func TestWithRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	write := &flakyWrite{errs: []error{pgError("40001"), pgError("40001"), pgError("40001"), pgError("40001")}}
+	err := withRetry(context.Background(), write.run)
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "40001" {
+		t.Fatalf("err = %v, want the last serialization failure", err)
+	}
+	if write.calls != maxRetryAttempts {
+		t.Fatalf("%d attempts, want %d", write.calls, maxRetryAttempts)
+	}
+}
+
+func TestWithRetryReturnsPermanentErrorsAtOnce(t *testing.T) {
+	uniqueViolation := pgError("23505")
+	write := &flakyWrite{errs: []error{uniqueViolation}}
+
+	if err := withRetry(context.Background(), write.run); err != uniqueViolation {
+		t.Fatalf("err = %v, want the unique violation unchanged", err)
+	}
+	if write.calls != 1 {
+		t.Fatalf("%d attempts, want 1", write.calls)
+	}
+}
+
+func TestWithRetryStopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	write := &flakyWrite{errs: []error{pgError("40001"), pgError("40001")}}
+	if err := withRetry(ctx, write.run); err == nil {
+		t.Fatal("retried after the context was cancelled")
+	}
+	if write.calls != 1 {
+		t.Fatalf("%d attempts, want 1", write.calls)
+	}
+}
+
+// fakeTx is enough of a transaction for write to recognize it
+type fakeTx struct {
+	pgx.Tx
+}
+
+func TestWriteInsideATransactionRunsOnce(t *testing.T) {
+	repo := &postgresGameRepo{pool: fakeTx{}}
+	write := &flakyWrite{errs: []error{pgError("40001")}}
+
+	if err := repo.write(context.Background(), write.run); err == nil {
+		t.Fatal("write retried inside a transaction")
+	}
+	if write.calls != 1 {
+		t.Fatalf("%d attempts, want 1", write.calls)
+	}
+}
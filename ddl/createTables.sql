@@ -1,10 +1,19 @@
 CREATE TABLE users (
     user_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    username TEXT UNIQUE NOT NULL,
+    username TEXT UNIQUE NOT NULL, -- Display casing, as registered
+    username_lower TEXT UNIQUE GENERATED ALWAYS AS (lower(username)) STORED, -- Lookups are case-insensitive
     password TEXT,
-    email TEXT
+    email TEXT,
+    email_undeliverable BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE
 );
 
+-- Reserved computer opponent for single-player games (business.BotUserID). It has no password, so it can't log in.
+INSERT INTO users (user_id, username) VALUES ('00000000-0000-0000-0000-00000000b07a', 'GolfBot');
+
+-- Stands in for deleted accounts in finished games and chat history (business.DeletedUserID)
+INSERT INTO users (user_id, username) VALUES ('00000000-0000-0000-0000-0000000de1e7', '[deleted]');
+
 CREATE TABLE sessions (
     session_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id UUID REFERENCES users(user_id),
@@ -16,8 +25,41 @@ CREATE TABLE sessions (
     metadata JSONB
 );
 
+CREATE TYPE friendship_status AS ENUM ('pending', 'accepted');
+
+-- One row per pair of users, whoever asked first. Rows go when either account is deleted.
+CREATE TABLE friendships (
+    requester_id UUID REFERENCES users(user_id) ON DELETE CASCADE,
+    addressee_id UUID REFERENCES users(user_id) ON DELETE CASCADE,
+    status friendship_status NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ DEFAULT now(),
+    accepted_at TIMESTAMPTZ,
+    PRIMARY KEY (requester_id, addressee_id),
+    CHECK (requester_id <> addressee_id)
+);
+
+CREATE UNIQUE INDEX friendships_pair_idx ON friendships (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+
 CREATE TYPE game_status AS ENUM ('waiting_for_players', 'in_progress', 'finished', 'abandoned');
 
+-- Single-use password reset links. Only a hash of the emailed token is kept.
+CREATE TABLE password_reset_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users(user_id),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+-- Links emailed to confirm a user owns their address. Only a hash of the token is kept.
+CREATE TABLE email_verification_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users(user_id),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
 CREATE TABLE games (
     game_id SERIAL PRIMARY KEY,
     public_id UUID DEFAULT gen_random_uuid(),
@@ -27,18 +69,45 @@ CREATE TABLE games (
     max_players INT,
     player_count INT,
     finished_at TIMESTAMPTZ,
-    winner_user_id UUID REFERENCES users(user_id)
+    winner_user_id UUID REFERENCES users(user_id),
+    rules JSONB NOT NULL DEFAULT '{}',
+    join_token TEXT UNIQUE,
+    last_move_at TIMESTAMPTZ,
+    original_game_id INT UNIQUE REFERENCES games(game_id) -- Set on a rematch
 );
 
-CREATE TYPE chat_scope AS ENUM ('global', 'game');
+CREATE INDEX games_finished_idx ON games (finished_at DESC, game_id DESC) WHERE status IN ('finished', 'abandoned');
+
+CREATE TYPE chat_scope AS ENUM ('global', 'game', 'dm');
 
 CREATE TABLE chat_messages (
     chat_message_id SERIAL PRIMARY KEY,
-    sender_user_id UUID REFERENCES users(user_id),
+    sender_user_id UUID REFERENCES users(user_id), -- NULL for system messages
     scope chat_scope,
     game_id INT REFERENCES games(game_id),
+    dm_user_a UUID REFERENCES users(user_id), -- Direct messages only, dm_user_a < dm_user_b
+    dm_user_b UUID REFERENCES users(user_id),
     message_text TEXT,
-    created_at TIMESTAMPTZ DEFAULT now()
+    created_at TIMESTAMPTZ DEFAULT now(),
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ, -- Soft delete, shown as "[deleted]"
+    is_system BOOLEAN NOT NULL DEFAULT false -- Posted by the server, e.g. "Alice joined the game"
+);
+
+CREATE TABLE message_reactions (
+    chat_message_id INT REFERENCES chat_messages(chat_message_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id),
+    emoji TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (chat_message_id, user_id, emoji)
+);
+
+-- Last message each user has read per chat scope ("game:<publicId>", "dm:<userA>:<userB>")
+CREATE TABLE chat_read_state (
+    user_id UUID REFERENCES users(user_id),
+    scope TEXT NOT NULL,
+    last_read_id INT NOT NULL,
+    PRIMARY KEY (user_id, scope)
 );
 
 CREATE TABLE game_players (
@@ -54,12 +123,48 @@ CREATE TABLE game_players (
 
 CREATE TABLE game_states (
     game_state_id SERIAL PRIMARY KEY,
-    game_id INT REFERENCES games(game_id),
+    game_id INT UNIQUE REFERENCES games(game_id),
     state_json JSONB,
+    initial_state_json JSONB,
     last_updated TIMESTAMPTZ DEFAULT now(),
     version INT
 );
 
+CREATE TABLE game_moves (
+    game_move_id SERIAL PRIMARY KEY,
+    game_id INT REFERENCES games(game_id),
+    user_id UUID REFERENCES users(user_id),
+    action TEXT NOT NULL,
+    card_index INT,
+    decks_json JSONB,
+    resulting_version INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (game_id, resulting_version)
+);
+
+CREATE TABLE game_events (
+    game_event_id SERIAL PRIMARY KEY,
+    game_id INT REFERENCES games(game_id),
+    event_type TEXT NOT NULL,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    metadata JSONB,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX game_events_game_id_idx ON game_events (game_id, game_event_id);
+
+CREATE TABLE audit_log (
+    audit_log_id SERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    ip_address TEXT,
+    user_agent TEXT,
+    metadata JSONB,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, created_at DESC);
+
 -- change owner to golfer for all tables
 DO $$
 DECLARE
@@ -55,6 +55,8 @@ interface GameMessage {
 interface GameEndData {
   winnerUserId: string;
   winnerUsername: string;
+  winnerUserIds: string[];
+  isTie: boolean;
   scores: { [userId: string]: number };
 }
 
@@ -292,6 +294,7 @@ function GameRoomContent() {
           setTimeout(() => setErrorMessage(null), 5000);
           break;
         case "game_end":
+        case "game_over":
           const endData = message.payload as GameEndData;
           setGameEndData(endData);
           setGameEndTime(new Date()); // Record when the game ended
@@ -728,10 +731,16 @@ function GameRoomContent() {
             </h2>
             <div className="bg-green-700 rounded-lg p-4 mb-6">
               <p className="text-white text-center text-lg">
-                <span className="font-semibold">Winner:</span>{" "}
-                <span className="text-yellow-300 font-bold">
-                  {gameEndData.winnerUsername}
-                </span>
+                {gameEndData.isTie ? (
+                  <span className="font-semibold">It&apos;s a tie!</span>
+                ) : (
+                  <>
+                    <span className="font-semibold">Winner:</span>{" "}
+                    <span className="text-yellow-300 font-bold">
+                      {gameEndData.winnerUsername}
+                    </span>
+                  </>
+                )}
               </p>
             </div>
             <div className="bg-gray-700 rounded-lg p-4 mb-6">
@@ -746,7 +755,7 @@ function GameRoomContent() {
                   >
                     <span className="text-white">
                       {player.username}
-                      {player.userId === gameEndData.winnerUserId && " 👑"}
+                      {gameEndData.winnerUserIds?.includes(player.userId) && " 👑"}
                     </span>
                     <span className="text-yellow-300 font-bold">
                       {gameEndData.scores[player.userId]} points
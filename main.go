@@ -8,6 +8,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -51,6 +53,32 @@ func cleanupGames(ctx context.Context, gameService *business.GameService) {
 	}
 }
 
+// startAbandonSweep periodically abandons in-progress games with no move for abandonAfter
+func startAbandonSweep(ctx context.Context, gameService *business.GameService, abandonAfter time.Duration) {
+	ticker := time.NewTicker(min(time.Hour, abandonAfter/2))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			abandoned, err := gameService.AbandonStaleGames(ctx, now, abandonAfter)
+			if err != nil {
+				log.Printf("Error abandoning stale games: %v", err)
+				continue
+			}
+			for _, publicID := range abandoned {
+				service.NotifyGameAbandoned(publicID)
+			}
+			if len(abandoned) > 0 {
+				log.Printf("Abandoned %d stalled game(s)", len(abandoned))
+			}
+		case <-ctx.Done():
+			log.Println("Abandon sweep stopped")
+			return
+		}
+	}
+}
+
 func main() {
 	ctx := context.Background()
 
@@ -74,28 +102,164 @@ func main() {
 	userRepo := database.NewUserRepository(db)
 	chatRepo := database.NewChatRepository(db)
 	gameRepo := database.NewGameRepository(db)
+	auditRepo := database.NewAuditRepository(db)
+	gameEventRepo := database.NewGameEventRepository(db)
+	moveLogRepo := database.NewMoveLogRepository(db)
+	friendRepo := database.NewFriendRepository(db)
 
 	// create business layer
 	userService := business.NewUserService(userRepo)
 	gameService := business.NewGameService(gameRepo, userRepo)
 	nonceManager := business.NewNonceManager()
 	emailService := service.NewEmailService()
+	auditLogger := business.NewAuditLogger(auditRepo)
+	gameEventLog := business.NewGameEventLog(gameEventRepo)
+	moveLog := business.NewMoveLog(moveLogRepo, gameRepo)
+	gameService.SetMoveLog(moveLog)
+	webhookService, err := service.NewWebhookService()
+	if err != nil {
+		log.Fatalf("Invalid webhook configuration: %v", err)
+	}
+	friendService := business.NewFriendService(friendRepo)
+
+	userService.SetPasswordResetMailer(func(user *database.User, token string) error {
+		return emailService.SendPasswordResetEmail(user.Email, service.PasswordResetURL(token))
+	})
+	userService.SetVerificationMailer(func(user *database.User, token string) error {
+		return emailService.SendVerificationEmail(user.Email, service.EmailVerificationURL(token))
+	})
+
+	if cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil {
+		if err := userService.SetBcryptCost(cost); err != nil {
+			log.Fatalf("Invalid BCRYPT_COST: %v", err)
+		}
+	}
+
+	sessionHours, _ := strconv.Atoi(os.Getenv("SESSION_TTL_HOURS"))
+	renewThreshold, _ := strconv.ParseFloat(os.Getenv("SESSION_RENEW_THRESHOLD"), 64)
+	userService.SetSessionPolicy(time.Duration(sessionHours)*time.Hour, renewThreshold)
+
+	passwordMinLength, _ := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	requireMixedCase, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_MIXED_CASE"))
+	requireDigit, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT"))
+	requireSymbol, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL"))
+	rejectCommon, _ := strconv.ParseBool(os.Getenv("PASSWORD_REJECT_COMMON"))
+	business.SetPasswordPolicy(business.PasswordPolicy{
+		MinLength:        passwordMinLength,
+		RequireMixedCase: requireMixedCase,
+		RequireDigit:     requireDigit,
+		RequireSymbol:    requireSymbol,
+		RejectCommon:     rejectCommon,
+	})
+
+	loginMaxFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES"))
+	loginWindowMinutes, _ := strconv.Atoi(os.Getenv("LOGIN_FAILURE_WINDOW_MINUTES"))
+	loginLockoutMinutes, _ := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES"))
+	userService.SetLoginLockout(loginMaxFailures, time.Duration(loginWindowMinutes)*time.Minute, time.Duration(loginLockoutMinutes)*time.Minute)
+
+	userService.SetAccountDeletedHandler(service.NotifyAccountDeleted)
+
+	// Stop mailing addresses that have been rejected outright
+	emailService.SetPermanentFailureHandler(func(userID string) {
+		if err := userService.MarkEmailUndeliverable(ctx, userID); err != nil {
+			log.Printf("Failed to mark email undeliverable for user %s: %v", userID, err)
+		}
+	})
 
 	// Set the services for HTTP handlers
 	service.SetUserService(userService)
 	service.SetNonceManager(nonceManager)
 	service.SetEmailService(emailService)
+	service.SetAuditLogger(auditLogger)
+	service.SetWebhookService(webhookService)
 	service.SetChatRepository(chatRepo)
 	service.SetGameRepository(gameRepo)
 	service.SetGameService(gameService)
+	service.SetGameEventLog(gameEventLog)
+	service.SetMoveLog(moveLog)
+	service.SetFriendService(friendService)
+	if enabled, _ := strconv.ParseBool(os.Getenv("CHAT_FILTER_ENABLED")); enabled {
+		chatFilter, err := business.LoadChatFilter(os.Getenv("CHAT_FILTER_WORDLIST"))
+		if err != nil {
+			log.Printf("Chat filter disabled: %v", err)
+		} else {
+			service.SetChatFilter(chatFilter)
+		}
+	}
+	// WebSocket origins default to the frontend's own
+	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
+	if allowedOrigins == "" {
+		allowedOrigins = os.Getenv("FRONTEND_URL")
+	}
+	service.SetAllowedOrigins(strings.Split(allowedOrigins, ","))
+	if devMode, err := strconv.ParseBool(os.Getenv("DEV_MODE")); err == nil {
+		service.SetDevMode(devMode)
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("COOKIE_SECURE")); err == nil {
+		service.SetCookieSecure(secure)
+	}
+	if err := service.SetCookieSameSite(os.Getenv("COOKIE_SAMESITE")); err != nil {
+		log.Fatalf("Invalid COOKIE_SAMESITE: %v", err)
+	}
+	if err := service.SetTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	if required, err := strconv.ParseBool(os.Getenv("REQUIRE_VERIFIED_EMAIL")); err == nil {
+		service.SetRequireVerifiedEmail(required)
+	}
+	if compression, err := strconv.ParseBool(os.Getenv("WS_COMPRESSION")); err == nil {
+		service.SetWebSocketCompression(compression)
+	}
+	service.SetAdminUserIDs(os.Getenv("ADMIN_USER_IDS"))
+	if verify, err := strconv.ParseBool(os.Getenv("GAME_VERIFY_ON_LOAD")); err == nil {
+		service.SetVerifyStateOnLoad(verify)
+	}
+	service.SetDuplicateConnectionPolicy(os.Getenv("GAME_DUPLICATE_CONNECTION_POLICY"))
+	service.SetDefaultTimeoutStrategy(os.Getenv("GAME_TIMEOUT_STRATEGY"))
+	if maxBody, err := strconv.ParseInt(os.Getenv("MAX_REQUEST_BODY_BYTES"), 10, 64); err == nil {
+		service.SetMaxRequestBodyBytes(maxBody)
+	}
+	if maxConns, err := strconv.Atoi(os.Getenv("WS_MAX_CONNECTIONS_PER_USER")); err == nil {
+		service.SetMaxConnectionsPerUser(maxConns)
+	}
+	if maxLength, err := strconv.Atoi(os.Getenv("CHAT_MAX_MESSAGE_LENGTH")); err == nil {
+		service.SetMaxChatMessageLength(maxLength)
+	}
+	chatBurst, _ := strconv.Atoi(os.Getenv("CHAT_RATE_BURST"))
+	chatPerSecond, _ := strconv.ParseFloat(os.Getenv("CHAT_RATE_PER_SECOND"), 64)
+	service.SetChatRateLimit(chatBurst, chatPerSecond)
+	if maxMissed, err := strconv.Atoi(os.Getenv("WS_MAX_MISSED_PONGS")); err == nil {
+		service.SetMaxMissedPongs(maxMissed)
+	}
+	if countdown, err := strconv.Atoi(os.Getenv("GAME_COUNTDOWN_SECONDS")); err == nil {
+		service.SetPreGameCountdown(countdown)
+	}
+	if grace, err := strconv.Atoi(os.Getenv("GAME_DISCONNECT_GRACE_SECONDS")); err == nil {
+		service.SetDisconnectGracePeriod(time.Duration(grace) * time.Second)
+	}
+	if turnSeconds, err := strconv.Atoi(os.Getenv("GAME_TURN_TIMEOUT_SECONDS")); err == nil {
+		service.SetTurnTimeout(time.Duration(turnSeconds) * time.Second)
+	}
 
 	// Start the chat hub as a background goroutine
 	go service.Hub.Run()
 
+	// Deliver webhooks in the background
+	go webhookService.Run(ctx)
+
 	// Start the game cleanup routine as a background goroutine
-	// Runs every hour and cleans up games inactive for 24+ hours
+	// Runs every hour and cleans up unstarted games inactive for 24+ hours
 	go startGameCleanup(ctx, gameService)
 
+	// Abandon games stalled in progress (0 disables)
+	abandonAfter := 24 * time.Hour
+	if hours, err := strconv.Atoi(os.Getenv("GAME_ABANDON_AFTER_HOURS")); err == nil {
+		abandonAfter = time.Duration(hours) * time.Hour
+	}
+	if abandonAfter > 0 {
+		go startAbandonSweep(ctx, gameService, abandonAfter)
+	}
+
 	// a mux (multiplexer) routes incoming requests to their respective handlers
 	mux := http.NewServeMux()
 
@@ -104,16 +268,56 @@ func main() {
 	mux.HandleFunc("/api/register", service.RegisterHandler)
 	mux.HandleFunc("/api/login", service.LoginHandler)
 	mux.HandleFunc("/api/logout", service.LogoutHandler)
+	mux.HandleFunc("/api/password/reset/request", service.RequestPasswordResetHandler)
+	mux.HandleFunc("/api/password/reset/confirm", service.ConfirmPasswordResetHandler)
+	mux.HandleFunc("/api/verify-email", service.VerifyEmailHandler)
 
 	// Protected API endpoints
 
+	// Account
+	mux.HandleFunc("/api/verify-email/resend", service.ResendVerificationHandler)
+	mux.HandleFunc("/api/account/password", service.ChangePasswordHandler)
+	mux.HandleFunc("/api/logout-all", service.LogoutAllHandler)
+	mux.HandleFunc("/api/account/sessions", service.SessionsHandler)
+	mux.HandleFunc("/api/account/delete", service.DeleteAccountHandler)
+
 	// Game management
 	mux.HandleFunc("/api/game/create", service.CreateGameHandler)
 	mux.HandleFunc("/api/game/invite", service.InvitePlayerHandler)
 	mux.HandleFunc("/api/game/accept", service.AcceptInvitationHandler)
 	mux.HandleFunc("/api/game/decline", service.DeclineInvitationHandler)
+	mux.HandleFunc("/api/game/leave", service.LeaveGameHandler)
+	mux.HandleFunc("/api/game/join", service.JoinByLinkHandler)
+	mux.HandleFunc("/api/game/regenerate-link", service.RegenerateJoinLinkHandler)
+	mux.HandleFunc("/api/game/rematch", service.RematchHandler)
 	mux.HandleFunc("/api/game/list", service.ListGamesHandler)
 	mux.HandleFunc("/api/game/details", service.GetGameHandler)
+	mux.HandleFunc("/api/game/state", service.GetGameStateHandler)
+	mux.HandleFunc("/api/game/history", service.GetGameHistoryHandler)
+	mux.HandleFunc("/api/game/events", service.GetGameEventsHandler)
+	mux.HandleFunc("/api/game/replay", service.GameReplayHandler)
+
+	// Player statistics
+	mux.HandleFunc("/api/stats", service.GetStatsHandler)
+	mux.HandleFunc("/api/leaderboard", service.GetLeaderboardHandler)
+
+	// Friends and presence
+	mux.HandleFunc("/api/friends", service.ListFriendsHandler)
+	mux.HandleFunc("/api/friends/request", service.SendFriendRequestHandler)
+	mux.HandleFunc("/api/friends/accept", service.AcceptFriendRequestHandler)
+	mux.HandleFunc("/api/friends/remove", service.RemoveFriendHandler)
+	mux.HandleFunc("/api/friends/online", service.GetOnlineFriendsHandler)
+
+	// Chat history
+	mux.HandleFunc("/api/chat/history", service.GetChatHistoryHandler)
+	mux.HandleFunc("/api/chat/dm", service.GetDirectMessagesHandler)
+	mux.HandleFunc("/api/chat/edit", service.EditChatMessageHandler)
+	mux.HandleFunc("/api/chat/delete", service.DeleteChatMessageHandler)
+	mux.HandleFunc("/api/chat/unread", service.GetUnreadCountsHandler)
+	mux.HandleFunc("/api/chat/read", service.MarkChatReadHandler)
+
+	// Admin tools, for the users in ADMIN_USER_IDS
+	mux.HandleFunc("/api/admin/game/verify", service.AdminVerifyGameHandler)
 
 	// WebSocket endpoints
 	mux.HandleFunc("/api/ws/chat", service.ChatHandler)
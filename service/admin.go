@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/business"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminUserIDs are the users allowed to call the /api/admin endpoints
+var adminUserIDs = map[string]bool{}
+
+// verifyStateOnLoad makes every newly opened game room check its persisted state
+// against the move log
+var verifyStateOnLoad = false
+
+// SetAdminUserIDs sets who may use the admin endpoints, as a comma-separated list of
+// user IDs. Empty leaves the endpoints closed to everyone.
+func SetAdminUserIDs(list string) {
+	admins := make(map[string]bool)
+	for _, userID := range strings.Split(list, ",") {
+		if userID = strings.TrimSpace(userID); userID != "" {
+			admins[userID] = true
+		}
+	}
+	adminUserIDs = admins
+}
+
+// SetVerifyStateOnLoad turns on checking each game's state against its move log
+// whenever a room for it is opened. Discrepancies are logged.
+func SetVerifyStateOnLoad(enabled bool) {
+	verifyStateOnLoad = enabled
+}
+
+// verifyGameResponse reports whether a game's persisted state is what its move log produces
+type verifyGameResponse struct {
+	PublicID    string   `json:"publicId"`
+	Consistent  bool     `json:"consistent"`
+	Differences []string `json:"differences"`       // State fields that don't match the replay
+	MoveLog     string   `json:"moveLog,omitempty"` // Why the move log itself couldn't be replayed
+}
+
+// AdminVerifyGameHandler replays a game's move log and compares the result with its
+// persisted state, to catch corrupted or tampered games
+func AdminVerifyGameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if !adminUserIDs[userID] {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		return
+	}
+
+	publicID := r.URL.Query().Get("publicId")
+	if publicID == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "publicId query parameter is required"})
+		return
+	}
+
+	diffs, err := gameService.VerifyGameState(ctx, publicID)
+	result := "consistent"
+	switch {
+	case err == nil && len(diffs) > 0:
+		result = "differs: " + strings.Join(diffs, ",")
+	case err != nil:
+		result = "error: " + err.Error()
+	}
+	recordAudit(r, business.AuditAdminAction, userID, map[string]string{
+		"action":   "verify_game_state",
+		"publicId": publicID,
+		"result":   result,
+	})
+
+	response := verifyGameResponse{PublicID: publicID, Consistent: err == nil && len(diffs) == 0, Differences: diffs}
+	if response.Differences == nil {
+		response.Differences = []string{}
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, business.ErrGameNotFound):
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+		return
+	case errors.Is(err, business.ErrReplayUnavailable):
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "No move log is available for this game"})
+		return
+	case errors.Is(err, business.ErrIllegalMove):
+		// Includes a recorded deck that doesn't match or is missing
+		response.MoveLog = err.Error()
+	default:
+		log.Printf("Error verifying game %s: %v", publicID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify game"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, response)
+}
+
+// verifyRoomState logs any difference between a game's persisted state and its
+// move log, for SetVerifyStateOnLoad. Games without a stored deal are skipped.
+func verifyRoomState(ctx context.Context, publicID string) {
+	diffs, err := gameService.VerifyGameState(ctx, publicID)
+	switch {
+	case errors.Is(err, business.ErrReplayUnavailable), errors.Is(err, business.ErrGameNotFound):
+	case err != nil:
+		log.Printf("Game %s failed verification against its move log: %v", publicID, err)
+	case len(diffs) > 0:
+		log.Printf("Game %s state differs from its move log in %v", publicID, diffs)
+	}
+}
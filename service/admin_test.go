@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func verifyGameRequest(t *testing.T, userID, publicID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/game/verify?publicId="+publicID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+	w := httptest.NewRecorder()
+	AdminVerifyGameHandler(w, req)
+	return w
+}
+
+func useAdmins(t *testing.T, list string) {
+	t.Helper()
+
+	prev := adminUserIDs
+	t.Cleanup(func() { adminUserIDs = prev })
+	SetAdminUserIDs(list)
+}
+
+func TestVerifyGameIsForAdminsOnly(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	audit := useFakeUsers(t, "secret")
+	useAdmins(t, "root")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	if w := verifyGameRequest(t, "alice", "game"); w.Code != http.StatusForbidden {
+		t.Fatalf("non-admin got %d, want 403", w.Code)
+	}
+	if len(audit.events) != 0 {
+		t.Fatalf("refused request recorded %d audit events", len(audit.events))
+	}
+}
+
+func TestVerifyGameReportsTamperedState(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	audit := useFakeUsers(t, "secret")
+	useAdmins(t, "root, ops")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	verify := func() verifyGameResponse {
+		t.Helper()
+		w := verifyGameRequest(t, "ops", "game")
+		if w.Code != http.StatusOK {
+			t.Fatalf("verify returned %d: %s", w.Code, w.Body.String())
+		}
+		var resp verifyGameResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := verify(); !resp.Consistent || len(resp.Differences) != 0 {
+		t.Fatalf("honest game = %+v, want consistent", resp)
+	}
+
+	// Reveal one of alice's cards behind the move log's back
+	stateJSON, _, err := repo.LoadGameState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state business.FullGameState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		t.Fatal(err)
+	}
+	state.Players[0].FaceUp[0] = true
+	if stateJSON, err = json.Marshal(&state); err != nil {
+		t.Fatal(err)
+	}
+	repo.mu.Lock()
+	repo.states["game"].state = stateJSON
+	repo.mu.Unlock()
+
+	resp := verify()
+	if resp.Consistent || len(resp.Differences) != 1 || resp.Differences[0] != "players" {
+		t.Fatalf("tampered game = %+v, want players to differ", resp)
+	}
+
+	if len(audit.events) != 2 {
+		t.Fatalf("recorded %d audit events, want one per verification", len(audit.events))
+	}
+	event := audit.events[1]
+	if event.EventType != business.AuditAdminAction || event.UserID == nil || *event.UserID != "ops" {
+		t.Fatalf("audit event = %+v, want an admin action by ops", event)
+	}
+}
+
+func TestVerifyGameNotFound(t *testing.T) {
+	useFakeGames(t)
+	useFakeUsers(t, "secret")
+	useAdmins(t, "root")
+
+	if w := verifyGameRequest(t, "root", "missing"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing game got %d, want 404", w.Code)
+	}
+}
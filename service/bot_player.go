@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"log"
+	"time"
+)
+
+// botMoveDelay is how long the bot "thinks" before each action
+var botMoveDelay = 800 * time.Millisecond
+
+// botTimerC returns the pending bot move's channel, or nil (blocks forever) if none
+func (r *GameRoom) botTimerC() <-chan time.Time {
+	if r.botTimer == nil {
+		return nil
+	}
+	return r.botTimer.C
+}
+
+// scheduleBot queues a bot move if the bot plays in this game and has something to do.
+// Must only be called from Run.
+func (r *GameRoom) scheduleBot(state *business.FullGameState) {
+	if r.botTimer != nil || state == nil || r.clients.Len() == 0 {
+		return
+	}
+	if !business.BotShouldAct(state, business.BotUserID) {
+		return
+	}
+	r.botTimer = time.NewTimer(botMoveDelay)
+}
+
+// playBotTurn makes one bot move through the same path as a player's action.
+// Must only be called from Run.
+func (r *GameRoom) playBotTurn(ctx context.Context) {
+	r.botTimer = nil
+
+	state := r.loadState(ctx)
+	if state == nil {
+		return
+	}
+
+	action, cardIndex := business.ComputeBotMove(state, business.BotUserID)
+	if action == "" {
+		return
+	}
+
+	payload := ActionPayload{Action: action}
+	if business.ActionNeedsCardIndex(action) {
+		payload.Data, _ = json.Marshal(CardIndexData{Index: cardIndex})
+	}
+
+	state, failure := applyActionWithRetry(r.publicID, business.BotUserID, payload)
+	if failure != "" {
+		log.Printf("Bot move %s failed in game %s: %s", action, r.publicID, failure)
+		return
+	}
+
+	gameEventLog.Record(ctx, r.publicID, business.GameEventTurnTaken, business.BotUserID, map[string]string{
+		"action": action,
+	})
+
+	if state = finishIfOver(ctx, r, state); state == nil {
+		return
+	}
+	broadcastGameState(r, r.publicID, state)
+	r.armTurnTimer(state)
+	r.scheduleBot(state)
+}
@@ -1,56 +1,114 @@
 package service
 
 import (
+	"compress/flate"
 	"context"
-	"encoding/json"
+	"errors"
 	"golf-card-game/database"
 	"log"
 	"net/http"
-	"os"
-	"sync"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 90 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = 20 * time.Second
-
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512 * 1024
-)
-
 // upgrader converts an incoming HTTP request to a WebSocket connection.
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		origin := r.Header.Get("Origin")
-		allowedOrigin := os.Getenv("FRONTEND_URL")
-		return origin == allowedOrigin
-	},
+	CheckOrigin:     checkOrigin,
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
+// wsCompressionLevel favours CPU over ratio; state and chat JSON compress well even at this level
+const wsCompressionLevel = flate.BestSpeed
+
+// SetWebSocketCompression turns permessage-deflate on or off for new connections.
+// It trades server CPU for bandwidth, which matters most to mobile players.
+func SetWebSocketCompression(enabled bool) {
+	upgrader.EnableCompression = enabled
+}
+
+// configureCompression compresses writes on conn when the client negotiated it.
+// Control frames such as pings are never compressed.
+func configureCompression(conn *websocket.Conn) {
+	if !upgrader.EnableCompression {
+		return
+	}
+	conn.EnableWriteCompression(true)
+	if err := conn.SetCompressionLevel(wsCompressionLevel); err != nil {
+		log.Printf("Failed to set WebSocket compression level: %v", err)
+	}
+}
+
+// allowedOrigins are the page origins that may open a WebSocket. Empty allows any.
+var allowedOrigins []string
+
+// devMode accepts WebSockets from any origin, for local development only
+var devMode = false
+
+// SetAllowedOrigins sets the origins allowed to open WebSockets. Blank entries are dropped.
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = allowedOrigins[:0]
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			allowedOrigins = append(allowedOrigins, origin)
+		}
+	}
+}
+
+// SetDevMode turns the WebSocket origin check off
+func SetDevMode(enabled bool) {
+	devMode = enabled
+}
+
+// checkOrigin guards against cross-site WebSocket hijacking: a page on another site
+// can't open a socket that rides on the user's session cookie.
+func checkOrigin(r *http.Request) bool {
+	if devMode || len(allowedOrigins) == 0 {
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	for _, allowed := range allowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	log.Printf("Rejected WebSocket from origin %q", origin)
+	return false
+}
+
 // ChatMessage is the payload exchanged over WebSockets.
 type ChatMessage struct {
+	ID       int    `json:"chatMessageId,omitempty"`
 	Message  string `json:"message"`
 	Username string `json:"username"`
 	Time     string `json:"time"`
+
+	Reactions map[string]int `json:"reactions,omitempty"` // In history only, live changes come as "reactions"
+	System    bool           `json:"system,omitempty"`    // Posted by the server rather than a user
+
+	// Sent by clients only: Type "typing" reports Typing instead of posting Message,
+	// a non-empty To sends Message privately to that username,
+	// and Type "reaction" toggles Emoji on the message with this ID
+	Type   string `json:"type,omitempty"`
+	Typing bool   `json:"typing,omitempty"`
+	To     string `json:"to,omitempty"`
+	Emoji  string `json:"emoji,omitempty"`
 }
 
 // LobbyMessage wraps different message types for the lobby
 type LobbyMessage struct {
-	Type    string      `json:"type"` // "chat", "player_list", "invitation_received", "invitation_accepted", "invitation_declined"
+	Type    string      `json:"type"` // "chat", "player_list", "invitation_received", "invitation_accepted", "invitation_declined", "invitation_unaccepted", "game_updated", "typing", "rate_limited", "message_rejected", "direct_message", "message_edited", "message_deleted", "reactions", "unread_update", "friend_request", "friend_accepted", "friend_presence"
 	Payload interface{} `json:"payload"`
 }
 
 // PlayerListPayload contains the list of online players
 type PlayerListPayload struct {
-	Players []string `json:"players"`
+	Players  []string                `json:"players"`
+	Statuses map[string]PlayerStatus `json:"statuses"` // By username
 }
 
 // InvitationPayload contains invitation event data
@@ -61,6 +119,12 @@ type InvitationPayload struct {
 	InviteeUsername string `json:"inviteeUsername,omitempty"`
 }
 
+// GameUpdatedPayload tells a lobby client that one of their games changed status
+type GameUpdatedPayload struct {
+	PublicID string `json:"publicId"`
+	Status   string `json:"status"`
+}
+
 var chatRepo database.ChatRepository
 
 func SetChatRepository(repo database.ChatRepository) {
@@ -69,11 +133,13 @@ func SetChatRepository(repo database.ChatRepository) {
 
 // ChatHub coordinates all chat activity.
 type ChatHub struct {
-	clients    map[*websocket.Conn]string // maps connection to userID
+	clients    *concurrentMap[*websocket.Conn, string] // maps connection to userID
 	broadcast  chan ChatMessage
 	register   chan *clientRegistration
 	unregister chan *websocket.Conn
-	mu         sync.RWMutex
+	conns      *connCounter // open connections per user
+
+	presenceChanged chan struct{} // a user entered or left a game room
 }
 
 type clientRegistration struct {
@@ -83,10 +149,13 @@ type clientRegistration struct {
 
 // Hub is the single global instance used by the server.
 var Hub = &ChatHub{
-	clients:    make(map[*websocket.Conn]string),
+	clients:    newConcurrentMap[*websocket.Conn, string](),
 	broadcast:  make(chan ChatMessage),
 	register:   make(chan *clientRegistration),
 	unregister: make(chan *websocket.Conn),
+	conns:      newConnCounter(),
+
+	presenceChanged: make(chan struct{}, 1),
 }
 
 func (h *ChatHub) Run() {
@@ -95,9 +164,8 @@ func (h *ChatHub) Run() {
 	for {
 		select {
 		case reg := <-h.register:
-			h.mu.Lock()
-			h.clients[reg.conn] = reg.userID
-			h.mu.Unlock()
+			h.clients.Set(reg.conn, reg.userID)
+			friendPresence.refresh(reg.userID)
 
 			// Send chat history to the new client from database
 			if chatRepo != nil {
@@ -109,12 +177,15 @@ func (h *ChatHub) Run() {
 						lobbyMsg := LobbyMessage{
 							Type: "chat",
 							Payload: ChatMessage{
-								Message:  msg.MessageText,
-								Username: msg.SenderUsername,
-								Time:     msg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+								ID:        msg.ChatMessageID,
+								Message:   msg.MessageText,
+								Username:  msg.SenderUsername,
+								Time:      msg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+								Reactions: msg.Reactions,
+								System:    msg.System,
 							},
 						}
-						if err := reg.conn.WriteJSON(lobbyMsg); err != nil {
+						if err := sendJSON(reg.conn, lobbyMsg); err != nil {
 							log.Printf("Error sending history: %v", err)
 						}
 					}
@@ -125,87 +196,113 @@ func (h *ChatHub) Run() {
 			h.broadcastPlayerList()
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				client.Close()
+			if userID, ok := h.clients.Delete(client); ok {
+				closeConn(client, websocket.CloseNormalClosure, "")
+				friendPresence.refresh(userID)
 			}
-			h.mu.Unlock()
 
 			// Broadcast updated player list to all clients
 			h.broadcastPlayerList()
 
+		case <-h.presenceChanged:
+			h.broadcastPlayerList()
+
 		case message := <-h.broadcast:
 			// Broadcast chat message to all connected clients
-			h.mu.RLock()
 			lobbyMsg := LobbyMessage{
 				Type:    "chat",
 				Payload: message,
 			}
-			for client := range h.clients {
-				if err := client.WriteJSON(lobbyMsg); err != nil {
+			h.clients.Range(func(client *websocket.Conn, _ string) bool {
+				if err := sendJSON(client, lobbyMsg); err != nil {
 					log.Printf("Error broadcasting: %v", err)
-					client.Close()
-					delete(h.clients, client)
+					closeConn(client, websocket.CloseInternalServerErr, "send failed")
+					h.clients.Delete(client)
 				}
-			}
-			h.mu.RUnlock()
+				return true
+			})
 		}
 	}
 }
 
-// SendNotificationToUser sends a notification to a specific user by their userID
-func (h *ChatHub) SendNotificationToUser(userID string, message LobbyMessage) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// sendToAll sends a message to every lobby connection
+func (h *ChatHub) sendToAll(message LobbyMessage) {
+	h.clients.Range(func(client *websocket.Conn, _ string) bool {
+		sendJSON(client, message)
+		return true
+	})
+}
 
-	for client, clientUserID := range h.clients {
-		if clientUserID == userID {
-			if err := client.WriteJSON(message); err != nil {
-				log.Printf("Error sending notification to user %s: %v", userID, err)
-			}
-		}
+// notifyPresenceChanged asks Run to resend the player list. Changes that arrive while
+// one is pending are folded into it.
+func (h *ChatHub) notifyPresenceChanged() {
+	select {
+	case h.presenceChanged <- struct{}{}:
+	default:
 	}
 }
 
+// SendNotificationToUser sends a notification to every lobby connection a user has open,
+// so each of their tabs sees it. It does nothing if the user isn't connected, and skips
+// connections that closed since they were registered.
+func (h *ChatHub) SendNotificationToUser(userID string, message LobbyMessage) {
+	h.clients.Range(func(client *websocket.Conn, clientUserID string) bool {
+		if clientUserID != userID {
+			return true
+		}
+		if err := sendJSON(client, message); err != nil && !errors.Is(err, errConnNotWritable) {
+			log.Printf("Error sending notification to user %s: %v", userID, err)
+		}
+		return true
+	})
+}
+
 // broadcastPlayerList sends the current list of online players to all connected clients
 func (h *ChatHub) broadcastPlayerList() {
 	ctx := context.Background()
 
-	h.mu.RLock()
-	userIDs := make([]string, 0, len(h.clients))
-	for _, userID := range h.clients {
+	userIDs := make([]string, 0, h.clients.Len())
+	h.clients.Range(func(_ *websocket.Conn, userID string) bool {
 		userIDs = append(userIDs, userID)
+		return true
+	})
+	if len(userIDs) == 0 {
+		return // nobody to tell
+	}
+
+	// One batched lookup; the user service caches users by ID, so reconnects are cheap
+	users, err := userService.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		log.Printf("Error getting users: %v", err)
 	}
-	h.mu.RUnlock()
 
-	// Get usernames for all connected users
 	usernames := make([]string, 0, len(userIDs))
+	statuses := make(map[string]PlayerStatus, len(userIDs))
 	for _, userID := range userIDs {
-		user, err := userService.GetUserByID(ctx, userID)
-		if err != nil {
-			log.Printf("Error getting user: %v", err)
+		user, ok := users[userID]
+		if !ok {
 			continue
 		}
 		usernames = append(usernames, user.Username)
+		statuses[user.Username] = presence.statusOf(userID)
 	}
 
 	// Create player list message
 	lobbyMsg := LobbyMessage{
 		Type: "player_list",
 		Payload: PlayerListPayload{
-			Players: usernames,
+			Players:  usernames,
+			Statuses: statuses,
 		},
 	}
 
 	// Broadcast to all clients
-	h.mu.RLock()
-	for client := range h.clients {
-		if err := client.WriteJSON(lobbyMsg); err != nil {
+	h.clients.Range(func(client *websocket.Conn, _ string) bool {
+		if err := sendJSON(client, lobbyMsg); err != nil {
 			log.Printf("Error broadcasting player list: %v", err)
 		}
-	}
-	h.mu.RUnlock()
+		return true
+	})
 }
 
 func ChatHandler(w http.ResponseWriter, r *http.Request) {
@@ -231,6 +328,17 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
+	configureCompression(conn)
+
+	if !Hub.conns.acquire(userID) {
+		rejectTooManyConnections(conn, userID)
+		return
+	}
+	defer Hub.conns.release(userID)
+
+	// From here on every write goes through the pump
+	stopWritePump := startWritePump(conn)
+	defer stopWritePump()
 
 	Hub.register <- &clientRegistration{
 		conn:   conn,
@@ -242,33 +350,16 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// Configure connection for heartbeat
-	conn.SetReadLimit(maxMessageSize)
-	conn.SetReadDeadline(time.Now().Add(pongWait))
-	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
+	stopHeartbeat := startHeartbeat(conn)
+	defer stopHeartbeat()
 
-	// Start ping ticker
-	ticker := time.NewTicker(pingPeriod)
-	defer ticker.Stop()
-
-	done := make(chan struct{})
-	defer close(done)
-
-	// Start goroutine to send pings
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					return
-				}
-			}
-		}
-	}()
+	typingID := typingKey("global", userID)
+	announceTyping := func(isTyping bool) {
+		Hub.sendTyping(userID, user.Username, isTyping)
+	}
+	defer typing.clear(typingID, announceTyping)
+
+	limiter := newChatLimiter()
 
 	for {
 		var msg ChatMessage
@@ -281,15 +372,62 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		// Validate message length (max 500 characters)
-		if len(msg.Message) > 500 {
-			log.Printf("Message too long from user %s: %d characters", userID, len(msg.Message))
+		if msg.Type == "typing" {
+			typing.update(typingID, msg.Typing, announceTyping)
+			continue
+		}
+
+		if msg.Type == "reaction" {
+			if chatRepo == nil {
+				continue
+			}
+			if ok, retryAfter := limiter.allow(); !ok {
+				sendJSON(conn, rateLimitedLobbyMessage(retryAfter))
+				continue
+			}
+			// The lobby can react to global messages and the user's own private ones
+			canSee := func(scope string) bool {
+				userA, userB, isDM := database.DMParticipants(scope)
+				return scope == "global" || (isDM && (userID == userA || userID == userB))
+			}
+			err := toggleReaction(ctx, userID, ReactionRequest{ChatMessageID: msg.ID, Emoji: msg.Emoji}, canSee)
+			if isReactionRejection(err) {
+				sendJSON(conn, messageRejectedLobbyMessage(err))
+			} else if err != nil {
+				log.Printf("Error toggling reaction: %v", err)
+			}
+			continue
+		}
+
+		text, err := validateChatMessage(msg.Message)
+		if err != nil {
+			sendJSON(conn, messageRejectedLobbyMessage(err))
+			continue
+		}
+		typing.clear(typingID, announceTyping)
+
+		if ok, retryAfter := limiter.allow(); !ok {
+			sendJSON(conn, rateLimitedLobbyMessage(retryAfter))
+			continue
+		}
+
+		if msg.To != "" {
+			if chatRepo == nil {
+				continue
+			}
+			if err := sendDirectMessage(ctx, user, msg.To, text); err != nil {
+				if err == errRecipientNotFound || err == errMessageToSelf {
+					sendJSON(conn, messageRejectedLobbyMessage(err))
+				} else {
+					log.Printf("Error sending direct message: %v", err)
+				}
+			}
 			continue
 		}
 
 		// Save message to database
 		if chatRepo != nil {
-			savedMsg, err := chatRepo.SaveMessage(ctx, userID, "global", msg.Message)
+			savedMsg, err := chatRepo.SaveMessage(ctx, userID, "global", text)
 			if err != nil {
 				log.Printf("Error saving message: %v", err)
 				continue
@@ -297,6 +435,7 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 
 			// Broadcast with username and saved timestamp
 			broadcastMsg := ChatMessage{
+				ID:       savedMsg.ChatMessageID,
 				Message:  savedMsg.MessageText,
 				Username: user.Username,
 				Time:     savedMsg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
@@ -306,22 +445,105 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorizeChatScope checks userID may read scope: anyone can read "global", active
+// players their game's chat, and the two participants their private messages.
+// It writes the error response itself when not.
+func authorizeChatScope(w http.ResponseWriter, r *http.Request, userID, scope string) bool {
+	ctx := r.Context()
+
+	if strings.HasPrefix(scope, "dm:") {
+		userA, userB, ok := database.DMParticipants(scope)
+		if !ok {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid scope"})
+			return false
+		}
+		if userID != userA && userID != userB {
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "You are not part of this conversation"})
+			return false
+		}
+	} else if scope != "global" {
+		publicID, found := strings.CutPrefix(scope, "game:")
+		if !found || publicID == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid scope"})
+			return false
+		}
+
+		inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
+		if err != nil {
+			log.Printf("Error validating user in game: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+			return false
+		}
+		if !inGame {
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "You are not a player in this game"})
+			return false
+		}
+	}
+
+	return true
+}
+
 // GetChatHistoryHandler returns chat history from database as JSON.
+// Accepts ?scope=global (default), ?scope=game:<publicId> or ?scope=dm:<userA>:<userB>,
+// plus an optional ?limit= (max 100) and ?before=<chatMessageId> to page back past the
+// latest messages. Game history is only available to active players
+// in that game, and direct messages only to their two participants.
 func GetChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
 	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if chatRepo == nil || gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = "global"
+	}
 
-	if chatRepo == nil {
-		http.Error(w, "Chat repository not initialized", http.StatusInternalServerError)
+	if !authorizeChatScope(w, r, userID, scope) {
 		return
 	}
 
-	messages, err := chatRepo.GetMessagesByScope(ctx, "global", 50)
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 100)
+	}
+
+	var messages []*database.ChatMessage
+	var err error
+	if beforeParam := r.URL.Query().Get("before"); beforeParam != "" {
+		beforeID, parseErr := strconv.Atoi(beforeParam)
+		if parseErr != nil || beforeID < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid before"})
+			return
+		}
+		messages, err = chatRepo.GetMessagesBefore(ctx, scope, beforeID, limit)
+	} else {
+		messages, err = chatRepo.GetMessagesByScope(ctx, scope, limit)
+	}
 	if err != nil {
 		log.Printf("Error fetching chat history: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get chat history"})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"messages": messages,
+	})
 }
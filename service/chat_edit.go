@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/database"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// MessageEditedPayload tells clients a chat message's text changed
+type MessageEditedPayload struct {
+	ChatMessageID int    `json:"chatMessageId"`
+	Message       string `json:"message"`
+}
+
+// MessageDeletedPayload tells clients a chat message was deleted
+type MessageDeletedPayload struct {
+	ChatMessageID int `json:"chatMessageId"`
+}
+
+// EditChatMessageHandler changes the text of one of the caller's chat messages
+func EditChatMessageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ChatMessageID int    `json:"chatMessageId"`
+		Message       string `json:"message"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if chatRepo == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	text, err := validateChatMessage(req.Message)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := chatRepo.UpdateMessage(ctx, req.ChatMessageID, userID, text); err != nil {
+		writeChatChangeError(w, err, "edit")
+		return
+	}
+
+	sendToChatScope(ctx, req.ChatMessageID, "message_edited", MessageEditedPayload{
+		ChatMessageID: req.ChatMessageID,
+		Message:       text,
+	})
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Message edited"})
+}
+
+// DeleteChatMessageHandler deletes one of the caller's chat messages
+func DeleteChatMessageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ChatMessageID int `json:"chatMessageId"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if chatRepo == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	if err := chatRepo.DeleteMessage(ctx, req.ChatMessageID, userID); err != nil {
+		writeChatChangeError(w, err, "delete")
+		return
+	}
+
+	sendToChatScope(ctx, req.ChatMessageID, "message_deleted", MessageDeletedPayload{
+		ChatMessageID: req.ChatMessageID,
+	})
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Message deleted"})
+}
+
+// writeChatChangeError maps an edit or delete failure to a response
+func writeChatChangeError(w http.ResponseWriter, err error, action string) {
+	switch err {
+	case database.ErrMessageNotFound:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Message not found"})
+	case database.ErrNotMessageSender:
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "You can only " + action + " your own messages"})
+	default:
+		log.Printf("Error trying to %s chat message: %v", action, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to " + action + " message"})
+	}
+}
+
+// sendToChatScope tells everyone who can see a message's chat about a change to it:
+// the whole lobby, the game's room, or the two users of a private conversation
+func sendToChatScope(ctx context.Context, messageID int, msgType string, payload interface{}) {
+	scope, err := chatRepo.GetMessageScope(ctx, messageID)
+	if err != nil {
+		log.Printf("Error looking up scope of chat message %d: %v", messageID, err)
+		return
+	}
+	sendToScope(scope, msgType, payload)
+}
+
+// sendToScope sends a message to everyone who can see a chat scope
+func sendToScope(scope string, msgType string, payload interface{}) {
+	if publicID, found := strings.CutPrefix(scope, "game:"); found {
+		room := GameHubInstance.GetRoom(publicID)
+		if room == nil {
+			return
+		}
+		data, _ := json.Marshal(payload)
+		select {
+		case room.broadcast <- GameMessage{Type: msgType, Payload: data}:
+		case <-room.ctx.Done():
+		}
+		return
+	}
+
+	msg := LobbyMessage{Type: msgType, Payload: payload}
+	if userA, userB, ok := database.DMParticipants(scope); ok {
+		Hub.SendNotificationToUser(userA, msg)
+		Hub.SendNotificationToUser(userB, msg)
+		return
+	}
+	Hub.sendToAll(msg)
+}
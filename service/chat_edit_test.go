@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// changeChatMessage posts body to an edit or delete handler as userID and returns the status
+func changeChatMessage(t *testing.T, handler http.HandlerFunc, userID string, body any) int {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/chat/edit", strings.NewReader(string(data)))
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code
+}
+
+func TestOnlyTheSenderCanEditOrDeleteAMessage(t *testing.T) {
+	ctx := context.Background()
+	repo := useFakeChat(t, 0)
+	msg, err := repo.SaveMessage(ctx, "alice", "global", "good gmae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, lobby := newTestConn(t)
+	connectLobby(t, server, "carol")
+
+	edit := map[string]any{"chatMessageId": msg.ChatMessageID, "message": "bob was here"}
+	if status := changeChatMessage(t, EditChatMessageHandler, "bob", edit); status != http.StatusForbidden {
+		t.Errorf("bob editing alice's message: status %d, want 403", status)
+	}
+	remove := map[string]any{"chatMessageId": msg.ChatMessageID}
+	if status := changeChatMessage(t, DeleteChatMessageHandler, "bob", remove); status != http.StatusForbidden {
+		t.Errorf("bob deleting alice's message: status %d, want 403", status)
+	}
+	repo.mu.Lock()
+	if saved := repo.savedMessage(msg.ChatMessageID); saved.MessageText != "good gmae" || saved.Deleted {
+		t.Errorf("bob changed alice's message to %+v", saved)
+	}
+	repo.mu.Unlock()
+
+	if status := changeChatMessage(t, DeleteChatMessageHandler, "alice", remove); status != http.StatusOK {
+		t.Fatalf("alice deleting her message: status %d", status)
+	}
+	var deleted MessageDeletedPayload
+	if err := json.Unmarshal(readMessageOfType(t, lobby, "message_deleted"), &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ChatMessageID != msg.ChatMessageID {
+		t.Errorf("lobby told message %d was deleted, want %d", deleted.ChatMessageID, msg.ChatMessageID)
+	}
+
+	// Once deleted it's gone, even for its sender
+	if status := changeChatMessage(t, EditChatMessageHandler, "alice", edit); status != http.StatusNotFound {
+		t.Errorf("editing a deleted message: status %d, want 404", status)
+	}
+}
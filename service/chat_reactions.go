@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+)
+
+// allowedReactions are the emojis players can react to chat messages with
+var allowedReactions = map[string]bool{
+	"👍":  true,
+	"👎":  true,
+	"😂":  true,
+	"❤️": true,
+	"😮":  true,
+	"😢":  true,
+	"🎉":  true,
+	"⛳":  true,
+}
+
+var (
+	errReactionNotAllowed = errors.New("that reaction is not allowed")
+	errTooManyReactions   = errors.New("this message has too many kinds of reactions")
+)
+
+// ReactionRequest is sent by a client to toggle its reaction on a message
+type ReactionRequest struct {
+	ChatMessageID int    `json:"chatMessageId"`
+	Emoji         string `json:"emoji"`
+}
+
+// ReactionsPayload carries a message's current reaction counts
+type ReactionsPayload struct {
+	ChatMessageID int            `json:"chatMessageId"`
+	Reactions     map[string]int `json:"reactions"`
+}
+
+// toggleReaction flips userID's reaction on a message and sends the new counts to
+// everyone who can see it. canSee reports whether the connection the reaction came in
+// on belongs to the message's scope, so users can only react where they can read.
+func toggleReaction(ctx context.Context, userID string, req ReactionRequest, canSee func(scope string) bool) error {
+	if !allowedReactions[req.Emoji] {
+		return errReactionNotAllowed
+	}
+
+	scope, err := chatRepo.GetMessageScope(ctx, req.ChatMessageID)
+	if err != nil {
+		return err
+	}
+	if !canSee(scope) {
+		return database.ErrMessageNotFound
+	}
+
+	if _, err := chatRepo.ToggleReaction(ctx, req.ChatMessageID, userID, req.Emoji); err != nil {
+		if err == database.ErrTooManyReactions {
+			return errTooManyReactions
+		}
+		return err
+	}
+
+	counts, err := chatRepo.GetReactionCounts(ctx, req.ChatMessageID)
+	if err != nil {
+		return err
+	}
+	sendToScope(scope, "reactions", ReactionsPayload{
+		ChatMessageID: req.ChatMessageID,
+		Reactions:     counts,
+	})
+	return nil
+}
+
+// isReactionRejection reports whether err should be reported back to the client
+// rather than logged
+func isReactionRejection(err error) bool {
+	return err == errReactionNotAllowed || err == errTooManyReactions || err == database.ErrMessageNotFound
+}
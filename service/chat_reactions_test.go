@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// react toggles userID's emoji on a message anyone can see
+func react(userID string, messageID int, emoji string) error {
+	everyone := func(string) bool { return true }
+	return toggleReaction(context.Background(), userID, ReactionRequest{ChatMessageID: messageID, Emoji: emoji}, everyone)
+}
+
+// readReactions reads the next reaction counts sent to client
+func readReactions(t *testing.T, client *websocket.Conn) ReactionsPayload {
+	t.Helper()
+	var payload ReactionsPayload
+	if err := json.Unmarshal(readMessageOfType(t, client, "reactions"), &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestReactionsToggleAndAreCapped(t *testing.T) {
+	repo := useFakeChat(t, 0)
+	msg, err := repo.SaveMessage(context.Background(), "alice", "global", "nice round")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, lobby := newTestConn(t)
+	connectLobby(t, server, "carol")
+
+	for _, step := range []struct {
+		userID string
+		want   map[string]int
+	}{
+		{"alice", map[string]int{"👍": 1}},
+		{"bob", map[string]int{"👍": 2}},
+		{"alice", map[string]int{"👍": 1}},
+		{"bob", map[string]int{}},
+	} {
+		if err := react(step.userID, msg.ChatMessageID, "👍"); err != nil {
+			t.Fatal(err)
+		}
+		got := readReactions(t, lobby)
+		if got.ChatMessageID != msg.ChatMessageID || !maps.Equal(got.Reactions, step.want) {
+			t.Fatalf("after %s toggled 👍: %+v, want %v", step.userID, got, step.want)
+		}
+	}
+
+	if err := react("alice", msg.ChatMessageID, "🍌"); !errors.Is(err, errReactionNotAllowed) {
+		t.Errorf("reacting with 🍌: err = %v, want errReactionNotAllowed", err)
+	}
+
+	// Six kinds fill the message; more of an existing kind still fit
+	for _, emoji := range []string{"👍", "👎", "😂", "😮", "😢", "🎉"} {
+		if err := react("alice", msg.ChatMessageID, emoji); err != nil {
+			t.Fatalf("%s: %v", emoji, err)
+		}
+	}
+	if err := react("bob", msg.ChatMessageID, "⛳"); !errors.Is(err, errTooManyReactions) {
+		t.Errorf("seventh kind: err = %v, want errTooManyReactions", err)
+	}
+	if err := react("bob", msg.ChatMessageID, "🎉"); err != nil {
+		t.Errorf("another 🎉: %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golf-card-game/business"
+	"golf-card-game/database"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// useFakeChat serves chat history with n global messages for one test
+func useFakeChat(t *testing.T, n int) *fakeChatRepo {
+	t.Helper()
+
+	prevChat, prevService := chatRepo, gameService
+	t.Cleanup(func() { chatRepo, gameService = prevChat, prevService })
+
+	repo := &fakeChatRepo{n: n}
+	chatRepo = repo
+	gameService = business.NewGameService(newFakeGameRepo(), nil)
+	return repo
+}
+
+// getChatHistory calls the history handler as alice and returns the status and messages
+func getChatHistory(t *testing.T, query string) (int, []*database.ChatMessage) {
+	t.Helper()
+	return getChatHistoryAs(t, "alice", query)
+}
+
+// getChatHistoryAs calls the history handler as userID
+func getChatHistoryAs(t *testing.T, userID, query string) (int, []*database.ChatMessage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chat/history?"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+	rec := httptest.NewRecorder()
+	GetChatHistoryHandler(rec, req)
+
+	var body struct {
+		Messages []*database.ChatMessage `json:"messages"`
+	}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, body.Messages
+}
+
+func TestChatHistoryPagesBackWithoutGaps(t *testing.T) {
+	useFakeChat(t, 230)
+
+	status, messages := getChatHistory(t, "")
+	if status != http.StatusOK || len(messages) != 50 || messages[0].ChatMessageID != 181 || messages[49].ChatMessageID != 230 {
+		t.Fatalf("first page: status %d with %d messages, want the latest 50 oldest first", status, len(messages))
+	}
+
+	for before := messages[0].ChatMessageID; ; {
+		status, page := getChatHistory(t, fmt.Sprintf("limit=40&before=%d", before))
+		if status != http.StatusOK {
+			t.Fatalf("before=%d: status %d", before, status)
+		}
+		if len(page) == 0 {
+			break
+		}
+		messages = append(page, messages...)
+		before = page[0].ChatMessageID
+	}
+
+	if len(messages) != 230 {
+		t.Fatalf("paged back through %d messages, want 230", len(messages))
+	}
+	for i, msg := range messages {
+		if msg.ChatMessageID != i+1 {
+			t.Fatalf("message %d has ID %d", i, msg.ChatMessageID)
+		}
+	}
+}
+
+func TestChatHistoryLimits(t *testing.T) {
+	repo := useFakeChat(t, 500)
+
+	if status, messages := getChatHistory(t, "limit=1000"); status != http.StatusOK || repo.lastLimit != 100 || len(messages) != 100 {
+		t.Fatalf("limit=1000: status %d, asked for %d, got %d messages, want 100", status, repo.lastLimit, len(messages))
+	}
+	for _, query := range []string{"limit=0", "limit=ten", "before=0", "before=x", "scope=game:"} {
+		if status, _ := getChatHistory(t, query); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, status)
+		}
+	}
+	if status, _ := getChatHistory(t, "scope=game:someone-elses"); status != http.StatusForbidden {
+		t.Errorf("another game's chat: status %d, want 403", status)
+	}
+}
+
+func TestGameChatHistoryIsForPlayersOnly(t *testing.T) {
+	useFakeChat(t, 120)
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", business.GameRules{}, "alice", "bob")
+	gameService = business.NewGameService(repo, nil)
+
+	for _, userID := range []string{"alice", "bob"} {
+		status, messages := getChatHistoryAs(t, userID, "scope=game:game")
+		if status != http.StatusOK || len(messages) != 50 || messages[0].Scope != "game:game" {
+			t.Fatalf("%s: status %d with %d messages", userID, status, len(messages))
+		}
+
+		// Paged the same way as global chat
+		status, page := getChatHistoryAs(t, userID, fmt.Sprintf("scope=game:game&limit=20&before=%d", messages[0].ChatMessageID))
+		if status != http.StatusOK || len(page) != 20 || page[19].ChatMessageID != messages[0].ChatMessageID-1 {
+			t.Fatalf("%s: earlier page has status %d with %d messages", userID, status, len(page))
+		}
+	}
+
+	if status, _ := getChatHistoryAs(t, "carol", "scope=game:game"); status != http.StatusForbidden {
+		t.Fatalf("carol: status %d, want 403", status)
+	}
+}
+
+func TestChatScopeAccess(t *testing.T) {
+	useFakeChat(t, 10)
+	repo := newFakeGameRepo()
+	repo.addGame("game", "in_progress", business.GameRules{}, "alice", "bob")
+	gameService = business.NewGameService(repo, nil)
+
+	for _, tc := range []struct {
+		userID, scope string
+		want          int
+	}{
+		{"carol", "global", http.StatusOK},
+		{"alice", "game:game", http.StatusOK},
+		{"carol", "game:game", http.StatusForbidden},
+		{"alice", database.DMScope("alice", "bob"), http.StatusOK},
+		{"bob", database.DMScope("alice", "bob"), http.StatusOK},
+		{"carol", database.DMScope("alice", "bob"), http.StatusForbidden},
+		{"alice", "dm:bob:alice", http.StatusBadRequest},
+		{"alice", "dm:alice", http.StatusBadRequest},
+		{"alice", "lobby", http.StatusBadRequest},
+	} {
+		if status, _ := getChatHistoryAs(t, tc.userID, "scope="+tc.scope); status != tc.want {
+			t.Errorf("%s reading %s: status %d, want %d", tc.userID, tc.scope, status, tc.want)
+		}
+	}
+}
+
+// newTestHub returns a hub that isn't running with one lobby connection per user.
+// The clients discard what they're sent so the write pumps never back up.
+func newTestHub(tb testing.TB, userIDs ...string) *ChatHub {
+	tb.Helper()
+
+	h := &ChatHub{
+		clients: newConcurrentMap[*websocket.Conn, string](),
+	}
+	for _, userID := range userIDs {
+		server, client := newTestConn(tb)
+		go func() {
+			for {
+				if _, _, err := client.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		h.clients.Set(server, userID)
+	}
+	return h
+}
+
+// useLobbyUsers swaps in a user service backed by a counting fake repo for one test
+func useLobbyUsers(tb testing.TB, userIDs ...string) *fakeUserRepo {
+	tb.Helper()
+
+	prevUserService := userService
+	tb.Cleanup(func() { userService = prevUserService })
+
+	repo := newFakeUserRepo(userIDs...)
+	userService = business.NewUserService(repo)
+	return repo
+}
+
+func TestPlayerListReadsUsernamesThroughTheUserCache(t *testing.T) {
+	users := useLobbyUsers(t, "alice", "bob", "carol")
+	h := newTestHub(t, "alice", "bob", "carol")
+
+	h.broadcastPlayerList()
+	if got := users.lookupCount(); got != 1 {
+		t.Fatalf("first broadcast made %d lookups, want 1 batched lookup", got)
+	}
+
+	h.broadcastPlayerList()
+	if got := users.lookupCount(); got != 1 {
+		t.Fatalf("second broadcast made %d more lookups, want the cached usernames", got-1)
+	}
+
+	// Logging out drops bob from the user service's cache, so he is read again
+	userService.ForgetUser("bob")
+	users.mu.Lock()
+	users.users["bob"].Username = "robert"
+	users.mu.Unlock()
+
+	h.broadcastPlayerList()
+	if got := users.lookupCount(); got != 2 {
+		t.Fatalf("broadcast after logout made %d more lookups, want 1", got-1)
+	}
+	found, err := userService.GetUsersByIDs(context.Background(), []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found["bob"].Username != "robert" || found["alice"].Username != "alice" {
+		t.Errorf("usernames = %q and %q, want the fresh robert and alice kept", found["bob"].Username, found["alice"].Username)
+	}
+	if got := users.lookupCount(); got != 2 {
+		t.Errorf("reading the names back made %d more lookups, want them cached", got-2)
+	}
+}
+
+// broadcastPlayerListPerUser is how the player list was built before lookups were
+// batched: one user read per connected client
+func (h *ChatHub) broadcastPlayerListPerUser() {
+	ctx := context.Background()
+
+	userIDs := make([]string, 0, h.clients.Len())
+	h.clients.Range(func(_ *websocket.Conn, userID string) bool {
+		userIDs = append(userIDs, userID)
+		return true
+	})
+
+	usernames := make([]string, 0, len(userIDs))
+	statuses := make(map[string]PlayerStatus, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := userService.GetUserByID(ctx, userID)
+		if err != nil {
+			continue
+		}
+		usernames = append(usernames, user.Username)
+		statuses[user.Username] = presence.statusOf(userID)
+	}
+
+	lobbyMsg := LobbyMessage{
+		Type:    "player_list",
+		Payload: PlayerListPayload{Players: usernames, Statuses: statuses},
+	}
+	h.clients.Range(func(client *websocket.Conn, _ string) bool {
+		sendJSON(client, lobbyMsg)
+		return true
+	})
+}
+
+// BenchmarkBroadcastPlayerList compares a player list broadcast to 50 lobby clients
+// with cold caches, as after a restart, and with warm ones, as on a reconnect
+func BenchmarkBroadcastPlayerList(b *testing.B) {
+	userIDs := make([]string, 50)
+	for i := range userIDs {
+		userIDs[i] = fmt.Sprintf("user-%02d", i)
+	}
+
+	forgetAll := func() {
+		for _, userID := range userIDs {
+			userService.ForgetUser(userID)
+		}
+	}
+
+	cases := []struct {
+		name      string
+		broadcast func(h *ChatHub)
+		cold      bool
+	}{
+		{"per-user/cold", (*ChatHub).broadcastPlayerListPerUser, true},
+		{"batched/cold", (*ChatHub).broadcastPlayerList, true},
+		{"per-user/warm", (*ChatHub).broadcastPlayerListPerUser, false},
+		{"batched/warm", (*ChatHub).broadcastPlayerList, false},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			users := useLobbyUsers(b, userIDs...)
+			h := newTestHub(b, userIDs...)
+			tc.broadcast(h)
+			before := users.lookupCount()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if tc.cold {
+					b.StopTimer()
+					forgetAll()
+					b.StartTimer()
+				}
+				tc.broadcast(h)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(users.lookupCount()-before)/float64(b.N), "lookups/op")
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	prevOrigins, prevDevMode := slices.Clone(allowedOrigins), devMode
+	t.Cleanup(func() { allowedOrigins, devMode = prevOrigins, prevDevMode })
+
+	for _, tc := range []struct {
+		name    string
+		allowed []string
+		devMode bool
+		origin  string
+		want    bool
+	}{
+		{"allowed origin", []string{"https://golf.example", "https://www.golf.example/"}, false, "https://www.golf.example", true},
+		{"other origin", []string{"https://golf.example"}, false, "https://evil.example", false},
+		{"no origin header", []string{"https://golf.example"}, false, "", false},
+		{"dev mode", []string{"https://golf.example"}, true, "https://evil.example", true},
+		{"no allowed origins", []string{" ", ""}, false, "https://evil.example", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			SetAllowedOrigins(tc.allowed)
+			SetDevMode(tc.devMode)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/ws/chat", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if got := checkOrigin(req); got != tc.want {
+				t.Errorf("checkOrigin = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// lobbyRunning starts the shared lobby hub the first time a test needs it
+var lobbyRunning sync.Once
+
+// dialLobby connects userID to the lobby socket with dialer and waits until the hub has
+// registered them. Before the test's other cleanups run, the connection is closed, the
+// hub has let userID go and their friends have been told.
+func dialLobby(t *testing.T, userID string, dialer *websocket.Dialer) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	lobbyRunning.Do(func() { go Hub.Run() })
+
+	var handlers sync.WaitGroup
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		ChatHandler(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
+	}))
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws/chat", nil)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("dial as %s: %v", userID, err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Close()
+		handlers.Wait()
+		waitForPresenceSent(t, userID)
+	})
+
+	// The hub sends the player list to a connection once it is registered
+	readMessageOfType(t, conn, "player_list")
+	return conn, resp
+}
+
+// sendChat posts a lobby chat message over conn
+func sendChat(t *testing.T, conn *websocket.Conn, msg ChatMessage) {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedLobbyChatIsEchoed(t *testing.T) {
+	prevCompression := upgrader.EnableCompression
+	t.Cleanup(func() { SetWebSocketCompression(prevCompression) })
+	SetWebSocketCompression(true)
+	useLobbyUsers(t, "alice")
+	useFakeChat(t, 0)
+
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	conn, resp := dialLobby(t, "alice", &dialer)
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("negotiated extensions %q, want permessage-deflate", ext)
+	}
+
+	text := strings.Repeat("fore! ", 50)
+	sendChat(t, conn, ChatMessage{Message: text})
+	var echo ChatMessage
+	if err := json.Unmarshal(readMessageOfType(t, conn, "chat"), &echo); err != nil {
+		t.Fatal(err)
+	}
+	if echo.Message != strings.TrimSpace(text) || echo.Username != "alice" {
+		t.Fatalf("echo = %q from %q, want alice's message back", echo.Message, echo.Username)
+	}
+}
+
+func TestNotificationReachesEveryTabOfTheUser(t *testing.T) {
+	firstServer, firstTab := newTestConn(t)
+	secondServer, secondTab := newTestConn(t)
+	connectLobby(t, firstServer, "bob")
+	connectLobby(t, secondServer, "bob")
+	// A tab whose write pump has already stopped is skipped
+	connectLobby(t, &websocket.Conn{}, "bob")
+
+	// Nobody is connected as carol, so this goes nowhere
+	Hub.SendNotificationToUser("carol", LobbyMessage{Type: "invitation_received"})
+
+	invite := InvitationPayload{GameID: 7, PublicID: "game", InviterUsername: "alice"}
+	Hub.SendNotificationToUser("bob", LobbyMessage{Type: "invitation_received", Payload: invite})
+	for i, tab := range []*websocket.Conn{firstTab, secondTab} {
+		var got InvitationPayload
+		if err := json.Unmarshal(readMessageOfType(t, tab, "invitation_received"), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != invite {
+			t.Errorf("tab %d got %+v, want %+v", i+1, got, invite)
+		}
+	}
+}
+
+func TestTypingReachesOthersButNotTheTypist(t *testing.T) {
+	useLobbyUsers(t, "alice", "bob")
+	useFakeChat(t, 0)
+	alice, _ := dialLobby(t, "alice", websocket.DefaultDialer)
+	bob, _ := dialLobby(t, "bob", websocket.DefaultDialer)
+
+	sendChat(t, alice, ChatMessage{Type: "typing", Typing: true})
+	var got TypingPayload
+	if err := json.Unmarshal(readMessageOfType(t, bob, "typing"), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (TypingPayload{Username: "alice", Typing: true}) {
+		t.Fatalf("bob got %+v, want alice typing", got)
+	}
+
+	// Everything alice is sent before her own message comes back would include the typing event
+	sendChat(t, alice, ChatMessage{Message: "hello"})
+	alice.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg LobbyMessage
+		if err := alice.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == "typing" {
+			t.Fatalf("alice was told about her own typing: %+v", msg.Payload)
+		}
+		if msg.Type == "chat" {
+			break
+		}
+	}
+}
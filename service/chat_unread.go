@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// UnreadUpdatePayload tells a lobby client how many messages it has missed in a scope
+type UnreadUpdatePayload struct {
+	Scope  string `json:"scope"`
+	Unread int    `json:"unread"`
+}
+
+// GetUnreadCountsHandler returns the caller's unread message counts by scope
+func GetUnreadCountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if chatRepo == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	counts, err := chatRepo.GetUnreadCounts(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting unread counts: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get unread counts"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"unread": counts,
+	})
+}
+
+// MarkChatReadHandler records that the caller has read a scope up to a message
+func MarkChatReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Scope  string `json:"scope"`
+		UpToID int    `json:"upToId"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if chatRepo == nil || gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	if req.UpToID < 1 {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid message ID"})
+		return
+	}
+	if !authorizeChatScope(w, r, userID, req.Scope) {
+		return
+	}
+
+	if err := chatRepo.MarkScopeRead(ctx, userID, req.Scope, req.UpToID); err != nil {
+		log.Printf("Error marking chat read: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to mark chat read"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Marked read"})
+}
+
+// notifyUnreadInGame pushes new unread counts to a game's players who aren't in its
+// room to see the message arrive
+func notifyUnreadInGame(ctx context.Context, room *GameRoom, senderUserID string) {
+	playerIDs, err := gameRepo.GetActivePlayerIDs(ctx, room.publicID)
+	if err != nil {
+		log.Printf("Error getting players for unread update in game %s: %v", room.publicID, err)
+		return
+	}
+
+	scope := "game:" + room.publicID
+	for _, playerID := range playerIDs {
+		if playerID == senderUserID || room.isConnected(playerID) {
+			continue
+		}
+		counts, err := chatRepo.GetUnreadCounts(ctx, playerID)
+		if err != nil {
+			log.Printf("Error getting unread counts for user %s: %v", playerID, err)
+			continue
+		}
+		Hub.SendNotificationToUser(playerID, LobbyMessage{
+			Type:    "unread_update",
+			Payload: UnreadUpdatePayload{Scope: scope, Unread: counts[scope]},
+		})
+	}
+}
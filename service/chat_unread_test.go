@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"testing"
+)
+
+func TestPlayersAwayFromTheRoomHearAboutUnreadMessages(t *testing.T) {
+	chat := useFakeChat(t, 0)
+	chat.unread = map[string]map[string]int{"bob": {"game:game": 4, "game:other": 1}}
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("game")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	server, bob := newTestConn(t)
+	connectLobby(t, server, "bob")
+
+	notifyUnreadInGame(context.Background(), room, "alice")
+
+	var update UnreadUpdatePayload
+	if err := json.Unmarshal(readMessageOfType(t, bob, "unread_update"), &update); err != nil {
+		t.Fatal(err)
+	}
+	if update != (UnreadUpdatePayload{Scope: "game:game", Unread: 4}) {
+		t.Fatalf("bob got %+v, want 4 unread in the game", update)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"golf-card-game/business"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxChatMessageLength caps chat messages, in characters
+var maxChatMessageLength = 1000
+
+// SetMaxChatMessageLength overrides the chat message length cap. Values below 1 are ignored.
+func SetMaxChatMessageLength(n int) {
+	if n >= 1 {
+		maxChatMessageLength = n
+	}
+}
+
+// chatFilter masks blocked words in chat. Nil when filtering is disabled.
+var chatFilter *business.ChatFilter
+
+// SetChatFilter enables word filtering for chat. Pass nil to disable it.
+func SetChatFilter(f *business.ChatFilter) {
+	chatFilter = f
+}
+
+var (
+	errChatMessageEmpty   = errors.New("message is empty")
+	errChatMessageTooLong = errors.New("message is too long")
+)
+
+// MessageRejectedPayload tells a client why its chat message was not sent
+type MessageRejectedPayload struct {
+	Reason string `json:"reason"`
+}
+
+// validateChatMessage cleans up a chat message before it is saved. Control characters
+// other than newlines and tabs are stripped, surrounding whitespace is trimmed, and
+// blocked words are masked if the chat filter is on.
+func validateChatMessage(text string) (string, error) {
+	text = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t') {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		return "", errChatMessageEmpty
+	}
+	if utf8.RuneCountInString(text) > maxChatMessageLength {
+		return "", errChatMessageTooLong
+	}
+	return chatFilter.Sanitize(text), nil
+}
+
+// messageRejectedLobbyMessage is sent to a lobby client whose chat message failed validation
+func messageRejectedLobbyMessage(err error) LobbyMessage {
+	return LobbyMessage{
+		Type:    "message_rejected",
+		Payload: MessageRejectedPayload{Reason: err.Error()},
+	}
+}
+
+// messageRejectedGameMessage is sent to a game client whose chat message failed validation
+func messageRejectedGameMessage(err error) GameMessage {
+	payload, _ := json.Marshal(MessageRejectedPayload{Reason: err.Error()})
+	return GameMessage{Type: "message_rejected", Payload: payload}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateChatMessage(t *testing.T) {
+	prevMax := maxChatMessageLength
+	t.Cleanup(func() { maxChatMessageLength = prevMax })
+	SetMaxChatMessageLength(10)
+
+	for _, tc := range []struct {
+		name    string
+		text    string
+		want    string
+		wantErr error
+	}{
+		{"plain", "nice swap", "nice swap", nil},
+		{"trimmed", "  gg \n", "gg", nil},
+		{"control characters stripped", "g\x00g\x07", "gg", nil},
+		{"newlines and tabs kept", "a\n\tb", "a\n\tb", nil},
+		{"exactly the cap", strings.Repeat("a", 10), strings.Repeat("a", 10), nil},
+		{"cap counts characters", strings.Repeat("é", 10), strings.Repeat("é", 10), nil},
+		{"empty", "", "", errChatMessageEmpty},
+		{"only whitespace", " \t\n ", "", errChatMessageEmpty},
+		{"only control characters", "\x00\x1b", "", errChatMessageEmpty},
+		{"too long", strings.Repeat("a", 11), "", errChatMessageTooLong},
+		{"too long after trimming", "  " + strings.Repeat("a", 11) + "  ", "", errChatMessageTooLong},
+	} {
+		got, err := validateChatMessage(tc.text)
+		if !errors.Is(err, tc.wantErr) || got != tc.want {
+			t.Errorf("%s: validateChatMessage(%q) = %q, %v, want %q, %v", tc.name, tc.text, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetClientIPOnlyBelievesTrustedProxies(t *testing.T) {
+	useTrustedProxies(t, "10.0.0.0/8, 192.0.2.1")
+
+	for _, tc := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		want         string
+	}{
+		{"direct", "203.0.113.5:4000", "", "", "203.0.113.5"},
+		{"direct with forged headers", "203.0.113.5:4000", "198.51.100.1", "198.51.100.2", "203.0.113.5"},
+		{"one proxy", "192.0.2.1:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"forged hop before the proxy's", "192.0.2.1:4000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of proxies", "10.0.0.2:4000", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"garbage hop", "192.0.2.1:4000", "not-an-ip", "", "192.0.2.1"},
+		{"real IP header", "192.0.2.1:4000", "", "198.51.100.2", "198.51.100.2"},
+		{"ipv6 client", "[2001:db8::1]:4000", "198.51.100.1", "", "2001:db8::1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := getClientIP(req); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	if err := SetTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("an invalid range was accepted")
+	}
+}
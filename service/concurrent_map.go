@@ -0,0 +1,65 @@
+package service
+
+import "sync"
+
+// concurrentMap is a map guarded by an RWMutex. Range iterates over a snapshot,
+// so callbacks are free to Delete (or Set) without holding the lock.
+type concurrentMap[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+func newConcurrentMap[K comparable, V any]() *concurrentMap[K, V] {
+	return &concurrentMap[K, V]{m: make(map[K]V)}
+}
+
+// Set stores value under key
+func (c *concurrentMap[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = value
+}
+
+// Get returns the value stored under key
+func (c *concurrentMap[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.m[key]
+	return value, ok
+}
+
+// Delete removes key and returns the value it held, if any
+func (c *concurrentMap[K, V]) Delete(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.m[key]
+	if ok {
+		delete(c.m, key)
+	}
+	return value, ok
+}
+
+// Len returns the number of entries
+func (c *concurrentMap[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.m)
+}
+
+// Range calls fn for each entry in a snapshot of the map. Iteration stops if fn returns false.
+func (c *concurrentMap[K, V]) Range(fn func(key K, value V) bool) {
+	c.mu.RLock()
+	keys := make([]K, 0, len(c.m))
+	values := make([]V, 0, len(c.m))
+	for k, v := range c.m {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	c.mu.RUnlock()
+
+	for i := range keys {
+		if !fn(keys[i], values[i]) {
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"sync"
+	"testing"
+)
+
+// Run with -race: writers, readers and ranging callbacks that modify the map all at once
+func TestConcurrentMapUnderConcurrentUse(t *testing.T) {
+	m := newConcurrentMap[int, int]()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := worker*1000 + i
+				m.Set(key, i)
+				if value, ok := m.Get(key); !ok || value != i {
+					t.Errorf("Get(%d) = %d, %v right after setting it to %d", key, value, ok, i)
+					return
+				}
+				m.Len()
+				if i%2 == 0 {
+					m.Delete(key)
+				}
+			}
+		}(worker)
+
+		// Range callbacks may write to the map they're ranging over
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				m.Range(func(key, value int) bool {
+					if key%1000 == 999 {
+						m.Delete(key)
+					} else {
+						m.Set(key, value)
+					}
+					return true
+				})
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	// Ranging may have put back deleted keys, but never with a wrong value
+	entries := 0
+	m.Range(func(key, value int) bool {
+		if value != key%1000 {
+			t.Errorf("key %d holds %d", key, value)
+		}
+		entries++
+		return true
+	})
+	if entries != m.Len() {
+		t.Fatalf("Range saw %d entries, Len() = %d", entries, m.Len())
+	}
+}
+
+func TestConcurrentMapRangeStopsEarly(t *testing.T) {
+	m := newConcurrentMap[string, int]()
+	for _, key := range []string{"a", "b", "c"} {
+		m.Set(key, 1)
+	}
+
+	calls := 0
+	m.Range(func(string, int) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Fatalf("Range called fn %d times after it returned false", calls)
+	}
+}
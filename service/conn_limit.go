@@ -0,0 +1,64 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxConnectionsPerUser caps how many sockets one user may hold open per hub
+var maxConnectionsPerUser = 3
+
+// SetMaxConnectionsPerUser overrides the per-user connection cap. Values below 1 are ignored.
+func SetMaxConnectionsPerUser(n int) {
+	if n >= 1 {
+		maxConnectionsPerUser = n
+	}
+}
+
+// connCounter counts open connections per user
+type connCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newConnCounter() *connCounter {
+	return &connCounter{counts: make(map[string]int)}
+}
+
+// acquire takes a connection slot for userID, reporting false if they are at the cap
+func (c *connCounter) acquire(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[userID] >= maxConnectionsPerUser {
+		return false
+	}
+	c.counts[userID]++
+	return true
+}
+
+// add takes a slot for userID regardless of the cap, for a connection that is
+// about to replace one they already hold
+func (c *connCounter) add(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[userID]++
+}
+
+// release gives back a slot taken by acquire
+func (c *connCounter) release(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[userID] <= 1 {
+		delete(c.counts, userID)
+		return
+	}
+	c.counts[userID]--
+}
+
+// rejectTooManyConnections closes an upgraded connection that is over the cap
+func rejectTooManyConnections(conn *websocket.Conn, userID string) {
+	log.Printf("Refusing connection for user %s: %d already open", userID, maxConnectionsPerUser)
+	closeConn(conn, websocket.ClosePolicyViolation, "too many connections")
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"golf-card-game/business"
+	"testing"
+	"time"
+)
+
+func TestConnectionOverTheCapIsRefused(t *testing.T) {
+	prevMax := maxConnectionsPerUser
+	t.Cleanup(func() { maxConnectionsPerUser = prevMax })
+	SetMaxConnectionsPerUser(2)
+
+	repo, _ := useFakeGames(t)
+	for _, publicID := range []string{"g1", "g2", "g3"} {
+		dealTestGame(t, repo, publicID, business.GameRules{}, "alice", "bob")
+	}
+
+	first, _, err := dialGame(t, "g1", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, first, "state")
+	second, _, err := dialGame(t, "g2", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, second, "state")
+
+	third, _, err := dialGame(t, "g3", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireClosed(t, third, "too many connections")
+
+	// Closing one gives its slot back
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for !GameHubInstance.conns.acquire("alice") {
+		if time.Now().After(deadline) {
+			t.Fatal("closed connection's slot was never released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	GameHubInstance.conns.release("alice")
+
+	retry, _, err := dialGame(t, "g3", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, retry, "state")
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	// cookieSecure forces the Secure flag on or off. When nil it is on if the app
+	// looks like it's served over HTTPS, see cookieSecureEnabled.
+	cookieSecure *bool
+
+	// Lax allows cross-site "safe" requests like GET, but not POST
+	cookieSameSite = http.SameSiteLaxMode
+)
+
+// SetCookieSecure forces the session cookie's Secure flag on or off
+func SetCookieSecure(secure bool) {
+	cookieSecure = &secure
+}
+
+// SetCookieSameSite sets the session cookie's SameSite mode: "lax", "strict" or "none".
+// "none" is only accepted by browsers on Secure cookies, so it also turns Secure on.
+func SetCookieSameSite(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", "lax":
+		cookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		cookieSameSite = http.SameSiteStrictMode
+	case "none":
+		cookieSameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("unknown SameSite mode %q", mode)
+	}
+	return nil
+}
+
+// cookieSecureEnabled reports whether cookies should only be sent over HTTPS
+func cookieSecureEnabled() bool {
+	if cookieSameSite == http.SameSiteNoneMode {
+		return true
+	}
+	if cookieSecure != nil {
+		return *cookieSecure
+	}
+	return isProduction() || strings.HasPrefix(os.Getenv("APP_URL"), "https://")
+}
+
+// newSessionCookie builds the session cookie. A negative maxAge deletes it.
+func newSessionCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     "session",
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   cookieSecureEnabled(),
+		SameSite: cookieSameSite,
+		MaxAge:   maxAge,
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSessionCookieFlags(t *testing.T) {
+	prevSecure, prevSameSite := cookieSecure, cookieSameSite
+	t.Cleanup(func() { cookieSecure, cookieSameSite = prevSecure, prevSameSite })
+
+	on, off := true, false
+	for _, tc := range []struct {
+		name         string
+		secure       *bool
+		sameSite     string
+		env, appURL  string
+		wantSecure   bool
+		wantSameSite http.SameSite
+	}{
+		{"local http", nil, "", "", "http://localhost:3000", false, http.SameSiteLaxMode},
+		{"https app url", nil, "", "", "https://golf.example.com", true, http.SameSiteLaxMode},
+		{"production", nil, "", "production", "", true, http.SameSiteLaxMode},
+		{"forced off in production", &off, "", "production", "", false, http.SameSiteLaxMode},
+		{"forced on over http", &on, "", "", "http://localhost:3000", true, http.SameSiteLaxMode},
+		{"strict", nil, "Strict", "", "", false, http.SameSiteStrictMode},
+		{"none forces secure", &off, "none", "", "http://localhost:3000", true, http.SameSiteNoneMode},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ENV", tc.env)
+			t.Setenv("APP_URL", tc.appURL)
+			cookieSecure = tc.secure
+			if err := SetCookieSameSite(tc.sameSite); err != nil {
+				t.Fatal(err)
+			}
+
+			cookie := newSessionCookie("token", 3600)
+			if cookie.Secure != tc.wantSecure || cookie.SameSite != tc.wantSameSite || !cookie.HttpOnly {
+				t.Errorf("Secure %v, SameSite %v, HttpOnly %v; want Secure %v, SameSite %v, HttpOnly",
+					cookie.Secure, cookie.SameSite, cookie.HttpOnly, tc.wantSecure, tc.wantSameSite)
+			}
+		})
+	}
+
+	if err := SetCookieSameSite("sometimes"); err == nil {
+		t.Error("an unknown SameSite mode was accepted")
+	}
+	if cookie := newSessionCookie("", -1); cookie.MaxAge >= 0 || cookie.Value != "" {
+		t.Errorf("logout cookie = %+v, want it deleted", cookie)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golf-card-game/database"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+var (
+	errRecipientNotFound = errors.New("recipient not found")
+	errMessageToSelf     = errors.New("you cannot message yourself")
+)
+
+// DirectMessagePayload is a private message between two users
+type DirectMessagePayload struct {
+	ID           int    `json:"chatMessageId"`
+	Message      string `json:"message"`
+	FromUsername string `json:"fromUsername"`
+	ToUsername   string `json:"toUsername"`
+	Time         string `json:"time"`
+}
+
+// sendDirectMessage saves a private message and delivers it to every lobby connection
+// of both users, so the sender's other tabs see it too
+func sendDirectMessage(ctx context.Context, sender *database.User, toUsername, text string) error {
+	recipient, err := userService.GetUser(ctx, toUsername)
+	if err != nil {
+		return errRecipientNotFound
+	}
+	if recipient.UserID == sender.UserID {
+		return errMessageToSelf
+	}
+
+	savedMsg, err := chatRepo.SaveMessage(ctx, sender.UserID, database.DMScope(sender.UserID, recipient.UserID), text)
+	if err != nil {
+		return err
+	}
+
+	msg := LobbyMessage{
+		Type: "direct_message",
+		Payload: DirectMessagePayload{
+			ID:           savedMsg.ChatMessageID,
+			Message:      savedMsg.MessageText,
+			FromUsername: sender.Username,
+			ToUsername:   recipient.Username,
+			Time:         savedMsg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		},
+	}
+	Hub.SendNotificationToUser(sender.UserID, msg)
+	Hub.SendNotificationToUser(recipient.UserID, msg)
+	return nil
+}
+
+// GetDirectMessagesHandler returns the caller's private messages with another user.
+// Expects ?with=<username>, plus an optional ?limit= (max 100).
+func GetDirectMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if chatRepo == nil || userService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	with := r.URL.Query().Get("with")
+	if with == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Missing user"})
+		return
+	}
+	other, err := userService.GetUser(ctx, with)
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	if other.UserID == userID {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "You cannot message yourself"})
+		return
+	}
+
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 100)
+	}
+
+	messages, err := chatRepo.GetMessagesByScope(ctx, database.DMScope(userID, other.UserID), limit)
+	if err != nil {
+		log.Printf("Error fetching direct messages: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get messages"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"messages": messages,
+	})
+}
@@ -2,15 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"golf-card-game/database"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/resend/resend-go/v3"
 )
 
+// ErrEmailUndeliverable is returned instead of sending to an address that failed permanently before
+var ErrEmailUndeliverable = errors.New("email address is marked undeliverable")
+
 // EmailService handles sending emails via Resend
 type EmailService struct {
 	client *resend.Client
+
+	// onPermanentFailure is called with the user's ID when their address is rejected
+	onPermanentFailure func(userID string)
 }
 
 // NewEmailService creates a new email service
@@ -26,12 +36,23 @@ func NewEmailService() *EmailService {
 	}
 }
 
+// SetPermanentFailureHandler sets the callback run when a send fails in a way retrying won't fix
+func (s *EmailService) SetPermanentFailureHandler(fn func(userID string)) {
+	s.onPermanentFailure = fn
+}
+
 // SendWelcomeEmail sends a welcome email to a newly registered user
-func (s *EmailService) SendWelcomeEmail(toEmail, username string) error {
+func (s *EmailService) SendWelcomeEmail(user *database.User) error {
 	if s.client == nil {
 		return fmt.Errorf("RESEND_API_KEY not configured")
 	}
 
+	if user.EmailUndeliverable {
+		return ErrEmailUndeliverable
+	}
+
+	toEmail, username := user.Email, user.Username
+
 	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
 	if fromEmail == "" {
 		fromEmail = "onboarding@resend.dev" // Default Resend test email
@@ -64,6 +85,9 @@ func (s *EmailService) SendWelcomeEmail(toEmail, username string) error {
 
 	sent, err := s.client.Emails.SendWithContext(ctx, params)
 	if err != nil {
+		if isPermanentEmailFailure(err) && s.onPermanentFailure != nil {
+			s.onPermanentFailure(user.UserID)
+		}
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -71,11 +95,114 @@ func (s *EmailService) SendWelcomeEmail(toEmail, username string) error {
 	return nil
 }
 
-// getAppURL returns the application URL from environment or defaults to localhost
+// SendPasswordResetEmail sends a link for choosing a new password
+func (s *EmailService) SendPasswordResetEmail(toEmail, resetURL string) error {
+	if s.client == nil {
+		return fmt.Errorf("RESEND_API_KEY not configured")
+	}
+
+	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
+	if fromEmail == "" {
+		fromEmail = "onboarding@resend.dev" // Default Resend test email
+	}
+
+	ctx := context.Background()
+	params := &resend.SendEmailRequest{
+		From:    "Golf Card Game <" + fromEmail + ">",
+		To:      []string{toEmail},
+		Subject: "Reset your Golf Card Game password",
+		Html: fmt.Sprintf(`
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h1 style="color: #2563eb;">Reset your password</h1>
+				<p>Someone asked to reset the password for your Golf Card Game account.</p>
+				<p><a href="%s" style="color: #2563eb;">Choose a new password</a></p>
+				<p>The link works once and expires in an hour. If you didn't ask for this, you can ignore this email.</p>
+				<hr style="margin: 30px 0; border: none; border-top: 1px solid #e5e7eb;">
+				<p style="color: #6b7280; font-size: 12px;">
+					This is an automated message. Please do not reply to this email.
+				</p>
+			</div>
+		`, resetURL),
+	}
+
+	sent, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	fmt.Printf("Password reset email sent to %s (ID: %s)\n", toEmail, sent.Id)
+	return nil
+}
+
+// SendVerificationEmail sends a link confirming the user owns their address
+func (s *EmailService) SendVerificationEmail(toEmail, verifyURL string) error {
+	if s.client == nil {
+		return fmt.Errorf("RESEND_API_KEY not configured")
+	}
+
+	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
+	if fromEmail == "" {
+		fromEmail = "onboarding@resend.dev" // Default Resend test email
+	}
+
+	ctx := context.Background()
+	params := &resend.SendEmailRequest{
+		From:    "Golf Card Game <" + fromEmail + ">",
+		To:      []string{toEmail},
+		Subject: "Confirm your Golf Card Game email",
+		Html: fmt.Sprintf(`
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h1 style="color: #2563eb;">Confirm your email</h1>
+				<p>Please confirm this is your address so we can send you game invitations and account notices.</p>
+				<p><a href="%s" style="color: #2563eb;">Confirm my email</a></p>
+				<p>The link expires in 24 hours. If you didn't create an account, you can ignore this email.</p>
+				<hr style="margin: 30px 0; border: none; border-top: 1px solid #e5e7eb;">
+				<p style="color: #6b7280; font-size: 12px;">
+					This is an automated message. Please do not reply to this email.
+				</p>
+			</div>
+		`, verifyURL),
+	}
+
+	sent, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	fmt.Printf("Verification email sent to %s (ID: %s)\n", toEmail, sent.Id)
+	return nil
+}
+
+// EmailVerificationURL returns the link that redeems a verification token
+func EmailVerificationURL(token string) string {
+	return getAppBaseURL() + "/api/verify-email?token=" + url.QueryEscape(token)
+}
+
+// PasswordResetURL returns the frontend page where a reset token is redeemed
+func PasswordResetURL(token string) string {
+	return getAppBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+}
+
+// isPermanentEmailFailure reports whether Resend rejected the recipient address itself.
+// Rate limits and server errors are temporary and don't count.
+func isPermanentEmailFailure(err error) bool {
+	if errors.Is(err, resend.ErrRateLimit) {
+		return false
+	}
+	// Resend reports bad recipients as a validation error on the `to` field
+	return strings.Contains(err.Error(), "`to`")
+}
+
+// getAppURL returns the application login URL from environment or defaults to localhost
 func getAppURL() string {
+	return getAppBaseURL() + "/login"
+}
+
+// getAppBaseURL returns the application URL from environment or defaults to localhost
+func getAppBaseURL() string {
 	url := os.Getenv("APP_URL")
 	if url == "" {
-		return "http://localhost:3000/login"
+		return "http://localhost:3000"
 	}
-	return url + "/login"
+	return url
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/business"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/resend/resend-go/v3"
+)
+
+// startResendStub serves a stand-in for the Resend API answering with statuses in
+// turn (then 200), and returns an EmailService sending to it
+func startResendStub(t *testing.T, statuses ...int) (*EmailService, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		w.Header().Set("Content-Type", "application/json")
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			switch statuses[n-1] {
+			case http.StatusUnprocessableEntity:
+				fmt.Fprint(w, `{"name": "validation_error", "message": "Invalid `+"`to`"+` field. The email address needs to follow the email@example.com format."}`)
+			default:
+				fmt.Fprintf(w, `{"message": %q}`, http.StatusText(statuses[n-1]))
+			}
+			return
+		}
+		fmt.Fprint(w, `{"id": "sent"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := resend.NewCustomClient(srv.Client(), "re_test")
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return &EmailService{client: client}, &requests
+}
+
+func TestBouncedAddressIsMarkedAndLaterSendsAreSkipped(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo("alice")
+	repo.users["alice"].Email = "alice@invalid"
+	users := business.NewUserService(repo)
+	s, requests := startResendStub(t, http.StatusUnprocessableEntity)
+	s.SetPermanentFailureHandler(func(userID string) {
+		if err := users.MarkEmailUndeliverable(ctx, userID); err != nil {
+			t.Errorf("marking %s: %v", userID, err)
+		}
+	})
+
+	user, err := users.GetUserByID(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendWelcomeEmail(user); err == nil || errors.Is(err, ErrEmailUndeliverable) {
+		t.Fatalf("first send: err = %v, want the rejection", err)
+	}
+	if !repo.users["alice"].EmailUndeliverable {
+		t.Fatal("the rejected address wasn't marked undeliverable")
+	}
+
+	user, err = users.GetUserByID(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendWelcomeEmail(user); !errors.Is(err, ErrEmailUndeliverable) {
+		t.Fatalf("second send: err = %v, want ErrEmailUndeliverable", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Resend was called %d times, want the second send skipped", n)
+	}
+}
+
+func TestTemporaryEmailFailuresDontMarkTheAddress(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError} {
+		s, _ := startResendStub(t, status)
+		marked := false
+		s.SetPermanentFailureHandler(func(string) { marked = true })
+
+		user := newFakeUserRepo("alice").users["alice"]
+		user.Email = "alice@example.com"
+		if err := s.SendWelcomeEmail(user); err == nil {
+			t.Errorf("%d: send succeeded", status)
+		}
+		if marked {
+			t.Errorf("%d: a temporary failure marked the address undeliverable", status)
+		}
+	}
+}
@@ -0,0 +1,691 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golf-card-game/business"
+	"golf-card-game/database"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeGameRepo is an in-memory GameRepository holding just enough to exercise the
+// game service. Methods a test doesn't need panic through the nil embedded interface.
+type fakeGameRepo struct {
+	database.GameRepository
+
+	mu      sync.Mutex
+	nextID  int
+	games   map[string]*database.Game
+	players map[string][]*database.GamePlayer
+	states  map[string]*fakeStateRow
+}
+
+type fakeStateRow struct {
+	state   []byte
+	initial []byte
+	version int
+}
+
+func newFakeGameRepo() *fakeGameRepo {
+	return &fakeGameRepo{
+		games:   make(map[string]*database.Game),
+		players: make(map[string][]*database.GamePlayer),
+		states:  make(map[string]*fakeStateRow),
+	}
+}
+
+// addGame creates a game with the given players, all active, the first one as creator
+func (r *fakeGameRepo) addGame(publicID, status string, rules business.GameRules, userIDs ...string) *database.Game {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rulesJSON, _ := json.Marshal(rules)
+	r.nextID++
+	game := &database.Game{
+		GameID:     r.nextID,
+		PublicID:   publicID,
+		CreatedBy:  userIDs[0],
+		Status:     status,
+		MaxPlayers: business.MaxPlayers,
+		Rules:      rulesJSON,
+	}
+	r.games[publicID] = game
+	for i, userID := range userIDs {
+		r.players[publicID] = append(r.players[publicID], &database.GamePlayer{
+			GameID: game.GameID, UserID: userID, OrderIndex: i, IsActive: true,
+		})
+	}
+	return game
+}
+
+func (r *fakeGameRepo) GetGameByPublicID(ctx context.Context, publicID string) (*database.Game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return nil, database.ErrGameNotFound
+	}
+	copied := *game
+	copied.PlayerCount = len(r.players[publicID])
+	return &copied, nil
+}
+
+func (r *fakeGameRepo) GetGamePlayers(ctx context.Context, publicID string) ([]*database.GamePlayer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	players := make([]*database.GamePlayer, 0, len(r.players[publicID]))
+	for _, player := range r.players[publicID] {
+		copied := *player
+		players = append(players, &copied)
+	}
+	return players, nil
+}
+
+func (r *fakeGameRepo) GetActivePlayerIDs(ctx context.Context, publicID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	for _, player := range r.players[publicID] {
+		if player.IsActive {
+			ids = append(ids, player.UserID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeGameRepo) AddPlayer(ctx context.Context, publicID string, userID string, orderIndex int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	r.players[publicID] = append(r.players[publicID], &database.GamePlayer{
+		GameID: game.GameID, UserID: userID, OrderIndex: orderIndex,
+	})
+	return nil
+}
+
+func (r *fakeGameRepo) DeletePlayer(ctx context.Context, publicID string, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	players := r.players[publicID][:0]
+	for _, player := range r.players[publicID] {
+		if player.UserID != userID {
+			players = append(players, player)
+		}
+	}
+	r.players[publicID] = players
+	return nil
+}
+
+func (r *fakeGameRepo) UpdatePlayerStatus(ctx context.Context, publicID string, userID string, isActive bool, joinedAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, player := range r.players[publicID] {
+		if player.UserID == userID {
+			player.IsActive = isActive
+			player.JoinedAt = joinedAt
+			return nil
+		}
+	}
+	return fmt.Errorf("player %s not in game %s", userID, publicID)
+}
+
+func (r *fakeGameRepo) UpdateGameStatus(ctx context.Context, publicID string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	game.Status = status
+	return nil
+}
+
+func (r *fakeGameRepo) UpdatePlayerScore(ctx context.Context, publicID string, userID string, score int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, player := range r.players[publicID] {
+		if player.UserID == userID {
+			player.Score = &score
+			return nil
+		}
+	}
+	return fmt.Errorf("player %s not in game %s", userID, publicID)
+}
+
+func (r *fakeGameRepo) FinishGame(ctx context.Context, publicID string, winnerUserID *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[publicID]
+	if !ok {
+		return database.ErrGameNotFound
+	}
+	game.Status = "finished"
+	game.WinnerUserID = winnerUserID
+	return nil
+}
+
+func (r *fakeGameRepo) SaveGameState(ctx context.Context, publicID string, stateJSON []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.states[publicID]; ok {
+		return database.ErrStateExists
+	}
+	r.states[publicID] = &fakeStateRow{state: stateJSON, initial: stateJSON, version: 1}
+	return nil
+}
+
+func (r *fakeGameRepo) LoadGameState(ctx context.Context, publicID string) ([]byte, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok {
+		return nil, 0, database.ErrStateNotFound
+	}
+	return row.state, row.version, nil
+}
+
+func (r *fakeGameRepo) LoadInitialGameState(ctx context.Context, publicID string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok || row.initial == nil {
+		return nil, database.ErrStateNotFound
+	}
+	return row.initial, nil
+}
+
+func (r *fakeGameRepo) UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.states[publicID]
+	if !ok || row.version != expectedVersion {
+		return database.ErrVersionConflict
+	}
+	row.state = stateJSON
+	row.version++
+	return nil
+}
+
+// WithTx runs fn directly; the fake has nothing to roll back
+func (r *fakeGameRepo) WithTx(ctx context.Context, fn func(tx database.GameRepository) error) error {
+	return fn(r)
+}
+
+// fakeMoveRepo is an in-memory MoveLogRepository
+type fakeMoveRepo struct {
+	mu    sync.Mutex
+	moves []*database.Move
+}
+
+func (r *fakeMoveRepo) RecordMove(ctx context.Context, gameID int, userID string, action string, cardIndex *int, decksJSON []byte, resultingVersion int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, move := range r.moves {
+		if move.GameID == gameID && move.ResultingVersion == resultingVersion {
+			return fmt.Errorf("duplicate move for version %d", resultingVersion)
+		}
+	}
+	r.moves = append(r.moves, &database.Move{
+		GameMoveID:       len(r.moves) + 1,
+		GameID:           gameID,
+		UserID:           userID,
+		Action:           action,
+		CardIndex:        cardIndex,
+		Decks:            decksJSON,
+		ResultingVersion: resultingVersion,
+	})
+	return nil
+}
+
+func (r *fakeMoveRepo) GetMoves(ctx context.Context, gameID int) ([]*database.Move, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var moves []*database.Move
+	for _, move := range r.moves {
+		if move.GameID == gameID {
+			moves = append(moves, move)
+		}
+	}
+	return moves, nil
+}
+
+// fakeUserRepo is an in-memory UserRepository keyed by user ID
+type fakeUserRepo struct {
+	database.UserRepository
+
+	mu      sync.Mutex
+	users   map[string]*database.User
+	lookups int // calls that read users by ID
+
+	verifyTokens map[string]*fakeToken // by token hash
+}
+
+// fakeToken is a row of email_verification_tokens
+type fakeToken struct {
+	userID    string
+	expiresAt time.Time
+	used      bool
+}
+
+func newFakeUserRepo(userIDs ...string) *fakeUserRepo {
+	r := &fakeUserRepo{users: make(map[string]*database.User), verifyTokens: make(map[string]*fakeToken)}
+	for _, userID := range userIDs {
+		r.users[userID] = &database.User{UserID: userID, Username: userID}
+	}
+	return r
+}
+
+func (r *fakeUserRepo) GetUserByID(ctx context.Context, userID string) (*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, database.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+	users := make(map[string]*database.User, len(userIDs))
+	for _, userID := range userIDs {
+		if user, ok := r.users[userID]; ok {
+			copied := *user
+			users[userID] = &copied
+		}
+	}
+	return users, nil
+}
+
+// lookupCount returns how many times users were read by ID
+func (r *fakeUserRepo) lookupCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+func (r *fakeUserRepo) GetUserByUsername(ctx context.Context, username string) (*database.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) UpdatePassword(ctx context.Context, userID, hashedPassword string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[userID].Password = hashedPassword
+	return nil
+}
+
+// DeleteAllSessions has nothing to do, as the fake keeps no sessions
+func (r *fakeUserRepo) DeleteAllSessions(ctx context.Context, userID string) error {
+	return nil
+}
+
+// CreateEmailVerificationToken stores a token; the user's unused ones stop working
+func (r *fakeUserRepo) MarkEmailUndeliverable(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.EmailUndeliverable = true
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) CreateEmailVerificationToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.verifyTokens {
+		if token.userID == userID {
+			token.used = true
+		}
+	}
+	r.verifyTokens[tokenHash] = &fakeToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *fakeUserRepo) VerifyEmailWithToken(ctx context.Context, tokenHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.verifyTokens[tokenHash]
+	if !ok || token.used || !time.Now().Before(token.expiresAt) {
+		return "", database.ErrInvalidVerifyToken
+	}
+	token.used = true
+	r.users[token.userID].EmailVerified = true
+	return token.userID, nil
+}
+
+// fakeGameEventRepo keeps every game's activity feed in memory, in append order
+type fakeGameEventRepo struct {
+	mu     sync.Mutex
+	events map[string][]*database.GameEvent
+}
+
+func (r *fakeGameEventRepo) AppendGameEvent(ctx context.Context, publicID string, event *database.GameEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.events == nil {
+		r.events = make(map[string][]*database.GameEvent)
+	}
+	event.GameEventID = len(r.events[publicID]) + 1
+	r.events[publicID] = append(r.events[publicID], event)
+	return nil
+}
+
+func (r *fakeGameEventRepo) GetGameEvents(ctx context.Context, publicID string, limit int) ([]*database.GameEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events[publicID]
+	return slices.Clone(events[max(0, len(events)-limit):]), nil
+}
+
+// fakeAuditRepo keeps appended audit events in memory
+type fakeAuditRepo struct {
+	mu     sync.Mutex
+	events []*database.AuditEvent
+}
+
+func (r *fakeAuditRepo) AppendEvent(ctx context.Context, event *database.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeAuditRepo) GetEvents(ctx context.Context, userID string, eventType string, limit int) ([]*database.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []*database.AuditEvent
+	for _, event := range r.events {
+		if eventType != "" && event.EventType != eventType {
+			continue
+		}
+		if userID != "" && (event.UserID == nil || *event.UserID != userID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events[:min(limit, len(events))], nil
+}
+
+// fakeChatRepo serves chat history from messages with IDs 1 to n in whatever scope is
+// asked for, followed by the messages saved after them in that scope
+type fakeChatRepo struct {
+	database.ChatRepository
+
+	mu        sync.Mutex
+	n         int
+	lastLimit int // limit of the most recent history query
+	saved     []*database.ChatMessage
+	reactions map[int]map[string]map[string]bool // message ID -> emoji -> who reacted
+	unread    map[string]map[string]int          // user ID -> scope -> unread count
+}
+
+func (r *fakeChatRepo) GetMessagesByScope(ctx context.Context, scope string, limit int) ([]*database.ChatMessage, error) {
+	return r.GetMessagesBefore(ctx, scope, math.MaxInt, limit)
+}
+
+// GetMessagesBefore returns the latest limit messages below beforeID, oldest first
+func (r *fakeChatRepo) GetMessagesBefore(ctx context.Context, scope string, beforeID int, limit int) ([]*database.ChatMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastLimit = limit
+	messages := []*database.ChatMessage{}
+	for id := 1; id <= r.n && id < beforeID; id++ {
+		messages = append(messages, &database.ChatMessage{ChatMessageID: id, Scope: scope, MessageText: fmt.Sprintf("message %d", id)})
+	}
+	for _, msg := range r.saved {
+		if msg.Scope == scope && msg.ChatMessageID < beforeID {
+			copied := *msg
+			messages = append(messages, &copied)
+		}
+	}
+	return messages[max(0, len(messages)-limit):], nil
+}
+
+func (r *fakeChatRepo) SaveMessage(ctx context.Context, senderUserID, scope, messageText string) (*database.ChatMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg := &database.ChatMessage{
+		ChatMessageID: r.n + len(r.saved) + 1,
+		SenderUserID:  senderUserID,
+		Scope:         scope,
+		MessageText:   messageText,
+		CreatedAt:     time.Now(),
+		System:        senderUserID == "",
+	}
+	r.saved = append(r.saved, msg)
+	copied := *msg
+	return &copied, nil
+}
+
+// savedMessage finds a message saved to r. Callers hold r.mu.
+func (r *fakeChatRepo) savedMessage(messageID int) *database.ChatMessage {
+	for _, msg := range r.saved {
+		if msg.ChatMessageID == messageID {
+			return msg
+		}
+	}
+	return nil
+}
+
+// changeableMessage returns a saved message senderUserID may edit or delete
+func (r *fakeChatRepo) changeableMessage(messageID int, senderUserID string) (*database.ChatMessage, error) {
+	msg := r.savedMessage(messageID)
+	if msg == nil || msg.Deleted {
+		return nil, database.ErrMessageNotFound
+	}
+	if msg.SenderUserID != senderUserID {
+		return nil, database.ErrNotMessageSender
+	}
+	return msg, nil
+}
+
+func (r *fakeChatRepo) UpdateMessage(ctx context.Context, messageID int, senderUserID, newText string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, err := r.changeableMessage(messageID, senderUserID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	msg.MessageText, msg.EditedAt = newText, &now
+	return nil
+}
+
+func (r *fakeChatRepo) DeleteMessage(ctx context.Context, messageID int, senderUserID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, err := r.changeableMessage(messageID, senderUserID)
+	if err != nil {
+		return err
+	}
+	msg.Deleted = true
+	return nil
+}
+
+func (r *fakeChatRepo) GetMessageScope(ctx context.Context, messageID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if msg := r.savedMessage(messageID); msg != nil {
+		return msg.Scope, nil
+	}
+	return "", database.ErrMessageNotFound
+}
+
+// ToggleReaction toggles like the real repository, including the cap on kinds of reaction
+func (r *fakeChatRepo) ToggleReaction(ctx context.Context, messageID int, userID, emoji string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if msg := r.savedMessage(messageID); msg == nil || msg.Deleted {
+		return false, database.ErrMessageNotFound
+	}
+	if r.reactions == nil {
+		r.reactions = make(map[int]map[string]map[string]bool)
+	}
+	byEmoji := r.reactions[messageID]
+	if byEmoji == nil {
+		byEmoji = make(map[string]map[string]bool)
+		r.reactions[messageID] = byEmoji
+	}
+	if byEmoji[emoji][userID] {
+		delete(byEmoji[emoji], userID)
+		if len(byEmoji[emoji]) == 0 {
+			delete(byEmoji, emoji)
+		}
+		return false, nil
+	}
+	if byEmoji[emoji] == nil {
+		if len(byEmoji) >= database.MaxReactionTypes {
+			return false, database.ErrTooManyReactions
+		}
+		byEmoji[emoji] = make(map[string]bool)
+	}
+	byEmoji[emoji][userID] = true
+	return true, nil
+}
+
+func (r *fakeChatRepo) GetReactionCounts(ctx context.Context, messageID int) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int)
+	for emoji, users := range r.reactions[messageID] {
+		counts[emoji] = len(users)
+	}
+	return counts, nil
+}
+
+func (r *fakeChatRepo) GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return maps.Clone(r.unread[userID]), nil
+}
+
+// fakeFriendRepo is a FriendRepository with a fixed set of friendships
+type fakeFriendRepo struct {
+	database.FriendRepository
+
+	friends map[string][]string
+}
+
+func (r *fakeFriendRepo) ListFriends(ctx context.Context, userID string) ([]*database.User, error) {
+	var users []*database.User
+	for _, friendID := range r.friends[userID] {
+		users = append(users, &database.User{UserID: friendID, Username: friendID})
+	}
+	return users, nil
+}
+
+// newTestConn opens a real websocket connection and returns its server side, with a
+// write pump running, and its client side for reading what the server sends
+func newTestConn(t testing.TB) (server, client *websocket.Conn) {
+	t.Helper()
+
+	serverConns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server = <-serverConns
+
+	stop := startWritePump(server)
+	t.Cleanup(func() {
+		stop()
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+// readMessageOfType reads from client until a message of type msgType arrives and
+// returns its payload
+func readMessageOfType(t *testing.T, client *websocket.Conn, msgType string) json.RawMessage {
+	t.Helper()
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("bad message %s: %v", data, err)
+		}
+		if msg.Type == msgType {
+			return msg.Payload
+		}
+	}
+}
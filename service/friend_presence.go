@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// FriendPresencePayload tells a user that one of their friends came online or went offline
+type FriendPresencePayload struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Online   bool   `json:"online"`
+}
+
+// friendPresenceTracker remembers who was last seen online, so friends are only told
+// when a user's first connection opens or their last one closes, not about every tab.
+// Changes are sent by one goroutine in the order they were seen, so a quick
+// offline-online flap can't reach friends the wrong way round.
+type friendPresenceTracker struct {
+	mu      sync.Mutex
+	online  map[string]bool
+	pending []presenceChange // oldest first, each kept until its messages are out
+
+	wake    chan struct{} // tells the sender pending has something in it
+	started sync.Once
+}
+
+// presenceChange is one user coming online or going offline
+type presenceChange struct {
+	userID string
+	online bool
+}
+
+var friendPresence = &friendPresenceTracker{
+	online: make(map[string]bool),
+	wake:   make(chan struct{}, 1),
+}
+
+// userOnline reports whether userID has a lobby or game room connection open
+func userOnline(userID string) bool {
+	connected := false
+	Hub.clients.Range(func(_ *websocket.Conn, clientUserID string) bool {
+		connected = clientUserID == userID
+		return !connected
+	})
+	return connected || presence.inGame(userID)
+}
+
+// refresh rechecks whether userID is online and tells their friends if that changed.
+// Call it after any of the user's lobby or game room connections opens or closes.
+func (t *friendPresenceTracker) refresh(userID string) {
+	t.mu.Lock()
+	online := userOnline(userID)
+	changed := online != t.online[userID]
+	if online {
+		t.online[userID] = true
+	} else {
+		delete(t.online, userID)
+	}
+	if changed {
+		t.pending = append(t.pending, presenceChange{userID: userID, online: online})
+	}
+	t.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	t.started.Do(func() { go t.send() })
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// send delivers pending changes one at a time, forever
+func (t *friendPresenceTracker) send() {
+	for range t.wake {
+		for {
+			t.mu.Lock()
+			if len(t.pending) == 0 {
+				t.mu.Unlock()
+				break
+			}
+			change := t.pending[0]
+			t.mu.Unlock()
+
+			notifyFriendsOfPresence(change.userID, change.online)
+
+			t.mu.Lock()
+			t.pending = t.pending[1:]
+			t.mu.Unlock()
+		}
+	}
+}
+
+// notifyFriendsOfPresence sends a friend_presence message to each of userID's friends
+func notifyFriendsOfPresence(userID string, online bool) {
+	if friendService == nil || userService == nil {
+		return
+	}
+
+	ctx := context.Background()
+	friends, err := friendService.ListFriends(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to get friends of user %s: %v", userID, err)
+		return
+	}
+	if len(friends) == 0 {
+		return
+	}
+
+	user, err := userService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to get user %s: %v", userID, err)
+		return
+	}
+
+	msg := LobbyMessage{
+		Type:    "friend_presence",
+		Payload: FriendPresencePayload{UserID: userID, Username: user.Username, Online: online},
+	}
+	for _, friend := range friends {
+		Hub.SendNotificationToUser(friend.UserID, msg)
+	}
+}
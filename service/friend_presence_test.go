@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/json"
+	"golf-card-game/business"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// useFakeFriends makes alice and bob friends for one test
+func useFakeFriends(t *testing.T) {
+	t.Helper()
+
+	waitForPresenceSent(t)
+	prevFriends, prevUsers := friendService, userService
+	t.Cleanup(func() {
+		waitForPresenceSent(t)
+		friendService, userService = prevFriends, prevUsers
+	})
+
+	friendService = business.NewFriendService(&fakeFriendRepo{friends: map[string][]string{
+		"alice": {"bob"},
+		"bob":   {"alice"},
+	}})
+	userService = business.NewUserService(newFakeUserRepo("alice", "bob"))
+}
+
+// waitForPresenceSent waits until the tracker has seen the current connections of
+// userIDs and every change it has seen has been sent to friends, so the services the
+// sender reads can be swapped without racing it
+func waitForPresenceSent(t testing.TB, userIDs ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		friendPresence.mu.Lock()
+		idle := len(friendPresence.pending) == 0
+		for _, userID := range userIDs {
+			idle = idle && friendPresence.online[userID] == userOnline(userID)
+		}
+		friendPresence.mu.Unlock()
+		if idle {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("presence changes still unsent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// connectLobby registers conn in the lobby as userID until the test ends
+func connectLobby(t *testing.T, conn *websocket.Conn, userID string) {
+	Hub.clients.Set(conn, userID)
+	t.Cleanup(func() {
+		Hub.clients.Delete(conn)
+		friendPresence.refresh(userID)
+	})
+}
+
+func TestFriendGoingOfflineNotifiesFriends(t *testing.T) {
+	useFakeFriends(t)
+	bobServer, bobClient := newTestConn(t)
+	connectLobby(t, bobServer, "bob")
+
+	aliceConn := &websocket.Conn{}
+	connectLobby(t, aliceConn, "alice")
+	friendPresence.refresh("alice")
+	if got := readPresence(t, bobClient); !got.Online || got.UserID != "alice" {
+		t.Fatalf("got %+v, want alice online", got)
+	}
+
+	Hub.clients.Delete(aliceConn)
+	friendPresence.refresh("alice")
+	if got := readPresence(t, bobClient); got.Online || got.UserID != "alice" {
+		t.Fatalf("got %+v, want alice offline", got)
+	}
+}
+
+func TestFriendPresenceArrivesInOrder(t *testing.T) {
+	useFakeFriends(t)
+	bobServer, bobClient := newTestConn(t)
+	connectLobby(t, bobServer, "bob")
+
+	aliceConn := &websocket.Conn{}
+	connectLobby(t, aliceConn, "alice")
+	const flaps = 50
+	for i := 0; i < flaps; i++ {
+		Hub.clients.Set(aliceConn, "alice")
+		friendPresence.refresh("alice")
+		Hub.clients.Delete(aliceConn)
+		friendPresence.refresh("alice")
+	}
+
+	for i := 0; i < 2*flaps; i++ {
+		want := i%2 == 0
+		if got := readPresence(t, bobClient); got.Online != want {
+			t.Fatalf("update %d: online = %v, want %v", i, got.Online, want)
+		}
+	}
+}
+
+func readPresence(t *testing.T, client *websocket.Conn) FriendPresencePayload {
+	t.Helper()
+
+	var payload FriendPresencePayload
+	if err := json.Unmarshal(readMessageOfType(t, client, "friend_presence"), &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"golf-card-game/business"
+	"log"
+	"net/http"
+	"sort"
+)
+
+var friendService *business.FriendService
+
+func SetFriendService(fs *business.FriendService) {
+	friendService = fs
+}
+
+// FriendPayload is a friend, or a user asking to be one, as sent to the client
+type FriendPayload struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// FriendStatus is a friend with what they're doing right now
+type FriendStatus struct {
+	FriendPayload
+	Online bool `json:"online"`
+	PlayerStatus
+}
+
+// ListFriendsHandler returns the user's friends, online ones first so they're easy to
+// invite, along with the friend requests waiting on the user
+func ListFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if friendService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	statuses, err := friendStatuses(ctx, userID)
+	if err != nil {
+		log.Printf("Error listing friends: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get friends"})
+		return
+	}
+	requests, err := friendService.ListFriendRequests(ctx, userID)
+	if err != nil {
+		log.Printf("Error listing friend requests: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get friends"})
+		return
+	}
+
+	pending := make([]FriendPayload, 0, len(requests))
+	for _, user := range requests {
+		pending = append(pending, FriendPayload{UserID: user.UserID, Username: user.Username})
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"friends":  statuses,
+		"requests": pending,
+	})
+}
+
+// GetOnlineFriendsHandler returns the caller's friends who have a lobby or game
+// connection open, for suggesting who to invite. Only friends' presence is revealed.
+// Clients keep the list current from friend_presence lobby messages.
+func GetOnlineFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if friendService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	statuses, err := friendStatuses(ctx, userID)
+	if err != nil {
+		log.Printf("Error listing friends: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get friends"})
+		return
+	}
+
+	online := make([]FriendStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Online {
+			online = append(online, status)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"online": online,
+	})
+}
+
+// friendStatuses returns the user's friends with their presence, online friends
+// first and each group ordered by username
+func friendStatuses(ctx context.Context, userID string) ([]FriendStatus, error) {
+	friends, err := friendService.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	friendIDs := make([]string, len(friends))
+	for i, friend := range friends {
+		friendIDs[i] = friend.UserID
+	}
+	inLobby := make(map[string]bool, len(friends))
+	for _, id := range Hub.OnlineUserIDs(friendIDs) {
+		inLobby[id] = true
+	}
+
+	statuses := make([]FriendStatus, 0, len(friends))
+	for _, friend := range friends {
+		status := presence.statusOf(friend.UserID)
+		statuses = append(statuses, FriendStatus{
+			FriendPayload: FriendPayload{UserID: friend.UserID, Username: friend.Username},
+			Online:        inLobby[friend.UserID] || status.Status != PresenceLobby,
+			PlayerStatus:  status,
+		})
+	}
+	// Friends arrive sorted by username, so a stable sort keeps that order within each group
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Online && !statuses[j].Online
+	})
+	return statuses, nil
+}
+
+// friendRequest is the body of the friend request, accept and remove endpoints
+type friendRequest struct {
+	Username string `json:"username"`
+}
+
+// SendFriendRequestHandler asks another user to be friends. If they had already
+// asked, the two become friends straight away.
+func SendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+
+	accepted, err := friendService.SendRequest(r.Context(), userID, other.UserID)
+	if err != nil {
+		switch err {
+		case business.ErrCannotFriendSelf, business.ErrCannotFriendUser:
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case business.ErrFriendRequestSent, business.ErrAlreadyFriends:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Printf("Error sending friend request: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to send friend request"})
+		}
+		return
+	}
+
+	me := lookupUsername(r, userID)
+	if accepted {
+		Hub.SendNotificationToUser(other.UserID, LobbyMessage{Type: "friend_accepted", Payload: FriendPayload{UserID: userID, Username: me}})
+		jsonResponse(w, http.StatusOK, map[string]string{"message": "You are now friends"})
+		return
+	}
+
+	Hub.SendNotificationToUser(other.UserID, LobbyMessage{Type: "friend_request", Payload: FriendPayload{UserID: userID, Username: me}})
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Friend request sent"})
+}
+
+// AcceptFriendRequestHandler accepts a pending friend request from another user
+func AcceptFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := friendService.AcceptRequest(r.Context(), userID, other.UserID); err != nil {
+		if err == business.ErrFriendRequestNotFound {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error accepting friend request: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to accept friend request"})
+		return
+	}
+
+	Hub.SendNotificationToUser(other.UserID, LobbyMessage{
+		Type:    "friend_accepted",
+		Payload: FriendPayload{UserID: userID, Username: lookupUsername(r, userID)},
+	})
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "You are now friends"})
+}
+
+// RemoveFriendHandler unfriends another user, or declines or cancels a pending request
+func RemoveFriendHandler(w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := friendService.RemoveFriend(r.Context(), userID, other.UserID); err != nil {
+		if err == business.ErrFriendNotFound {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error removing friend: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove friend"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Friend removed"})
+}
+
+// decodeFriendRequest does the checks shared by the friend endpoints and resolves the
+// other user. It writes the error response itself and returns ok=false on failure.
+func decodeFriendRequest(w http.ResponseWriter, r *http.Request) (userID string, other FriendPayload, ok bool) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return "", FriendPayload{}, false
+	}
+
+	userID, ok = r.Context().Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return "", FriendPayload{}, false
+	}
+
+	if friendService == nil || userService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return "", FriendPayload{}, false
+	}
+
+	var req friendRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return "", FriendPayload{}, false
+	}
+	if req.Username == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Username is required"})
+		return "", FriendPayload{}, false
+	}
+
+	user, err := userService.GetUser(r.Context(), req.Username)
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return "", FriendPayload{}, false
+	}
+	return userID, FriendPayload{UserID: user.UserID, Username: user.Username}, true
+}
+
+// lookupUsername returns userID's username, or "" if it can't be found
+func lookupUsername(r *http.Request, userID string) string {
+	user, err := userService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return ""
+	}
+	return user.Username
+}
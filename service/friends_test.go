@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOnlineFriendsListsOnlyFriendsWhoAreOnline(t *testing.T) {
+	prevFriends := friendService
+	t.Cleanup(func() { friendService = prevFriends })
+	friendService = business.NewFriendService(&fakeFriendRepo{friends: map[string][]string{
+		"alice": {"bob", "carol", "dave"},
+	}})
+
+	// bob is in the lobby, carol is playing, dave is offline and eve isn't a friend.
+	// Nobody's presence is refreshed, so no friend_presence sends outlive the test.
+	connectLobby(t, &websocket.Conn{}, "bob")
+	connectLobby(t, &websocket.Conn{}, "eve")
+	carolConn := &websocket.Conn{}
+	presence.mu.Lock()
+	presence.conns[carolConn] = gamePresence{userID: "carol", publicID: "game-1"}
+	presence.mu.Unlock()
+	t.Cleanup(func() {
+		presence.mu.Lock()
+		delete(presence.conns, carolConn)
+		presence.mu.Unlock()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/friends/online", nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, "alice"))
+	rec := httptest.NewRecorder()
+	GetOnlineFriendsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var body struct {
+		Online []FriendStatus `json:"online"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []FriendStatus{
+		{FriendPayload{"bob", "bob"}, true, PlayerStatus{Status: PresenceLobby}},
+		{FriendPayload{"carol", "carol"}, true, PlayerStatus{Status: PresenceInGame, PublicID: "game-1"}},
+	}
+	if len(body.Online) != len(want) {
+		t.Fatalf("online = %+v, want %+v", body.Online, want)
+	}
+	for i := range want {
+		if body.Online[i] != want[i] {
+			t.Errorf("online[%d] = %+v, want %+v", i, body.Online[i], want[i])
+		}
+	}
+}
@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golf-card-game/business"
 	"golf-card-game/database"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,39 +22,137 @@ type GameHub struct {
 	// Map of publicID to room
 	rooms map[string]*GameRoom
 	mu    sync.RWMutex
+
+	conns *connCounter // open connections per user, across all rooms
 }
 
 // GameRoom represents a single game instance with its connected players
 type GameRoom struct {
 	publicID   string
-	clients    map[*websocket.Conn]string // conn -> userID
+	clients    *concurrentMap[*websocket.Conn, string] // conn -> userID
+	spectators *concurrentMap[*websocket.Conn, string] // conn -> userID, for non-players watching
 	broadcast  chan GameMessage
 	register   chan *gameClientRegistration
 	unregister chan *websocket.Conn
-	mu         sync.RWMutex
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	// Pre-game countdown, owned by Run
+	countdownSeconds int
+	countdownTicker  func() (<-chan time.Time, func()) // starts the ticks between seconds, returns their stop
+	countdownDone    chan int                          // receives the generation of a countdown that finished
+	countdownGen     int
+	countdownCancel  context.CancelFunc // non-nil while a countdown is running
+
+	// Connections that asked for ?full_state=true, owned by Run
+	fullState map[*websocket.Conn]bool
+
+	// Absence tracking for stuck turn recovery, owned by Run
+	createdAt      time.Time
+	disconnectedAt map[string]time.Time
+
+	// Turn timer, owned by Run. turnCommitted carries each state saved outside Run.
+	turnTimeout   time.Duration
+	turnTimer     *time.Timer
+	turnVersion   int // state version the running timer was armed for
+	turnCommitted chan *business.FullGameState
+
+	// Pending computer opponent move, owned by Run
+	botTimer *time.Timer
+
+	// Last turn announced with "turn_changed"
+	turnMu        sync.Mutex
+	announcedTurn turnMarker
+
+	// Reconnect tokens by userID, see reconnect.go
+	reconnectMu     sync.Mutex
+	reconnectTokens map[string]reconnectGrant
+}
+
+// turnMarker identifies whose turn it is closely enough to notice any change
+type turnMarker struct {
+	round   int
+	phase   business.GamePhase
+	turnIdx int
 }
 
 type gameClientRegistration struct {
-	conn   *websocket.Conn
-	userID string
+	conn      *websocket.Conn
+	userID    string
+	spectator bool
+	fullState bool // follow every table update with a complete state
 }
 
+// tableUpdates are the room broadcasts that report a change at the table without
+// carrying the board. Connections that asked for full_state get their complete state
+// after each one, so they never have to piece the picture together from updates.
+var tableUpdates = map[string]bool{
+	"countdown":          true,
+	"player_joined":      true,
+	"player_left":        true,
+	"player_reconnected": true,
+	"turn_changed":       true,
+	"turn_timeout":       true,
+	"game_started":       true,
+	"round_end":          true,
+	"game_abandoned":     true,
+}
+
+// spectatorViewer is the viewer ID spectators' state is built for. It matches no
+// player, so every face-down card and the drawn card stay hidden.
+const spectatorViewer = ""
+
+// Policies for a player opening the same game in more than one tab
+const (
+	DuplicatePolicyTakeover = "takeover" // Close the older connection, keep the new one
+	DuplicatePolicyReject   = "reject"   // Keep the older connection, refuse the new one
+)
+
+// duplicateConnectionPolicy controls how a second connection for the same player is handled
+var duplicateConnectionPolicy = DuplicatePolicyTakeover
+
+// countdownSeconds is the length of the pre-game countdown (0 deals immediately)
+var countdownSeconds = 3
+
+// countdownTick is the interval between countdown ticks
+var countdownTick = time.Second
+
+// disconnectGracePeriod is how long the current player may be absent with a drawn card
+// before the server discards it for them
+var disconnectGracePeriod = 60 * time.Second
+
+// turnTimeout is how long a player has to finish their turn (0 disables the timer)
+var turnTimeout = 60 * time.Second
+
+// defaultTimeoutStrategy is used for games created without a timeout strategy
+var defaultTimeoutStrategy = business.TimeoutFlipLeftmost
+
+// requireVerifiedEmail stops users who haven't confirmed their email from creating games
+var requireVerifiedEmail = false
+
+// stuckTurnCheckInterval is how often a room checks for an abandoned drawn card
+const stuckTurnCheckInterval = 15 * time.Second
+
 // GameMessage represents any message sent in a game room
 type GameMessage struct {
-	Type    string          `json:"type"` // "chat", "state", "action", "player_joined", "player_left"
+	Type    string          `json:"type"` // "chat", "state", "action", "sync", "turn_changed", "game_started", "game_abandoned", "player_joined", "player_reconnected", "player_left", "spectator_count", "typing", "rate_limited", "message_rejected", "message_edited", "message_deleted", "reactions"
 	Payload json.RawMessage `json:"payload"`
 }
 
 // ChatPayload for chat messages within a game
 type ChatPayload struct {
-	Message  string `json:"message"`
-	Username string `json:"username"`
-	Time     string `json:"time"`
+	ID        int            `json:"chatMessageId,omitempty"`
+	Message   string         `json:"message"`
+	Username  string         `json:"username"`
+	Time      string         `json:"time"`
+	Reactions map[string]int `json:"reactions,omitempty"` // In history only, live changes come as "reactions"
+	System    bool           `json:"system,omitempty"`    // Posted by the server rather than a player
 }
 
-// GameStatePayload represents the current state of the game
+// GameStatePayload represents the current state of the game.
+// Every "state" message is a complete snapshot for the viewer, never a delta, so
+// clients can render from any single message. Reduced-motion clients connecting with
+// ?full_state=true also get one after every table update (see tableUpdates).
 type GameStatePayload struct {
 	PublicID        string       `json:"publicId"`
 	Status          string       `json:"status"`
@@ -65,6 +166,27 @@ type GameStatePayload struct {
 	DrawnCard       *Card        `json:"drawnCard"`
 	DiscardTopCard  *Card        `json:"discardTopCard"`
 	DeckCount       int          `json:"deckCount"`
+	HasDrawnCard    bool         `json:"hasDrawnCard"`             // Someone has drawn and must swap or discard
+	ReconnectToken  string       `json:"reconnectToken,omitempty"` // Pass as ?reconnectToken= to resume after a drop
+	YourTurn        bool         `json:"yourTurn"`
+
+	YourVisibleScore     int  `json:"yourVisibleScore"`
+	OpponentVisibleScore *int `json:"opponentVisibleScore,omitempty"` // Omitted mid-game when the rules hide it
+
+	// Every other player in turn order. OpponentCards/OpponentVisibleScore mirror the first entry.
+	Opponents []OpponentView `json:"opponents"`
+
+	// Match play progress
+	Round            int            `json:"round"`
+	TargetScore      int            `json:"targetScore"`
+	CumulativeScores map[string]int `json:"cumulativeScores"`
+}
+
+// OpponentView is what a viewer can see of another player's hand
+type OpponentView struct {
+	UserID       string `json:"userId"`
+	Cards        []Card `json:"cards"`
+	VisibleScore *int   `json:"visibleScore,omitempty"`
 }
 
 type PlayerInfo struct {
@@ -83,7 +205,7 @@ type Card struct {
 
 // ActionPayload for game actions
 type ActionPayload struct {
-	Action string          `json:"action"` // "initial_flip", "draw_deck", "draw_discard", "swap_card", "discard_flip"
+	Action string          `json:"action"` // "initial_flip", "draw_deck", "draw_discard", "swap_card" (or "swap"), "discard_flip", "knock", "cancel_draw", "resign"
 	Data   json.RawMessage `json:"data"`
 }
 
@@ -100,10 +222,13 @@ type ErrorPayload struct {
 // Global game hub instance
 var GameHubInstance = &GameHub{
 	rooms: make(map[string]*GameRoom),
+	conns: newConnCounter(),
 }
 
 var gameRepo database.GameRepository
 var gameService *business.GameService
+var gameEventLog *business.GameEventLog
+var moveLog *business.MoveLog
 
 func SetGameRepository(repo database.GameRepository) {
 	gameRepo = repo
@@ -113,6 +238,66 @@ func SetGameService(gs *business.GameService) {
 	gameService = gs
 }
 
+func SetGameEventLog(l *business.GameEventLog) {
+	gameEventLog = l
+}
+
+func SetMoveLog(l *business.MoveLog) {
+	moveLog = l
+}
+
+// SetDuplicateConnectionPolicy sets how a room handles a player connecting twice.
+// Unknown values are ignored and the default (takeover) is kept.
+func SetDuplicateConnectionPolicy(policy string) {
+	switch policy {
+	case DuplicatePolicyTakeover, DuplicatePolicyReject:
+		duplicateConnectionPolicy = policy
+	case "":
+		// Keep default
+	default:
+		log.Printf("Unknown duplicate connection policy %q, using %q", policy, duplicateConnectionPolicy)
+	}
+}
+
+// SetDefaultTimeoutStrategy sets the timeout strategy for games that don't pick one.
+// Unknown values are ignored and the default (leftmost) is kept.
+func SetDefaultTimeoutStrategy(name string) {
+	if name == "" {
+		return
+	}
+	if _, err := business.TimeoutStrategyFor(name); err != nil {
+		log.Printf("Unknown timeout strategy %q, using %q", name, business.TimeoutFlipLeftmost)
+		return
+	}
+	defaultTimeoutStrategy = name
+}
+
+// SetRequireVerifiedEmail turns on the verified email requirement for creating games
+func SetRequireVerifiedEmail(required bool) {
+	requireVerifiedEmail = required
+}
+
+// SetPreGameCountdown sets the pre-game countdown length in seconds. Zero disables it.
+func SetPreGameCountdown(seconds int) {
+	if seconds >= 0 {
+		countdownSeconds = seconds
+	}
+}
+
+// SetDisconnectGracePeriod sets how long a disconnected player keeps their turn
+func SetDisconnectGracePeriod(d time.Duration) {
+	if d > 0 {
+		disconnectGracePeriod = d
+	}
+}
+
+// SetTurnTimeout sets how long a player has to act before a move is forced. Zero disables it.
+func SetTurnTimeout(d time.Duration) {
+	if d >= 0 {
+		turnTimeout = d
+	}
+}
+
 // GetOrCreateRoom returns an existing room or creates a new one
 func (h *GameHub) GetOrCreateRoom(publicID string) *GameRoom {
 	h.mu.Lock()
@@ -122,21 +307,56 @@ func (h *GameHub) GetOrCreateRoom(publicID string) *GameRoom {
 		return room
 	}
 
+	room := newGameRoom(publicID)
+	h.rooms[publicID] = room
+	go room.Run()
+	if verifyStateOnLoad {
+		go verifyRoomState(room.ctx, publicID)
+	}
+
+	return room
+}
+
+// newGameRoom returns a room for publicID that isn't running yet
+func newGameRoom(publicID string) *GameRoom {
 	ctx, cancel := context.WithCancel(context.Background())
-	room := &GameRoom{
+	return &GameRoom{
 		publicID:   publicID,
-		clients:    make(map[*websocket.Conn]string),
+		clients:    newConcurrentMap[*websocket.Conn, string](),
+		spectators: newConcurrentMap[*websocket.Conn, string](),
 		broadcast:  make(chan GameMessage, 256),
 		register:   make(chan *gameClientRegistration),
 		unregister: make(chan *websocket.Conn),
 		ctx:        ctx,
 		cancel:     cancel,
+
+		countdownSeconds: countdownSeconds,
+		countdownTicker:  newCountdownTicker,
+		countdownDone:    make(chan int, 1),
+
+		fullState: make(map[*websocket.Conn]bool),
+
+		createdAt:      time.Now(),
+		disconnectedAt: make(map[string]time.Time),
+
+		reconnectTokens: make(map[string]reconnectGrant),
+
+		turnTimeout:   turnTimeout,
+		turnCommitted: make(chan *business.FullGameState, 16),
 	}
+}
 
-	h.rooms[publicID] = room
-	go room.Run()
+// newCountdownTicker ticks once every countdownTick
+func newCountdownTicker() (<-chan time.Time, func()) {
+	ticker := time.NewTicker(countdownTick)
+	return ticker.C, ticker.Stop
+}
 
-	return room
+// GetRoom returns the room for a game, or nil if nobody has opened it
+func (h *GameHub) GetRoom(publicID string) *GameRoom {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.rooms[publicID]
 }
 
 // CloseRoom shuts down a game room
@@ -150,23 +370,76 @@ func (h *GameHub) CloseRoom(publicID string) {
 	}
 }
 
+// closeRoomAfterGrace shuts room down once roomCloseGrace has passed. If the room was
+// closed in the meantime and its game reopened, the newer room is left alone.
+func (h *GameHub) closeRoomAfterGrace(room *GameRoom) {
+	time.AfterFunc(roomCloseGrace, func() {
+		h.closeRoomIfCurrent(room)
+	})
+}
+
+// closeRoomIfCurrent shuts down room if it is still the hub's room for its game
+func (h *GameHub) closeRoomIfCurrent(room *GameRoom) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[room.publicID] == room {
+		room.cancel()
+		delete(h.rooms, room.publicID)
+	}
+}
+
 // Run manages the game room's lifecycle
 func (r *GameRoom) Run() {
+	stuckTurnTicker := time.NewTicker(stuckTurnCheckInterval)
+	defer stuckTurnTicker.Stop()
+	defer r.armTurnTimer(nil)
+	defer func() {
+		if r.botTimer != nil {
+			r.botTimer.Stop()
+		}
+	}()
+
 	for {
 		select {
 		case <-r.ctx.Done():
 			// Clean up all connections
-			r.mu.Lock()
-			for conn := range r.clients {
-				conn.Close()
-			}
-			r.mu.Unlock()
+			r.clients.Range(func(conn *websocket.Conn, _ string) bool {
+				closeConn(conn, websocket.CloseGoingAway, "game room closed")
+				r.clients.Delete(conn)
+				presence.leave(conn)
+				return true
+			})
+			r.spectators.Range(func(conn *websocket.Conn, _ string) bool {
+				closeConn(conn, websocket.CloseGoingAway, "game room closed")
+				r.spectators.Delete(conn)
+				presence.leave(conn)
+				return true
+			})
 			return
 
 		case reg := <-r.register:
-			r.mu.Lock()
-			r.clients[reg.conn] = reg.userID
-			r.mu.Unlock()
+			if reg.fullState {
+				r.fullState[reg.conn] = true
+			}
+
+			// Spectators only watch; they take no part in turns or absence tracking
+			if reg.spectator {
+				r.spectators.Set(reg.conn, reg.userID)
+				presence.enter(reg.conn, reg.userID, r.publicID, true)
+				r.sendChatHistory(reg.conn)
+				r.sendGameState(reg.conn, spectatorViewer)
+				r.broadcastSpectatorCount()
+				continue
+			}
+
+			if !r.resolveDuplicateConnection(reg) {
+				continue
+			}
+			r.clients.Set(reg.conn, reg.userID)
+			presence.enter(reg.conn, reg.userID, r.publicID, false)
+			delete(r.disconnectedAt, reg.userID)
+			gameEventLog.Record(context.Background(), r.publicID, business.GameEventPlayerJoined, reg.userID, nil)
 
 			// Send chat history for this game
 			r.sendChatHistory(reg.conn)
@@ -178,75 +451,519 @@ func (r *GameRoom) Run() {
 			// This ensures everyone gets updated when the second player joins
 			ctx := context.Background()
 
-			// Load or initialize game state
-			var state *business.FullGameState
-			stateJSON, _, err := gameRepo.LoadGameState(ctx, r.publicID)
-
-			if err != nil {
-				// No state exists yet - check if we should initialize
-				game, err := gameRepo.GetGameByPublicID(ctx, r.publicID)
-				if err == nil && game.Status == "in_progress" {
-					// Game just started, initialize the state
-					players, err := gameRepo.GetGamePlayers(ctx, r.publicID)
-					if err == nil {
-						activePlayers := []string{}
-						for _, p := range players {
-							if p.IsActive {
-								activePlayers = append(activePlayers, p.UserID)
-							}
-						}
-
-						if len(activePlayers) == 2 {
-							newState, err := gameService.InitializeGame(ctx, r.publicID, activePlayers)
-							if err == nil {
-								// Save the initial state
-								stateJSON, _ := json.Marshal(newState)
-								if err := gameRepo.SaveGameState(ctx, r.publicID, stateJSON); err == nil {
-									state = newState
-								}
-							}
-						}
-					}
-				}
-			} else {
-				// Parse existing state
-				var parsedState business.FullGameState
-				if err := json.Unmarshal(stateJSON, &parsedState); err == nil {
-					parsedState.PublicID = r.publicID // Ensure PublicID is set
-					state = &parsedState
+			state := r.loadState(ctx)
+			if state == nil && r.countdownCancel == nil && r.readyToDeal(ctx) {
+				if r.countdownSeconds > 0 {
+					r.startCountdown()
+				} else {
+					state = r.initializeState(ctx)
 				}
 			}
 
+			// A player already dealt in is picking up a game in progress, whether or not
+			// this room was recreated since they dropped
+			if state != nil && state.Phase != business.PhaseFinished && hasSeat(state, reg.userID) {
+				r.broadcastPlayerReconnected(reg.conn, reg.userID)
+			}
+
 			broadcastGameState(r, r.publicID, state)
+			if r.turnTimer == nil {
+				r.armTurnTimer(state)
+			}
+			r.scheduleBot(state)
+			r.recoverStuckTurn(ctx)
+
+		case state := <-r.turnCommitted:
+			r.armTurnTimer(state)
+			r.scheduleBot(state)
+
+		case <-r.turnTimerC():
+			r.handleTurnTimeout(context.Background())
+
+		case <-r.botTimerC():
+			r.playBotTurn(context.Background())
+
+		case <-stuckTurnTicker.C:
+			if r.clients.Len() > 0 {
+				r.recoverStuckTurn(context.Background())
+			}
+
+		case gen := <-r.countdownDone:
+			// Ignore countdowns that were cancelled after they finished
+			if r.countdownCancel == nil || gen != r.countdownGen {
+				continue
+			}
+			r.countdownCancel = nil
+
+			ctx := context.Background()
+			state := r.loadState(ctx)
+			if state == nil {
+				state = r.initializeState(ctx)
+			}
+			broadcastGameState(r, r.publicID, state)
+			r.armTurnTimer(state)
+			r.scheduleBot(state)
 
 		case conn := <-r.unregister:
-			r.mu.Lock()
-			if userID, ok := r.clients[conn]; ok {
-				delete(r.clients, conn)
-				conn.Close()
-				r.mu.Unlock()
+			presence.leave(conn)
+			delete(r.fullState, conn)
+			if _, ok := r.spectators.Delete(conn); ok {
+				closeConn(conn, websocket.CloseNormalClosure, "")
+				r.broadcastSpectatorCount()
+				continue
+			}
+			if userID, ok := r.clients.Delete(conn); ok {
+				closeConn(conn, websocket.CloseNormalClosure, "")
 
 				// Notify other players someone left
 				r.broadcastPlayerLeft(userID)
-			} else {
-				r.mu.Unlock()
+				if !r.isConnected(userID) {
+					r.disconnectedAt[userID] = time.Now()
+					r.expireReconnectToken(userID)
+					gameEventLog.Record(context.Background(), r.publicID, business.GameEventPlayerLeft, userID, nil)
+				}
+
+				// A player leaving mid-countdown cancels the deal until everyone is back
+				if r.countdownCancel != nil {
+					r.countdownCancel()
+					r.countdownCancel = nil
+					r.broadcastCountdown(0, true)
+				}
 			}
 
 		case message := <-r.broadcast:
+			// State is private per player and must go through broadcastGameState,
+			// which redacts it for each recipient. Never fan one copy out to everyone.
+			if message.Type == "state" {
+				log.Printf("Refusing to broadcast shared state in game %s", r.publicID)
+				continue
+			}
+
 			// Broadcast to all connected clients in this room
-			r.mu.RLock()
-			for client := range r.clients {
-				if err := client.WriteJSON(message); err != nil {
+			r.clients.Range(func(client *websocket.Conn, userID string) bool {
+				if err := sendJSON(client, message); err != nil {
 					log.Printf("Error broadcasting to client in game %s: %v", r.publicID, err)
-					client.Close()
-					delete(r.clients, client)
+					closeConn(client, websocket.CloseInternalServerErr, "send failed")
+					r.clients.Delete(client)
+					return true
+				}
+				if r.fullState[client] && tableUpdates[message.Type] {
+					r.sendGameState(client, userID)
+				}
+				return true
+			})
+			r.spectators.Range(func(spectator *websocket.Conn, _ string) bool {
+				if err := sendJSON(spectator, message); err != nil {
+					log.Printf("Error broadcasting to spectator in game %s: %v", r.publicID, err)
+					closeConn(spectator, websocket.CloseInternalServerErr, "send failed")
+					r.spectators.Delete(spectator)
+					return true
 				}
+				if r.fullState[spectator] && tableUpdates[message.Type] {
+					r.sendGameState(spectator, spectatorViewer)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// isConnected reports whether the user has at least one open connection in the room
+func (r *GameRoom) isConnected(userID string) bool {
+	connected := false
+	r.clients.Range(func(_ *websocket.Conn, id string) bool {
+		if id == userID {
+			connected = true
+			return false
+		}
+		return true
+	})
+	return connected
+}
+
+// absentSince returns when the user was last seen. Players who never connected
+// to this room are treated as absent since the room was created.
+func (r *GameRoom) absentSince(userID string) time.Time {
+	if t, ok := r.disconnectedAt[userID]; ok {
+		return t
+	}
+	return r.createdAt
+}
+
+// recoverStuckTurn discards a drawn card left behind by a current player who has
+// been gone longer than the grace period, so the rest of the table can continue.
+// Must only be called from Run.
+func (r *GameRoom) recoverStuckTurn(ctx context.Context) {
+	state, version, err := gameService.LoadState(ctx, r.publicID)
+	if err != nil {
+		return
+	}
+
+	if state.DrawnCard == nil || state.CurrentTurnIdx >= len(state.Players) {
+		return
+	}
+
+	currentUserID := state.Players[state.CurrentTurnIdx].UserID
+	if r.isConnected(currentUserID) || time.Since(r.absentSince(currentUserID)) < disconnectGracePeriod {
+		return
+	}
+
+	if err := gameService.ResolveStuckDraw(state); err != nil {
+		log.Printf("Could not recover stuck turn in game %s: %v", r.publicID, err)
+		return
+	}
+
+	if err := gameService.SaveState(ctx, state, version); err != nil {
+		log.Printf("Failed to save recovered state for game %s: %v", r.publicID, err)
+		return
+	}
+	moveLog.Record(ctx, r.publicID, currentUserID, business.ActionDiscardStuck, nil, state)
+
+	log.Printf("Recovered stuck turn in game %s: discarded drawn card for absent player %s", r.publicID, currentUserID)
+
+	if state = finishIfOver(ctx, r, state); state == nil {
+		return
+	}
+	broadcastGameState(r, r.publicID, state)
+	r.armTurnTimer(state)
+	r.scheduleBot(state)
+}
+
+// turnTimerC returns the running turn timer's channel, or nil (blocks forever) if none
+func (r *GameRoom) turnTimerC() <-chan time.Time {
+	if r.turnTimer == nil {
+		return nil
+	}
+	return r.turnTimer.C
+}
+
+// armTurnTimer restarts the turn timer for state, or stops it if state is nil or
+// not in a turn-based phase. Must only be called from Run.
+func (r *GameRoom) armTurnTimer(state *business.FullGameState) {
+	if r.turnTimer != nil {
+		r.turnTimer.Stop()
+		r.turnTimer = nil
+	}
+
+	if r.turnTimeout <= 0 || state == nil {
+		return
+	}
+	if state.Phase != business.PhaseMainGame && state.Phase != business.PhaseFinalRound {
+		return
+	}
+
+	r.turnTimer = time.NewTimer(r.turnTimeout)
+	r.turnVersion = state.Version
+}
+
+// notifyTurnCommitted tells Run a move was saved so it can restart the turn timer
+func (r *GameRoom) notifyTurnCommitted(state *business.FullGameState) {
+	select {
+	case r.turnCommitted <- state:
+	case <-r.ctx.Done():
+	}
+}
+
+// TurnChangedPayload is sent whenever the turn passes or the phase changes
+type TurnChangedPayload struct {
+	CurrentTurnIdx  int    `json:"currentTurnIdx"`
+	UserID          string `json:"userId"` // Whose turn it now is
+	Phase           string `json:"phase"`
+	FinalRoundTurns int    `json:"finalRoundTurns"`
+}
+
+// announceTurn sends "turn_changed" if the turn or phase differs from the last one announced
+func (r *GameRoom) announceTurn(state *business.FullGameState) {
+	if state == nil || state.CurrentTurnIdx >= len(state.Players) {
+		return
+	}
+
+	marker := turnMarker{round: state.Round, phase: state.Phase, turnIdx: state.CurrentTurnIdx}
+	r.turnMu.Lock()
+	changed := marker != r.announcedTurn
+	r.announcedTurn = marker
+	r.turnMu.Unlock()
+	if !changed {
+		return
+	}
+
+	payload, _ := json.Marshal(TurnChangedPayload{
+		CurrentTurnIdx:  state.CurrentTurnIdx,
+		UserID:          state.Players[state.CurrentTurnIdx].UserID,
+		Phase:           string(state.Phase),
+		FinalRoundTurns: state.FinalRoundTurns,
+	})
+	r.broadcast <- GameMessage{
+		Type:    "turn_changed",
+		Payload: payload,
+	}
+}
+
+// TurnTimeoutPayload names the player whose move was forced
+type TurnTimeoutPayload struct {
+	UserID string `json:"userId"`
+}
+
+// handleTurnTimeout forces a move for a player who ran out of time.
+// Must only be called from Run.
+func (r *GameRoom) handleTurnTimeout(ctx context.Context) {
+	r.turnTimer = nil
+
+	// Don't play out a game nobody is watching; the next player to join re-arms the timer
+	if r.clients.Len() == 0 {
+		return
+	}
+
+	state, version, err := gameService.LoadState(ctx, r.publicID)
+	if err != nil {
+		log.Printf("Failed to load state for turn timeout in game %s: %v", r.publicID, err)
+		r.turnTimer = time.NewTimer(r.turnTimeout)
+		return
+	}
+
+	// Someone moved after the timer was armed; their commit will re-arm it
+	if version != r.turnVersion {
+		r.armTurnTimer(state)
+		return
+	}
+
+	userID, cardIndex, err := gameService.ForceTimeoutMove(state)
+	if err != nil {
+		// Leave a drawn card to stuck turn recovery, and keep the clock running so the
+		// turn is tried again rather than waiting forever
+		log.Printf("Could not force move in game %s: %v", r.publicID, err)
+		r.recoverStuckTurn(ctx)
+		if r.turnTimer == nil {
+			r.armTurnTimer(r.loadState(ctx))
+		}
+		return
+	}
+
+	if err := gameService.SaveState(ctx, state, version); err != nil {
+		log.Printf("Failed to save forced move for game %s: %v", r.publicID, err)
+		r.armTurnTimer(r.loadState(ctx))
+		return
+	}
+
+	log.Printf("Turn timed out in game %s for player %s", r.publicID, userID)
+	// Logged so a random flip replays as the card that was really flipped
+	var loggedIndex *int
+	if cardIndex != -1 {
+		loggedIndex = &cardIndex
+	}
+	moveLog.Record(ctx, r.publicID, userID, business.ActionTimeout, loggedIndex, state)
+	gameEventLog.Record(ctx, r.publicID, business.GameEventTurnTaken, userID, map[string]string{"action": "timeout"})
+
+	payload, _ := json.Marshal(TurnTimeoutPayload{UserID: userID})
+	r.broadcast <- GameMessage{
+		Type:    "turn_timeout",
+		Payload: payload,
+	}
+
+	if state = finishIfOver(ctx, r, state); state == nil {
+		return
+	}
+	broadcastGameState(r, r.publicID, state)
+	r.armTurnTimer(state)
+	r.scheduleBot(state)
+}
+
+// loadState returns the persisted game state, or nil if none exists yet
+func (r *GameRoom) loadState(ctx context.Context) *business.FullGameState {
+	state, _, err := gameService.LoadState(ctx, r.publicID)
+	if err != nil {
+		if !errors.Is(err, database.ErrStateNotFound) {
+			log.Printf("Error loading game state: %v", err)
+		}
+		return nil
+	}
+	return state
+}
+
+// readyToDeal reports whether the game has started but has no state yet
+func (r *GameRoom) readyToDeal(ctx context.Context) bool {
+	game, err := gameRepo.GetGameByPublicID(ctx, r.publicID)
+	if err != nil || game.Status != "in_progress" {
+		return false
+	}
+
+	activePlayers, err := gameRepo.GetActivePlayerIDs(ctx, r.publicID)
+	return err == nil && len(activePlayers) == game.MaxPlayers
+}
+
+// initializeState deals a new game and saves it. Returns nil on failure.
+func (r *GameRoom) initializeState(ctx context.Context) *business.FullGameState {
+	state, created, err := gameService.LoadOrInitState(ctx, r.publicID)
+	if err != nil {
+		log.Printf("Error initializing game: %v", err)
+		return nil
+	}
+	if created {
+		recordRoundStarted(ctx, r.publicID, state)
+	}
+	return state
+}
+
+// announceGameStarted tells anyone already in the room that a game dealt outside it
+// has begun, and pushes each of them their view of the board. Every path that starts
+// a game calls it once, so it also sends the game_started webhook.
+func announceGameStarted(ctx context.Context, publicID string) {
+	state, _, err := gameService.LoadState(ctx, publicID)
+	if err != nil {
+		log.Printf("Error loading started game %s: %v", publicID, err)
+		return
+	}
+	recordRoundStarted(ctx, publicID, state)
+	playerIDs := make([]string, 0, len(state.Players))
+	for _, player := range state.Players {
+		playerIDs = append(playerIDs, player.UserID)
+	}
+	webhookService.Emit(WebhookGameStarted, map[string]interface{}{
+		"publicId":  publicID,
+		"playerIds": playerIDs,
+	})
+	notifyGameUpdated(ctx, publicID, "in_progress", nil)
+	postSystemMessage(ctx, publicID, "The game has started")
+
+	room := GameHubInstance.GetRoom(publicID)
+	if room == nil {
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]string{"publicId": publicID})
+	select {
+	case room.broadcast <- GameMessage{Type: "game_started", Payload: payload}:
+	case <-room.ctx.Done():
+		return
+	}
+	broadcastGameState(room, publicID, state)
+	room.notifyTurnCommitted(state)
+}
+
+// NotifyGameAbandoned records that a stalled game was abandoned, tells anyone still in
+// its room, and closes the room after the usual grace period
+func NotifyGameAbandoned(publicID string) {
+	ctx := context.Background()
+	gameEventLog.Record(ctx, publicID, business.GameEventGameFinished, "", map[string]string{"reason": "abandoned"})
+	notifyGameUpdated(ctx, publicID, "abandoned", nil)
+	postSystemMessage(ctx, publicID, "The game was abandoned")
+
+	room := GameHubInstance.GetRoom(publicID)
+	if room == nil {
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]string{"publicId": publicID})
+	select {
+	case room.broadcast <- GameMessage{Type: "game_abandoned", Payload: payload}:
+	case <-room.ctx.Done():
+		return
+	}
+
+	GameHubInstance.closeRoomAfterGrace(room)
+}
+
+// notifyGameUpdated tells each of a game's players in the lobby that its status changed,
+// so they can refresh that game without reloading the list. players may be nil.
+func notifyGameUpdated(ctx context.Context, publicID, status string, players []*database.GamePlayer) {
+	if players == nil {
+		var err error
+		players, err = gameRepo.GetGamePlayers(ctx, publicID)
+		if err != nil {
+			log.Printf("Failed to get players for game %s: %v", publicID, err)
+			return
+		}
+	}
+
+	msg := LobbyMessage{
+		Type:    "game_updated",
+		Payload: GameUpdatedPayload{PublicID: publicID, Status: status},
+	}
+	for _, player := range players {
+		if player.IsActive {
+			Hub.SendNotificationToUser(player.UserID, msg)
+		}
+	}
+}
+
+// recordRoundStarted adds a new deal to the game's activity feed
+func recordRoundStarted(ctx context.Context, publicID string, state *business.FullGameState) {
+	gameEventLog.Record(ctx, publicID, business.GameEventRoundStarted, "", map[string]string{
+		"round": strconv.Itoa(state.Round),
+	})
+}
+
+// startCountdown broadcasts countdown ticks and then signals Run to deal.
+// Must only be called from Run.
+func (r *GameRoom) startCountdown() {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.countdownGen++
+	r.countdownCancel = cancel
+	gen := r.countdownGen
+
+	go func() {
+		ticks, stop := r.countdownTicker()
+		defer stop()
+
+		for remaining := r.countdownSeconds; remaining > 0; remaining-- {
+			r.broadcastCountdown(remaining, false)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticks:
 			}
-			r.mu.RUnlock()
 		}
-	}
+
+		select {
+		case r.countdownDone <- gen:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// CountdownPayload is sent on each countdown tick before the first deal
+type CountdownPayload struct {
+	Remaining int  `json:"remaining"`
+	Cancelled bool `json:"cancelled"`
+}
+
+func (r *GameRoom) broadcastCountdown(remaining int, cancelled bool) {
+	payload, _ := json.Marshal(CountdownPayload{Remaining: remaining, Cancelled: cancelled})
+	r.broadcast <- GameMessage{
+		Type:    "countdown",
+		Payload: payload,
+	}
+}
+
+// resolveDuplicateConnection applies the duplicate connection policy when the
+// registering user already has a connection in this room. Returns false if the
+// new connection was rejected. Only called from Run, which owns registration.
+func (r *GameRoom) resolveDuplicateConnection(reg *gameClientRegistration) bool {
+	accepted := true
+	r.clients.Range(func(conn *websocket.Conn, userID string) bool {
+		if userID != reg.userID {
+			return true
+		}
+
+		if duplicateConnectionPolicy == DuplicatePolicyReject {
+			closeConn(reg.conn, websocket.ClosePolicyViolation, "already connected elsewhere")
+			log.Printf("Rejected duplicate connection for user %s in game %s", reg.userID, r.publicID)
+			accepted = false
+			return false
+		}
+
+		// Takeover: drop the older connection so only the newest one can act
+		closeConn(conn, websocket.ClosePolicyViolation, "connected from another tab")
+		r.clients.Delete(conn)
+		log.Printf("Replaced older connection for user %s in game %s", reg.userID, r.publicID)
+		return true
+	})
+
+	return accepted
 }
 
+// sendGameState sends the viewer's snapshot to a single connection. Before the deal
+// this is the waiting state; dealing itself is left to Run.
 func (r *GameRoom) sendGameState(conn *websocket.Conn, userID string) {
 	if gameRepo == nil || gameService == nil {
 		return
@@ -265,66 +982,21 @@ func (r *GameRoom) sendGameState(conn *websocket.Conn, userID string) {
 		return
 	}
 
-	// Try to load existing game state
-	stateJSON, _, err := gameRepo.LoadGameState(ctx, r.publicID)
-	var state *business.FullGameState
-
-	if err != nil {
-		// No state exists yet - check if we should initialize
-		if game.Status == "in_progress" {
-			// Game just started, initialize the state
-			activePlayers := []string{}
-			for _, p := range players {
-				if p.IsActive {
-					activePlayers = append(activePlayers, p.UserID)
-				}
-			}
-
-			if len(activePlayers) == 2 {
-				newState, err := gameService.InitializeGame(ctx, r.publicID, activePlayers)
-				if err != nil {
-					log.Printf("Error initializing game: %v", err)
-					return
-				}
-
-				// Save the initial state
-				stateJSON, _ := json.Marshal(newState)
-				if err := gameRepo.SaveGameState(ctx, r.publicID, stateJSON); err != nil {
-					log.Printf("Error saving initial state: %v", err)
-					return
-				}
-
-				state = newState
-			} else {
-				log.Printf("Cannot initialize game: need 2 active players, have %d", len(activePlayers))
-				// Send waiting state without game state
-				state = nil
-			}
-		} else {
-			// Game not started yet, send waiting state
-			log.Printf("Game in waiting_for_players status, sending lobby state")
-			state = nil
-		}
-	} else {
-		// Parse existing state
-		var parsedState business.FullGameState
-		if err := json.Unmarshal(stateJSON, &parsedState); err != nil {
-			log.Printf("Error parsing game state: %v", err)
-			return
-		}
-		parsedState.PublicID = r.publicID // Ensure PublicID is set
-		state = &parsedState
-	}
+	// nil until the game has been dealt
+	state := r.loadState(ctx)
 
 	// Build and send personalized state
 	statePayload := buildGameStatePayload(game, state, players, userID)
+	if userID != spectatorViewer {
+		statePayload.ReconnectToken = r.reconnectTokenFor(userID)
+	}
 	payload, _ := json.Marshal(statePayload)
 	msg := GameMessage{
 		Type:    "state",
 		Payload: payload,
 	}
 
-	if err := conn.WriteJSON(msg); err != nil {
+	if err := sendJSON(conn, msg); err != nil {
 		log.Printf("Error sending game state: %v", err)
 	}
 }
@@ -344,16 +1016,19 @@ func (r *GameRoom) sendChatHistory(conn *websocket.Conn) {
 
 	for _, msg := range messages {
 		chatPayload := ChatPayload{
-			Message:  msg.MessageText,
-			Username: msg.SenderUsername,
-			Time:     msg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:        msg.ChatMessageID,
+			Message:   msg.MessageText,
+			Username:  msg.SenderUsername,
+			Time:      msg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			Reactions: msg.Reactions,
+			System:    msg.System,
 		}
 		payload, _ := json.Marshal(chatPayload)
 		gameMsg := GameMessage{
 			Type:    "chat",
 			Payload: payload,
 		}
-		if err := conn.WriteJSON(gameMsg); err != nil {
+		if err := sendJSON(conn, gameMsg); err != nil {
 			log.Printf("Error sending chat history: %v", err)
 		}
 	}
@@ -368,6 +1043,45 @@ func (r *GameRoom) broadcastPlayerJoined(userID string) {
 	r.broadcast <- msg
 }
 
+// broadcastPlayerReconnected tells everyone except the rejoining connection that a
+// player is back in a game already under way
+func (r *GameRoom) broadcastPlayerReconnected(conn *websocket.Conn, userID string) {
+	payload, _ := json.Marshal(map[string]string{"userId": userID})
+	msg := GameMessage{
+		Type:    "player_reconnected",
+		Payload: payload,
+	}
+	r.clients.Range(func(client *websocket.Conn, _ string) bool {
+		if client == conn {
+			return true
+		}
+		if err := sendJSON(client, msg); err != nil {
+			log.Printf("Error sending reconnect notice in game %s: %v", r.publicID, err)
+		}
+		return true
+	})
+}
+
+// hasSeat reports whether userID was dealt into the game
+func hasSeat(state *business.FullGameState, userID string) bool {
+	for _, player := range state.Players {
+		if player.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastSpectatorCount tells the room how many people are watching
+func (r *GameRoom) broadcastSpectatorCount() {
+	payload, _ := json.Marshal(map[string]int{"count": r.spectators.Len()})
+	msg := GameMessage{
+		Type:    "spectator_count",
+		Payload: payload,
+	}
+	r.broadcast <- msg
+}
+
 func (r *GameRoom) broadcastPlayerLeft(userID string) {
 	payload, _ := json.Marshal(map[string]string{"userId": userID})
 	msg := GameMessage{
@@ -377,17 +1091,78 @@ func (r *GameRoom) broadcastPlayerLeft(userID string) {
 	r.broadcast <- msg
 }
 
-// GameWebSocketHandler handles WebSocket connections for a specific game
-func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+// authorizeGameConnection checks the session user may open the game socket, as a player
+// or, with ?spectate=true, as a spectator. It writes the HTTP error itself when not.
+func authorizeGameConnection(w http.ResponseWriter, r *http.Request, publicID string) (userID, username string, spectating, ok bool) {
 	ctx := r.Context()
 
 	// Get user from session
-	userID, ok := ctx.Value(userIDKey).(string)
-	if !ok || userID == "" {
+	userID, _ = ctx.Value(userIDKey).(string)
+	if userID == "" {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		return "", "", false, false
+	}
+
+	// Validate user is in the game
+	inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
+	if err != nil {
+		log.Printf("Error validating user in game: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", "", false, false
+	}
+	if !inGame {
+		// Tell non-players whether the game is closed to spectators
+		switch err := gameService.CanSpectate(ctx, publicID); err {
+		case nil:
+		case business.ErrGameNotFound:
+			http.Error(w, "Game not found", http.StatusNotFound)
+			return "", "", false, false
+		case business.ErrSpectatingDisabled:
+			http.Error(w, "Spectators are not allowed in this game", http.StatusForbidden)
+			return "", "", false, false
+		default:
+			log.Printf("Error checking spectator access: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return "", "", false, false
+		}
+		if r.URL.Query().Get("spectate") != "true" {
+			http.Error(w, "You are not a player in this game", http.StatusForbidden)
+			return "", "", false, false
+		}
+		game, err := gameRepo.GetGameByPublicID(ctx, publicID)
+		if err != nil || game.Status != "in_progress" {
+			http.Error(w, "Only games in progress can be watched", http.StatusForbidden)
+			return "", "", false, false
+		}
+		spectating = true
+	}
+
+	// Get username
+	user, err := userService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", "", false, false
 	}
 
+	return userID, user.Username, spectating, true
+}
+
+// parseFullStateParam reads the full_state connect parameter, which is absent or a
+// boolean. Reduced-motion clients send full_state=true so that every table update
+// (see tableUpdates) is followed by their complete state. ok is false if it is unreadable.
+func parseFullStateParam(value string) (fullState bool, ok bool) {
+	if value == "" {
+		return false, true
+	}
+	fullState, err := strconv.ParseBool(value)
+	return fullState, err == nil
+}
+
+// GameWebSocketHandler handles WebSocket connections for a specific game
+func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	// Extract publicID from URL path - expecting /api/ws/game/{publicId}
 	// Parse public ID from path
 	path := r.URL.Path
@@ -399,29 +1174,40 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate user is in the game
 	if gameService == nil || gameRepo == nil {
 		http.Error(w, "Service not initialized", http.StatusInternalServerError)
 		return
 	}
 
-	inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
-	if err != nil {
-		log.Printf("Error validating user in game: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	fullState, ok := parseFullStateParam(r.URL.Query().Get("full_state"))
+	if !ok {
+		http.Error(w, "Invalid full_state", http.StatusBadRequest)
 		return
 	}
-	if !inGame {
-		http.Error(w, "You are not a player in this game", http.StatusForbidden)
-		return
+
+	// A reconnect token from a recent connection to the live room stands in for the
+	// session and membership checks
+	var userID, username string
+	resumed := false
+	if token := r.URL.Query().Get("reconnectToken"); token != "" {
+		if room := GameHubInstance.GetRoom(publicID); room != nil {
+			userID, username, resumed = room.redeemReconnectToken(token)
+		}
 	}
 
-	// Get username
-	user, err := userService.GetUserByID(ctx, userID)
-	if err != nil {
-		log.Printf("Error getting user: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	spectating := false
+	if !resumed {
+		var ok bool
+		userID, username, spectating, ok = authorizeGameConnection(w, r, publicID)
+		if !ok {
+			return
+		}
+	}
+
+	// Spectators get the view built for no player in particular
+	viewerID := userID
+	if spectating {
+		viewerID = spectatorViewer
 	}
 
 	// Upgrade to WebSocket
@@ -430,48 +1216,55 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
+	configureCompression(conn)
 
 	// Get or create room for this game
 	room := GameHubInstance.GetOrCreateRoom(publicID)
 
-	// Register client
-	room.register <- &gameClientRegistration{
-		conn:   conn,
-		userID: userID,
+	// A player's new connection to a room replaces their old one there, so it may
+	// go over the cap briefly; anything else must fit under it
+	replacing := !spectating && duplicateConnectionPolicy == DuplicatePolicyTakeover && room.isConnected(userID)
+	if replacing {
+		GameHubInstance.conns.add(userID)
+	} else if !GameHubInstance.conns.acquire(userID) {
+		rejectTooManyConnections(conn, userID)
+		return
+	}
+	defer GameHubInstance.conns.release(userID)
+
+	// From here on every write goes through the pump
+	stopWritePump := startWritePump(conn)
+	defer stopWritePump()
+	if !spectating {
+		room.issueReconnectToken(userID, username)
+	}
+
+	// Register client. A room closed in the meantime has already dropped its clients.
+	select {
+	case room.register <- &gameClientRegistration{conn: conn, userID: userID, spectator: spectating, fullState: fullState}:
+	case <-room.ctx.Done():
+		closeConn(conn, websocket.CloseGoingAway, "game room closed")
+		return
 	}
 
 	defer func() {
-		room.unregister <- conn
+		select {
+		case room.unregister <- conn:
+		case <-room.ctx.Done():
+		}
 	}()
 
 	// Configure connection for heartbeat
-	conn.SetReadLimit(maxMessageSize)
-	conn.SetReadDeadline(time.Now().Add(pongWait))
-	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
+	stopHeartbeat := startHeartbeat(conn)
+	defer stopHeartbeat()
 
-	// Start ping ticker
-	ticker := time.NewTicker(pingPeriod)
-	defer ticker.Stop()
-
-	done := make(chan struct{})
-	defer close(done)
+	typingID := typingKey("game:"+publicID, userID)
+	announceTyping := func(isTyping bool) {
+		room.sendTyping(userID, username, isTyping)
+	}
+	defer typing.clear(typingID, announceTyping)
 
-	// Start goroutine to send pings
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					return
-				}
-			}
-		}
-	}()
+	limiter := newChatLimiter()
 
 	// Listen for messages from client
 	for {
@@ -494,16 +1287,22 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			// Validate message length (max 500 characters)
-			if len(chatPayload.Message) > 500 {
-				log.Printf("Message too long from user %s in game %s: %d characters", userID, publicID, len(chatPayload.Message))
+			text, err := validateChatMessage(chatPayload.Message)
+			if err != nil {
+				sendJSON(conn, messageRejectedGameMessage(err))
+				continue
+			}
+			typing.clear(typingID, announceTyping)
+
+			if ok, retryAfter := limiter.allow(); !ok {
+				sendJSON(conn, rateLimitedGameMessage(retryAfter))
 				continue
 			}
 
 			// Save message to database with game scope
 			if chatRepo != nil {
 				scope := fmt.Sprintf("game:%s", publicID)
-				savedMsg, err := chatRepo.SaveMessage(ctx, userID, scope, chatPayload.Message)
+				savedMsg, err := chatRepo.SaveMessage(ctx, userID, scope, text)
 				if err != nil {
 					log.Printf("Error saving game chat message: %v", err)
 					continue
@@ -511,8 +1310,9 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 
 				// Broadcast to room
 				broadcastPayload := ChatPayload{
+					ID:       savedMsg.ChatMessageID,
 					Message:  savedMsg.MessageText,
-					Username: user.Username,
+					Username: username,
 					Time:     savedMsg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 				}
 				payload, _ := json.Marshal(broadcastPayload)
@@ -520,9 +1320,49 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 					Type:    "chat",
 					Payload: payload,
 				}
+				go notifyUnreadInGame(context.Background(), room, userID)
+			}
+
+		case "typing":
+			var typingPayload TypingPayload
+			if err := json.Unmarshal(msg.Payload, &typingPayload); err != nil {
+				log.Printf("Error unmarshaling typing payload: %v", err)
+				continue
+			}
+			typing.update(typingID, typingPayload.Typing, announceTyping)
+
+		case "reaction":
+			var reaction ReactionRequest
+			if err := json.Unmarshal(msg.Payload, &reaction); err != nil {
+				log.Printf("Error unmarshaling reaction payload: %v", err)
+				continue
+			}
+			if chatRepo == nil {
+				continue
+			}
+			if ok, retryAfter := limiter.allow(); !ok {
+				sendJSON(conn, rateLimitedGameMessage(retryAfter))
+				continue
+			}
+			// Only this game's messages can be reacted to from its room
+			canSee := func(scope string) bool { return scope == "game:"+publicID }
+			err := toggleReaction(ctx, userID, reaction, canSee)
+			if isReactionRejection(err) {
+				sendJSON(conn, messageRejectedGameMessage(err))
+			} else if err != nil {
+				log.Printf("Error toggling reaction: %v", err)
 			}
 
+		case "sync":
+			// Client asked for a fresh snapshot, e.g. after waking from sleep
+			room.sendGameState(conn, viewerID)
+
 		case "action":
+			if spectating {
+				sendError(conn, "Spectators cannot take actions")
+				continue
+			}
+
 			// Handle game actions
 			var actionPayload ActionPayload
 			if err := json.Unmarshal(msg.Payload, &actionPayload); err != nil {
@@ -531,106 +1371,221 @@ func GameWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			// Load current game state
-			stateJSON, version, err := gameRepo.LoadGameState(context.Background(), publicID)
-			if err != nil {
-				log.Printf("Failed to load game state: %v", err)
-				sendError(conn, "Failed to load game state")
+			if actionPayload.Action == "resign" {
+				resign(room, userID, conn)
 				continue
 			}
 
-			var state business.FullGameState
-			if err := json.Unmarshal(stateJSON, &state); err != nil {
-				log.Printf("Failed to unmarshal game state: %v", err)
-				sendError(conn, "Failed to parse game state")
+			state, failure := applyActionWithRetry(publicID, userID, actionPayload)
+			if failure != "" {
+				sendError(conn, failure)
 				continue
 			}
-			state.PublicID = publicID // Ensure PublicID is set
 
-			// Execute action based on type
-			var actionErr error
-			switch actionPayload.Action {
-			case "initial_flip":
-				var data CardIndexData
-				if err := json.Unmarshal(actionPayload.Data, &data); err != nil {
-					sendError(conn, "Invalid card index")
-					continue
-				}
-				actionErr = gameService.InitialFlipCard(&state, userID, data.Index)
+			gameEventLog.Record(ctx, publicID, business.GameEventTurnTaken, userID, map[string]string{
+				"action": actionPayload.Action,
+			})
 
-			case "draw_deck":
-				actionErr = gameService.DrawFromDeck(&state, userID)
+			// Check if game is finished
+			if state = finishIfOver(context.Background(), room, state); state == nil {
+				continue
+			}
 
-			case "draw_discard":
-				actionErr = gameService.DrawFromDiscard(&state, userID)
+			// Broadcast updated state to all players
+			broadcastGameState(room, publicID, state)
+			room.notifyTurnCommitted(state)
 
-			case "swap_card":
-				var data CardIndexData
-				if err := json.Unmarshal(actionPayload.Data, &data); err != nil {
-					sendError(conn, "Invalid card index")
-					continue
-				}
-				actionErr = gameService.SwapCard(&state, userID, data.Index)
+		default:
+			log.Printf("Unknown message type: %s", msg.Type)
+		}
+	}
+}
 
-			case "discard_flip":
-				var data CardIndexData
-				if err := json.Unmarshal(actionPayload.Data, &data); err != nil {
-					sendError(conn, "Invalid card index")
-					continue
-				}
-				actionErr = gameService.DiscardAndFlip(&state, userID, data.Index)
+// applyActionWithRetry loads the latest state, applies the action and saves it with
+// optimistic locking. A version conflict reloads and retries once. On failure the
+// returned message is meant for the acting client only.
+func applyActionWithRetry(publicID, userID string, action ActionPayload) (*business.FullGameState, string) {
+	ctx := context.Background()
 
-			default:
-				sendError(conn, fmt.Sprintf("Unknown action: %s", actionPayload.Action))
-				continue
-			}
+	for attempt := 1; ; attempt++ {
+		state, version, err := gameService.LoadState(ctx, publicID)
+		if err != nil {
+			log.Printf("Failed to load game state: %v", err)
+			return nil, "Failed to load game state"
+		}
 
-			// Handle action errors
-			if actionErr != nil {
-				log.Printf("Action error for user %s: %v", userID, actionErr)
-				sendError(conn, actionErr.Error())
-				continue
-			}
+		cardIndex, err := applyAction(state, userID, action)
+		if err != nil {
+			log.Printf("Action error for user %s: %v", userID, err)
+			return nil, err.Error()
+		}
 
-			// Save updated state with optimistic locking
-			updatedStateJSON, err := json.Marshal(state)
-			if err != nil {
-				log.Printf("Failed to marshal updated state: %v", err)
-				sendError(conn, "Failed to save game state")
-				continue
-			}
+		err = gameService.SaveState(ctx, state, version)
+		if err == nil {
+			moveLog.Record(ctx, publicID, userID, action.Action, cardIndex, state)
+			return state, ""
+		}
+		if !errors.Is(err, database.ErrVersionConflict) {
+			log.Printf("Failed to update game state: %v", err)
+			return nil, "Failed to save game state"
+		}
+		if attempt == 2 {
+			log.Printf("Version conflict in game %s persisted after retry", publicID)
+			return nil, "Game state changed, please try again"
+		}
+	}
+}
 
-			err = gameRepo.UpdateGameState(context.Background(), publicID, updatedStateJSON, version)
-			if err != nil {
-				log.Printf("Failed to update game state: %v", err)
-				sendError(conn, "Failed to save game state (version conflict)")
-				continue
-			}
+// resign ends the game with userID forfeiting and tells the room. The finished state
+// is saved and logged before the result is recorded, so a lost write can't leave a
+// result behind that the saved game and its replay don't show. A version conflict
+// reloads and tries again once.
+func resign(room *GameRoom, userID string, conn *websocket.Conn) {
+	ctx := context.Background()
 
-			// Check if game is finished
-			if state.Phase == business.PhaseFinished {
-				winnerUserID, err := gameService.FinishGame(context.Background(), &state)
-				if err != nil {
-					log.Printf("Failed to finish game: %v", err)
-				} else {
-					log.Printf("Game %s finished, winner: %s", publicID, winnerUserID)
+	for attempt := 1; ; attempt++ {
+		state, version, err := gameService.LoadState(ctx, room.publicID)
+		if err != nil {
+			log.Printf("Failed to load game state: %v", err)
+			sendError(conn, "Failed to load game state")
+			return
+		}
 
-					// Save state again after flipping remaining cards
-					finalStateJSON, _ := json.Marshal(state)
-					gameRepo.UpdateGameState(context.Background(), publicID, finalStateJSON, version+1)
+		winnerUserIDs, err := gameService.ResignGame(state, userID)
+		if err != nil {
+			log.Printf("Resign error for user %s: %v", userID, err)
+			sendError(conn, err.Error())
+			return
+		}
 
-					// Broadcast game end notification
-					broadcastGameEnd(room, publicID, &state, winnerUserID)
-				}
+		err = gameService.SaveState(ctx, state, version)
+		if err == nil {
+			moveLog.Record(ctx, room.publicID, userID, business.ActionResign, nil, state)
+			if err := gameService.RecordResult(ctx, state, winnerUserIDs); err != nil {
+				log.Printf("Failed to record resignation in game %s: %v", room.publicID, err)
+				sendError(conn, "Failed to record the result")
+				return
 			}
 
-			// Broadcast updated state to all players
-			broadcastGameState(room, publicID, &state)
+			log.Printf("Player %s resigned game %s, winners: %v", userID, room.publicID, winnerUserIDs)
 
-		default:
-			log.Printf("Unknown message type: %s", msg.Type)
+			broadcastGameEnd(room, room.publicID, state, winnerUserIDs, gameOverResignation)
+			broadcastGameState(room, room.publicID, state)
+			room.notifyTurnCommitted(state)
+			return
+		}
+		if !errors.Is(err, database.ErrVersionConflict) {
+			log.Printf("Failed to save state after resignation in game %s: %v", room.publicID, err)
+			sendError(conn, "Failed to save game state")
+			return
+		}
+		if attempt == 2 {
+			log.Printf("Version conflict resigning game %s persisted after retry", room.publicID)
+			sendError(conn, "Game state changed, please try again")
+			return
+		}
+	}
+}
+
+// errBadCardIndex is returned by applyAction when an action's data can't be decoded
+var errBadCardIndex = errors.New("Invalid card index")
+
+// applyAction decodes a client action and applies it to the state. Returns the card
+// index the action targeted, or nil if it doesn't target a card.
+func applyAction(state *business.FullGameState, userID string, action ActionPayload) (*int, error) {
+	// Server-driven moves can't be requested by clients
+	if business.IsServerAction(action.Action) {
+		return nil, fmt.Errorf("Unknown action: %s", action.Action)
+	}
+
+	var cardIndex *int
+	if business.ActionNeedsCardIndex(action.Action) {
+		var data CardIndexData
+		if err := json.Unmarshal(action.Data, &data); err != nil {
+			return nil, errBadCardIndex
+		}
+		cardIndex = &data.Index
+	}
+
+	index := 0
+	if cardIndex != nil {
+		index = *cardIndex
+	}
+
+	if err := gameService.ApplyAction(state, userID, action.Action, index); err != nil {
+		if err == business.ErrUnknownAction {
+			return nil, fmt.Errorf("Unknown action: %s", action.Action)
+		}
+		return nil, err
+	}
+	return cardIndex, nil
+}
+
+// RoundEndPayload is sent when a deal ends but the match continues
+type RoundEndPayload struct {
+	Round            int            `json:"round"` // The deal that just ended
+	CumulativeScores map[string]int `json:"cumulativeScores"`
+}
+
+// finishIfOver scores a deal that just reached the finished phase, either closing out
+// the game or, in match play, starting the next deal. The outcome is saved before
+// anything is announced. A version conflict reloads and tries again, which leaves
+// alone a deal that another writer already finished. Returns the state to broadcast,
+// or nil if the outcome couldn't be saved.
+func finishIfOver(ctx context.Context, room *GameRoom, state *business.FullGameState) *business.FullGameState {
+	for attempt := 1; state.Phase == business.PhaseFinished; attempt++ {
+		version := state.Version
+		winnerUserIDs, err := gameService.FinishGame(state)
+		if err != nil {
+			log.Printf("Failed to finish game %s: %v", room.publicID, err)
+			return nil
+		}
+
+		err = gameService.SaveState(ctx, state, version)
+		if err == nil {
+			announceFinishedDeal(ctx, room, state, winnerUserIDs)
+			return state
 		}
+		if !errors.Is(err, database.ErrVersionConflict) || attempt == 2 {
+			log.Printf("Failed to save finished deal in game %s: %v", room.publicID, err)
+			return nil
+		}
+
+		state, _, err = gameService.LoadState(ctx, room.publicID)
+		if err != nil {
+			log.Printf("Failed to reload game %s after version conflict: %v", room.publicID, err)
+			return nil
+		}
+	}
+	return state
+}
+
+// announceFinishedDeal tells the room about a finished deal whose outcome is saved:
+// the next deal of a match, or the end of the game
+func announceFinishedDeal(ctx context.Context, room *GameRoom, state *business.FullGameState, winnerUserIDs []string) {
+	// Match play continues with a fresh deal
+	if state.Phase != business.PhaseFinished {
+		moveLog.Record(ctx, room.publicID, "", business.ActionDeal, nil, state)
+
+		payload, _ := json.Marshal(RoundEndPayload{
+			Round:            state.Round - 1,
+			CumulativeScores: state.CumulativeScores,
+		})
+		room.broadcast <- GameMessage{
+			Type:    "round_end",
+			Payload: payload,
+		}
+		recordRoundStarted(ctx, room.publicID, state)
+		return
+	}
+
+	if err := gameService.RecordResult(ctx, state, winnerUserIDs); err != nil {
+		log.Printf("Failed to record result of game %s: %v", room.publicID, err)
+		return
 	}
+	log.Printf("Game %s finished, winners: %v", room.publicID, winnerUserIDs)
+
+	broadcastGameEnd(room, room.publicID, state, winnerUserIDs, "")
 }
 
 // sendError sends an error message to a specific client
@@ -640,12 +1595,14 @@ func sendError(conn *websocket.Conn, errorMsg string) {
 		Type:    "error",
 		Payload: errPayload,
 	}
-	if err := conn.WriteJSON(msg); err != nil {
+	if err := sendJSON(conn, msg); err != nil {
 		log.Printf("Failed to send error message: %v", err)
 	}
 }
 
-// broadcastGameState broadcasts the current game state to all clients in the room
+// broadcastGameState sends each client in the room its own redacted view of state.
+// This is the only way state may reach clients; the room's shared broadcast channel
+// rejects "state" messages.
 func broadcastGameState(room *GameRoom, publicID string, state *business.FullGameState) {
 	// Get game from database for status
 	game, err := gameRepo.GetGameByPublicID(context.Background(), publicID)
@@ -662,32 +1619,63 @@ func broadcastGameState(room *GameRoom, publicID string, state *business.FullGam
 	}
 
 	// Send personalized state to each connected client
-	room.mu.RLock()
-	defer room.mu.RUnlock()
-
-	for conn, userID := range room.clients {
+	room.clients.Range(func(conn *websocket.Conn, userID string) bool {
 		statePayload := buildGameStatePayload(game, state, players, userID)
+		statePayload.ReconnectToken = room.reconnectTokenFor(userID)
 		payload, _ := json.Marshal(statePayload)
 		msg := GameMessage{
 			Type:    "state",
 			Payload: payload,
 		}
 
-		if err := conn.WriteJSON(msg); err != nil {
+		if err := sendJSON(conn, msg); err != nil {
 			log.Printf("Failed to send state to user %s: %v", userID, err)
 		}
+		return true
+	})
+
+	// Every spectator sees the same fully hidden view
+	if room.spectators.Len() > 0 {
+		payload, _ := json.Marshal(buildGameStatePayload(game, state, players, spectatorViewer))
+		msg := GameMessage{
+			Type:    "state",
+			Payload: payload,
+		}
+		room.spectators.Range(func(conn *websocket.Conn, userID string) bool {
+			if err := sendJSON(conn, msg); err != nil {
+				log.Printf("Failed to send state to spectator %s: %v", userID, err)
+			}
+			return true
+		})
 	}
+
+	room.announceTurn(state)
 }
 
 // GameEndPayload for game end notification
 type GameEndPayload struct {
-	WinnerUserID   string         `json:"winnerUserId"`
-	WinnerUsername string         `json:"winnerUsername"`
-	Scores         map[string]int `json:"scores"`
+	WinnerUserID   string                         `json:"winnerUserId"`
+	WinnerUsername string                         `json:"winnerUsername"`
+	Scores         map[string]int                 `json:"scores"`
+	Standings      []business.PlayerStanding      `json:"standings"`
+	Reason         string                         `json:"reason,omitempty"` // Set when the game ended early, e.g. "resignation"
+	Hands          map[string][6]business.CardDef `json:"hands"`            // Every player's fully revealed hand
+
+	// Everyone sharing the lowest score. WinnerUserID/WinnerUsername are only set
+	// when there is exactly one.
+	WinnerUserIDs []string `json:"winnerUserIds"`
+	IsTie         bool     `json:"isTie"`
 }
 
-// broadcastGameEnd sends game end notification to all players
-func broadcastGameEnd(room *GameRoom, publicID string, state *business.FullGameState, winnerUserID string) {
+// Reasons a game can end before it is played out
+const gameOverResignation = "resignation"
+
+// roomCloseGrace is how long a finished game's room stays open so players can see the result
+const roomCloseGrace = 30 * time.Second
+
+// broadcastGameEnd sends the "game_over" message with final scores and every hand to
+// all players, then schedules the room to close. Reason is empty for a game played to the end.
+func broadcastGameEnd(room *GameRoom, publicID string, state *business.FullGameState, winnerUserIDs []string, reason string) {
 	// Get players to get usernames
 	players, err := gameRepo.GetGamePlayers(context.Background(), publicID)
 	if err != nil {
@@ -697,34 +1685,72 @@ func broadcastGameEnd(room *GameRoom, publicID string, state *business.FullGameS
 
 	// Build scores map and find winner username
 	scores := business.GetFinalScores(state)
-	var winnerUsername string
+	usernames := make(map[string]string, len(players))
 	for _, p := range players {
-		if p.UserID == winnerUserID {
-			winnerUsername = p.Username
-			break
-		}
+		usernames[p.UserID] = p.Username
+	}
+
+	// A tie has no single winner
+	var winnerUserID string
+	if len(winnerUserIDs) == 1 {
+		winnerUserID = winnerUserIDs[0]
+	}
+	winnerUsername := usernames[winnerUserID]
+
+	standings := business.GetFinalStandings(state)
+	for i := range standings {
+		standings[i].Username = usernames[standings[i].UserID]
 	}
 
 	endPayload := GameEndPayload{
 		WinnerUserID:   winnerUserID,
 		WinnerUsername: winnerUsername,
 		Scores:         scores,
+		Standings:      standings,
+		Reason:         reason,
+		WinnerUserIDs:  winnerUserIDs,
+		IsTie:          len(winnerUserIDs) > 1,
+		Hands:          make(map[string][6]business.CardDef, len(state.Players)),
+	}
+	for _, player := range state.Players {
+		endPayload.Hands[player.UserID] = player.Hand
+	}
+
+	eventMetadata := map[string]string{"winnerUserIds": strings.Join(winnerUserIDs, ",")}
+	if reason != "" {
+		eventMetadata["reason"] = reason
+	}
+	gameEventLog.Record(context.Background(), publicID, business.GameEventGameFinished, winnerUserID, eventMetadata)
+	notifyGameUpdated(context.Background(), publicID, "finished", players)
+	if endPayload.IsTie {
+		postSystemMessage(context.Background(), publicID, "The game ended in a tie")
+	} else if winnerUsername != "" {
+		postSystemMessage(context.Background(), publicID, winnerUsername+" won the game")
 	}
 
+	webhookService.Emit(WebhookGameFinished, map[string]interface{}{
+		"publicId":      publicID,
+		"winnerUserId":  winnerUserID,
+		"winnerUserIds": winnerUserIDs,
+		"standings":     standings,
+	})
+
 	payload, _ := json.Marshal(endPayload)
 	msg := GameMessage{
-		Type:    "game_end",
+		Type:    "game_over",
 		Payload: payload,
 	}
 
-	room.mu.RLock()
-	defer room.mu.RUnlock()
-
-	for conn := range room.clients {
-		if err := conn.WriteJSON(msg); err != nil {
+	sendEnd := func(conn *websocket.Conn, _ string) bool {
+		if err := sendJSON(conn, msg); err != nil {
 			log.Printf("Failed to send game end notification: %v", err)
 		}
+		return true
 	}
+	room.clients.Range(sendEnd)
+	room.spectators.Range(sendEnd)
+
+	GameHubInstance.closeRoomAfterGrace(room)
 }
 
 // buildGameStatePayload creates a personalized state payload for a specific user
@@ -760,13 +1786,23 @@ func buildGameStatePayload(game *database.Game, state *business.FullGameState, d
 			DrawnCard:       nil,
 			DiscardTopCard:  nil,
 			DeckCount:       0,
+			Opponents:       []OpponentView{},
 		}
 	}
 
+	// Work only from the viewer's redacted copy so hidden cards can't leak
+	state = business.BuildPlayerView(state, viewerUserID)
+
 	// Find viewer's player index
 	var yourCards []Card
 	var opponentCards []Card
 	var currentPlayerID string
+	var yourVisibleScore int
+	var opponentVisibleScore *int
+	opponents := make([]OpponentView, 0, len(state.Players))
+
+	// Opponent scores are withheld during play if the rules ask for it
+	showOpponentScore := !state.Rules.HideOpponentScore || state.Phase == business.PhaseFinished
 
 	if len(state.Players) > 0 {
 		currentPlayerID = state.Players[state.CurrentTurnIdx].UserID
@@ -777,27 +1813,28 @@ func buildGameStatePayload(game *database.Game, state *business.FullGameState, d
 		isViewer := player.UserID == viewerUserID
 
 		for i := 0; i < 6; i++ {
-			if player.FaceUp[i] {
-				// Show actual card if face-up (visible to everyone)
-				cards[i] = Card{
-					Suit:  player.Hand[i].Suit,
-					Value: player.Hand[i].Rank,
-					Index: i,
-				}
-			} else {
-				// Hide face-down cards
-				cards[i] = Card{
-					Suit:  "back",
-					Value: "hidden",
-					Index: i,
-				}
+			// Face-down cards are already HiddenCard in the view
+			cards[i] = Card{
+				Suit:  player.Hand[i].Suit,
+				Value: player.Hand[i].Rank,
+				Index: i,
 			}
 		}
 
+		visibleScore := business.CalculateScore(&player, state.Rules.Scoring)
 		if isViewer {
 			yourCards = cards
+			yourVisibleScore = visibleScore
 		} else {
-			opponentCards = cards
+			opponent := OpponentView{UserID: player.UserID, Cards: cards}
+			if showOpponentScore {
+				opponent.VisibleScore = &visibleScore
+			}
+			if len(opponents) == 0 {
+				opponentCards = opponent.Cards
+				opponentVisibleScore = opponent.VisibleScore
+			}
+			opponents = append(opponents, opponent)
 		}
 	}
 
@@ -828,6 +1865,7 @@ func buildGameStatePayload(game *database.Game, state *business.FullGameState, d
 		Phase:           string(state.Phase),
 		CurrentPlayerID: currentPlayerID,
 		CurrentUserId:   viewerUserID,
+		YourTurn:        currentPlayerID != "" && currentPlayerID == viewerUserID,
 		CurrentTurn:     state.CurrentTurnIdx,
 		Players:         playerInfos,
 		YourCards:       yourCards,
@@ -835,5 +1873,14 @@ func buildGameStatePayload(game *database.Game, state *business.FullGameState, d
 		DrawnCard:       drawnCard,
 		DiscardTopCard:  discardTopCard,
 		DeckCount:       len(state.Deck),
+		HasDrawnCard:    state.DrawnCard != nil,
+
+		YourVisibleScore:     yourVisibleScore,
+		OpponentVisibleScore: opponentVisibleScore,
+		Opponents:            opponents,
+
+		Round:            state.Round,
+		TargetScore:      state.TargetScore,
+		CumulativeScores: state.CumulativeScores,
 	}
 }
@@ -0,0 +1,1365 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golf-card-game/business"
+	"golf-card-game/database"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// useFakeGames points the game handler's dependencies at in-memory fakes for one test
+func useFakeGames(t *testing.T) (*fakeGameRepo, *fakeMoveRepo) {
+	t.Helper()
+
+	prevRepo, prevService, prevMoveLog := gameRepo, gameService, moveLog
+	t.Cleanup(func() {
+		gameRepo, gameService, moveLog = prevRepo, prevService, prevMoveLog
+	})
+
+	repo := newFakeGameRepo()
+	moves := &fakeMoveRepo{}
+	gameRepo = repo
+	gameService = business.NewGameService(repo, nil)
+	moveLog = business.NewMoveLog(moves, repo)
+	gameService.SetMoveLog(moveLog)
+	return repo, moves
+}
+
+// newTestRoom returns a room that isn't running, so its broadcasts can be inspected
+func newTestRoom(publicID string) *GameRoom {
+	return &GameRoom{
+		publicID:   publicID,
+		clients:    newConcurrentMap[*websocket.Conn, string](),
+		spectators: newConcurrentMap[*websocket.Conn, string](),
+		broadcast:  make(chan GameMessage, 256),
+		ctx:        context.Background(),
+
+		reconnectTokens: make(map[string]reconnectGrant),
+
+		turnCommitted: make(chan *business.FullGameState, 16),
+	}
+}
+
+// dealTestGame creates an in-progress game for userIDs and deals it
+func dealTestGame(t *testing.T, repo *fakeGameRepo, publicID string, rules business.GameRules, userIDs ...string) {
+	t.Helper()
+
+	repo.addGame(publicID, "in_progress", rules, userIDs...)
+	if _, _, err := gameService.LoadOrInitState(context.Background(), publicID); err != nil {
+		t.Fatalf("LoadOrInitState: %v", err)
+	}
+}
+
+// botAction picks the next move in state with the bot strategy, for whoever has to move
+func botAction(t *testing.T, state *business.FullGameState) (string, ActionPayload) {
+	t.Helper()
+
+	userID := state.Players[state.CurrentTurnIdx].UserID
+	if state.Phase == business.PhaseInitialFlip {
+		for _, player := range state.Players {
+			if player.InitialFlips < 2 {
+				userID = player.UserID
+				break
+			}
+		}
+	}
+
+	action, cardIndex := business.ComputeBotMove(state, userID)
+	if action == "" {
+		t.Fatalf("no move for %s in phase %s", userID, state.Phase)
+	}
+	payload := ActionPayload{Action: action}
+	if business.ActionNeedsCardIndex(action) {
+		payload.Data, _ = json.Marshal(CardIndexData{Index: cardIndex})
+	}
+	return userID, payload
+}
+
+// playUntilDealEnds applies bot moves until the current deal reaches the finished phase
+func playUntilDealEnds(t *testing.T, publicID string) *business.FullGameState {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		state, _, err := gameService.LoadState(context.Background(), publicID)
+		if err != nil {
+			t.Fatalf("LoadState: %v", err)
+		}
+		if state.Phase == business.PhaseFinished {
+			return state
+		}
+
+		userID, payload := botAction(t, state)
+		if _, failure := applyActionWithRetry(publicID, userID, payload); failure != "" {
+			t.Fatalf("%s by %s: %s", payload.Action, userID, failure)
+		}
+	}
+	t.Fatal("deal did not finish")
+	return nil
+}
+
+// drainBroadcasts returns the messages of the given type queued on the room
+func drainBroadcasts(room *GameRoom, msgType string) []GameMessage {
+	var msgs []GameMessage
+	for {
+		select {
+		case msg := <-room.broadcast:
+			if msg.Type == msgType {
+				msgs = append(msgs, msg)
+			}
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestFinishIfOverPlaysATwoDealMatch(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("match")
+
+	// No six-card hand scores 100, so the match takes at least two deals
+	dealTestGame(t, repo, "match", business.GameRules{TargetScore: 100}, "alice", "bob")
+
+	roundEnds := 0
+	for deal := 1; ; deal++ {
+		if deal > 50 {
+			t.Fatal("match did not finish")
+		}
+
+		finished := playUntilDealEnds(t, "match")
+		state := finishIfOver(ctx, room, finished)
+		if state == nil {
+			t.Fatalf("deal %d: finishIfOver failed", deal)
+		}
+
+		persisted, version, err := gameService.LoadState(ctx, "match")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != state.Version || persisted.Round != state.Round || persisted.Phase != state.Phase {
+			t.Fatalf("deal %d: returned state (v%d, round %d, %s) isn't the saved one (v%d, round %d, %s)",
+				deal, state.Version, state.Round, state.Phase, version, persisted.Round, persisted.Phase)
+		}
+
+		for _, msg := range drainBroadcasts(room, "round_end") {
+			var payload RoundEndPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Round != deal || persisted.Round != deal+1 {
+				t.Fatalf("round_end for deal %d announced while deal %d is saved", payload.Round, persisted.Round)
+			}
+			roundEnds++
+		}
+
+		if state.Phase == business.PhaseFinished {
+			break
+		}
+	}
+
+	if roundEnds == 0 {
+		t.Fatal("match ended without a round_end")
+	}
+	game, err := repo.GetGameByPublicID(ctx, "match")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "finished" {
+		t.Fatalf("game status = %q, want finished", game.Status)
+	}
+
+	// The logged deals let the whole match be replayed
+	diffs, err := gameService.VerifyGameState(ctx, "match")
+	if err != nil {
+		t.Fatalf("VerifyGameState: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("replayed match differs in %v", diffs)
+	}
+}
+
+func TestFinishIfOverLeavesADealAnotherWriterFinished(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("match")
+	dealTestGame(t, repo, "match", business.GameRules{TargetScore: 100}, "alice", "bob")
+
+	first := playUntilDealEnds(t, "match")
+	stale, _, err := gameService.LoadState(ctx, "match")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if finishIfOver(ctx, room, first) == nil {
+		t.Fatal("first finishIfOver failed")
+	}
+	state := finishIfOver(ctx, room, stale)
+	if state == nil {
+		t.Fatal("second finishIfOver failed")
+	}
+
+	if state.Round != 2 {
+		t.Fatalf("round = %d, want 2: the deal was finished twice", state.Round)
+	}
+	if got := len(drainBroadcasts(room, "round_end")); got != 1 {
+		t.Fatalf("got %d round_end messages, want 1", got)
+	}
+}
+
+// failingStateRepo refuses every state write
+type failingStateRepo struct {
+	*fakeGameRepo
+}
+
+func (r failingStateRepo) UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error {
+	return errors.New("connection reset")
+}
+
+func TestFinishIfOverAnnouncesNothingWhenTheSaveFails(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("match")
+	dealTestGame(t, repo, "match", business.GameRules{TargetScore: 100}, "alice", "bob")
+	finished := playUntilDealEnds(t, "match")
+
+	gameService = business.NewGameService(failingStateRepo{repo}, nil)
+	if state := finishIfOver(context.Background(), room, finished); state != nil {
+		t.Fatal("finishIfOver returned a state it couldn't save")
+	}
+	if got := len(drainBroadcasts(room, "round_end")); got != 0 {
+		t.Fatalf("got %d round_end messages for an unsaved deal", got)
+	}
+}
+
+func TestResignSavesAndLogsBeforeRecordingTheResult(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("game")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	resign(room, "alice", nil)
+
+	game, err := repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "finished" || game.WinnerUserID == nil || *game.WinnerUserID != "bob" {
+		t.Fatalf("game = %s won by %v, want finished and won by bob", game.Status, game.WinnerUserID)
+	}
+
+	moves, err := moveLog.GetMoves(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Action != business.ActionResign || moves[0].UserID != "alice" {
+		t.Fatalf("moves = %+v, want alice's resignation", moves)
+	}
+	diffs, err := gameService.VerifyGameState(ctx, "game")
+	if err != nil {
+		t.Fatalf("VerifyGameState: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("replayed resignation differs in %v", diffs)
+	}
+}
+
+func TestFinishingAGameTellsBothPlayersInTheLobby(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	room := newTestRoom("game")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	lobby := make(map[string]*websocket.Conn)
+	for _, userID := range []string{"alice", "bob"} {
+		server, client := newTestConn(t)
+		connectLobby(t, server, userID)
+		lobby[userID] = client
+	}
+
+	resign(room, "alice", nil)
+
+	for userID, client := range lobby {
+		var update GameUpdatedPayload
+		if err := json.Unmarshal(readMessageOfType(t, client, "game_updated"), &update); err != nil {
+			t.Fatal(err)
+		}
+		if update != (GameUpdatedPayload{PublicID: "game", Status: "finished"}) {
+			t.Errorf("%s got %+v, want game finished", userID, update)
+		}
+	}
+}
+
+func TestResignRecordsNothingWhenTheSaveFails(t *testing.T) {
+	repo, moves := useFakeGames(t)
+	room := newTestRoom("game")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	gameService = business.NewGameService(failingStateRepo{repo}, nil)
+	resign(room, "alice", nil)
+
+	game, err := repo.GetGameByPublicID(context.Background(), "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "in_progress" {
+		t.Fatalf("game status = %q after an unsaved resignation", game.Status)
+	}
+	if len(moves.moves) != 0 {
+		t.Fatalf("unsaved resignation was logged: %+v", moves.moves)
+	}
+}
+
+// gameSocketTest is the test whose services dialGame has swapped in
+var gameSocketTest *testing.T
+
+// dialGame serves the game socket for whichever user the ?user= parameter names and
+// connects to publicID with the given extra query. The connection is closed, and its
+// handler has returned, before the test's other cleanups run.
+func dialGame(t *testing.T, publicID, userID, query string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	// The first dial of a test swaps the services in, and puts them back once every
+	// connection the test opened has closed
+	if gameSocketTest != t {
+		gameSocketTest = t
+		prevUserService, prevTimeout := userService, turnTimeout
+		t.Cleanup(func() {
+			waitForPresenceSent(t, "alice", "bob", "carol")
+			userService, turnTimeout = prevUserService, prevTimeout
+			gameSocketTest = nil
+		})
+		userService = business.NewUserService(newFakeUserRepo("alice", "bob", "carol"))
+		turnTimeout = 0
+	}
+
+	var handlers sync.WaitGroup
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		ctx := context.WithValue(r.Context(), userIDKey, r.URL.Query().Get("user"))
+		GameWebSocketHandler(w, r.WithContext(ctx))
+	}))
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws/game/" + publicID + "?user=" + userID + "&" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	t.Cleanup(func() {
+		if conn != nil {
+			conn.Close()
+		}
+		srv.Close()
+		handlers.Wait()
+		GameHubInstance.CloseRoom(publicID)
+	})
+	return conn, resp, err
+}
+
+// requireCompleteState fails unless payload carries every field of GameStatePayload
+// that is always sent, with full hands for the viewer and their opponent
+func requireCompleteState(t *testing.T, payload json.RawMessage) GameStatePayload {
+	t.Helper()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatal(err)
+	}
+	stateType := reflect.TypeOf(GameStatePayload{})
+	for i := 0; i < stateType.NumField(); i++ {
+		name, opts, _ := strings.Cut(stateType.Field(i).Tag.Get("json"), ",")
+		if opts != "omitempty" {
+			if _, ok := fields[name]; !ok {
+				t.Fatalf("state is missing %q: %s", name, payload)
+			}
+		}
+	}
+
+	var state GameStatePayload
+	if err := json.Unmarshal(payload, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.YourCards) != 6 || len(state.Opponents) != 1 || len(state.Opponents[0].Cards) != 6 || len(state.Players) != 2 {
+		t.Fatalf("state has %d cards, %d opponents and %d players: %s",
+			len(state.YourCards), len(state.Opponents), len(state.Players), payload)
+	}
+	return state
+}
+
+func TestFullStateClientsOnlyGetCompleteStates(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	conn, _, err := dialGame(t, "game", "alice", "full_state=true")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	requireCompleteState(t, readMessageOfType(t, conn, "state"))
+
+	// Each flip comes back as a whole new snapshot showing every flip so far
+	flips := []int{0, 3}
+	for n, index := range flips {
+		data, _ := json.Marshal(CardIndexData{Index: index})
+		action, _ := json.Marshal(ActionPayload{Action: "initial_flip", Data: data})
+		if err := conn.WriteJSON(GameMessage{Type: "action", Payload: action}); err != nil {
+			t.Fatal(err)
+		}
+		// Table updates may bring states from before the flip first
+		state := requireCompleteState(t, readMessageOfType(t, conn, "state"))
+		for state.YourCards[index].Suit == "back" {
+			state = requireCompleteState(t, readMessageOfType(t, conn, "state"))
+		}
+		for _, flipped := range flips[:n+1] {
+			if state.YourCards[flipped].Suit == "back" {
+				t.Fatalf("after %d flips, card %d is still face down", n+1, flipped)
+			}
+		}
+	}
+}
+
+func TestFullStateClientsGetTheBoardAfterEachCountdownTick(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	repo.addGame("game", "in_progress", business.GameRules{}, "alice", "bob").MaxPlayers = 2
+	ticks := startCountdownRoom(t, "game", 2)
+
+	alice, _, err := dialGame(t, "game", "alice", "full_state=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want := 2; want > 0; want-- {
+		if countdown := readCountdown(t, alice); countdown.Remaining != want {
+			t.Fatalf("countdown = %+v, want %d", countdown, want)
+		}
+		var msg GameMessage
+		alice.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := alice.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "state" {
+			t.Fatalf("countdown %d was followed by %s, want the full state", want, msg.Type)
+		}
+		if want > 1 {
+			tick(t, ticks)
+		}
+	}
+
+	// Without the parameter, a countdown tick is all bob hears until the next one
+	readCountdown(t, bob)
+	var msg GameMessage
+	bob.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if err := bob.ReadJSON(&msg); err == nil {
+		t.Fatalf("bob got %s after the countdown without asking for full_state", msg.Type)
+	}
+}
+
+func TestGameSocketRejectsAnUnreadableFullState(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	_, resp, err := dialGame(t, "game", "alice", "full_state=maybe")
+	if err == nil {
+		t.Fatal("connected with full_state=maybe")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("response = %v, want 400", resp)
+	}
+}
+
+// barrierStateRepo holds the first n state loads until all n have happened, so that
+// many writers start from the same version, and counts the writes it turns away
+type barrierStateRepo struct {
+	*fakeGameRepo
+
+	loads     sync.WaitGroup
+	remaining atomic.Int32
+	conflicts atomic.Int32
+}
+
+func newBarrierStateRepo(repo *fakeGameRepo, n int) *barrierStateRepo {
+	r := &barrierStateRepo{fakeGameRepo: repo}
+	r.loads.Add(n)
+	r.remaining.Store(int32(n))
+	return r
+}
+
+func (r *barrierStateRepo) LoadGameState(ctx context.Context, publicID string) ([]byte, int, error) {
+	stateJSON, version, err := r.fakeGameRepo.LoadGameState(ctx, publicID)
+	if r.remaining.Add(-1) >= 0 {
+		r.loads.Done()
+		r.loads.Wait()
+	}
+	return stateJSON, version, err
+}
+
+func (r *barrierStateRepo) UpdateGameState(ctx context.Context, publicID string, stateJSON []byte, expectedVersion int) error {
+	err := r.fakeGameRepo.UpdateGameState(ctx, publicID, stateJSON, expectedVersion)
+	if errors.Is(err, database.ErrVersionConflict) {
+		r.conflicts.Add(1)
+	}
+	return err
+}
+
+func TestConcurrentSwapsCommitOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	repo, moves := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	// Play the initial flips, then have the current player draw
+	var state *business.FullGameState
+	for {
+		var err error
+		state, _, err = gameService.LoadState(ctx, "game")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Phase != business.PhaseInitialFlip {
+			break
+		}
+		userID, payload := botAction(t, state)
+		if _, failure := applyActionWithRetry("game", userID, payload); failure != "" {
+			t.Fatalf("%s: %s", payload.Action, failure)
+		}
+	}
+	current := state.Players[state.CurrentTurnIdx].UserID
+	if _, failure := applyActionWithRetry("game", current, ActionPayload{Action: "draw_deck"}); failure != "" {
+		t.Fatalf("draw_deck: %s", failure)
+	}
+	_, before, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := len(moves.moves)
+
+	// Two swaps of the same drawn card, both read at the same version
+	barrier := newBarrierStateRepo(repo, 2)
+	gameService = business.NewGameService(barrier, nil)
+
+	failures := make(chan string, 2)
+	var wg sync.WaitGroup
+	for _, index := range []int{0, 5} {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			data, _ := json.Marshal(CardIndexData{Index: index})
+			_, failure := applyActionWithRetry("game", current, ActionPayload{Action: "swap_card", Data: data})
+			failures <- failure
+		}(index)
+	}
+	wg.Wait()
+	close(failures)
+
+	succeeded := 0
+	for failure := range failures {
+		if failure == "" {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d swaps succeeded, want exactly 1", succeeded)
+	}
+	if barrier.conflicts.Load() != 1 {
+		t.Fatalf("%d version conflicts, want 1", barrier.conflicts.Load())
+	}
+
+	_, after, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before+1 {
+		t.Fatalf("version went from %d to %d, want one write", before, after)
+	}
+	if got := len(moves.moves) - logged; got != 1 {
+		t.Fatalf("%d swaps logged, want 1", got)
+	}
+}
+
+// markSecrets gives every card nobody may see (face-down hand cards and the deck) a
+// suit naming it, so a leak shows up as that name in the bytes a client receives.
+// Hand cards at the flipped indices are marked "flipped-" instead of "secret-".
+func markSecrets(t *testing.T, publicID string, flipped []int) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, version, err := gameService.LoadState(ctx, publicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := range state.Players {
+		player := &state.Players[p]
+		for i := range player.Hand {
+			switch {
+			case slices.Contains(flipped, i):
+				player.Hand[i].Suit = fmt.Sprintf("flipped-%s-%d", player.UserID, i)
+			case !player.FaceUp[i]:
+				player.Hand[i].Suit = fmt.Sprintf("secret-%s-%d", player.UserID, i)
+			}
+		}
+	}
+	for i := range state.Deck {
+		state.Deck[i].Suit = fmt.Sprintf("secret-deck-%d", i)
+	}
+	if err := gameService.SaveState(ctx, state, version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readUntilPlaying returns everything conn receives up to and including the first
+// state past the initial flip
+func readUntilPlaying(t *testing.T, conn *websocket.Conn) []byte {
+	t.Helper()
+
+	var received []byte
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("reading: %v", err)
+		}
+		received = append(received, data...)
+
+		var msg GameMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "state" {
+			continue
+		}
+		var state GameStatePayload
+		if err := json.Unmarshal(msg.Payload, &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Phase == string(business.PhaseMainGame) {
+			return received
+		}
+	}
+}
+
+func TestBroadcastStateLeaksNoHiddenCards(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{SpectatorsAllowed: true}, "alice", "bob")
+	flips := []int{0, 3}
+	markSecrets(t, "game", flips)
+
+	conns := map[string]*websocket.Conn{}
+	for _, viewer := range []struct{ userID, query string }{
+		{"alice", ""}, {"bob", ""}, {"carol", "spectate=true"},
+	} {
+		conn, _, err := dialGame(t, "game", viewer.userID, viewer.query)
+		if err != nil {
+			t.Fatalf("dial %s: %v", viewer.userID, err)
+		}
+		conns[viewer.userID] = conn
+	}
+
+	// Both players turn up one card in each row; the rest stay secret
+	for _, userID := range []string{"alice", "bob"} {
+		for _, index := range flips {
+			data, _ := json.Marshal(CardIndexData{Index: index})
+			action, _ := json.Marshal(ActionPayload{Action: "initial_flip", Data: data})
+			if err := conns[userID].WriteJSON(GameMessage{Type: "action", Payload: action}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for viewer, conn := range conns {
+		received := readUntilPlaying(t, conn)
+		if i := bytes.Index(received, []byte("secret-")); i >= 0 {
+			end := min(i+24, len(received))
+			t.Errorf("%s received a hidden card: %s", viewer, received[i:end])
+		}
+		if bytes.Contains(received, []byte(`"deck":`)) {
+			t.Errorf("%s received the raw deck", viewer)
+		}
+		// Cards turned up are everyone's to see
+		for _, userID := range []string{"alice", "bob"} {
+			for _, index := range flips {
+				if marker := fmt.Sprintf("flipped-%s-%d", userID, index); !bytes.Contains(received, []byte(marker)) {
+					t.Errorf("%s never saw %s", viewer, marker)
+				}
+			}
+		}
+	}
+}
+
+func TestSpectatorsCannotChangeTheGame(t *testing.T) {
+	ctx := context.Background()
+	repo, moves := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{SpectatorsAllowed: true}, "alice", "bob")
+	_, before, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, err := dialGame(t, "game", "carol", "spectate=true")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	var count map[string]int
+	if err := json.Unmarshal(readMessageOfType(t, conn, "spectator_count"), &count); err != nil || count["count"] != 1 {
+		t.Fatalf("spectator_count = %v (%v), want 1", count, err)
+	}
+
+	// Moves for either seat, and resigning, are all turned away
+	flip, _ := json.Marshal(CardIndexData{Index: 0})
+	for _, payload := range []ActionPayload{
+		{Action: "initial_flip", Data: flip},
+		{Action: "draw_deck"},
+		{Action: "resign"},
+	} {
+		action, _ := json.Marshal(payload)
+		if err := conn.WriteJSON(GameMessage{Type: "action", Payload: action}); err != nil {
+			t.Fatal(err)
+		}
+		var rejection ErrorPayload
+		if err := json.Unmarshal(readMessageOfType(t, conn, "error"), &rejection); err != nil {
+			t.Fatal(err)
+		}
+		if rejection.Error != "Spectators cannot take actions" {
+			t.Fatalf("%s: error = %q", payload.Action, rejection.Error)
+		}
+	}
+
+	// A sync round trip: everything sent before it has been handled
+	if err := conn.WriteJSON(GameMessage{Type: "sync"}); err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, conn, "state")
+
+	_, after, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Fatalf("state version went from %d to %d", before, after)
+	}
+	game, err := repo.GetGameByPublicID(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Status != "in_progress" {
+		t.Fatalf("game status = %q", game.Status)
+	}
+	if len(moves.moves) != 0 {
+		t.Fatalf("spectator moves were logged: %+v", moves.moves)
+	}
+}
+
+// useDuplicatePolicy sets the duplicate connection policy for one test
+func useDuplicatePolicy(t *testing.T, policy string) {
+	prev := duplicateConnectionPolicy
+	t.Cleanup(func() { duplicateConnectionPolicy = prev })
+	SetDuplicateConnectionPolicy(policy)
+}
+
+// requireClosed fails unless the server closes conn with the given reason
+func requireClosed(t *testing.T, conn *websocket.Conn, reason string) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reason {
+			t.Fatalf("err = %v, want a policy violation close saying %q", err, reason)
+		}
+		return
+	}
+}
+
+func TestSecondTabTakesOverTheConnection(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	useDuplicatePolicy(t, DuplicatePolicyTakeover)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	first, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, first, "state")
+	second, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireClosed(t, first, "connected from another tab")
+	readMessageOfType(t, second, "state")
+	if err := second.WriteJSON(GameMessage{Type: "sync"}); err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, second, "state")
+
+	room := GameHubInstance.GetRoom("game")
+	if n := room.clients.Len(); n != 1 {
+		t.Fatalf("room has %d connections for alice, want 1", n)
+	}
+}
+
+func TestSecondTabIsRejected(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	useDuplicatePolicy(t, DuplicatePolicyReject)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	first, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, first, "state")
+	second, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireClosed(t, second, "already connected elsewhere")
+	if err := first.WriteJSON(GameMessage{Type: "sync"}); err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, first, "state")
+}
+
+func TestSpectatingIsRejectedUnlessTheGameAllowsIt(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "closed", business.GameRules{}, "alice", "bob")
+	repo.addGame("gone", "abandoned", business.GameRules{SpectatorsAllowed: true}, "alice", "bob")
+
+	for _, publicID := range []string{"closed", "gone"} {
+		_, resp, err := dialGame(t, publicID, "carol", "spectate=true")
+		if err == nil {
+			t.Fatalf("%s: carol was let in to watch", publicID)
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s: response = %v, want 403", publicID, resp)
+		}
+	}
+}
+
+// startCountdownRoom runs a room for publicID whose countdown lasts seconds and only
+// moves on when the test sends on the returned channel
+func startCountdownRoom(t *testing.T, publicID string, seconds int) chan time.Time {
+	t.Helper()
+
+	ticks := make(chan time.Time)
+	room := newGameRoom(publicID)
+	room.turnTimeout = 0
+	room.countdownSeconds = seconds
+	room.countdownTicker = func() (<-chan time.Time, func()) { return ticks, func() {} }
+
+	GameHubInstance.mu.Lock()
+	GameHubInstance.rooms[publicID] = room
+	GameHubInstance.mu.Unlock()
+	go room.Run()
+	return ticks
+}
+
+// readCountdown reads the next countdown message from conn
+func readCountdown(t *testing.T, conn *websocket.Conn) CountdownPayload {
+	t.Helper()
+
+	var countdown CountdownPayload
+	if err := json.Unmarshal(readMessageOfType(t, conn, "countdown"), &countdown); err != nil {
+		t.Fatal(err)
+	}
+	return countdown
+}
+
+// tick sends one countdown tick, failing if nothing is counting down
+func tick(t *testing.T, ticks chan time.Time) {
+	t.Helper()
+
+	select {
+	case ticks <- time.Now():
+	case <-time.After(2 * time.Second):
+		t.Fatal("no countdown took the tick")
+	}
+}
+
+func TestCountdownDealsAfterTheLastSecond(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	repo.addGame("game", "in_progress", business.GameRules{}, "alice", "bob").MaxPlayers = 2
+	ticks := startCountdownRoom(t, "game", 3)
+
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dialGame(t, "game", "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for want := 3; want > 0; want-- {
+		if countdown := readCountdown(t, alice); countdown.Remaining != want || countdown.Cancelled {
+			t.Fatalf("countdown = %+v, want %d", countdown, want)
+		}
+		if _, _, err := gameService.LoadState(ctx, "game"); err == nil {
+			t.Fatalf("game dealt with %d seconds to go", want)
+		}
+		tick(t, ticks)
+	}
+
+	for {
+		var state GameStatePayload
+		if err := json.Unmarshal(readMessageOfType(t, alice, "state"), &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Phase == string(business.PhaseInitialFlip) {
+			break
+		}
+	}
+	if _, _, err := gameService.LoadState(ctx, "game"); err != nil {
+		t.Fatalf("dealt state wasn't saved: %v", err)
+	}
+}
+
+func TestDisconnectCancelsTheCountdown(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	repo.addGame("game", "in_progress", business.GameRules{}, "alice", "bob").MaxPlayers = 2
+	ticks := startCountdownRoom(t, "game", 3)
+
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countdown := readCountdown(t, alice); countdown.Remaining != 3 {
+		t.Fatalf("countdown = %+v, want 3", countdown)
+	}
+
+	bob.Close()
+	if countdown := readCountdown(t, alice); !countdown.Cancelled {
+		t.Fatalf("countdown = %+v, want it cancelled", countdown)
+	}
+
+	select {
+	case ticks <- time.Now():
+		t.Fatal("the cancelled countdown is still ticking")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, _, err := gameService.LoadState(ctx, "game"); err == nil {
+		t.Fatal("game dealt after its countdown was cancelled")
+	}
+}
+
+// playInitialFlips has every player turn up their two cards
+func playInitialFlips(t *testing.T, publicID string) *business.FullGameState {
+	t.Helper()
+
+	for {
+		state, _, err := gameService.LoadState(context.Background(), publicID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Phase != business.PhaseInitialFlip {
+			return state
+		}
+		userID, payload := botAction(t, state)
+		if _, failure := applyActionWithRetry(publicID, userID, payload); failure != "" {
+			t.Fatalf("%s: %s", payload.Action, failure)
+		}
+	}
+}
+
+func TestStuckDrawIsDiscardedOnceTheGraceRunsOut(t *testing.T) {
+	ctx := context.Background()
+	repo, moves := useFakeGames(t)
+	room := newTestRoom("game")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	playInitialFlips(t, "game")
+	if _, failure := applyActionWithRetry("game", "alice", ActionPayload{Action: "draw_deck"}); failure != "" {
+		t.Fatalf("draw_deck: %s", failure)
+	}
+	drawn, version, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice only just left, so her turn is left alone
+	room.disconnectedAt = map[string]time.Time{"alice": time.Now()}
+	room.recoverStuckTurn(ctx)
+	if _, after, _ := gameService.LoadState(ctx, "game"); after != version {
+		t.Fatal("a drawn card was discarded within the grace period")
+	}
+
+	room.disconnectedAt["alice"] = time.Now().Add(-disconnectGracePeriod - time.Second)
+	room.recoverStuckTurn(ctx)
+	state, after, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != version+1 || state.DrawnCard != nil || state.CurrentTurnIdx != 1 {
+		t.Fatalf("v%d, drawn %v, turn %d: want the draw discarded and bob to move", after, state.DrawnCard, state.CurrentTurnIdx)
+	}
+	if top := state.DiscardPile[len(state.DiscardPile)-1]; top != *drawn.DrawnCard {
+		t.Fatalf("discard pile top = %v, want alice's drawn %v", top, *drawn.DrawnCard)
+	}
+	last := moves.moves[len(moves.moves)-1]
+	if last.Action != business.ActionDiscardStuck || last.UserID != "alice" {
+		t.Fatalf("last logged move = %s by %s, want the recovery", last.Action, last.UserID)
+	}
+}
+
+func TestHiddenOpponentScoresAreOnlySentAtGameEnd(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "hidden", business.GameRules{HideOpponentScore: true}, "alice", "bob", "carol")
+	dealTestGame(t, repo, "shown", business.GameRules{}, "alice", "bob", "carol")
+
+	payloadFor := func(publicID string, phase business.GamePhase) GameStatePayload {
+		t.Helper()
+		game, players, err := gameService.GetGameWithPlayers(ctx, publicID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := playInitialFlips(t, publicID)
+		state.Phase = phase
+		return buildGameStatePayload(game, state, players, "alice")
+	}
+
+	for _, tc := range []struct {
+		publicID string
+		phase    business.GamePhase
+		shown    bool
+	}{
+		{"hidden", business.PhaseMainGame, false},
+		{"hidden", business.PhaseFinalRound, false},
+		{"hidden", business.PhaseFinished, true},
+		{"shown", business.PhaseMainGame, true},
+	} {
+		payload := payloadFor(tc.publicID, tc.phase)
+		if (payload.OpponentVisibleScore != nil) != tc.shown {
+			t.Errorf("%s in %s: opponentVisibleScore = %v, want shown %v", tc.publicID, tc.phase, payload.OpponentVisibleScore, tc.shown)
+		}
+		for _, opponent := range payload.Opponents {
+			if (opponent.VisibleScore != nil) != tc.shown {
+				t.Errorf("%s in %s: %s's score = %v, want shown %v", tc.publicID, tc.phase, opponent.UserID, opponent.VisibleScore, tc.shown)
+			}
+		}
+
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Contains(encoded, []byte(`"opponentVisibleScore"`)); got != tc.shown {
+			t.Errorf("%s in %s: opponentVisibleScore sent = %v", tc.publicID, tc.phase, got)
+		}
+	}
+}
+
+// sendAction sends a game action over a player's socket
+func sendAction(t *testing.T, conn *websocket.Conn, action string, cardIndex int) {
+	t.Helper()
+
+	payload := ActionPayload{Action: action}
+	if business.ActionNeedsCardIndex(action) {
+		payload.Data, _ = json.Marshal(CardIndexData{Index: cardIndex})
+	}
+	data, _ := json.Marshal(payload)
+	if err := conn.WriteJSON(GameMessage{Type: "action", Payload: data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTurnChanged reads the next turn_changed message from conn
+func readTurnChanged(t *testing.T, conn *websocket.Conn) TurnChangedPayload {
+	t.Helper()
+
+	var turn TurnChangedPayload
+	if err := json.Unmarshal(readMessageOfType(t, conn, "turn_changed"), &turn); err != nil {
+		t.Fatal(err)
+	}
+	return turn
+}
+
+func TestTurnChangedFollowsTheTurn(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := map[string]*websocket.Conn{"alice": alice, "bob": bob}
+
+	for _, userID := range []string{"alice", "bob"} {
+		sendAction(t, conns[userID], "initial_flip", 0)
+		sendAction(t, conns[userID], "initial_flip", 3)
+	}
+
+	// Both players hear whose turn opens the main game
+	var first TurnChangedPayload
+	for userID, conn := range conns {
+		turn := readTurnChanged(t, conn)
+		for turn.Phase == string(business.PhaseInitialFlip) {
+			turn = readTurnChanged(t, conn)
+		}
+		if turn.Phase != string(business.PhaseMainGame) {
+			t.Fatalf("%s heard phase %s, want main game", userID, turn.Phase)
+		}
+		first = turn
+	}
+	state, _, err := gameService.LoadState(context.Background(), "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current := state.Players[state.CurrentTurnIdx].UserID; first.UserID != current || first.CurrentTurnIdx != state.CurrentTurnIdx {
+		t.Fatalf("announced %s (seat %d), want %s (seat %d)", first.UserID, first.CurrentTurnIdx, current, state.CurrentTurnIdx)
+	}
+
+	// Drawing doesn't end the turn, so the next announcement is after the discard
+	sendAction(t, conns[first.UserID], "draw_deck", 0)
+	sendAction(t, conns[first.UserID], "discard_flip", 1)
+	for userID, conn := range conns {
+		turn := readTurnChanged(t, conn)
+		if turn.UserID == first.UserID || turn.CurrentTurnIdx != 1-first.CurrentTurnIdx {
+			t.Fatalf("%s heard the turn pass to %s (seat %d), want the other player", userID, turn.UserID, turn.CurrentTurnIdx)
+		}
+	}
+}
+
+// collectGameMessages reads everything sent to conn in the background, so a long game
+// never backs up the connection's send buffer
+func collectGameMessages(conn *websocket.Conn) <-chan GameMessage {
+	msgs := make(chan GameMessage, 4096)
+	go func() {
+		defer close(msgs)
+		for {
+			var msg GameMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	return msgs
+}
+
+// waitForCommit waits until publicID's state is saved at a version past version
+func waitForCommit(t *testing.T, publicID string, version int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, current, err := gameService.LoadState(context.Background(), publicID); err == nil && current > version {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no commit after version %d", version)
+}
+
+func TestGamePlayedOverTheSocketEndsWithGameOver(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	conns := make(map[string]*websocket.Conn)
+	received := make(map[string]<-chan GameMessage)
+	for _, userID := range []string{"alice", "bob"} {
+		conn, _, err := dialGame(t, "game", userID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[userID] = conn
+		received[userID] = collectGameMessages(conn)
+	}
+
+	for moves := 0; ; moves++ {
+		if moves == 1000 {
+			t.Fatal("game did not finish")
+		}
+		state, version, err := gameService.LoadState(context.Background(), "game")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Phase == business.PhaseFinished {
+			break
+		}
+		userID, payload := botAction(t, state)
+		data, _ := json.Marshal(payload)
+		if err := conns[userID].WriteJSON(GameMessage{Type: "action", Payload: data}); err != nil {
+			t.Fatal(err)
+		}
+		waitForCommit(t, "game", version)
+	}
+
+	for userID, msgs := range received {
+		var end GameEndPayload
+		timeout := time.After(2 * time.Second)
+		for end.Scores == nil {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					t.Fatalf("%s's socket closed before game_over", userID)
+				}
+				if msg.Type == "game_over" {
+					if err := json.Unmarshal(msg.Payload, &end); err != nil {
+						t.Fatal(err)
+					}
+				}
+			case <-timeout:
+				t.Fatalf("%s never got game_over", userID)
+			}
+		}
+		if len(end.Scores) != 2 || len(end.Hands) != 2 || len(end.WinnerUserIDs) == 0 || end.Reason != "" {
+			t.Errorf("%s got game_over %+v, want both scores and hands and a winner", userID, end)
+		}
+	}
+	if game := repo.games["game"]; game.Status != "finished" {
+		t.Errorf("game status = %s, want finished", game.Status)
+	}
+}
+
+func TestReconnectingMidGameResumesTheGame(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, alice, "state")
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, bob, "state")
+
+	// Alice, in the first seat, opens the main game and draws before dropping
+	playInitialFlips(t, "game")
+	state, failure := applyActionWithRetry("game", "alice", ActionPayload{Action: "draw_deck"})
+	if failure != "" {
+		t.Fatalf("draw_deck: %s", failure)
+	}
+
+	alice.Close()
+	readMessageOfType(t, bob, "player_left")
+
+	resumed, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	var view GameStatePayload
+	if err := json.Unmarshal(readMessageOfType(t, resumed, "state"), &view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Phase != string(business.PhaseMainGame) || !view.YourTurn || !view.HasDrawnCard || view.DrawnCard == nil {
+		t.Fatalf("resumed in phase %s, your turn %v, drawn %v: want alice's turn with her drawn card", view.Phase, view.YourTurn, view.DrawnCard)
+	}
+	if view.DrawnCard.Suit != state.DrawnCard.Suit || view.DrawnCard.Value != state.DrawnCard.Rank {
+		t.Errorf("drawn card = %+v, want %+v", *view.DrawnCard, *state.DrawnCard)
+	}
+	for i, faceUp := range state.Players[0].FaceUp {
+		card := view.YourCards[i]
+		if faceUp && (card.Suit != state.Players[0].Hand[i].Suit || card.Value != state.Players[0].Hand[i].Rank) {
+			t.Errorf("card %d = %+v, want %+v", i, card, state.Players[0].Hand[i])
+		}
+		if !faceUp && card.Suit != "back" {
+			t.Errorf("card %d = %+v, want it still face down", i, card)
+		}
+	}
+
+	var notice map[string]string
+	if err := json.Unmarshal(readMessageOfType(t, bob, "player_reconnected"), &notice); err != nil {
+		t.Fatal(err)
+	}
+	if notice["userId"] != "alice" {
+		t.Errorf("bob heard %v reconnect, want alice", notice["userId"])
+	}
+}
+
+func TestGameEventsFeedIsInTheOrderThingsHappened(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	prevEvents := gameEventLog
+	t.Cleanup(func() { gameEventLog = prevEvents })
+	gameEventLog = business.NewGameEventLog(&fakeGameEventRepo{})
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, alice, "state")
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, bob, "state")
+
+	sendAction(t, alice, "initial_flip", 0)
+	readMessageOfType(t, alice, "state")
+	sendAction(t, alice, "resign", 0)
+	readMessageOfType(t, bob, "game_over")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/game/events?publicId=game", nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, "bob"))
+	rec := httptest.NewRecorder()
+	GetGameEventsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Events []*database.GameEvent `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, event := range body.Events {
+		entry := event.EventType
+		if event.UserID != nil {
+			entry += " " + *event.UserID
+		}
+		got = append(got, entry)
+	}
+	want := []string{
+		business.GameEventPlayerJoined + " alice",
+		business.GameEventPlayerJoined + " bob",
+		business.GameEventTurnTaken + " alice",
+		business.GameEventGameFinished + " bob",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("feed = %v, want %v", got, want)
+	}
+}
+
+func TestDelayedCloseLeavesAReopenedRoomAlone(t *testing.T) {
+	h := &GameHub{rooms: make(map[string]*GameRoom), conns: newConnCounter()}
+	finished := newGameRoom("game")
+	h.rooms["game"] = finished
+
+	// The finished game's room closes early and a rematch under the same ID reopens it
+	h.CloseRoom("game")
+	reopened := newGameRoom("game")
+	h.rooms["game"] = reopened
+
+	h.closeRoomIfCurrent(finished)
+	if h.GetRoom("game") != reopened || reopened.ctx.Err() != nil {
+		t.Fatal("closing the finished room shut down the one that replaced it")
+	}
+
+	h.closeRoomIfCurrent(reopened)
+	if h.GetRoom("game") != nil || reopened.ctx.Err() == nil {
+		t.Fatal("the current room was left open")
+	}
+}
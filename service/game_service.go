@@ -1,10 +1,12 @@
 package service
 
 import (
-	"encoding/json"
+	"errors"
 	"golf-card-game/business"
+	"golf-card-game/database"
 	"log"
 	"net/http"
+	"strconv"
 )
 
 // CreateGameHandler creates a new game
@@ -21,18 +23,102 @@ func CreateGameHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if requireVerifiedEmail {
+		user, err := userService.GetUserByID(ctx, userID)
+		if err != nil {
+			log.Printf("Error getting user: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create game"})
+			return
+		}
+		if !user.EmailVerified {
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "Verify your email to create games"})
+			return
+		}
+	}
+
+	// Body is optional; an empty body creates a standard game
+	var req struct {
+		Ruleset                    string `json:"ruleset"`
+		MaxPlayers                 int    `json:"maxPlayers"`
+		TargetScore                *int   `json:"targetScore"`
+		HideOpponentScore          bool   `json:"hideOpponentScore"`
+		ForbidFirstTurnDiscardDraw bool   `json:"forbidFirstTurnDiscardDraw"`
+		SpectatorsAllowed          bool   `json:"spectatorsAllowed"`
+		HideReplay                 bool   `json:"hideReplay"`
+		TimeoutStrategy            string `json:"timeoutStrategy"`
+		VsBot                      bool   `json:"vsBot"` // Play against the computer; starts immediately
+		FourOfAKind                bool   `json:"fourOfAKind"`
+		FourOfAKindBonus           int    `json:"fourOfAKindBonus"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			jsonResponse(w, err.status, map[string]string{"error": err.message})
+			return
+		}
+	}
+
+	rules, err := business.RulesForRuleset(req.Ruleset)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Unknown ruleset"})
+		return
+	}
+	rules.HideOpponentScore = req.HideOpponentScore
+	rules.ForbidFirstTurnDiscardDraw = req.ForbidFirstTurnDiscardDraw
+	rules.SpectatorsAllowed = req.SpectatorsAllowed
+	rules.HideReplay = req.HideReplay
+	rules.Scoring.FourOfAKind = req.FourOfAKind
+	rules.Scoring.FourOfAKindBonus = req.FourOfAKindBonus
+	rules.TargetScore = business.DefaultTargetScore
+	if _, err := business.TimeoutStrategyFor(req.TimeoutStrategy); err != nil {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Unknown timeout strategy"})
+		return
+	}
+	rules.TimeoutStrategy = req.TimeoutStrategy
+	if rules.TimeoutStrategy == "" {
+		rules.TimeoutStrategy = defaultTimeoutStrategy
+	}
+	if req.TargetScore != nil {
+		if *req.TargetScore < 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "targetScore cannot be negative"})
+			return
+		}
+		rules.TargetScore = *req.TargetScore
+	}
+
 	if gameService == nil {
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
 		return
 	}
 
-	game, err := gameService.CreateGame(ctx, userID)
+	maxPlayers := req.MaxPlayers
+	if maxPlayers == 0 {
+		maxPlayers = business.MinPlayers
+	}
+
+	var game *database.Game
+	if req.VsBot {
+		game, err = gameService.CreateBotGame(ctx, userID, rules)
+	} else {
+		game, err = gameService.CreateGame(ctx, userID, maxPlayers, rules)
+	}
 	if err != nil {
+		if err == business.ErrInvalidPlayerCount {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "maxPlayers must be between 2 and 4"})
+			return
+		}
 		log.Printf("Error creating game: %v", err)
 		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create game"})
 		return
 	}
 
+	webhookService.Emit(WebhookGameCreated, map[string]string{
+		"publicId":  game.PublicID,
+		"createdBy": userID,
+	})
+	if req.VsBot {
+		announceGameStarted(ctx, game.PublicID)
+	}
+
 	jsonResponse(w, http.StatusCreated, map[string]interface{}{
 		"publicId": game.PublicID,
 		"status":   game.Status,
@@ -58,8 +144,8 @@ func InvitePlayerHandler(w http.ResponseWriter, r *http.Request) {
 		InvitedUsername string `json:"invitedUsername"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
 		return
 	}
 
@@ -140,8 +226,8 @@ func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
 		PublicID string `json:"publicId"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
 		return
 	}
 
@@ -150,7 +236,7 @@ func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := gameService.AcceptInvitation(ctx, req.PublicID, userID)
+	started, err := gameService.AcceptInvitation(ctx, req.PublicID, userID)
 	if err != nil {
 		switch err {
 		case business.ErrGameNotFound:
@@ -168,6 +254,13 @@ func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if acceptor, err := userService.GetUserByID(ctx, userID); err == nil {
+		postSystemMessage(ctx, req.PublicID, acceptor.Username+" joined the game")
+	}
+	if started {
+		announceGameStarted(ctx, req.PublicID)
+	}
+
 	// Get game details and notify all active players
 	game, players, err := gameService.GetGameWithPlayers(ctx, req.PublicID)
 	if err == nil {
@@ -210,8 +303,8 @@ func DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
 		PublicID string `json:"publicId"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
 		return
 	}
 
@@ -258,6 +351,275 @@ func DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "Invitation declined"})
 }
 
+// LeaveGameHandler reverts an accepted invitation before the game starts
+func LeaveGameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		PublicID string `json:"publicId"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	err := gameService.LeaveGame(ctx, req.PublicID, userID)
+	if err != nil {
+		switch err {
+		case business.ErrGameNotFound:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+		case business.ErrNotInvited:
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "Not invited to this game"})
+		case business.ErrCreatorCannotLeave:
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "The game creator cannot leave"})
+		case business.ErrInvalidGameStatus:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game has already started"})
+		default:
+			log.Printf("Error leaving game: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to leave game"})
+		}
+		return
+	}
+
+	// Notify the creator that the seat has re-opened
+	game, err := gameService.GetGameByPublicID(ctx, req.PublicID)
+	if err == nil {
+		leaver, err := userService.GetUserByID(ctx, userID)
+		if err == nil {
+			postSystemMessage(ctx, game.PublicID, leaver.Username+" left the game")
+			Hub.SendNotificationToUser(game.CreatedBy, LobbyMessage{
+				Type: "invitation_unaccepted",
+				Payload: InvitationPayload{
+					PublicID:        game.PublicID,
+					InviteeUsername: leaver.Username,
+				},
+			})
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Left game"})
+}
+
+// RegenerateJoinLinkHandler replaces a waiting game's join link. Creator only.
+func RegenerateJoinLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		PublicID string `json:"publicId"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	token, err := gameService.RegenerateJoinToken(ctx, req.PublicID, userID)
+	if err != nil {
+		switch err {
+		case business.ErrGameNotFound:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+		case business.ErrNotGameCreator:
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "Only the game creator can change the join link"})
+		case business.ErrInvalidGameStatus:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game has already started"})
+		default:
+			log.Printf("Error regenerating join link: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to regenerate join link"})
+		}
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"token": token,
+		"url":   getAppBaseURL() + "/join?token=" + token,
+	})
+}
+
+// JoinByLinkHandler joins the game behind a join link token
+func JoinByLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	game, started, err := gameService.JoinByToken(ctx, req.Token, userID)
+	if err != nil {
+		switch err {
+		case business.ErrInvalidJoinToken:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Join link is invalid or has expired"})
+		case business.ErrGameFull:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game is full"})
+		case business.ErrAlreadyInGame:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Already in game"})
+		case business.ErrInvalidGameStatus:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game has already started"})
+		default:
+			log.Printf("Error joining by link: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to join game"})
+		}
+		return
+	}
+
+	// Let the creator know someone took a seat
+	joiner, err := userService.GetUserByID(ctx, userID)
+	if err == nil {
+		postSystemMessage(ctx, game.PublicID, joiner.Username+" joined the game")
+		Hub.SendNotificationToUser(game.CreatedBy, LobbyMessage{
+			Type: "invitation_accepted",
+			Payload: InvitationPayload{
+				PublicID:        game.PublicID,
+				InviteeUsername: joiner.Username,
+			},
+		})
+	}
+	if started {
+		announceGameStarted(ctx, game.PublicID)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"publicId": game.PublicID,
+		"status":   game.Status,
+	})
+}
+
+// RematchHandler starts a new game with the players of a finished one and invites them.
+// Asking again returns the rematch that already exists.
+func RematchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		PublicID string `json:"publicId"`
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if gameService == nil || userService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	game, created, err := gameService.Rematch(ctx, req.PublicID, userID)
+	if err != nil {
+		switch err {
+		case business.ErrGameNotFound:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+		case business.ErrNotGameParticipant:
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "You are not a player in this game"})
+		case business.ErrGameNotFinished:
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game is not finished"})
+		default:
+			log.Printf("Error creating rematch: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create rematch"})
+		}
+		return
+	}
+
+	if !created {
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"publicId": game.PublicID,
+			"status":   game.Status,
+		})
+		return
+	}
+
+	webhookService.Emit(WebhookGameCreated, map[string]string{
+		"publicId":  game.PublicID,
+		"createdBy": userID,
+	})
+	if game.Status == "in_progress" {
+		announceGameStarted(ctx, game.PublicID)
+	}
+
+	// Invite everyone else the same way a manual invitation does
+	_, players, err := gameService.GetGameWithPlayers(ctx, game.PublicID)
+	if err == nil {
+		inviter, err := userService.GetUserByID(ctx, userID)
+		if err == nil {
+			for _, player := range players {
+				if !player.IsActive {
+					Hub.SendNotificationToUser(player.UserID, LobbyMessage{
+						Type: "invitation_received",
+						Payload: InvitationPayload{
+							PublicID:        game.PublicID,
+							InviterUsername: inviter.Username,
+						},
+					})
+				}
+			}
+		}
+	}
+
+	jsonResponse(w, http.StatusCreated, map[string]string{
+		"publicId": game.PublicID,
+		"status":   game.Status,
+	})
+}
+
 // ListGamesHandler returns pending invitations and active games for a user
 func ListGamesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -299,6 +661,202 @@ func ListGamesHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetGameHistoryHandler returns a page of the user's finished and abandoned games, newest first,
+// each with the final scores and the user's result.
+// Accepts an optional ?cursor= from the previous page and ?limit= (max 100).
+func GetGameHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	limit := 20
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 100)
+	}
+
+	games, nextCursor, err := gameService.GetGameHistory(ctx, userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if err == business.ErrInvalidCursor {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid cursor"})
+			return
+		}
+		log.Printf("Error getting game history: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get game history"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"games":      games,
+		"nextCursor": nextCursor,
+	})
+}
+
+// GetGameEventsHandler returns a game's activity feed, oldest first.
+// Players and permitted spectators may read it.
+func GetGameEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	publicID := r.URL.Query().Get("publicId")
+	if publicID == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "publicId query parameter is required"})
+		return
+	}
+
+	if gameService == nil || gameEventLog == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 200)
+	}
+
+	inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
+	if err != nil {
+		log.Printf("Error validating user in game: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+		return
+	}
+	if !inGame {
+		switch err := gameService.CanSpectate(ctx, publicID); err {
+		case nil:
+		case business.ErrGameNotFound:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+			return
+		case business.ErrSpectatingDisabled:
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "Spectators are not allowed in this game"})
+			return
+		default:
+			log.Printf("Error checking spectator access: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+			return
+		}
+	}
+
+	events, err := gameEventLog.GetEvents(ctx, publicID, limit)
+	if err != nil {
+		log.Printf("Error getting game events: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get game events"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
+
+// GameReplayHandler returns every state of a finished game, rebuilt from its move log.
+// Non-players may fetch it unless the game hides its replay.
+func GameReplayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	publicID := r.URL.Query().Get("publicId")
+	if publicID == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "publicId query parameter is required"})
+		return
+	}
+
+	if gameService == nil || moveLog == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
+	if err != nil {
+		log.Printf("Error validating user in game: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+		return
+	}
+	if !inGame {
+		switch err := gameService.CanSpectate(ctx, publicID); err {
+		case nil:
+		case business.ErrGameNotFound:
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+			return
+		case business.ErrSpectatingDisabled:
+			jsonResponse(w, http.StatusForbidden, map[string]string{"error": "This game's replay is not public"})
+			return
+		default:
+			log.Printf("Error checking spectator access: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+			return
+		}
+	}
+
+	moves, err := moveLog.GetMoves(ctx, publicID)
+	if err != nil {
+		writeReplayError(w, publicID, err)
+		return
+	}
+
+	snapshots, err := gameService.ReplaySnapshots(ctx, publicID, moves)
+	if err != nil {
+		writeReplayError(w, publicID, err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, snapshots)
+}
+
+// writeReplayError maps a replay failure to its HTTP response
+func writeReplayError(w http.ResponseWriter, publicID string, err error) {
+	switch err {
+	case business.ErrGameNotFound:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game not found"})
+	case business.ErrGameNotFinished:
+		jsonResponse(w, http.StatusConflict, map[string]string{"error": "Game is still in progress"})
+	case business.ErrReplayUnavailable:
+		jsonResponse(w, http.StatusNotFound, map[string]string{"error": "No replay is available for this game"})
+	default:
+		log.Printf("Error building replay for game %s: %v", publicID, err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to build replay"})
+	}
+}
+
 // GetGameHandler returns game details with players
 func GetGameHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -353,3 +911,173 @@ func GetGameHandler(w http.ResponseWriter, r *http.Request) {
 		"players": players,
 	})
 }
+
+// GetGameStateHandler returns the caller's view of the current board, the same view the
+// game WebSocket sends, for clients that only need to poll
+func GetGameStateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	publicID := r.URL.Query().Get("publicId")
+	if publicID == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "publicId query parameter is required"})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	inGame, err := gameService.ValidateUserInGame(ctx, publicID, userID)
+	if err != nil {
+		log.Printf("Error validating user in game: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to validate access"})
+		return
+	}
+	if !inGame {
+		jsonResponse(w, http.StatusForbidden, map[string]string{"error": "You are not a player in this game"})
+		return
+	}
+
+	state, _, err := gameService.LoadState(ctx, publicID)
+	if err != nil {
+		if errors.Is(err, database.ErrStateNotFound) {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Game has not started yet"})
+		} else {
+			log.Printf("Error loading game state: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get game state"})
+		}
+		return
+	}
+
+	// Initial flips happen simultaneously, so anyone with flips left is due to act
+	yourTurn := false
+	switch state.Phase {
+	case business.PhaseFinished:
+	case business.PhaseInitialFlip:
+		for _, player := range state.Players {
+			if player.UserID == userID {
+				yourTurn = player.InitialFlips < 2
+			}
+		}
+	default:
+		if state.CurrentTurnIdx < len(state.Players) {
+			yourTurn = state.Players[state.CurrentTurnIdx].UserID == userID
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"state":          business.BuildPlayerView(state, userID),
+		"currentTurnIdx": state.CurrentTurnIdx,
+		"phase":          state.Phase,
+		"yourTurn":       yourTurn,
+	})
+}
+
+// GetLeaderboardHandler returns the top players.
+// Expects ?metric=wins or ?metric=avg_score, plus an optional ?limit= (max 100).
+func GetLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	if gameService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = "wins"
+	}
+
+	limit := 20
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 100)
+	}
+
+	entries, err := gameService.GetLeaderboard(r.Context(), metric, limit)
+	if err != nil {
+		if errors.Is(err, business.ErrInvalidLeaderboardMetric) {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error getting leaderboard: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get leaderboard"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"metric":   metric,
+		"minGames": database.LeaderboardMinGames,
+		"entries":  entries,
+	})
+}
+
+// GetStatsHandler returns the current user's statistics, or another user's with ?username=
+func GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if gameService == nil || userService == nil {
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Service not initialized"})
+		return
+	}
+
+	if username := r.URL.Query().Get("username"); username != "" {
+		user, err := userService.GetUser(ctx, username)
+		// The deleted-account placeholder pools many players' games, so it has no stats of its own
+		if err != nil || user.UserID == business.DeletedUserID {
+			jsonResponse(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		userID = user.UserID
+	}
+
+	streak, err := gameService.GetStreak(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting streak: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get stats"})
+		return
+	}
+
+	stats, err := gameService.GetPlayerStats(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting player stats: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get stats"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"streak":       streak,
+		"gamesPlayed":  stats.GamesPlayed,
+		"wins":         stats.Wins,
+		"averageScore": stats.AverageScore,
+		"bestScore":    stats.BestScore,
+	})
+}
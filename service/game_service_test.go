@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"golf-card-game/database"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// statsRepo serves fixed statistics, and zeros for players without any
+type statsRepo struct {
+	*fakeGameRepo
+
+	stats map[string]database.PlayerStats
+}
+
+func (r statsRepo) GetPlayerStats(ctx context.Context, userID string) (*database.PlayerStats, error) {
+	stats := r.stats[userID]
+	return &stats, nil
+}
+
+func (r statsRepo) GetStreak(ctx context.Context, userID string) (int, error) {
+	return 0, nil
+}
+
+// getStats calls the stats handler as alice with the given query
+func getStats(t *testing.T, query string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats?"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, "alice"))
+	rec := httptest.NewRecorder()
+	GetStatsHandler(rec, req)
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func TestStatsHandler(t *testing.T) {
+	useFakeGames(t)
+	prevUserService := userService
+	t.Cleanup(func() { userService = prevUserService })
+
+	users := newFakeUserRepo("alice", "bob", business.DeletedUserID)
+	userService = business.NewUserService(users)
+	gameService = business.NewGameService(statsRepo{
+		fakeGameRepo: newFakeGameRepo(),
+		stats: map[string]database.PlayerStats{
+			"bob": {GamesPlayed: 4, Wins: 3, AverageScore: 6.5, BestScore: 2},
+		},
+	}, nil)
+
+	// A player with no games gets zeros rather than nulls
+	status, body := getStats(t, "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	for _, field := range []string{"streak", "gamesPlayed", "wins", "averageScore", "bestScore"} {
+		if body[field] != float64(0) {
+			t.Errorf("%s = %v, want 0", field, body[field])
+		}
+	}
+
+	status, body = getStats(t, "username=bob")
+	if status != http.StatusOK || body["gamesPlayed"] != float64(4) || body["wins"] != float64(3) ||
+		body["averageScore"] != 6.5 || body["bestScore"] != float64(2) {
+		t.Fatalf("bob's stats: status %d, %v", status, body)
+	}
+
+	for _, username := range []string{"nobody", business.DeletedUserID} {
+		if status, _ := getStats(t, "username="+username); status != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", username, status)
+		}
+	}
+}
+
+// getReplay calls the replay handler as userID and returns the status and snapshots
+func getReplay(t *testing.T, userID, publicID string) (int, []*business.FullGameState) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/game/replay?publicId="+publicID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+	rec := httptest.NewRecorder()
+	GameReplayHandler(rec, req)
+
+	var snapshots []*business.FullGameState
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &snapshots); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, snapshots
+}
+
+func TestNonPlayersMayFetchAFinishedReplay(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	for _, game := range []struct {
+		publicID string
+		rules    business.GameRules
+	}{
+		{"public", business.GameRules{}},
+		{"private", business.GameRules{HideReplay: true}},
+	} {
+		dealTestGame(t, repo, game.publicID, game.rules, "alice", "bob")
+		if finishIfOver(ctx, newTestRoom(game.publicID), playUntilDealEnds(t, game.publicID)) == nil {
+			t.Fatalf("%s: finishIfOver failed", game.publicID)
+		}
+	}
+
+	// Spectating is off for both, which only governs games still being played
+	status, snapshots := getReplay(t, "carol", "public")
+	if status != http.StatusOK || len(snapshots) < 2 {
+		t.Fatalf("carol's replay: status %d with %d snapshots", status, len(snapshots))
+	}
+	if status, _ := getReplay(t, "carol", "private"); status != http.StatusForbidden {
+		t.Errorf("hidden replay: status %d, want 403", status)
+	}
+	if status, _ := getReplay(t, "alice", "private"); status != http.StatusOK {
+		t.Errorf("a player's own hidden replay: status %d, want 200", status)
+	}
+}
+
+// gameStateResponse is the body of a successful GetGameStateHandler call
+type gameStateResponse struct {
+	State    *business.FullGameState `json:"state"`
+	Phase    business.GamePhase      `json:"phase"`
+	YourTurn bool                    `json:"yourTurn"`
+}
+
+// getGameState calls the game state handler as userID and returns the status and body
+func getGameState(t *testing.T, userID, publicID string) (int, gameStateResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/game/state?publicId="+publicID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+	rec := httptest.NewRecorder()
+	GetGameStateHandler(rec, req)
+
+	var resp gameStateResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestGameStateIsOnlyForPlayersOnceDealt(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	repo.addGame("waiting", "waiting_for_players", business.GameRules{}, "alice", "bob")
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	for _, tc := range []struct {
+		userID, publicID string
+		want             int
+	}{
+		{"alice", "waiting", http.StatusNotFound},
+		{"carol", "game", http.StatusForbidden},
+		{"alice", "game", http.StatusOK},
+	} {
+		if status, _ := getGameState(t, tc.userID, tc.publicID); status != tc.want {
+			t.Errorf("%s reading %s: status %d, want %d", tc.userID, tc.publicID, status, tc.want)
+		}
+	}
+}
+
+func TestGameStateHidesWhatTheViewerCannotSee(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	playInitialFlips(t, "game")
+	if _, failure := applyActionWithRetry("game", "alice", ActionPayload{Action: "draw_deck"}); failure != "" {
+		t.Fatalf("draw_deck: %s", failure)
+	}
+	state, _, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, viewer := range []string{"alice", "bob"} {
+		status, resp := getGameState(t, viewer, "game")
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d", viewer, status)
+		}
+		if resp.YourTurn != (viewer == "alice") || resp.Phase != business.PhaseMainGame {
+			t.Errorf("%s: yourTurn %v in %s, want it to be alice's turn in the main game", viewer, resp.YourTurn, resp.Phase)
+		}
+
+		for i, player := range resp.State.Players {
+			for j, card := range player.Hand {
+				want := state.Players[i].Hand[j]
+				if !state.Players[i].FaceUp[j] {
+					want = business.HiddenCard
+				}
+				if card != want {
+					t.Errorf("%s sees %s's card %d as %v, want %v", viewer, player.UserID, j, card, want)
+				}
+			}
+		}
+		for i, card := range resp.State.Deck {
+			if card != business.HiddenCard {
+				t.Fatalf("%s sees deck card %d as %v", viewer, i, card)
+			}
+		}
+
+		wantDrawn := business.HiddenCard
+		if viewer == "alice" {
+			wantDrawn = *state.DrawnCard
+		}
+		if resp.State.DrawnCard == nil || *resp.State.DrawnCard != wantDrawn {
+			t.Errorf("%s sees the drawn card as %v, want %v", viewer, resp.State.DrawnCard, wantDrawn)
+		}
+	}
+}
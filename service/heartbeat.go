@@ -0,0 +1,80 @@
+package service
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Shared by the lobby chat and game WebSockets so both reap dead connections the same way
+const (
+	// Maximum message size allowed from peer.
+	maxMessageSize = 512 * 1024
+)
+
+// pingPeriod is how often pings are sent to the peer
+var pingPeriod = 20 * time.Second
+
+// maxMissedPongs is how many consecutive pings may go unanswered before the
+// connection is dropped. The default roughly matches the old 90s pong deadline.
+var maxMissedPongs = 4
+
+// SetMaxMissedPongs overrides the missed-pong tolerance. Values below 1 are ignored.
+func SetMaxMissedPongs(n int) {
+	if n >= 1 {
+		maxMissedPongs = n
+	}
+}
+
+// startHeartbeat configures read limits and pong handling on conn and starts a
+// goroutine that pings every pingPeriod. If maxMissedPongs pings in a row get no
+// pong, the connection is closed, which ends the caller's read loop.
+// The returned function stops the heartbeat and must be called when the connection ends.
+func startHeartbeat(conn *websocket.Conn) (stop func()) {
+	// Hard read deadline sits one ping past the miss limit so the counter decides first
+	readWait := pingPeriod * time.Duration(maxMissedPongs+1)
+
+	var pongReceived atomic.Bool
+	pongReceived.Store(true)
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(readWait))
+	conn.SetPongHandler(func(string) error {
+		pongReceived.Store(true)
+		conn.SetReadDeadline(time.Now().Add(readWait))
+		return nil
+	})
+
+	ticker := time.NewTicker(pingPeriod)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		missed := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if pongReceived.Swap(false) {
+					missed = 0
+				} else {
+					missed++
+					if missed >= maxMissedPongs {
+						log.Printf("Closing connection after %d missed pongs", missed)
+						conn.Close()
+						return
+					}
+				}
+
+				if err := sendPing(conn); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
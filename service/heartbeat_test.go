@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"golf-card-game/business"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHeartbeatDropsTheConnectionOnTheNthMissedPong(t *testing.T) {
+	prevPeriod, prevMissed := pingPeriod, maxMissedPongs
+	t.Cleanup(func() { pingPeriod, maxMissedPongs = prevPeriod, prevMissed })
+	pingPeriod = 30 * time.Millisecond
+	SetMaxMissedPongs(3)
+
+	server, client := newTestConn(t)
+	stop := startHeartbeat(server)
+	defer stop()
+
+	// The server only sees pongs while it reads
+	go func() {
+		for {
+			if _, _, err := server.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The client answers every third ping, then none at all
+	const answered = 12
+	pings := 0
+	client.SetPingHandler(func(data string) error {
+		pings++
+		if pings <= answered && pings%3 == 0 {
+			return client.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		}
+		return nil
+	})
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := client.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	if pings < answered {
+		t.Fatalf("dropped after %d pings while missing only 2 pongs in a row", pings)
+	}
+	if missed := pings - answered; missed != 3 {
+		t.Fatalf("dropped after %d unanswered pings, want 3", missed)
+	}
+}
+
+func TestStaleGameConnectionIsReaped(t *testing.T) {
+	prevPeriod, prevMissed := pingPeriod, maxMissedPongs
+	t.Cleanup(func() { pingPeriod, maxMissedPongs = prevPeriod, prevMissed })
+	pingPeriod = 20 * time.Millisecond
+	SetMaxMissedPongs(2)
+
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, alice, "state")
+	bob, _, err := dialGame(t, "game", "bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, bob, "state")
+
+	// Alice's client has gone quiet and answers no pings; bob keeps reading, so he does
+	var left map[string]string
+	if err := json.Unmarshal(readMessageOfType(t, bob, "player_left"), &left); err != nil {
+		t.Fatal(err)
+	}
+	if left["userId"] != "alice" {
+		t.Fatalf("player_left for %q, want alice", left["userId"])
+	}
+
+	room := GameHubInstance.GetOrCreateRoom("game")
+	if room.isConnected("alice") || !room.isConnected("bob") {
+		t.Fatalf("after the reap alice connected = %v, bob connected = %v", room.isConnected("alice"), room.isConnected("bob"))
+	}
+}
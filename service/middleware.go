@@ -25,8 +25,12 @@ func SessionMiddleware(next http.Handler) http.Handler {
 			path == "/api/register" ||
 			path == "/api/register/nonce" ||
 			path == "/api/logout" ||
+			path == "/api/password/reset/request" ||
+			path == "/api/password/reset/confirm" ||
+			path == "/api/verify-email" ||
 			strings.HasPrefix(r.URL.Path, "/login") ||
 			strings.HasPrefix(r.URL.Path, "/register") ||
+			strings.HasPrefix(r.URL.Path, "/reset-password") ||
 			strings.HasPrefix(r.URL.Path, "/instructions") ||
 			strings.HasPrefix(r.URL.Path, "/static/") ||
 			strings.HasPrefix(r.URL.Path, "/_next/") {
@@ -35,6 +39,14 @@ func SessionMiddleware(next http.Handler) http.Handler {
 		}
 
 		cookie, err := r.Cookie("session")
+
+		// A game socket resuming with a reconnect token is checked by the handler
+		if (err != nil || cookie.Value == "") && strings.HasPrefix(r.URL.Path, "/api/ws/game/") &&
+			r.URL.Query().Get("reconnectToken") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		if err != nil || cookie.Value == "" {
 			// Return 401 for API requests, redirect for page requests
 			if strings.HasPrefix(r.URL.Path, "/api/") {
@@ -46,7 +58,7 @@ func SessionMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Validate the session token
-		userID, err := userService.ValidateSession(r.Context(), cookie.Value)
+		userID, renewed, err := userService.ValidateAndRenewSession(r.Context(), cookie.Value)
 		if err != nil {
 			// Return 401 for API requests, redirect for page requests
 			if strings.HasPrefix(r.URL.Path, "/api/") {
@@ -56,6 +68,11 @@ func SessionMiddleware(next http.Handler) http.Handler {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
+		// Keep the cookie alive as long as the renewed session
+		if renewed {
+			http.SetCookie(w, newSessionCookie(cookie.Value, int(userService.SessionTTL().Seconds())))
+		}
+
 		// Add userID to context
 		ctx := context.WithValue(r.Context(), userIDKey, userID)
 		// Continue to the underlying handler
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// What a user shown in the lobby is doing
+const (
+	PresenceLobby      = "lobby"
+	PresenceInGame     = "in_game"
+	PresenceSpectating = "spectating"
+)
+
+// PlayerStatus is a lobby user's presence. PublicID names the game for in_game and spectating.
+type PlayerStatus struct {
+	Status   string `json:"status"`
+	PublicID string `json:"publicId,omitempty"`
+}
+
+// gamePresence is one game room connection
+type gamePresence struct {
+	userID     string
+	publicID   string
+	spectating bool
+}
+
+// presenceStore records which game rooms users are connected to. Game rooms write it
+// and the lobby reads it. Entries are per connection so tab takeovers can't leave stale ones.
+type presenceStore struct {
+	mu    sync.RWMutex
+	conns map[*websocket.Conn]gamePresence
+}
+
+var presence = &presenceStore{conns: make(map[*websocket.Conn]gamePresence)}
+
+// enter records a game room connection and tells the lobby
+func (p *presenceStore) enter(conn *websocket.Conn, userID, publicID string, spectating bool) {
+	p.mu.Lock()
+	p.conns[conn] = gamePresence{userID: userID, publicID: publicID, spectating: spectating}
+	p.mu.Unlock()
+	Hub.notifyPresenceChanged()
+	friendPresence.refresh(userID)
+}
+
+// leave forgets a game room connection and tells the lobby. Unknown connections are ignored.
+func (p *presenceStore) leave(conn *websocket.Conn) {
+	p.mu.Lock()
+	entry, ok := p.conns[conn]
+	delete(p.conns, conn)
+	p.mu.Unlock()
+	if ok {
+		Hub.notifyPresenceChanged()
+		friendPresence.refresh(entry.userID)
+	}
+}
+
+// inGame reports whether userID has any game room connection open
+func (p *presenceStore) inGame(userID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, entry := range p.conns {
+		if entry.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// statusOf reports what userID is doing. Playing wins over spectating, which wins over idling.
+func (p *presenceStore) statusOf(userID string) PlayerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := PlayerStatus{Status: PresenceLobby}
+	for _, entry := range p.conns {
+		if entry.userID != userID {
+			continue
+		}
+		if !entry.spectating {
+			return PlayerStatus{Status: PresenceInGame, PublicID: entry.publicID}
+		}
+		status = PlayerStatus{Status: PresenceSpectating, PublicID: entry.publicID}
+	}
+	return status
+}
+
+// OnlineUserIDs returns the subset of userIDs with an open lobby connection,
+// preserving the caller's order. Checks every user in a single pass over the hub.
+func (h *ChatHub) OnlineUserIDs(userIDs []string) []string {
+	connected := make(map[string]bool, h.clients.Len())
+	h.clients.Range(func(_ *websocket.Conn, userID string) bool {
+		connected[userID] = true
+		return true
+	})
+
+	online := make([]string, 0, len(userIDs))
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if connected[userID] && !seen[userID] {
+			online = append(online, userID)
+			seen[userID] = true
+		}
+	}
+	return online
+}
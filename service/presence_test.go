@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/json"
+	"golf-card-game/business"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// readPlayerList reads lobby messages until the next player list
+func readPlayerList(t *testing.T, conn *websocket.Conn) PlayerListPayload {
+	t.Helper()
+	var list PlayerListPayload
+	if err := json.Unmarshal(readMessageOfType(t, conn, "player_list"), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestJoiningAGameRoomShowsInGameInTheLobby(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	if _, _, err := dialGame(t, "game", "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	lobby, _ := dialLobby(t, "alice", websocket.DefaultDialer)
+	if got := presence.statusOf("alice"); got.Status != PresenceLobby {
+		t.Fatalf("alice is %+v before joining, want lobby", got)
+	}
+
+	if _, _, err := dialGame(t, "game", "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := PlayerStatus{Status: PresenceInGame, PublicID: "game"}
+	for {
+		if got := readPlayerList(t, lobby).Statuses["alice"]; got == want {
+			return
+		}
+	}
+}
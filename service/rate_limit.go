@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+)
+
+var (
+	// chatBurst is how many chat messages a connection may send back to back
+	chatBurst = 5
+
+	// chatRefill is how long it takes to earn back one message
+	chatRefill = time.Second
+)
+
+// SetChatRateLimit overrides the per-connection chat limit. Values below 1 are ignored.
+func SetChatRateLimit(burst int, messagesPerSecond float64) {
+	if burst >= 1 {
+		chatBurst = burst
+	}
+	if messagesPerSecond > 0 {
+		chatRefill = time.Duration(float64(time.Second) / messagesPerSecond)
+	}
+}
+
+// RateLimitedPayload tells a client its message was dropped and when to try again
+type RateLimitedPayload struct {
+	RetryAfterMs int64 `json:"retryAfterMs"`
+}
+
+// tokenBucket limits one connection's chat messages. It is only used from that
+// connection's read loop, so it needs no locking.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newChatLimiter() *tokenBucket {
+	return &tokenBucket{tokens: float64(chatBurst), last: time.Now()}
+}
+
+// allow takes a token if one is available. Otherwise it reports how long until one is.
+func (b *tokenBucket) allow() (bool, time.Duration) {
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) / float64(chatRefill)
+	if b.tokens > float64(chatBurst) {
+		b.tokens = float64(chatBurst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(chatRefill))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// rateLimitedLobbyMessage is sent to a lobby client whose chat message was dropped
+func rateLimitedLobbyMessage(retryAfter time.Duration) LobbyMessage {
+	return LobbyMessage{
+		Type:    "rate_limited",
+		Payload: RateLimitedPayload{RetryAfterMs: retryAfter.Milliseconds()},
+	}
+}
+
+// rateLimitedGameMessage is sent to a game client whose chat message was dropped
+func rateLimitedGameMessage(retryAfter time.Duration) GameMessage {
+	payload, _ := json.Marshal(RateLimitedPayload{RetryAfterMs: retryAfter.Milliseconds()})
+	return GameMessage{Type: "rate_limited", Payload: payload}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSixthRapidChatMessageIsRateLimited(t *testing.T) {
+	prevBurst, prevRefill := chatBurst, chatRefill
+	t.Cleanup(func() { chatBurst, chatRefill = prevBurst, prevRefill })
+	// No token comes back while the test runs
+	SetChatRateLimit(5, 1.0/3600)
+	useLobbyUsers(t, "alice")
+	repo := useFakeChat(t, 0)
+	conn, _ := dialLobby(t, "alice", websocket.DefaultDialer)
+
+	for i := 0; i < 6; i++ {
+		sendChat(t, conn, ChatMessage{Message: "spam"})
+	}
+
+	// Echoes come through the hub, so they may arrive either side of the rejection
+	echoes := 0
+	var limited *RateLimitedPayload
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for echoes < 5 || limited == nil {
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("after %d echoes and rate_limited %v: %v", echoes, limited, err)
+		}
+		switch msg.Type {
+		case "chat":
+			echoes++
+		case "rate_limited":
+			if limited != nil {
+				t.Fatal("more than one message was rate limited")
+			}
+			limited = &RateLimitedPayload{}
+			if err := json.Unmarshal(msg.Payload, limited); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if limited.RetryAfterMs <= 0 {
+		t.Errorf("retry after %dms, want a wait", limited.RetryAfterMs)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.saved) != 5 {
+		t.Errorf("saved %d messages, want the first 5", len(repo.saved))
+	}
+}
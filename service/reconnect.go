@@ -0,0 +1,72 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"time"
+)
+
+// reconnectTokenTTL is how long a player's reconnect token keeps working after they drop
+const reconnectTokenTTL = 2 * time.Minute
+
+// reconnectGrant lets one player back into a room without a session round trip
+type reconnectGrant struct {
+	token    string
+	username string
+	expires  time.Time // Zero while the player is still connected
+}
+
+// issueReconnectToken gives userID a fresh single-use token for this room, replacing
+// any earlier one. It is sent to them in their "state" messages.
+func (r *GameRoom) issueReconnectToken(userID, username string) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		log.Printf("Failed to generate reconnect token: %v", err)
+		return
+	}
+
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+	r.reconnectTokens[userID] = reconnectGrant{
+		token:    base64.RawURLEncoding.EncodeToString(randomBytes),
+		username: username,
+	}
+}
+
+// reconnectTokenFor returns the token to hand userID, or "" if they have none
+func (r *GameRoom) reconnectTokenFor(userID string) string {
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+	return r.reconnectTokens[userID].token
+}
+
+// expireReconnectToken starts the countdown on userID's token once they have no
+// connection left in the room
+func (r *GameRoom) expireReconnectToken(userID string) {
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+	if grant, ok := r.reconnectTokens[userID]; ok {
+		grant.expires = time.Now().Add(reconnectTokenTTL)
+		r.reconnectTokens[userID] = grant
+	}
+}
+
+// redeemReconnectToken uses up a token and returns the player it belongs to
+func (r *GameRoom) redeemReconnectToken(token string) (userID, username string, ok bool) {
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+
+	for id, grant := range r.reconnectTokens {
+		if subtle.ConstantTimeCompare([]byte(grant.token), []byte(token)) != 1 {
+			continue
+		}
+		delete(r.reconnectTokens, id)
+		if !grant.expires.IsZero() && time.Now().After(grant.expires) {
+			return "", "", false
+		}
+		return id, grant.username, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"golf-card-game/business"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReconnectTokenIsSingleUse(t *testing.T) {
+	room := newTestRoom("game")
+	room.issueReconnectToken("alice", "Alice")
+	token := room.reconnectTokenFor("alice")
+	if token == "" {
+		t.Fatal("no token issued")
+	}
+
+	if _, _, ok := room.redeemReconnectToken("not-" + token); ok {
+		t.Fatal("a wrong token was accepted")
+	}
+	userID, username, ok := room.redeemReconnectToken(token)
+	if !ok || userID != "alice" || username != "Alice" {
+		t.Fatalf("redeem = %q, %q, %v, want alice's", userID, username, ok)
+	}
+	if _, _, ok := room.redeemReconnectToken(token); ok {
+		t.Fatal("the token worked twice")
+	}
+}
+
+func TestReconnectTokenExpiresAfterTheDrop(t *testing.T) {
+	room := newTestRoom("game")
+	room.issueReconnectToken("alice", "Alice")
+	token := room.reconnectTokenFor("alice")
+
+	room.expireReconnectToken("alice")
+	grant := room.reconnectTokens["alice"]
+	grant.expires = time.Now().Add(-time.Second)
+	room.reconnectTokens["alice"] = grant
+
+	if _, _, ok := room.redeemReconnectToken(token); ok {
+		t.Fatal("an expired token was accepted")
+	}
+}
+
+func TestReconnectTokenResumesWithoutASession(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	useDuplicatePolicy(t, DuplicatePolicyTakeover)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	first, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state GameStatePayload
+	if err := json.Unmarshal(readMessageOfType(t, first, "state"), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.ReconnectToken == "" {
+		t.Fatal("initial state carries no reconnect token")
+	}
+
+	// No session user, only the token
+	resumed, _, err := dialGame(t, "game", "", "reconnectToken="+state.ReconnectToken)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	var resumedState GameStatePayload
+	if err := json.Unmarshal(readMessageOfType(t, resumed, "state"), &resumedState); err != nil {
+		t.Fatal(err)
+	}
+	if resumedState.CurrentUserId != "alice" || len(resumedState.YourCards) != 6 {
+		t.Fatalf("resumed as %q with %d cards, want alice's view", resumedState.CurrentUserId, len(resumedState.YourCards))
+	}
+	if resumedState.ReconnectToken == "" || resumedState.ReconnectToken == state.ReconnectToken {
+		t.Fatal("resuming didn't issue a fresh token")
+	}
+	requireClosed(t, first, "connected from another tab")
+
+	// The used token doesn't work again
+	_, resp, err := dialGame(t, "game", "", "reconnectToken="+state.ReconnectToken)
+	if err == nil {
+		t.Fatal("resumed twice with one token")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("response = %v, want 401", resp)
+	}
+}
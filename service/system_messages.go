@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// postSystemMessage saves a server-authored line, like "Alice joined the game", to a
+// game's chat and shows it to anyone in the room. It writes to the room's connections
+// directly, so it is safe to call from the room's own Run loop.
+func postSystemMessage(ctx context.Context, publicID, text string) {
+	if chatRepo == nil {
+		return
+	}
+
+	savedMsg, err := chatRepo.SaveMessage(ctx, "", "game:"+publicID, text)
+	if err != nil {
+		log.Printf("Error saving system message for game %s: %v", publicID, err)
+		return
+	}
+
+	room := GameHubInstance.GetRoom(publicID)
+	if room == nil {
+		return
+	}
+
+	payload, _ := json.Marshal(ChatPayload{
+		ID:      savedMsg.ChatMessageID,
+		Message: savedMsg.MessageText,
+		Time:    savedMsg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		System:  true,
+	})
+	msg := GameMessage{Type: "chat", Payload: payload}
+
+	send := func(conn *websocket.Conn, _ string) bool {
+		sendJSON(conn, msg)
+		return true
+	}
+	room.clients.Range(send)
+	room.spectators.Range(send)
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"golf-card-game/business"
+	"net/http"
+	"testing"
+)
+
+func TestGameStartIsInTheGamesChatHistory(t *testing.T) {
+	useFakeChat(t, 3)
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+
+	announceGameStarted(context.Background(), "game")
+
+	status, messages := getChatHistoryAs(t, "bob", "scope=game:game")
+	if status != http.StatusOK || len(messages) != 4 {
+		t.Fatalf("status %d with %d messages, want the 3 before and the system message", status, len(messages))
+	}
+	last := messages[3]
+	if !last.System || last.SenderUserID != "" || last.MessageText != "The game has started" {
+		t.Fatalf("last message = %+v, want the system message that the game started", last)
+	}
+
+	// It belongs to that game alone
+	if _, global := getChatHistory(t, ""); len(global) != 3 {
+		t.Fatalf("global chat has %d messages, want the system message kept out", len(global))
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"golf-card-game/business"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startTimedRoom runs a room for publicID whose players get timeout to finish a turn
+func startTimedRoom(t *testing.T, publicID string, timeout time.Duration) {
+	t.Helper()
+
+	room := newGameRoom(publicID)
+	room.turnTimeout = timeout
+
+	GameHubInstance.mu.Lock()
+	GameHubInstance.rooms[publicID] = room
+	GameHubInstance.mu.Unlock()
+	go room.Run()
+}
+
+// readTurnTimeout reads the next turn_timeout message from conn
+func readTurnTimeout(t *testing.T, conn *websocket.Conn) TurnTimeoutPayload {
+	t.Helper()
+
+	var timeout TurnTimeoutPayload
+	if err := json.Unmarshal(readMessageOfType(t, conn, "turn_timeout"), &timeout); err != nil {
+		t.Fatal(err)
+	}
+	return timeout
+}
+
+func TestTurnTimeoutForcesTheCurrentPlayersMove(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	state := playInitialFlips(t, "game")
+	current := state.Players[state.CurrentTurnIdx].UserID
+	startTimedRoom(t, "game", 50*time.Millisecond)
+
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout := readTurnTimeout(t, alice); timeout.UserID != current {
+		t.Fatalf("turn_timeout for %s, want %s", timeout.UserID, current)
+	}
+
+	// Later turns may time out too, so look for the first forced move
+	logged, err := moveLog.GetMoves(context.Background(), "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range logged {
+		if move.Action == business.ActionTimeout {
+			if move.UserID != current {
+				t.Fatalf("first timeout logged for %s, want %s", move.UserID, current)
+			}
+			return
+		}
+	}
+	t.Fatal("the forced move wasn't logged")
+}
+
+func TestCommittingAMoveRestartsTheTurnTimer(t *testing.T) {
+	const timeout = time.Second
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	state := playInitialFlips(t, "game")
+	current := state.Players[state.CurrentTurnIdx].UserID
+	startTimedRoom(t, "game", timeout)
+
+	conns := make(map[string]*websocket.Conn)
+	for _, userID := range []string{"alice", "bob"} {
+		conn, _, err := dialGame(t, "game", userID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[userID] = conn
+	}
+
+	// Drawing commits without ending the turn, so the player gets a full timeout again
+	time.Sleep(timeout / 3)
+	drewAt := time.Now()
+	sendAction(t, conns[current], "draw_deck", 0)
+
+	conns[current].SetReadDeadline(time.Now().Add(3 * timeout))
+	for {
+		var msg GameMessage
+		if err := conns[current].ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for turn_timeout: %v", err)
+		}
+		if msg.Type == "turn_timeout" {
+			break
+		}
+	}
+	if waited := time.Since(drewAt); waited < timeout {
+		t.Fatalf("turn timed out %v after the draw, want at least %v", waited, timeout)
+	}
+}
+
+func TestClosingTheRoomStopsTheTurnTimer(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	playInitialFlips(t, "game")
+	_, version, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTimedRoom(t, "game", timeout)
+
+	alice, _, err := dialGame(t, "game", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMessageOfType(t, alice, "state")
+	GameHubInstance.CloseRoom("game")
+
+	time.Sleep(3 * timeout)
+	if _, after, _ := gameService.LoadState(ctx, "game"); after != version {
+		t.Fatalf("state moved from v%d to v%d after the room closed", version, after)
+	}
+}
+
+func TestTurnTimerIsRearmedWhenTheMoveCannotBeForced(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	playInitialFlips(t, "game")
+
+	// A strategy this server doesn't know makes ForceTimeoutMove fail
+	state, version, err := gameService.LoadState(ctx, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Rules.TimeoutStrategy = "unknown"
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.mu.Lock()
+	repo.states["game"].state = stateJSON
+	repo.mu.Unlock()
+
+	room := newTestRoom("game")
+	room.turnTimeout = time.Minute
+	room.turnVersion = version
+	room.clients.Set(&websocket.Conn{}, "alice")
+	room.handleTurnTimeout(ctx)
+	if room.turnTimer == nil {
+		t.Fatal("turn timer left stopped after a move couldn't be forced")
+	}
+	room.armTurnTimer(nil)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Minimum gap between "still typing" broadcasts for one user in one scope
+	typingDebounce = time.Second
+
+	// How long a typing indicator stays up without a follow-up from the client
+	typingTimeout = 5 * time.Second
+)
+
+// TypingPayload says whether a user is typing in a chat
+type TypingPayload struct {
+	Username string `json:"username,omitempty"`
+	Typing   bool   `json:"typing"`
+}
+
+// typingState is one user's indicator in one chat scope
+type typingState struct {
+	lastSent time.Time
+	timer    *time.Timer
+}
+
+// typingTracker collapses floods of typing events into at most one broadcast per
+// typingDebounce, and clears indicators whose client went quiet. Nothing is persisted.
+type typingTracker struct {
+	mu     sync.Mutex
+	active map[string]*typingState // by typingKey
+}
+
+var typing = &typingTracker{active: make(map[string]*typingState)}
+
+// typingKey identifies a user's indicator within a chat scope ("global" or "game:<publicId>")
+func typingKey(scope, userID string) string {
+	return scope + "|" + userID
+}
+
+// update records a typing event and calls announce when others should hear about it
+func (t *typingTracker) update(key string, isTyping bool, announce func(isTyping bool)) {
+	t.mu.Lock()
+	state := t.active[key]
+
+	if !isTyping {
+		if state == nil {
+			t.mu.Unlock()
+			return
+		}
+		state.timer.Stop()
+		delete(t.active, key)
+		t.mu.Unlock()
+		announce(false)
+		return
+	}
+
+	if state != nil {
+		state.timer.Reset(typingTimeout)
+		if time.Since(state.lastSent) < typingDebounce {
+			t.mu.Unlock()
+			return
+		}
+		state.lastSent = time.Now()
+		t.mu.Unlock()
+		announce(true)
+		return
+	}
+
+	state = &typingState{lastSent: time.Now()}
+	state.timer = time.AfterFunc(typingTimeout, func() {
+		t.mu.Lock()
+		if t.active[key] != state {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.active, key)
+		t.mu.Unlock()
+		announce(false)
+	})
+	t.active[key] = state
+	t.mu.Unlock()
+	announce(true)
+}
+
+// clear drops a user's indicator, e.g. once they send their message or disconnect
+func (t *typingTracker) clear(key string, announce func(isTyping bool)) {
+	t.update(key, false, announce)
+}
+
+// sendTyping tells everyone in the lobby except userID whether they are typing
+func (h *ChatHub) sendTyping(userID, username string, isTyping bool) {
+	msg := LobbyMessage{
+		Type:    "typing",
+		Payload: TypingPayload{Username: username, Typing: isTyping},
+	}
+	h.clients.Range(func(client *websocket.Conn, clientUserID string) bool {
+		if clientUserID != userID {
+			sendJSON(client, msg)
+		}
+		return true
+	})
+}
+
+// sendTyping tells everyone in the room except userID whether they are typing
+func (r *GameRoom) sendTyping(userID, username string, isTyping bool) {
+	payload, err := json.Marshal(TypingPayload{Username: username, Typing: isTyping})
+	if err != nil {
+		log.Printf("Failed to marshal typing payload: %v", err)
+		return
+	}
+	msg := GameMessage{Type: "typing", Payload: payload}
+
+	send := func(client *websocket.Conn, clientUserID string) bool {
+		if clientUserID != userID {
+			sendJSON(client, msg)
+		}
+		return true
+	}
+	r.clients.Range(send)
+	r.spectators.Range(send)
+}
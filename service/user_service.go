@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 var userService *business.UserService
 var nonceManager *business.NonceManager
 var emailService *EmailService
+var auditLogger *business.AuditLogger
 
 // SetUserService sets the user service dependency
 func SetUserService(us *business.UserService) {
@@ -30,6 +32,16 @@ func SetEmailService(es *EmailService) {
 	emailService = es
 }
 
+// SetAuditLogger sets the audit logger dependency
+func SetAuditLogger(al *business.AuditLogger) {
+	auditLogger = al
+}
+
+// recordAudit writes a security event with the request's client IP and user agent
+func recordAudit(r *http.Request, eventType, userID string, metadata map[string]string) {
+	auditLogger.Record(r.Context(), eventType, userID, getClientIP(r), r.Header.Get("User-Agent"), metadata)
+}
+
 type registerRequest struct {
 	Username       string `json:"username"`
 	Password       string `json:"password"`
@@ -72,8 +84,8 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req registerRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
 		return
 	}
 
@@ -108,16 +120,25 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(r, business.AuditRegister, user.UserID, nil)
+
 	// Send welcome email (non-blocking, don't fail registration if email fails)
 	if emailService != nil {
 		go func() {
-			if err := emailService.SendWelcomeEmail(user.Email, user.Username); err != nil {
+			if err := emailService.SendWelcomeEmail(user); err != nil {
 				// Log error but don't fail the registration
 				fmt.Printf("Failed to send welcome email to %s: %v\n", user.Email, err)
 			}
 		}()
 	}
 
+	// Ask the user to confirm their address
+	go func() {
+		if err := userService.SendEmailVerification(context.Background(), user.UserID); err != nil {
+			fmt.Printf("Failed to send verification email to %s: %v\n", user.Email, err)
+		}
+	}()
+
 	jsonResponse(w, http.StatusCreated, map[string]interface{}{
 		"message": "User created successfully",
 		"user": map[string]string{
@@ -136,28 +157,30 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req loginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
 		return
 	}
 
-	token, err := userService.LoginUser(r.Context(), req.Username, req.Password)
-	if err != nil {
+	token, userID, err := userService.LoginUser(r.Context(), req.Username, req.Password, r.Header.Get("User-Agent"), getClientIP(r))
+	switch {
+	case errors.Is(err, business.ErrAccountLocked):
+		recordAudit(r, business.AuditLoginFailure, "", map[string]string{"username": req.Username})
+		jsonResponse(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, business.ErrInvalidCredentials):
+		recordAudit(r, business.AuditLoginFailure, "", map[string]string{"username": req.Username})
 		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
 		return
+	case err != nil:
+		fmt.Printf("Error logging in: %v\n", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to log in"})
+		return
 	}
 
-	// Set cookie (HttpOnly for security; Secure in production with HTTPS)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   isProduction(), // true in production (HTTPS), false in local dev (HTTP)
-		// Lax mode allows cross-site "safe" requests like GET, but not POST
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   86400, // 24 hours in seconds
-	})
+	recordAudit(r, business.AuditLoginSuccess, userID, nil)
+
+	http.SetCookie(w, newSessionCookie(token, int(userService.SessionTTL().Seconds())))
 
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "Logged in successfully"})
 }
@@ -171,67 +194,298 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	cookie, err := r.Cookie("session")
 	if err == nil && cookie.Value != "" {
+		if userID, err := userService.ValidateSession(r.Context(), cookie.Value); err == nil {
+			recordAudit(r, business.AuditLogout, userID, nil)
+			userService.ForgetUser(userID)
+		}
 		_ = userService.LogoutUser(r.Context(), cookie.Value)
 	}
 
-	// Clear the session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   isProduction(), // true in production (HTTPS), false in local dev (HTTP)
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   -1, // Delete cookie
-	})
+	http.SetCookie(w, newSessionCookie("", -1))
 
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
 }
 
-// isProduction checks if we're running in production mode
-// In production, cookies should have the Secure flag set
-func isProduction() bool {
-	env := os.Getenv("ENV")
-	return env == "production" || env == "prod"
+// LogoutAllHandler ends every one of the user's sessions, including this one
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if err := userService.LogoutAll(r.Context(), userID); err != nil {
+		fmt.Printf("Error logging out all sessions: %v\n", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+		return
+	}
+
+	recordAudit(r, business.AuditLogout, userID, map[string]string{"scope": "all"})
+	http.SetCookie(w, newSessionCookie("", -1))
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Logged out everywhere"})
 }
 
-// getClientIP extracts the client's IP address from the request
-// It checks X-Forwarded-For and X-Real-IP headers for proxied requests
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (common for proxied requests)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs separated by commas
-		// Take the first one
-		for i := 0; i < len(forwarded); i++ {
-			if forwarded[i] == ',' {
-				return forwarded[:i]
+// SessionsHandler lists the user's signed-in sessions (GET), or signs one out
+// (DELETE with ?id=)
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := userService.GetSessions(ctx, userID)
+		if err != nil {
+			fmt.Printf("Error listing sessions: %v\n", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get sessions"})
+			return
+		}
+
+		if cookie, err := r.Cookie("session"); err == nil {
+			currentID := database.SessionIDForToken(cookie.Value)
+			for _, session := range sessions {
+				session.Current = session.ID == currentID
+			}
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"sessions": sessions,
+		})
+
+	case http.MethodDelete:
+		sessionID := r.URL.Query().Get("id")
+		if sessionID == "" {
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Missing session ID"})
+			return
+		}
+
+		if err := userService.RevokeSession(ctx, userID, sessionID); err != nil {
+			if err == database.ErrSessionNotFound {
+				jsonResponse(w, http.StatusNotFound, map[string]string{"error": "Session not found"})
+				return
 			}
+			fmt.Printf("Error revoking session: %v\n", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+			return
 		}
-		return forwarded
+
+		recordAudit(r, business.AuditLogout, userID, map[string]string{"sessionId": sessionID})
+		jsonResponse(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
 	}
+}
 
-	// Check X-Real-IP header (another common proxy header)
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+// RequestPasswordResetHandler emails a reset link. It reports success whether or not
+// the email belongs to an account, so it can't be used to find out who is registered.
+func RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
 	}
 
-	// Fall back to RemoteAddr, but strip the port
-	ip := r.RemoteAddr
-	// Remove port if present (IPv4 format: "ip:port" or IPv6 format: "[ip]:port")
-	for i := len(ip) - 1; i >= 0; i-- {
-		if ip[i] == ':' {
-			// Check if this is IPv6 by looking for brackets
-			if i > 0 && ip[0] == '[' {
-				// IPv6 format: [ip]:port
-				return ip[1 : i-1]
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	// Done in the background so response time doesn't reveal whether the email matched
+	if req.Email != "" {
+		go func() {
+			if err := userService.RequestPasswordReset(context.Background(), req.Email); err != nil {
+				fmt.Printf("Password reset request failed: %v\n", err)
 			}
-			// IPv4 format: ip:port
-			return ip[:i]
+		}()
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "If an account uses that email, a reset link is on its way",
+	})
+}
+
+// ConfirmPasswordResetHandler sets a new password using the token from a reset email
+func ConfirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	userID, err := userService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		switch {
+		case err == business.ErrInvalidResetToken, business.IsPasswordPolicyError(err):
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			fmt.Printf("Error resetting password: %v\n", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
 		}
+		return
 	}
-	return ip
+
+	recordAudit(r, business.AuditPasswordChange, userID, map[string]string{"method": "reset"})
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+// VerifyEmailHandler redeems the link from a verification email, then sends the
+// browser on to the login page with the outcome
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	userID, err := userService.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		if err != business.ErrInvalidVerifyToken {
+			fmt.Printf("Error verifying email: %v\n", err)
+		}
+		http.Redirect(w, r, getAppURL()+"?verified=false", http.StatusSeeOther)
+		return
+	}
+
+	fmt.Printf("Email verified for user %s\n", userID)
+	http.Redirect(w, r, getAppURL()+"?verified=true", http.StatusSeeOther)
+}
+
+// ResendVerificationHandler emails the logged-in user a new verification link
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if err := userService.SendEmailVerification(r.Context(), userID); err != nil {
+		if err == business.ErrEmailAlreadyVerified {
+			jsonResponse(w, http.StatusConflict, map[string]string{"error": "Email is already verified"})
+			return
+		}
+		fmt.Printf("Error resending verification email: %v\n", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to send verification email"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+// ChangePasswordHandler lets a logged-in user pick a new password
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if err := userService.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		switch {
+		case err == business.ErrWrongPassword:
+			jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		case business.IsPasswordPolicyError(err):
+			jsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			fmt.Printf("Error changing password: %v\n", err)
+			jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	// Changing the password signed out every session, this one included
+	recordAudit(r, business.AuditPasswordChange, userID, map[string]string{"method": "change"})
+	http.SetCookie(w, newSessionCookie("", -1))
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Password changed, please log in again"})
+}
+
+// DeleteAccountHandler permanently deletes the logged-in user's account after
+// checking their password
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	userID, ok := r.Context().Value(userIDKey).(string)
+	if !ok || userID == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		jsonResponse(w, err.status, map[string]string{"error": err.message})
+		return
+	}
+
+	if err := userService.DeleteAccount(r.Context(), userID, req.Password); err != nil {
+		if err == business.ErrWrongPassword {
+			jsonResponse(w, http.StatusUnauthorized, map[string]string{"error": "Password is incorrect"})
+			return
+		}
+		fmt.Printf("Error deleting account: %v\n", err)
+		jsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete account"})
+		return
+	}
+
+	// The user row is gone, so the audit entry can only name the account in its metadata
+	recordAudit(r, business.AuditAccountDeleted, "", map[string]string{"userId": userID})
+	http.SetCookie(w, newSessionCookie("", -1))
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Account deleted"})
+}
+
+// NotifyAccountDeleted tells the players of a deleted account's abandoned games
+func NotifyAccountDeleted(userID string, abandonedGames []string) {
+	for _, publicID := range abandonedGames {
+		NotifyGameAbandoned(publicID)
+	}
+}
+
+// isProduction checks if we're running in production mode
+// In production, cookies get the Secure flag unless COOKIE_SECURE says otherwise
+func isProduction() bool {
+	env := os.Getenv("ENV")
+	return env == "production" || env == "prod"
 }
 
 // verifyTurnstileToken verifies a Cloudflare Turnstile token
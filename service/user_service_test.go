@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golf-card-game/business"
+	"golf-card-game/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useFakeUsers points the user handlers at an in-memory user repository holding alice
+// with the given password, and an in-memory audit log
+func useFakeUsers(t *testing.T, password string) *fakeAuditRepo {
+	t.Helper()
+
+	prevUserService, prevAudit := userService, auditLogger
+	t.Cleanup(func() { userService, auditLogger = prevUserService, prevAudit })
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := newFakeUserRepo("alice")
+	users.users["alice"].Password = string(hash)
+	userService = business.NewUserService(users)
+	if err := userService.SetBcryptCost(bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+
+	audit := &fakeAuditRepo{}
+	auditLogger = business.NewAuditLogger(audit)
+	return audit
+}
+
+// useTrustedProxies trusts list as reverse proxies for the rest of the test
+func useTrustedProxies(t *testing.T, list string) {
+	t.Helper()
+
+	prev := trustedProxies
+	t.Cleanup(func() { trustedProxies = prev })
+	if err := SetTrustedProxies(list); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedLoginIsAudited(t *testing.T) {
+	audit := useFakeUsers(t, "correct horse")
+	useTrustedProxies(t, "192.0.2.1") // httptest's RemoteAddr
+
+	for _, username := range []string{"alice", "nobody"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"`+username+`","password":"wrong"}`))
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		rec := httptest.NewRecorder()
+		LoginHandler(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: status = %d, want 401", username, rec.Code)
+		}
+	}
+
+	events, err := auditLogger.GetEvents(context.Background(), "", business.AuditLoginFailure, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || len(audit.events) != 2 {
+		t.Fatalf("got %d failure events of %d in all, want 2 and 2", len(events), len(audit.events))
+	}
+	for i, username := range []string{"alice", "nobody"} {
+		event := events[i]
+		if event.UserID != nil || event.Metadata["username"] != username ||
+			event.IPAddress != "203.0.113.9" || event.UserAgent != "test-agent" {
+			t.Errorf("event %d = %+v, want a failure for %s from 203.0.113.9 with no user ID", i, event, username)
+		}
+	}
+}
+
+func TestRotatingForwardedForStillLocksOut(t *testing.T) {
+	login := func(password, forwardedFor string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"alice","password":"`+password+`"}`))
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		LoginHandler(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		name    string
+		proxies string
+		via     string // what a trusted proxy appends to the header
+	}{
+		{"no proxy", "", ""},
+		{"behind a proxy", "192.0.2.0/24", ", 198.51.100.7"},
+	} {
+		useFakeUsers(t, "correct horse")
+		useTrustedProxies(t, tc.proxies)
+
+		for i := 0; i < 5; i++ {
+			if code := login("wrong", fmt.Sprintf("203.0.113.%d", i)+tc.via); code != http.StatusUnauthorized {
+				t.Fatalf("%s: attempt %d: status = %d, want 401", tc.name, i+1, code)
+			}
+		}
+		if code := login("correct horse", "203.0.113.99"+tc.via); code != http.StatusTooManyRequests {
+			t.Errorf("%s: status = %d after five failures, want 429", tc.name, code)
+		}
+	}
+}
+
+// sessionUserRepo is a fakeUserRepo whose CreateSession returns err
+type sessionUserRepo struct {
+	*fakeUserRepo
+	err error
+}
+
+func (r sessionUserRepo) CreateSession(ctx context.Context, userID, token string, expiresAt time.Time, userAgent, ipAddress string) error {
+	return r.err
+}
+
+func TestOnlyRejectedLoginsAreAuditedAsFailures(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		sessionErr error
+		wantStatus int
+		wantEvent  string // "" for none
+	}{
+		{"logged in", nil, http.StatusOK, business.AuditLoginSuccess},
+		{"session not stored", errors.New("connection refused"), http.StatusInternalServerError, ""},
+	} {
+		audit := useFakeUsers(t, "correct horse")
+		users := newFakeUserRepo("alice")
+		users.users["alice"].Password = string(hash)
+		userService = business.NewUserService(sessionUserRepo{fakeUserRepo: users, err: tc.sessionErr})
+
+		rec := httptest.NewRecorder()
+		LoginHandler(rec, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"alice","password":"correct horse"}`)))
+		if rec.Code != tc.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.wantStatus)
+		}
+
+		switch {
+		case tc.wantEvent == "" && len(audit.events) != 0:
+			t.Errorf("%s: recorded %s, want nothing", tc.name, audit.events[0].EventType)
+		case tc.wantEvent != "" && (len(audit.events) != 1 || audit.events[0].EventType != tc.wantEvent ||
+			audit.events[0].UserID == nil || *audit.events[0].UserID != "alice"):
+			t.Errorf("%s: recorded %+v, want one %s for alice", tc.name, audit.events, tc.wantEvent)
+		}
+	}
+}
+
+// verifyEmail follows an emailed verification link and returns where it redirects to
+func verifyEmail(t *testing.T, token string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	VerifyEmailHandler(rec, httptest.NewRequest(http.MethodGet, "/api/verify-email?token="+token, nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status %d, want a redirect", rec.Code)
+	}
+	return rec.Header().Get("Location")
+}
+
+func TestVerifyEmailLinksWorkOnceBeforeTheyExpire(t *testing.T) {
+	ctx := context.Background()
+	prevUserService := userService
+	t.Cleanup(func() { userService = prevUserService })
+	users := newFakeUserRepo("alice")
+	userService = business.NewUserService(users)
+	var sent []string
+	userService.SetVerificationMailer(func(user *database.User, token string) error {
+		sent = append(sent, token)
+		return nil
+	})
+	send := func() string {
+		t.Helper()
+		if err := userService.SendEmailVerification(ctx, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		return sent[len(sent)-1]
+	}
+
+	// Asking again replaces the link from the first email
+	replaced, latest := send(), send()
+	if got := verifyEmail(t, replaced); !strings.HasSuffix(got, "?verified=false") {
+		t.Errorf("replaced link redirected to %s", got)
+	}
+	if got := verifyEmail(t, latest); !strings.HasSuffix(got, "?verified=true") {
+		t.Fatalf("latest link redirected to %s", got)
+	}
+	if !users.users["alice"].EmailVerified {
+		t.Fatal("alice's email wasn't marked verified")
+	}
+	if got := verifyEmail(t, latest); !strings.HasSuffix(got, "?verified=false") {
+		t.Errorf("second use redirected to %s", got)
+	}
+
+	users.users["alice"].EmailVerified = false
+	expired := send()
+	for _, token := range users.verifyTokens {
+		token.expiresAt = time.Now().Add(-time.Minute)
+	}
+	if got := verifyEmail(t, expired); !strings.HasSuffix(got, "?verified=false") {
+		t.Errorf("expired link redirected to %s", got)
+	}
+	if users.users["alice"].EmailVerified {
+		t.Error("an expired link verified the email")
+	}
+}
+
+// changePassword calls the change-password handler as alice and returns the status
+func changePassword(t *testing.T, current, next string) int {
+	t.Helper()
+
+	body := `{"currentPassword":"` + current + `","newPassword":"` + next + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/account/password", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, "alice"))
+	rec := httptest.NewRecorder()
+	ChangePasswordHandler(rec, req)
+	return rec.Code
+}
+
+func TestChangePasswordNeedsTheCurrentPassword(t *testing.T) {
+	useFakeUsers(t, "old password")
+
+	if status := changePassword(t, "wrong password", "new password"); status != http.StatusUnauthorized {
+		t.Fatalf("wrong current password: status %d, want 401", status)
+	}
+	if status := changePassword(t, "old password", "short"); status != http.StatusBadRequest {
+		t.Fatalf("too short a new password: status %d, want 400", status)
+	}
+	if status := changePassword(t, "old password", "new password"); status != http.StatusOK {
+		t.Fatalf("change: status %d", status)
+	}
+
+	// The old password stopped working and the new one took its place
+	if status := changePassword(t, "old password", "third password"); status != http.StatusUnauthorized {
+		t.Fatalf("old password after the change: status %d, want 401", status)
+	}
+	if status := changePassword(t, "new password", "third password"); status != http.StatusOK {
+		t.Fatalf("new password after the change: status %d", status)
+	}
+}
@@ -2,10 +2,22 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 )
 
+// maxRequestBodyBytes caps the size of JSON request bodies (default 1 MB)
+var maxRequestBodyBytes int64 = 1 << 20
+
+// SetMaxRequestBodyBytes overrides the JSON request body size limit.
+// Non-positive values are ignored.
+func SetMaxRequestBodyBytes(limit int64) {
+	if limit > 0 {
+		maxRequestBodyBytes = limit
+	}
+}
+
 // jsonResponse writes the given payload as JSON with the provided status code.
 // If encoding fails, it logs the error and writes a 500 response.
 func jsonResponse(w http.ResponseWriter, status int, payload interface{}) {
@@ -18,3 +30,30 @@ func jsonResponse(w http.ResponseWriter, status int, payload interface{}) {
 		http.Error(w, `{"error":"Internal error"}`, http.StatusInternalServerError)
 	}
 }
+
+// bodyError describes why a request body couldn't be decoded, along with the
+// HTTP status that should be returned to the client
+type bodyError struct {
+	status  int
+	message string
+}
+
+func (e *bodyError) Error() string {
+	return e.message
+}
+
+// decodeJSONBody decodes the request body into dst, rejecting bodies larger
+// than maxRequestBodyBytes with a 413 and malformed JSON with a 400.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) *bodyError {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return &bodyError{status: http.StatusRequestEntityTooLarge, message: "Request body too large"}
+		}
+		return &bodyError{status: http.StatusBadRequest, message: "Invalid request body"}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	prev := maxRequestBodyBytes
+	t.Cleanup(func() { maxRequestBodyBytes = prev })
+	SetMaxRequestBodyBytes(64)
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int // 0 for success
+	}{
+		{"valid", `{"username": "alice"}`, 0},
+		{"at the limit", `{"username": "` + strings.Repeat("a", 64-16) + `"}`, 0},
+		{"oversized", `{"username": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge},
+		{"malformed", `{"username": `, http.StatusBadRequest},
+		{"wrong type", `{"username": 3}`, http.StatusBadRequest},
+		{"empty", ``, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(tc.body))
+		var dst struct {
+			Username string `json:"username"`
+		}
+		err := decodeJSONBody(httptest.NewRecorder(), req, &dst)
+
+		switch {
+		case tc.want == 0 && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.want != 0 && (err == nil || err.status != tc.want):
+			t.Errorf("%s: err = %v, want status %d", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook event types
+const (
+	WebhookGameCreated  = "game_created"
+	WebhookGameStarted  = "game_started"
+	WebhookGameFinished = "game_finished"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body
+const WebhookSignatureHeader = "X-Golf-Signature"
+
+const (
+	webhookQueueSize   = 256
+	webhookMaxAttempts = 4
+	webhookBaseBackoff = time.Second
+	webhookTimeout     = 10 * time.Second
+)
+
+// ErrWebhookSecretMissing is returned when webhook URLs are configured without a secret
+// to sign the deliveries with
+var ErrWebhookSecretMissing = errors.New("WEBHOOK_URLS is set but WEBHOOK_SECRET is empty")
+
+// WebhookEvent is the JSON body POSTed to each webhook URL
+type WebhookEvent struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookService delivers game lifecycle events to external URLs in the background
+type WebhookService struct {
+	urls    []string
+	secret  []byte
+	client  *http.Client
+	queues  map[string]chan []byte // Marshaled events per URL, so a slow receiver only delays itself
+	backoff time.Duration          // Wait before the first retry, doubled for each one after
+}
+
+var webhookService *WebhookService
+
+// SetWebhookService sets the webhook service dependency
+func SetWebhookService(ws *WebhookService) {
+	webhookService = ws
+}
+
+// NewWebhookService creates a webhook service from WEBHOOK_URLS (comma separated)
+// and WEBHOOK_SECRET. With no URLs configured, events are dropped. URLs without a
+// secret are refused with ErrWebhookSecretMissing, since receivers couldn't tell
+// our deliveries from anyone else's.
+func NewWebhookService() (*WebhookService, error) {
+	var urls []string
+	for _, u := range strings.Split(os.Getenv("WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if len(urls) > 0 && secret == "" {
+		return nil, ErrWebhookSecretMissing
+	}
+
+	queues := make(map[string]chan []byte, len(urls))
+	for _, url := range urls {
+		queues[url] = make(chan []byte, webhookQueueSize)
+	}
+
+	return &WebhookService{
+		urls:    urls,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: webhookTimeout},
+		queues:  queues,
+		backoff: webhookBaseBackoff,
+	}, nil
+}
+
+// Run delivers queued events, one worker per URL, until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context) {
+	var workers sync.WaitGroup
+	for url, queue := range s.queues {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case body := <-queue:
+					s.deliver(ctx, url, body)
+				}
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// Emit queues an event for delivery to every URL without blocking the caller
+func (s *WebhookService) Emit(eventType string, data interface{}) {
+	if s == nil || len(s.urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(WebhookEvent{Type: eventType, Timestamp: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("Failed to marshal webhook event %s: %v", eventType, err)
+		return
+	}
+	for _, url := range s.urls {
+		select {
+		case s.queues[url] <- body:
+		default:
+			log.Printf("Webhook queue for %s full, dropping %s event", url, eventType)
+		}
+	}
+}
+
+// Sign returns the signature sent in WebhookSignatureHeader for a body
+func (s *WebhookService) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookStatusError is a delivery the receiver answered with a non-2xx status
+type webhookStatusError struct {
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.status)
+}
+
+// retryable reports whether the receiver might accept the same request later. Other
+// 4xx responses mean it never will.
+func (e *webhookStatusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests
+}
+
+// deliver POSTs a body to one URL, retrying with exponential backoff
+func (s *WebhookService) deliver(ctx context.Context, url string, body []byte) {
+	backoff := s.backoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		err := s.post(ctx, url, body)
+		if err == nil {
+			return
+		}
+
+		var statusErr *webhookStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			log.Printf("Webhook to %s rejected: %v", url, err)
+			return
+		}
+
+		if attempt == webhookMaxAttempts {
+			log.Printf("Giving up on webhook to %s after %d attempts: %v", url, attempt, err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *WebhookService) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, s.Sign(body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &webhookStatusError{status: resp.StatusCode}
+	}
+	return nil
+}
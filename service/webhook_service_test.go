@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"golf-card-game/business"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookDelivery is one request a stub receiver got
+type webhookDelivery struct {
+	body      []byte
+	signature string
+}
+
+// startWebhookReceiver serves a stub webhook receiver answering with statuses in turn
+// (then 200), and runs a WebhookService pointed at it with secret
+func startWebhookReceiver(t *testing.T, secret string, statuses ...int) (*WebhookService, <-chan webhookDelivery, *atomic.Int32) {
+	t.Helper()
+
+	deliveries := make(chan webhookDelivery, 16)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := int(attempts.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		deliveries <- webhookDelivery{body: body, signature: r.Header.Get(WebhookSignatureHeader)}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("WEBHOOK_URLS", srv.URL)
+	t.Setenv("WEBHOOK_SECRET", secret)
+	s, err := NewWebhookService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+	return s, deliveries, &attempts
+}
+
+// awaitDelivery returns the next request the receiver accepted
+func awaitDelivery(t *testing.T, deliveries <-chan webhookDelivery) webhookDelivery {
+	t.Helper()
+
+	select {
+	case delivery := <-deliveries:
+		return delivery
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook arrived")
+		return webhookDelivery{}
+	}
+}
+
+func TestGameFinishedWebhookArrivesSigned(t *testing.T) {
+	s, deliveries, _ := startWebhookReceiver(t, "hush")
+	s.Emit(WebhookGameFinished, map[string]string{"publicId": "game"})
+
+	delivery := awaitDelivery(t, deliveries)
+	mac := hmac.New(sha256.New, []byte("hush"))
+	mac.Write(delivery.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); delivery.signature != want {
+		t.Fatalf("signature = %q, want %q", delivery.signature, want)
+	}
+
+	var event struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(delivery.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != WebhookGameFinished || event.Data["publicId"] != "game" {
+		t.Fatalf("event = %+v", event)
+	}
+}
+
+func TestWebhookIsRetriedAfterAServerError(t *testing.T) {
+	s, deliveries, attempts := startWebhookReceiver(t, "hush", http.StatusInternalServerError, http.StatusBadGateway)
+	s.Emit(WebhookGameStarted, map[string]string{"publicId": "game"})
+
+	awaitDelivery(t, deliveries)
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("delivered on attempt %d, want 3", n)
+	}
+}
+
+func TestWebhookRejectedWithAClientErrorIsNotRetried(t *testing.T) {
+	s, deliveries, attempts := startWebhookReceiver(t, "hush", http.StatusBadRequest)
+	s.Emit(WebhookGameStarted, map[string]string{"publicId": "bad"})
+	s.Emit(WebhookGameStarted, map[string]string{"publicId": "good"})
+
+	awaitDelivery(t, deliveries)
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("second event delivered on request %d, want 2 with the rejected one not retried", n)
+	}
+}
+
+func TestSlowWebhookReceiverDoesNotHoldUpTheOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	deliveries := make(chan webhookDelivery, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- webhookDelivery{body: body}
+	}))
+	t.Cleanup(fast.Close)
+
+	t.Setenv("WEBHOOK_URLS", slow.URL+","+fast.URL)
+	t.Setenv("WEBHOOK_SECRET", "hush")
+	s, err := NewWebhookService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+
+	s.Emit(WebhookGameFinished, map[string]string{"publicId": "game"})
+	awaitDelivery(t, deliveries)
+}
+
+func TestWebhookURLsNeedASecret(t *testing.T) {
+	t.Setenv("WEBHOOK_URLS", "https://example.com/hook")
+	t.Setenv("WEBHOOK_SECRET", "")
+	if _, err := NewWebhookService(); !errors.Is(err, ErrWebhookSecretMissing) {
+		t.Fatalf("err = %v, want ErrWebhookSecretMissing", err)
+	}
+
+	t.Setenv("WEBHOOK_URLS", "")
+	if _, err := NewWebhookService(); err != nil {
+		t.Fatalf("with no URLs, err = %v", err)
+	}
+}
+
+func TestAnnouncingAStartedGameSendsOneWebhook(t *testing.T) {
+	repo, _ := useFakeGames(t)
+	dealTestGame(t, repo, "game", business.GameRules{}, "alice", "bob")
+	s, deliveries, attempts := startWebhookReceiver(t, "hush")
+	prev := webhookService
+	t.Cleanup(func() { webhookService = prev })
+	webhookService = s
+
+	announceGameStarted(context.Background(), "game")
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			PublicID  string   `json:"publicId"`
+			PlayerIDs []string `json:"playerIds"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(awaitDelivery(t, deliveries).body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != WebhookGameStarted || event.Data.PublicID != "game" || len(event.Data.PlayerIDs) != 2 {
+		t.Fatalf("event = %+v, want game_started for alice and bob", event)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("receiver got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a single message to the peer.
+	writeWait = 10 * time.Second
+
+	// Messages queued for a connection before it is considered too slow to keep.
+	sendBufferSize = 256
+)
+
+var (
+	errConnNotWritable = errors.New("connection has no write pump")
+	errSendBufferFull  = errors.New("connection send buffer is full")
+)
+
+// connWriter owns all data writes to one connection. gorilla/websocket allows only
+// one concurrent writer, so hubs, rooms, handlers and the heartbeat queue frames here
+// instead of writing directly. Close frames still go through WriteControl, which is
+// safe to call alongside the pump.
+type connWriter struct {
+	conn *websocket.Conn
+	send chan []byte
+	ping chan struct{}
+	done chan struct{}
+}
+
+// connWriters maps each open connection to its pump
+var connWriters = newConcurrentMap[*websocket.Conn, *connWriter]()
+
+// startWritePump makes a new goroutine the only writer for conn. It must be started
+// before the connection is handed to a hub or room. The returned function stops the
+// pump and must be called when the connection ends.
+func startWritePump(conn *websocket.Conn) (stop func()) {
+	w := &connWriter{
+		conn: conn,
+		send: make(chan []byte, sendBufferSize),
+		ping: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	connWriters.Set(conn, w)
+	go w.run()
+
+	return func() {
+		connWriters.Delete(conn)
+		close(w.done)
+	}
+}
+
+func (w *connWriter) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+
+		case message := <-w.send:
+			w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := w.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				w.conn.Close()
+				return
+			}
+
+		case <-w.ping:
+			w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := w.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				w.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// closeConn tells the peer why the server is hanging up, then closes the connection.
+// The close frame bypasses the pump, so it goes out even if the pump is stuck.
+func closeConn(conn *websocket.Conn, code int, reason string) {
+	closeMsg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// sendJSON queues v for conn. A client too slow to drain its buffer is disconnected
+// rather than allowed to hold up everyone else.
+func sendJSON(conn *websocket.Conn, v interface{}) error {
+	w, ok := connWriters.Get(conn)
+	if !ok {
+		return errConnNotWritable
+	}
+
+	message, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case w.send <- message:
+		return nil
+	default:
+		log.Printf("Dropping slow WebSocket client after %d queued messages", sendBufferSize)
+		closeConn(conn, websocket.CloseTryAgainLater, "too slow")
+		return errSendBufferFull
+	}
+}
+
+// sendPing queues a ping for conn. A ping already waiting to go out is enough.
+func sendPing(conn *websocket.Conn) error {
+	w, ok := connWriters.Get(conn)
+	if !ok {
+		return errConnNotWritable
+	}
+
+	select {
+	case w.ping <- struct{}{}:
+	default:
+	}
+	return nil
+}
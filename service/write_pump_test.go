@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type pumpTestMessage struct {
+	Sender int `json:"sender"`
+	Seq    int `json:"seq"`
+}
+
+// Run with -race: every connection gets messages and pings from several goroutines at
+// once, the way hubs, rooms and the heartbeat share it. All messages must arrive
+// whole, and each sender's in the order it sent them.
+func TestWritePumpSerializesConcurrentWrites(t *testing.T) {
+	const clients, senders, perSender = 10, 4, 50
+
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		server, client := newTestConn(t)
+
+		for s := 0; s < senders; s++ {
+			wg.Add(1)
+			go func(sender int) {
+				defer wg.Done()
+				for seq := 0; seq < perSender; seq++ {
+					if err := sendJSON(server, pumpTestMessage{Sender: sender, Seq: seq}); err != nil {
+						t.Errorf("sendJSON: %v", err)
+						return
+					}
+					if seq%10 == 0 {
+						sendPing(server)
+					}
+				}
+			}(s)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			next := make([]int, senders)
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for received := 0; received < senders*perSender; received++ {
+				_, data, err := client.ReadMessage()
+				if err != nil {
+					t.Errorf("after %d messages: %v", received, err)
+					return
+				}
+				var msg pumpTestMessage
+				if err := json.Unmarshal(data, &msg); err != nil {
+					t.Errorf("garbled message %q: %v", data, err)
+					return
+				}
+				if msg.Seq != next[msg.Sender] {
+					t.Errorf("sender %d: got message %d, want %d", msg.Sender, msg.Seq, next[msg.Sender])
+					return
+				}
+				next[msg.Sender]++
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Nothing may write to a connection whose pump hasn't started or has stopped
+func TestSendJSONNeedsAWritePump(t *testing.T) {
+	if err := sendJSON(&websocket.Conn{}, pumpTestMessage{}); err != errConnNotWritable {
+		t.Fatalf("err = %v, want errConnNotWritable", err)
+	}
+	if err := sendPing(&websocket.Conn{}); err != errConnNotWritable {
+		t.Fatalf("err = %v, want errConnNotWritable", err)
+	}
+}